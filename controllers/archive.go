@@ -0,0 +1,80 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/patrick-salvatore/tournament-live-scoring/models"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+func (tc *TournamentController) HandleGetTournamentArchive(e *core.RequestEvent) error {
+	tournamentId := e.Request.PathValue("tournamentId")
+
+	archive, err := models.GetTournamentArchive(tc.db, tournamentId)
+	if err != nil {
+		return e.Error(http.StatusInternalServerError, err.Error(), nil)
+	}
+
+	tournamentName, _ := archive.Records["tournaments"][0]["name"].(string)
+	e.Response.Header().Set("Content-Disposition", "attachment; filename="+exportFileName(tournamentName, "archive.json"))
+
+	return e.JSON(http.StatusOK, archive)
+}
+
+// HandleImportTournamentArchive rebuilds a tournament from a bundle produced
+// by HandleGetTournamentArchive. Pass ?preserveIds=true to keep the original
+// ids, e.g. when moving an event from staging to prod.
+func (tc *TournamentController) HandleImportTournamentArchive(e *core.RequestEvent) error {
+	preserveIds := e.Request.URL.Query().Get("preserveIds") == "true"
+
+	var archive models.TournamentArchive
+	err := json.NewDecoder(e.Request.Body).Decode(&archive)
+	if err != nil {
+		return e.BadRequestError(err.Error(), nil)
+	}
+
+	columns, err := getArchiveColumns(tc.app)
+	if err != nil {
+		return e.Error(http.StatusInternalServerError, err.Error(), nil)
+	}
+
+	var tournamentId string
+	err = tc.app.RunInTransaction(func(txDb core.App) error {
+		var err error
+		tournamentId, err = models.RestoreTournamentArchive(txDb.DB(), archive, preserveIds, columns)
+		if err != nil {
+			return err
+		}
+
+		_, err = models.CreateAuditLog(txDb.DB(), tournamentId, "tournament.import", e.RealIP(), map[string]any{
+			"sourceTournamentId": archive.TournamentId,
+			"exportedAt":         archive.ExportedAt,
+		})
+		return err
+	})
+
+	if err != nil {
+		return e.BadRequestError(err.Error(), nil)
+	}
+
+	leaderboards.Invalidate(tournamentId)
+
+	return e.JSON(http.StatusCreated, map[string]string{"tournamentId": tournamentId})
+}
+
+// getArchiveColumns reads the fields of every archived table's collection,
+// which are the only columns a restore may write.
+func getArchiveColumns(app core.App) (map[string][]string, error) {
+	columns := make(map[string][]string)
+	for _, name := range models.ArchiveTableNames() {
+		collection, err := app.FindCollectionByNameOrId(name)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		columns[name] = collection.Fields.FieldNames()
+	}
+
+	return columns, nil
+}
@@ -0,0 +1,51 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/patrick-salvatore/tournament-live-scoring/models"
+)
+
+func TestImportTournamentArchiveRejectsUnknownColumns(t *testing.T) {
+	app := newTestApp(t)
+	tc := NewTournamentController(app)
+
+	tournament, _ := newTestTournament(t, app, models.CreateTournamentData{Name: "Spring", TeamCount: 1, AwardedHandicap: 1}, [][]models.Player{
+		{{Name: "Al", Handicap: 10}},
+	})
+
+	archive, err := models.GetTournamentArchive(app.DB(), tournament.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	restore := func(archive *models.TournamentArchive) int {
+		t.Helper()
+
+		body, err := json.Marshal(archive)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return serveTest(app, tc.HandleImportTournamentArchive, testRequest{method: "POST", body: string(body)}).Code
+	}
+
+	if code := restore(archive); code != http.StatusCreated {
+		t.Fatalf("restoring the bundle: expected 201, got %d", code)
+	}
+
+	archive.Records["holes"][0]["score) VALUES ('1'); --"] = "4"
+	if code := restore(archive); code != http.StatusBadRequest {
+		t.Fatalf("restoring a bundle with an unknown column: expected 400, got %d", code)
+	}
+
+	var count int
+	err = app.DB().NewQuery("SELECT COUNT(*) FROM tournaments").Row(&count)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 2 {
+		t.Fatalf("expected only the first restore to land, got %d tournaments", count)
+	}
+}
@@ -0,0 +1,739 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/bits"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/patrick-salvatore/tournament-live-scoring/models"
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+type BracketMatchResult struct {
+	models.BracketMatch
+	MatchPlayStatus
+	// NextMatchId is the match the winner goes through to, empty for the
+	// final.
+	NextMatchId string `json:"nextMatchId,omitempty"`
+}
+
+type BracketRound struct {
+	Round    int                  `json:"round"`
+	Name     string               `json:"name"`
+	Deadline string               `json:"deadline"`
+	Matches  []BracketMatchResult `json:"matches"`
+}
+
+// BracketTree is a bracket laid out for display, round by round, each match
+// pointing at the one its winner plays next.
+type BracketTree struct {
+	models.Bracket
+	Rounds       []BracketRound `json:"rounds"`
+	ChampionId   string         `json:"championId,omitempty"`
+	ChampionName string         `json:"championName,omitempty"`
+}
+
+func (tc *TournamentController) HandleGetBrackets(e *core.RequestEvent) error {
+	tournamentId := e.Request.PathValue("tournamentId")
+
+	_, err := models.GetTournamentById(tc.db, tournamentId)
+	if err != nil {
+		return e.NotFoundError(err.Error(), tournamentId)
+	}
+
+	trees, err := getBracketTrees(tc.db, tournamentId)
+	if err != nil {
+		return e.Error(http.StatusInternalServerError, err.Error(), nil)
+	}
+
+	return e.JSON(http.StatusOK, trees)
+}
+
+// HandleCreateBracket draws a knockout bracket from the tournament's
+// players, or the ones given. Players are seeded by handicap index or by
+// a stroke play qualifier, top seeds get the byes, and with a start date
+// every round gets a deadline roundDays after the one before.
+func (tc *TournamentController) HandleCreateBracket(e *core.RequestEvent) error {
+	var data models.BracketCreate
+	tournamentId := e.Request.PathValue("tournamentId")
+
+	err := json.NewDecoder(e.Request.Body).Decode(&data)
+	if err != nil {
+		return e.BadRequestError(err.Error(), nil)
+	}
+
+	tournament, err := models.GetTournamentById(tc.db, tournamentId)
+	if err != nil {
+		return e.NotFoundError(err.Error(), tournamentId)
+	}
+	if !tournament.IsMatchPlay {
+		return e.BadRequestError("brackets need a match play tournament", nil)
+	}
+
+	if data.Seeding == "" {
+		data.Seeding = models.BRACKET_SEEDING_HANDICAP
+	}
+	if data.Seeding != models.BRACKET_SEEDING_HANDICAP && data.Seeding != models.BRACKET_SEEDING_QUALIFYING {
+		return e.BadRequestError("seeding must be handicap or qualifying", nil)
+	}
+	if data.Scoring == "" {
+		data.Scoring = models.TEAM_SCORING_NET
+	}
+	if data.Scoring != models.TEAM_SCORING_NET && data.Scoring != models.TEAM_SCORING_GROSS {
+		return e.BadRequestError("scoring must be net or gross", nil)
+	}
+	if data.QualifyingTournamentId == "" {
+		data.QualifyingTournamentId = tournamentId
+	}
+	if data.RoundDays == 0 {
+		data.RoundDays = models.DEFAULT_BRACKET_ROUND_DAYS
+	}
+	if data.RoundDays < 0 {
+		return e.BadRequestError("roundDays must be greater than 0", nil)
+	}
+	if data.StartDate != "" {
+		_, err = time.Parse(time.DateOnly, data.StartDate)
+		if err != nil {
+			return e.BadRequestError("startDate must be a date like 2006-01-02", nil)
+		}
+	}
+	if data.Name == "" {
+		data.Name = "Match Play Championship"
+	}
+
+	entrants, err := tc.getBracketEntrants(tournamentId, data.PlayerIds)
+	if err != nil {
+		return e.BadRequestError(err.Error(), nil)
+	}
+	if len(entrants) < 2 {
+		return e.BadRequestError("a bracket needs at least 2 players", nil)
+	}
+
+	seeded, err := seedBracketPlayers(tc.db, data.Seeding, data.QualifyingTournamentId, entrants)
+	if err != nil {
+		return e.Error(http.StatusInternalServerError, err.Error(), nil)
+	}
+
+	size := 1 << bits.Len(uint(len(seeded)-1))
+	slots := []models.BracketSlot{}
+	for _, seed := range bracketSeedOrder(size) {
+		if seed <= len(seeded) {
+			slots = append(slots, models.BracketSlot{PlayerId: seeded[seed-1].PlayerId, Seed: seed})
+		} else {
+			slots = append(slots, models.BracketSlot{})
+		}
+	}
+
+	var bracket *models.Bracket
+	err = tc.app.RunInTransaction(func(txDb core.App) error {
+		bracket, err = models.CreateBracket(txDb.DB(), tournamentId, data, slots)
+		if err != nil {
+			return err
+		}
+
+		matches, err := models.GetBracketMatches(txDb.DB(), tournamentId)
+		if err != nil {
+			return err
+		}
+		for _, match := range *matches {
+			if match.BracketId != bracket.Id || match.Round != 1 || (match.PlayerAId != "" && match.PlayerBId != "") {
+				continue
+			}
+
+			winnerId := match.PlayerAId
+			if winnerId == "" {
+				winnerId = match.PlayerBId
+			}
+			err = closeBracketMatch(txDb.DB(), *bracket, match, winnerId, models.BRACKET_RESULT_BYE, "Bye")
+			if err != nil {
+				return err
+			}
+		}
+
+		_, err = models.CreateAuditLog(txDb.DB(), tournamentId, "bracket.create", e.RealIP(), data)
+		return err
+	})
+
+	if err != nil {
+		return e.Error(http.StatusInternalServerError, err.Error(), nil)
+	}
+
+	trees, err := getBracketTrees(tc.db, tournamentId)
+	if err != nil {
+		return e.Error(http.StatusInternalServerError, err.Error(), nil)
+	}
+	for _, tree := range trees {
+		if tree.Id == bracket.Id {
+			return e.JSON(http.StatusCreated, tree)
+		}
+	}
+
+	return e.JSON(http.StatusCreated, bracket)
+}
+
+func (tc *TournamentController) HandleDeleteBracket(e *core.RequestEvent) error {
+	tournamentId := e.Request.PathValue("tournamentId")
+	bracketId := e.Request.PathValue("bracketId")
+
+	var deleted bool
+	err := tc.app.RunInTransaction(func(txDb core.App) error {
+		var err error
+		deleted, err = models.DeleteBracket(txDb.DB(), tournamentId, bracketId)
+		if err != nil || !deleted {
+			return err
+		}
+
+		_, err = models.CreateAuditLog(txDb.DB(), tournamentId, "bracket.delete", e.RealIP(), map[string]any{
+			"bracketId": bracketId,
+		})
+		return err
+	})
+
+	if err != nil {
+		return e.Error(http.StatusInternalServerError, err.Error(), nil)
+	}
+	if !deleted {
+		return e.NotFoundError("bracket not found", bracketId)
+	}
+
+	return e.NoContent(http.StatusNoContent)
+}
+
+// HandleUpdateBracketMatch lets the committee link a match to the round it
+// is played in, move its deadline or decide it. A decision sends the winner
+// through in place of anyone advanced before as long as the next match
+// hasn't started, and stands over whatever the round's scores say.
+func (tc *TournamentController) HandleUpdateBracketMatch(e *core.RequestEvent) error {
+	var data models.BracketMatchUpdate
+	tournamentId := e.Request.PathValue("tournamentId")
+	matchId := e.Request.PathValue("matchId")
+
+	err := json.NewDecoder(e.Request.Body).Decode(&data)
+	if err != nil {
+		return e.BadRequestError(err.Error(), nil)
+	}
+
+	play, err := getBracketPlay(tc.db, tournamentId)
+	if err != nil {
+		return e.Error(http.StatusInternalServerError, err.Error(), nil)
+	}
+	match, ok := play.match(matchId)
+	if !ok {
+		return e.NotFoundError("match not found", matchId)
+	}
+
+	if data.Deadline != nil && *data.Deadline != "" {
+		_, err = time.Parse(time.DateOnly, *data.Deadline)
+		if err != nil {
+			return e.BadRequestError("deadline must be a date like 2006-01-02", nil)
+		}
+	}
+	if data.RoundTournamentId != nil && *data.RoundTournamentId != "" {
+		if match.PlayerAId == "" || match.PlayerBId == "" {
+			return e.Error(http.StatusConflict, "the match is still waiting on an opponent", nil)
+		}
+		if match.WinnerId != "" && data.WinnerId == nil {
+			return e.Error(http.StatusConflict, "the match is closed", nil)
+		}
+
+		_, err = models.GetTournamentById(tc.db, *data.RoundTournamentId)
+		if err != nil {
+			return e.BadRequestError("the round's tournament was not found", nil)
+		}
+		for _, player := range [][2]string{{match.PlayerAId, match.PlayerAName}, {match.PlayerBId, match.PlayerBName}} {
+			_, err = models.GetTeamPlayer(tc.db, *data.RoundTournamentId, player[0])
+			if err != nil {
+				return e.BadRequestError(fmt.Sprintf("%s is not playing in that round", player[1]), nil)
+			}
+		}
+	}
+	if data.WinnerId != nil {
+		if *data.WinnerId != "" && *data.WinnerId != match.PlayerAId && *data.WinnerId != match.PlayerBId {
+			return e.BadRequestError("the winner must be one of the match's players", nil)
+		}
+
+		next := play.next(*match)
+		if next != nil && (len(next.Holes) > 0 || next.WinnerId != "") {
+			return e.Error(http.StatusConflict, "the next match has already started", nil)
+		}
+	}
+
+	err = tc.app.RunInTransaction(func(txDb core.App) error {
+		if data.Deadline != nil {
+			err := models.UpdateBracketMatchDeadline(txDb.DB(), matchId, *data.Deadline)
+			if err != nil {
+				return err
+			}
+		}
+
+		if data.RoundTournamentId != nil {
+			err := models.SetBracketMatchRound(txDb.DB(), matchId, *data.RoundTournamentId)
+			if err != nil {
+				return err
+			}
+		}
+
+		if data.WinnerId != nil {
+			result, score := models.BRACKET_RESULT_COMMITTEE, ""
+			if data.Score != nil {
+				score = *data.Score
+			}
+			if *data.WinnerId == "" {
+				result, score = "", ""
+			}
+			err := closeBracketMatch(txDb.DB(), play.brackets[match.BracketId], *match, *data.WinnerId, result, score)
+			if err != nil {
+				return err
+			}
+		} else if data.RoundTournamentId != nil && *data.RoundTournamentId != "" {
+			// the round may already have been played
+			err := settleBracketMatches(txDb.DB(), *data.RoundTournamentId)
+			if err != nil {
+				return err
+			}
+		}
+
+		_, err := models.CreateAuditLog(txDb.DB(), tournamentId, "bracket.match_update", e.RealIP(), map[string]any{
+			"matchId": matchId,
+			"update":  data,
+		})
+		return err
+	})
+
+	if err != nil {
+		return e.Error(http.StatusInternalServerError, err.Error(), nil)
+	}
+
+	return tc.respondBracketMatch(e, tournamentId, matchId)
+}
+
+// ApplyBracketDeadlines decides the open matches whose deadline has passed,
+// sending through whoever is ahead in their round or otherwise the higher
+// seed. It is run by the bracket deadline cron job.
+func (tc *TournamentController) ApplyBracketDeadlines() (int, error) {
+	today := time.Now().Format(time.DateOnly)
+
+	tournamentIds, err := models.GetOverdueBracketTournaments(tc.db, today)
+	if err != nil {
+		return 0, err
+	}
+
+	defaulted := 0
+	for _, tournamentId := range tournamentIds {
+		err = tc.app.RunInTransaction(func(txDb core.App) error {
+			// a default can fill a later match whose deadline has also
+			// passed, so the draw is read again after each one
+			for {
+				play, err := getBracketPlay(txDb.DB(), tournamentId)
+				if err != nil {
+					return err
+				}
+
+				match, ok := play.overdue(today)
+				if !ok {
+					return nil
+				}
+
+				status := play.status(*match)
+				winnerId, score := match.PlayerAId, ""
+				switch {
+				case status.Leader == models.MATCH_SIDE_A:
+					score = status.MatchPlayScore
+				case status.Leader == models.MATCH_SIDE_B:
+					winnerId, score = match.PlayerBId, status.MatchPlayScore
+				case match.SeedB < match.SeedA:
+					winnerId = match.PlayerBId
+				}
+
+				err = closeBracketMatch(txDb.DB(), play.brackets[match.BracketId], *match, winnerId, models.BRACKET_RESULT_DEFAULT, score)
+				if err != nil {
+					return err
+				}
+
+				_, err = models.CreateAuditLog(txDb.DB(), tournamentId, "bracket.default", "system", map[string]any{
+					"matchId":  match.Id,
+					"winnerId": winnerId,
+					"deadline": match.Deadline,
+				})
+				if err != nil {
+					return err
+				}
+				defaulted++
+			}
+		})
+
+		if err != nil {
+			return defaulted, fmt.Errorf("tournament %s: %w", tournamentId, err)
+		}
+	}
+
+	return defaulted, nil
+}
+
+// settleBracketMatches closes the open matches played in a round once its
+// scores decide them, sending each winner through. It runs as scores come
+// in, so a match closes with its last putt.
+func settleBracketMatches(db dbx.Builder, roundTournamentId string) error {
+	tournamentIds, err := models.GetBracketTournamentsByRound(db, roundTournamentId)
+	if err != nil {
+		return err
+	}
+
+	for _, tournamentId := range tournamentIds {
+		play, err := getBracketPlay(db, tournamentId)
+		if err != nil {
+			return err
+		}
+
+		for _, match := range play.matches {
+			if match.RoundTournamentId != roundTournamentId || match.WinnerId != "" {
+				continue
+			}
+
+			status := play.status(match)
+			if status.Status != MATCH_PLAY_WON {
+				continue
+			}
+
+			winnerId := match.PlayerAId
+			if status.Leader == models.MATCH_SIDE_B {
+				winnerId = match.PlayerBId
+			}
+			err = closeBracketMatch(db, play.brackets[match.BracketId], match, winnerId, models.BRACKET_RESULT_PLAYED, status.MatchPlayScore)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// closeBracketMatch records a match's winner and puts them into their slot
+// in the next round. An empty winner reopens the match and empties the slot.
+func closeBracketMatch(db dbx.Builder, bracket models.Bracket, match models.BracketMatch, winnerId string, result string, score string) error {
+	err := models.CloseBracketMatch(db, match.Id, winnerId, result, score)
+	if err != nil {
+		return err
+	}
+
+	if match.Round >= bracketRounds(bracket.Size) {
+		return nil
+	}
+
+	seed := 0
+	switch winnerId {
+	case match.PlayerAId:
+		seed = match.SeedA
+	case match.PlayerBId:
+		seed = match.SeedB
+	}
+
+	side := models.MATCH_SIDE_A
+	if match.Position%2 == 1 {
+		side = models.MATCH_SIDE_B
+	}
+
+	return models.SetBracketMatchPlayer(db, bracket.Id, match.Round+1, match.Position/2, side, winnerId, seed)
+}
+
+// playBracketMatch plays a knockout match over its round. A match can't be
+// halved, so one all square after the last hole stays open until the
+// committee records who won the playoff.
+func playBracketMatch(match models.BracketMatch, numbers []int) MatchPlayStatus {
+	status := playMatch(match.Holes, numbers)
+	if status.Status == MATCH_PLAY_HALVED {
+		status.Status = MATCH_PLAY_IN_PROGRESS
+		status.MatchPlayScore = "AS"
+	}
+
+	return status
+}
+
+func (tc *TournamentController) getBracketEntrants(tournamentId string, playerIds []string) ([]models.TeamPlayer, error) {
+	teamPlayers, err := models.GetTeamPlayersByTournament(tc.db, tournamentId)
+	if err != nil {
+		return nil, err
+	}
+	if len(playerIds) == 0 {
+		return *teamPlayers, nil
+	}
+
+	byId := make(map[string]models.TeamPlayer)
+	for _, teamPlayer := range *teamPlayers {
+		byId[teamPlayer.PlayerId] = teamPlayer
+	}
+
+	entrants := []models.TeamPlayer{}
+	seen := make(map[string]bool)
+	for _, playerId := range playerIds {
+		teamPlayer, ok := byId[playerId]
+		if !ok {
+			return nil, fmt.Errorf("player %s is not in this tournament", playerId)
+		}
+		if seen[playerId] {
+			return nil, fmt.Errorf("player %s is entered twice", playerId)
+		}
+		seen[playerId] = true
+		entrants = append(entrants, teamPlayer)
+	}
+
+	return entrants, nil
+}
+
+// seedBracketPlayers orders entrants from the top seed down. Qualifying
+// seeds follow the net stroke play leaderboard, with anyone who didn't post
+// a score seeded after by handicap index.
+func seedBracketPlayers(db dbx.Builder, seeding string, qualifyingTournamentId string, entrants []models.TeamPlayer) ([]models.TeamPlayer, error) {
+	seeded := make([]models.TeamPlayer, len(entrants))
+	copy(seeded, entrants)
+	sort.SliceStable(seeded, func(i, j int) bool {
+		if seeded[i].Handicap != seeded[j].Handicap {
+			return seeded[i].Handicap < seeded[j].Handicap
+		}
+		return seeded[i].PlayerName < seeded[j].PlayerName
+	})
+
+	if seeding != models.BRACKET_SEEDING_QUALIFYING {
+		return seeded, nil
+	}
+
+	snapshot, err := leaderboards.Get(db, qualifyingTournamentId, true)
+	if err != nil {
+		return nil, err
+	}
+
+	qualified := make(map[string]int)
+	for _, row := range snapshot.Rows {
+		if row.Thru > 0 {
+			qualified[row.Id] = len(qualified)
+		}
+	}
+
+	sort.SliceStable(seeded, func(i, j int) bool {
+		a, aOk := qualified[seeded[i].PlayerId]
+		b, bOk := qualified[seeded[j].PlayerId]
+		if aOk != bOk {
+			return aOk
+		}
+		return aOk && a < b
+	})
+
+	return seeded, nil
+}
+
+// bracketSeedOrder lays seeds out over the first round so the top seeds
+// can only meet in the last rounds: 1 v 8, 4 v 5, 2 v 7, 3 v 6 for eight.
+func bracketSeedOrder(size int) []int {
+	order := []int{1}
+	for len(order) < size {
+		next := []int{}
+		for _, seed := range order {
+			next = append(next, seed, len(order)*2+1-seed)
+		}
+		order = next
+	}
+
+	return order
+}
+
+func bracketRounds(size int) int {
+	return bits.Len(uint(size)) - 1
+}
+
+// bracketRoundName names a round by how many players are left in it.
+func bracketRoundName(round int, rounds int) string {
+	switch rounds - round {
+	case 0:
+		return "Final"
+	case 1:
+		return "Semifinals"
+	case 2:
+		return "Quarterfinals"
+	}
+
+	return fmt.Sprintf("Round of %d", 1<<(rounds-round+1))
+}
+
+// bracketPlay is a tournament's brackets and their matches, each match's
+// holes decided from the scores its players posted in the round it is
+// linked to.
+type bracketPlay struct {
+	brackets map[string]models.Bracket
+	matches  []models.BracketMatch
+	// numbers holds the holes of the course each match's round is on.
+	numbers map[string][]int
+}
+
+func getBracketPlay(db dbx.Builder, tournamentId string) (*bracketPlay, error) {
+	brackets, err := models.GetBrackets(db, tournamentId)
+	if err != nil {
+		return nil, err
+	}
+	matches, err := models.GetBracketMatches(db, tournamentId)
+	if err != nil {
+		return nil, err
+	}
+
+	play := bracketPlay{
+		brackets: make(map[string]models.Bracket),
+		matches:  *matches,
+		numbers:  make(map[string][]int),
+	}
+	for _, bracket := range *brackets {
+		play.brackets[bracket.Id] = bracket
+	}
+
+	rounds := make(map[string]*matchPlayRound)
+	for i := range play.matches {
+		match := &play.matches[i]
+		if match.RoundTournamentId == "" || match.PlayerAId == "" || match.PlayerBId == "" {
+			continue
+		}
+
+		round, ok := rounds[match.RoundTournamentId]
+		if !ok {
+			round, err = getMatchPlayRound(db, match.RoundTournamentId)
+			if err != nil {
+				return nil, err
+			}
+			rounds[match.RoundTournamentId] = round
+		}
+
+		scoring := play.brackets[match.BracketId].Scoring
+		match.Holes = round.decideHoles([]string{match.PlayerAId}, []string{match.PlayerBId}, scoring, false)
+		play.numbers[match.Id] = round.numbers
+	}
+
+	return &play, nil
+}
+
+func (play *bracketPlay) status(match models.BracketMatch) MatchPlayStatus {
+	return playBracketMatch(match, play.numbers[match.Id])
+}
+
+func (play *bracketPlay) match(matchId string) (*models.BracketMatch, bool) {
+	for i := range play.matches {
+		if play.matches[i].Id == matchId {
+			return &play.matches[i], true
+		}
+	}
+
+	return nil, false
+}
+
+// next is the match the winner of the given one goes through to, nil for
+// the final.
+func (play *bracketPlay) next(match models.BracketMatch) *models.BracketMatch {
+	for i := range play.matches {
+		next := &play.matches[i]
+		if next.BracketId == match.BracketId && next.Round == match.Round+1 && next.Position == match.Position/2 {
+			return next
+		}
+	}
+
+	return nil
+}
+
+// overdue finds the first open match between two players whose deadline is
+// before today.
+func (play *bracketPlay) overdue(today string) (*models.BracketMatch, bool) {
+	for i := range play.matches {
+		match := &play.matches[i]
+		if match.WinnerId != "" || match.PlayerAId == "" || match.PlayerBId == "" {
+			continue
+		}
+		if match.Deadline != "" && match.Deadline < today {
+			return match, true
+		}
+	}
+
+	return nil, false
+}
+
+func (tc *TournamentController) respondBracketMatch(e *core.RequestEvent, tournamentId string, matchId string) error {
+	trees, err := getBracketTrees(tc.db, tournamentId)
+	if err != nil {
+		return e.Error(http.StatusInternalServerError, err.Error(), nil)
+	}
+	for _, tree := range trees {
+		for _, round := range tree.Rounds {
+			for _, match := range round.Matches {
+				if match.Id == matchId {
+					return e.JSON(http.StatusOK, match)
+				}
+			}
+		}
+	}
+
+	return e.NotFoundError("match not found", matchId)
+}
+
+func getBracketTrees(db dbx.Builder, tournamentId string) ([]BracketTree, error) {
+	brackets, err := models.GetBrackets(db, tournamentId)
+	if err != nil {
+		return nil, err
+	}
+	play, err := getBracketPlay(db, tournamentId)
+	if err != nil {
+		return nil, err
+	}
+
+	trees := []BracketTree{}
+	for _, bracket := range *brackets {
+		rounds := bracketRounds(bracket.Size)
+		tree := BracketTree{Bracket: bracket, Rounds: []BracketRound{}}
+		for round := 1; round <= rounds; round++ {
+			tree.Rounds = append(tree.Rounds, BracketRound{
+				Round:   round,
+				Name:    bracketRoundName(round, rounds),
+				Matches: []BracketMatchResult{},
+			})
+		}
+
+		for _, match := range play.matches {
+			if match.BracketId != bracket.Id {
+				continue
+			}
+
+			result := BracketMatchResult{
+				BracketMatch:    match,
+				MatchPlayStatus: play.status(match),
+			}
+			if next := play.next(match); next != nil {
+				result.NextMatchId = next.Id
+			}
+			// the recorded result stands, as byes, defaults and committee
+			// decisions close matches that weren't played out
+			if match.WinnerId != "" {
+				result.Status = MATCH_PLAY_WON
+				result.Leader = models.MATCH_SIDE_A
+				if match.WinnerId == match.PlayerBId {
+					result.Leader = models.MATCH_SIDE_B
+				}
+				result.MatchPlayScore = match.Score
+			}
+			round := &tree.Rounds[match.Round-1]
+			round.Matches = append(round.Matches, result)
+			if round.Deadline == "" {
+				round.Deadline = match.Deadline
+			}
+
+			if match.Round == rounds && match.WinnerId != "" {
+				tree.ChampionId = match.WinnerId
+				tree.ChampionName = match.PlayerAName
+				if match.WinnerId == match.PlayerBId {
+					tree.ChampionName = match.PlayerBName
+				}
+			}
+		}
+
+		trees = append(trees, tree)
+	}
+
+	return trees, nil
+}
@@ -0,0 +1,199 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"testing"
+
+	"github.com/patrick-salvatore/tournament-live-scoring/models"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+func newTestBracket(t *testing.T, app core.App, tc *TournamentController, players []models.Player, body string) (*models.Tournament, []models.BracketMatch) {
+	t.Helper()
+
+	teams := [][]models.Player{}
+	for _, player := range players {
+		teams = append(teams, []models.Player{player})
+	}
+	tournament, _ := newTestTournament(t, app, models.CreateTournamentData{Name: "Championship", TeamCount: len(players), AwardedHandicap: 1, IsMatchPlay: true}, teams)
+
+	rec := serveTest(app, tc.HandleCreateBracket, testRequest{
+		method:     "POST",
+		body:       body,
+		pathValues: map[string]string{"tournamentId": tournament.Id},
+	})
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("creating the bracket: expected 201, got %d: %s", rec.Code, rec.Body)
+	}
+
+	return tournament, bracketMatches(t, app, tournament.Id)
+}
+
+func bracketMatches(t *testing.T, app core.App, tournamentId string) []models.BracketMatch {
+	t.Helper()
+
+	matches, err := models.GetBracketMatches(app.DB(), tournamentId)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return *matches
+}
+
+func TestBracketMatchIsDecidedByItsRound(t *testing.T) {
+	app := newTestApp(t)
+	tc := NewTournamentController(app)
+
+	tournament, matches := newTestBracket(t, app, tc, []models.Player{
+		{Name: "Al", Handicap: 2},
+		{Name: "Bo", Handicap: 5},
+		{Name: "Cy", Handicap: 8},
+	}, `{"scoring": "gross"}`)
+
+	// seeds 1 v 4 then 2 v 3, so Al has a bye into the final
+	bye, match, final := matches[0], matches[1], matches[2]
+	if bye.Result != models.BRACKET_RESULT_BYE || final.PlayerAId != bye.PlayerAId {
+		t.Fatalf("expected the top seed through on a bye, got %+v", final)
+	}
+
+	round, _ := newTestTournament(t, app, models.CreateTournamentData{Name: "Week 1", TeamCount: 1, AwardedHandicap: 1}, [][]models.Player{
+		{{Id: match.PlayerAId, Handicap: 5}, {Id: match.PlayerBId, Handicap: 8}},
+	})
+	gross := func(playerId string, score string, holes int) {
+		scores := map[int]string{}
+		for number := 1; number <= holes; number++ {
+			scores[number] = score
+		}
+		setTestScores(t, app, round.Id, playerId, scores)
+	}
+	gross(match.PlayerAId, "3", 3)
+	gross(match.PlayerBId, "6", 3)
+
+	link := func(roundTournamentId string) int {
+		return serveTest(app, tc.HandleUpdateBracketMatch, testRequest{
+			method:     "PUT",
+			body:       `{"roundTournamentId": "` + roundTournamentId + `"}`,
+			pathValues: map[string]string{"tournamentId": tournament.Id, "matchId": match.Id},
+		}).Code
+	}
+	if code := link(tournament.Id + "x"); code != http.StatusBadRequest {
+		t.Fatalf("linking a round that doesn't exist: expected 400, got %d", code)
+	}
+	if code := link(round.Id); code != http.StatusOK {
+		t.Fatalf("linking the round: expected 200, got %d", code)
+	}
+
+	trees, err := getBracketTrees(app.DB(), tournament.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	status := trees[0].Rounds[0].Matches[1]
+	if status.Status != MATCH_PLAY_IN_PROGRESS || status.MatchPlayScore != "3 UP" || status.WinnerId != "" {
+		t.Fatalf("expected Bo 3 UP through 3, got %+v", status.MatchPlayStatus)
+	}
+
+	gross(match.PlayerAId, "3", 10)
+	gross(match.PlayerBId, "6", 10)
+	err = settleBracketMatches(app.DB(), round.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	matches = bracketMatches(t, app, tournament.Id)
+	match, final = matches[1], matches[2]
+	if match.WinnerId != match.PlayerAId || match.Result != models.BRACKET_RESULT_PLAYED || match.Score != "10&8" {
+		t.Fatalf("expected Bo to win 10&8, got %+v", match)
+	}
+	if final.PlayerBId != match.PlayerAId {
+		t.Fatalf("expected Bo through to the final, got %+v", final)
+	}
+}
+
+func TestBracketDeadlinesCascadeInOneRun(t *testing.T) {
+	app := newTestApp(t)
+	tc := NewTournamentController(app)
+
+	tournament, _ := newTestBracket(t, app, tc, []models.Player{
+		{Name: "Al", Handicap: 2},
+		{Name: "Bo", Handicap: 5},
+		{Name: "Cy", Handicap: 8},
+		{Name: "Di", Handicap: 11},
+	}, `{"startDate": "2020-01-01"}`)
+
+	defaulted, err := tc.ApplyBracketDeadlines()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if defaulted != 3 {
+		t.Fatalf("expected both semifinals and the final defaulted, got %d", defaulted)
+	}
+
+	trees, err := getBracketTrees(app.DB(), tournament.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if trees[0].ChampionName != "Al" {
+		body, _ := json.Marshal(trees[0])
+		t.Fatalf("expected the top seed to win on defaults, got %s", body)
+	}
+}
+
+func TestNetBracketMatchIsPlayedOffTheLowHandicap(t *testing.T) {
+	app := newTestApp(t)
+	tc := NewTournamentController(app)
+
+	// Al plays off 3 and Bo off 9 from the whites, so Bo gets six strokes
+	// on stroke index 1-6 rather than Al's three and Bo's nine each
+	_, matches := newTestBracket(t, app, tc, []models.Player{
+		{Name: "Al", Handicap: 4},
+		{Name: "Bo", Handicap: 10},
+	}, `{"scoring": "net"}`)
+	match := matches[0]
+	if match.PlayerAName != "Al" || match.PlayerBName != "Bo" {
+		t.Fatalf("expected Al to be drawn against Bo, got %+v", match)
+	}
+
+	round, _ := newTestTournament(t, app, models.CreateTournamentData{Name: "Week 1", TeamCount: 1, AwardedHandicap: 1}, [][]models.Player{
+		{{Id: match.PlayerAId}, {Id: match.PlayerBId}},
+	})
+	// Al makes 4 everywhere. Bo's extra strokes by hole, with the stroke
+	// index of each: off full handicaps Al wins 2, 7 and 9 and Bo 18 for
+	// Al 2 UP; off the difference Bo wins 1, 15 and 18 and Al 2 and 9 for
+	// Bo 1 UP.
+	extra := map[int]int{
+		2:  2,  // 9
+		6:  1,  // 5
+		7:  1,  // 3
+		9:  2,  // 7
+		10: 1,  // 4
+		11: 1,  // 6
+		18: -1, // 8
+	}
+	al, bo := map[int]string{}, map[int]string{}
+	for number := 1; number <= 18; number++ {
+		al[number] = "4"
+		bo[number] = strconv.Itoa(4 + extra[number])
+	}
+	setTestScores(t, app, round.Id, match.PlayerAId, al)
+	setTestScores(t, app, round.Id, match.PlayerBId, bo)
+
+	rec := serveTest(app, tc.HandleUpdateBracketMatch, testRequest{
+		method:     "PUT",
+		body:       `{"roundTournamentId": "` + round.Id + `"}`,
+		pathValues: map[string]string{"tournamentId": match.TournamentId, "matchId": match.Id},
+	})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("linking the round: expected 200, got %d: %s", rec.Code, rec.Body)
+	}
+	err := settleBracketMatches(app.DB(), round.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	match = bracketMatches(t, app, match.TournamentId)[0]
+	if match.WinnerId != match.PlayerBId || match.Score != "1 UP" {
+		t.Fatalf("expected Bo to win 1 UP, got %+v", match)
+	}
+}
@@ -0,0 +1,388 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/patrick-salvatore/tournament-live-scoring/models"
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+var errContestLeaderStands = errors.New("the contest's leader stands")
+
+type ContestResult struct {
+	models.Contest
+	Leader  *models.ContestEntry  `json:"leader"`
+	Entries []models.ContestEntry `json:"entries,omitempty"`
+}
+
+type ContestEntryRequest struct {
+	PlayerId string  `json:"playerId"`
+	Distance float64 `json:"distance"`
+	Note     string  `json:"note,omitempty"`
+}
+
+type ContestVoidRequest struct {
+	Voided bool `json:"voided"`
+}
+
+// HandleGetContests lists the tournament's contests with their leaders and
+// every entry made, voided ones included.
+func (tc *TournamentController) HandleGetContests(e *core.RequestEvent) error {
+	tournamentId := e.Request.PathValue("tournamentId")
+
+	results, err := getContestResults(tc.db, tournamentId, true)
+	if err != nil {
+		return e.Error(http.StatusInternalServerError, err.Error(), nil)
+	}
+
+	return e.JSON(http.StatusOK, results)
+}
+
+// HandleCreateContest attaches a closest to the pin or long drive contest to
+// one of the course's holes.
+func (tc *TournamentController) HandleCreateContest(e *core.RequestEvent) error {
+	var data models.ContestCreate
+	tournamentId := e.Request.PathValue("tournamentId")
+
+	err := json.NewDecoder(e.Request.Body).Decode(&data)
+	if err != nil {
+		return e.BadRequestError(err.Error(), nil)
+	}
+
+	course, err := models.GetCourseByTournamentId(tc.db, tournamentId)
+	if err != nil {
+		return e.NotFoundError(err.Error(), tournamentId)
+	}
+	if _, ok := getHoleDataMap(course)[data.Hole]; !ok {
+		return e.BadRequestError(fmt.Sprintf("the course has no hole %d", data.Hole), nil)
+	}
+
+	switch data.Kind {
+	case models.CONTEST_KIND_CLOSEST_TO_PIN:
+		if data.Name == "" {
+			data.Name = fmt.Sprintf("Closest to the pin #%d", data.Hole)
+		}
+		if data.Unit == "" {
+			data.Unit = "ft"
+		}
+	case models.CONTEST_KIND_LONG_DRIVE:
+		if data.Name == "" {
+			data.Name = fmt.Sprintf("Long drive #%d", data.Hole)
+		}
+		if data.Unit == "" {
+			data.Unit = "yd"
+		}
+	default:
+		return e.BadRequestError("kind must be closest_to_pin or long_drive", nil)
+	}
+
+	var contest *models.Contest
+	err = tc.app.RunInTransaction(func(txDb core.App) error {
+		contest, err = models.CreateContest(txDb.DB(), tournamentId, data)
+		if err != nil {
+			return err
+		}
+
+		_, err = models.CreateAuditLog(txDb.DB(), tournamentId, "contest.create", e.RealIP(), contest)
+		return err
+	})
+
+	if err != nil {
+		return e.Error(http.StatusInternalServerError, err.Error(), nil)
+	}
+
+	return e.JSON(http.StatusCreated, contest)
+}
+
+func (tc *TournamentController) HandleDeleteContest(e *core.RequestEvent) error {
+	tournamentId := e.Request.PathValue("tournamentId")
+	contestId := e.Request.PathValue("contestId")
+
+	contest, err := models.GetContest(tc.db, tournamentId, contestId)
+	if err != nil {
+		return e.NotFoundError(err.Error(), contestId)
+	}
+
+	err = tc.app.RunInTransaction(func(txDb core.App) error {
+		err := models.DeleteContest(txDb.DB(), contestId)
+		if err != nil {
+			return err
+		}
+
+		_, err = models.CreateAuditLog(txDb.DB(), tournamentId, "contest.delete", e.RealIP(), contest)
+		return err
+	})
+
+	if err != nil {
+		return e.Error(http.StatusInternalServerError, err.Error(), nil)
+	}
+
+	leaderboards.InvalidateContests(tournamentId)
+
+	return e.NoContent(http.StatusNoContent)
+}
+
+// HandleOverrideContest sets a contest's result by hand. The override wins
+// whatever was measured on the course until it is voided.
+func (tc *TournamentController) HandleOverrideContest(e *core.RequestEvent) error {
+	var data ContestEntryRequest
+	tournamentId := e.Request.PathValue("tournamentId")
+	contestId := e.Request.PathValue("contestId")
+
+	err := json.NewDecoder(e.Request.Body).Decode(&data)
+	if err != nil {
+		return e.BadRequestError(err.Error(), nil)
+	}
+
+	_, err = models.GetContest(tc.db, tournamentId, contestId)
+	if err != nil {
+		return e.NotFoundError(err.Error(), contestId)
+	}
+
+	teamPlayer, err := models.GetTeamPlayer(tc.db, tournamentId, data.PlayerId)
+	if err != nil {
+		return e.BadRequestError("player is not in this tournament", nil)
+	}
+	if data.Distance < 0 {
+		return e.BadRequestError("distance can't be negative", nil)
+	}
+
+	var entry *models.ContestEntry
+	err = tc.app.RunInTransaction(func(txDb core.App) error {
+		entry, err = models.CreateContestEntry(txDb.DB(), models.ContestEntryCreate{
+			ContestId:    contestId,
+			TournamentId: tournamentId,
+			TeamId:       teamPlayer.TeamId,
+			PlayerId:     teamPlayer.PlayerId,
+			Distance:     data.Distance,
+			Override:     true,
+			Note:         data.Note,
+		})
+		if err != nil {
+			return err
+		}
+
+		_, err = models.CreateAuditLog(txDb.DB(), tournamentId, "contest.override", e.RealIP(), entry)
+		return err
+	})
+
+	if err != nil {
+		return e.Error(http.StatusInternalServerError, err.Error(), nil)
+	}
+
+	leaderboards.InvalidateContests(tournamentId)
+	entry.PlayerName = teamPlayer.PlayerName
+
+	return e.JSON(http.StatusCreated, entry)
+}
+
+// HandleVoidContestEntry strikes an entry, or an override, from a contest.
+// The best entry left takes the lead again.
+func (tc *TournamentController) HandleVoidContestEntry(e *core.RequestEvent) error {
+	data := ContestVoidRequest{Voided: true}
+	tournamentId := e.Request.PathValue("tournamentId")
+	contestId := e.Request.PathValue("contestId")
+	entryId := e.Request.PathValue("entryId")
+
+	if e.Request.ContentLength != 0 {
+		err := json.NewDecoder(e.Request.Body).Decode(&data)
+		if err != nil {
+			return e.BadRequestError(err.Error(), nil)
+		}
+	}
+
+	_, err := models.GetContest(tc.db, tournamentId, contestId)
+	if err != nil {
+		return e.NotFoundError(err.Error(), contestId)
+	}
+
+	err = tc.app.RunInTransaction(func(txDb core.App) error {
+		err := models.VoidContestEntry(txDb.DB(), contestId, entryId, data.Voided)
+		if err != nil {
+			return err
+		}
+
+		_, err = models.CreateAuditLog(txDb.DB(), tournamentId, "contest.void", e.RealIP(), map[string]any{
+			"contestId": contestId,
+			"entryId":   entryId,
+			"voided":    data.Voided,
+		})
+		return err
+	})
+
+	if err != nil {
+		return e.NotFoundError(err.Error(), entryId)
+	}
+
+	leaderboards.InvalidateContests(tournamentId)
+
+	results, err := getContestResults(tc.db, tournamentId, true)
+	if err != nil {
+		return e.Error(http.StatusInternalServerError, err.Error(), nil)
+	}
+	for _, result := range results {
+		if result.Id == contestId {
+			return e.JSON(http.StatusOK, result)
+		}
+	}
+
+	return e.NotFoundError("contest not found", contestId)
+}
+
+// HandleGetContestBoard shows a team the current leader of every contest in
+// its tournament.
+func (tc *TournamentController) HandleGetContestBoard(e *core.RequestEvent) error {
+	tournamentId := e.Request.Context().Value(TournamentId).(string)
+
+	results, err := getContestResults(tc.db, tournamentId, false)
+	if err != nil {
+		return e.Error(http.StatusInternalServerError, err.Error(), nil)
+	}
+
+	return e.JSON(http.StatusOK, results)
+}
+
+// HandleSubmitContestEntry records a shot by one of the team's players. As
+// on the paper sheet at the hole, an entry has to beat the current leader
+// to take its place.
+func (tc *TournamentController) HandleSubmitContestEntry(e *core.RequestEvent) error {
+	var data ContestEntryRequest
+	teamId := e.Request.Context().Value(TeamId).(string)
+	tournamentId := e.Request.Context().Value(TournamentId).(string)
+	contestId := e.Request.PathValue("contestId")
+
+	err := json.NewDecoder(e.Request.Body).Decode(&data)
+	if err != nil {
+		return e.BadRequestError(err.Error(), nil)
+	}
+	if data.Distance <= 0 {
+		return e.BadRequestError("distance must be greater than 0", nil)
+	}
+
+	contest, err := models.GetContest(tc.db, tournamentId, contestId)
+	if err != nil {
+		return e.NotFoundError(err.Error(), contestId)
+	}
+
+	teamPlayer, err := models.GetTeamPlayer(tc.db, tournamentId, data.PlayerId)
+	if err != nil || teamPlayer.TeamId != teamId {
+		return e.BadRequestError("player is not on your team", nil)
+	}
+
+	// the leader is checked in the same transaction as the insert, so two
+	// entries racing each other can't both beat the same leader
+	var entry *models.ContestEntry
+	var leader *models.ContestEntry
+	err = tc.app.RunInTransaction(func(txDb core.App) error {
+		results, err := getContestResults(txDb.DB(), tournamentId, false)
+		if err != nil {
+			return err
+		}
+		for _, result := range results {
+			if result.Id != contestId || result.Leader == nil {
+				continue
+			}
+			if result.Leader.Override || !contestEntryBeats(contest.Kind, data.Distance, result.Leader.Distance) {
+				leader = result.Leader
+				return errContestLeaderStands
+			}
+		}
+
+		entry, err = models.CreateContestEntry(txDb.DB(), models.ContestEntryCreate{
+			ContestId:    contestId,
+			TournamentId: tournamentId,
+			TeamId:       teamId,
+			PlayerId:     teamPlayer.PlayerId,
+			Distance:     data.Distance,
+			Note:         data.Note,
+		})
+		if err != nil {
+			return err
+		}
+
+		_, err = models.CreateAuditLog(txDb.DB(), tournamentId, "contest.entry", e.RealIP(), entry)
+		return err
+	})
+
+	if errors.Is(err, errContestLeaderStands) {
+		if leader.Override {
+			return e.Error(http.StatusConflict, "the result of this contest has been set by the committee", nil)
+		}
+		return e.Error(http.StatusConflict, fmt.Sprintf("%s leads with %s %s", leader.PlayerName, formatPoints(leader.Distance), contest.Unit), leader)
+	}
+	if err != nil {
+		return e.Error(http.StatusInternalServerError, err.Error(), nil)
+	}
+
+	leaderboards.InvalidateContests(tournamentId)
+	entry.PlayerName = teamPlayer.PlayerName
+
+	return e.JSON(http.StatusCreated, entry)
+}
+
+// getContestResults works out each contest's leader from its entries. The
+// latest override wins outright, otherwise the best entry that hasn't been
+// voided leads, the earlier entry holding on a tie.
+func getContestResults(db dbx.Builder, tournamentId string, withEntries bool) ([]ContestResult, error) {
+	contests, err := models.GetContests(db, tournamentId)
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := models.GetContestEntries(db, tournamentId)
+	if err != nil {
+		return nil, err
+	}
+
+	entriesByContest := make(map[string][]models.ContestEntry)
+	for _, entry := range *entries {
+		entriesByContest[entry.ContestId] = append(entriesByContest[entry.ContestId], entry)
+	}
+
+	results := []ContestResult{}
+	for _, contest := range *contests {
+		result := ContestResult{Contest: contest}
+
+		var best, override *models.ContestEntry
+		for i := range entriesByContest[contest.Id] {
+			entry := &entriesByContest[contest.Id][i]
+			if entry.Voided {
+				continue
+			}
+			if entry.Override {
+				override = entry
+				continue
+			}
+			if best == nil || contestEntryBeats(contest.Kind, entry.Distance, best.Distance) {
+				best = entry
+			}
+		}
+
+		result.Leader = best
+		if override != nil {
+			result.Leader = override
+		}
+		if withEntries {
+			result.Entries = entriesByContest[contest.Id]
+			if result.Entries == nil {
+				result.Entries = []models.ContestEntry{}
+			}
+		}
+
+		results = append(results, result)
+	}
+
+	return results, nil
+}
+
+func contestEntryBeats(kind string, distance float64, leader float64) bool {
+	if kind == models.CONTEST_KIND_LONG_DRIVE {
+		return distance > leader
+	}
+
+	return distance < leader
+}
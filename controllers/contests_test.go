@@ -0,0 +1,59 @@
+package controllers
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/patrick-salvatore/tournament-live-scoring/models"
+)
+
+func TestSubmitContestEntry(t *testing.T) {
+	app := newTestApp(t)
+	tc := NewTournamentController(app)
+
+	tournament, teams := newTestTournament(t, app, models.CreateTournamentData{Name: "Spring", TeamCount: 1, AwardedHandicap: 1}, [][]models.Player{
+		{{Name: "Al", Handicap: 10}, {Name: "Bo", Handicap: 14}},
+	})
+	contest, err := models.CreateContest(app.DB(), tournament.Id, models.ContestCreate{Hole: 3, Kind: models.CONTEST_KIND_CLOSEST_TO_PIN, Unit: "ft"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	players, err := models.GetPlayersFromTeamId(app.DB(), teams[0].Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	submit := func(playerId string, distance string) int {
+		return serveTest(app, tc.HandleSubmitContestEntry, testRequest{
+			method:       "POST",
+			body:         `{"playerId": "` + playerId + `", "distance": ` + distance + `}`,
+			pathValues:   map[string]string{"contestId": contest.Id},
+			teamId:       teams[0].Id,
+			tournamentId: tournament.Id,
+		}).Code
+	}
+
+	if code := submit((*players)[0].Id, "12"); code != http.StatusCreated {
+		t.Fatalf("first entry: expected 201, got %d", code)
+	}
+	if code := submit((*players)[1].Id, "15"); code != http.StatusConflict {
+		t.Fatalf("an entry that doesn't beat the leader: expected 409, got %d", code)
+	}
+	if code := submit((*players)[1].Id, "4"); code != http.StatusCreated {
+		t.Fatalf("an entry that beats the leader: expected 201, got %d", code)
+	}
+
+	logs, err := models.GetAuditLogByTournament(app.DB(), tournament.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries := 0
+	for _, log := range *logs {
+		if log.Action == "contest.entry" {
+			entries++
+		}
+	}
+	if entries != 2 {
+		t.Fatalf("expected the two accepted entries audited, got %d", entries)
+	}
+}
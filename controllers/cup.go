@@ -0,0 +1,770 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+
+	"github.com/patrick-salvatore/tournament-live-scoring/models"
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+const (
+	MATCH_PLAY_NOT_STARTED = "not_started"
+	MATCH_PLAY_IN_PROGRESS = "in_progress"
+	MATCH_PLAY_WON         = "won"
+	MATCH_PLAY_HALVED      = "halved"
+)
+
+// cupFormatSizes is how many players each side sends out per match.
+var cupFormatSizes = map[string]int{
+	models.CUP_FORMAT_FOURBALLS: 2,
+	models.CUP_FORMAT_FOURSOMES: 2,
+	models.CUP_FORMAT_SINGLES:   1,
+}
+
+// cupFormatScoring is what decides a hole in each format.
+var cupFormatScoring = map[string]string{
+	models.CUP_FORMAT_FOURBALLS: models.TEAM_SCORING_NET,
+	models.CUP_FORMAT_FOURSOMES: models.TEAM_SCORING_NET,
+	models.CUP_FORMAT_SINGLES:   models.TEAM_SCORING_NET,
+}
+
+var cupFormatNames = map[string]string{
+	models.CUP_FORMAT_FOURBALLS: "Fourballs",
+	models.CUP_FORMAT_FOURSOMES: "Foursomes",
+	models.CUP_FORMAT_SINGLES:   "Singles",
+}
+
+// MatchPlayStatus is where a match stands on the holes recorded so far.
+type MatchPlayStatus struct {
+	Status string `json:"status"`
+	// Margin is how many holes side A is up.
+	Margin         int    `json:"margin"`
+	Thru           int    `json:"thru"`
+	Leader         string `json:"leader,omitempty"`
+	MatchPlayScore string `json:"matchPlayScore"`
+}
+
+type CupMatchResult struct {
+	models.CupMatch
+	MatchPlayStatus
+	// Points are what each side has won, Projected what each would take if
+	// the match finished as it stands.
+	PointsA    float64 `json:"pointsA"`
+	PointsB    float64 `json:"pointsB"`
+	ProjectedA float64 `json:"projectedA"`
+	ProjectedB float64 `json:"projectedB"`
+}
+
+type CupSessionResult struct {
+	models.CupSession
+	Matches []CupMatchResult `json:"matches"`
+}
+
+// CupSide is a side's row in the cup standings. MatchPlayScore carries its
+// points and Thru the matches it has finished.
+type CupSide struct {
+	LeaderboardRow
+	Side      string  `json:"side"`
+	Points    float64 `json:"points"`
+	Projected float64 `json:"projected"`
+}
+
+type CupStandings struct {
+	Sides       []CupSide          `json:"sides"`
+	PointsToWin float64            `json:"pointsToWin"`
+	Winner      string             `json:"winner,omitempty"`
+	Sessions    []CupSessionResult `json:"sessions"`
+}
+
+// HandleGetCup returns the running cup score, with every session's matches
+// and where each one stands.
+func (tc *TournamentController) HandleGetCup(e *core.RequestEvent) error {
+	tournamentId := e.Request.PathValue("tournamentId")
+
+	tournament, err := models.GetTournamentById(tc.db, tournamentId)
+	if err != nil {
+		return e.NotFoundError(err.Error(), tournamentId)
+	}
+	if !tournament.IsMatchPlay {
+		return e.BadRequestError("the tournament is not match play", nil)
+	}
+
+	standings, err := getCupStandings(tc.db, tournamentId)
+	if err != nil {
+		return e.Error(http.StatusInternalServerError, err.Error(), nil)
+	}
+
+	return e.JSON(http.StatusOK, standings)
+}
+
+// HandleCreateCupSession adds a session of fourballs, foursomes or singles.
+// Matches can be given side by side; otherwise each side's players are
+// paired off lowest handicap first and sent out against each other. The
+// session is played in the cup's tournament unless another round is given,
+// and its holes are decided from the scores posted there.
+func (tc *TournamentController) HandleCreateCupSession(e *core.RequestEvent) error {
+	var data models.CupSessionCreate
+	tournamentId := e.Request.PathValue("tournamentId")
+
+	err := json.NewDecoder(e.Request.Body).Decode(&data)
+	if err != nil {
+		return e.BadRequestError(err.Error(), nil)
+	}
+
+	tournament, err := models.GetTournamentById(tc.db, tournamentId)
+	if err != nil {
+		return e.NotFoundError(err.Error(), tournamentId)
+	}
+	if !tournament.IsMatchPlay {
+		return e.BadRequestError("cup sessions need a match play tournament", nil)
+	}
+
+	size, ok := cupFormatSizes[data.Format]
+	if !ok {
+		return e.BadRequestError("format must be fourballs, foursomes or singles", nil)
+	}
+
+	sides, rosters, err := getCupSides(tc.db, tournamentId)
+	if err != nil {
+		return e.BadRequestError(err.Error(), nil)
+	}
+
+	if len(data.Matches) == 0 {
+		data.Matches = pairCupMatches(rosters[models.MATCH_SIDE_A], rosters[models.MATCH_SIDE_B], size)
+		if len(data.Matches) == 0 {
+			return e.BadRequestError(fmt.Sprintf("each side needs at least %d players", size), nil)
+		}
+	} else {
+		err = validateCupMatches(data.Matches, rosters, size)
+		if err != nil {
+			return e.BadRequestError(err.Error(), nil)
+		}
+	}
+
+	if data.Name == "" {
+		sessions, err := models.GetCupSessions(tc.db, tournamentId)
+		if err != nil {
+			return e.Error(http.StatusInternalServerError, err.Error(), nil)
+		}
+		data.Name = fmt.Sprintf("Session %d: %s", len(*sessions)+1, cupFormatNames[data.Format])
+	}
+
+	if data.RoundTournamentId == "" {
+		data.RoundTournamentId = tournamentId
+	}
+	if data.RoundTournamentId != tournamentId {
+		_, err = models.GetTournamentById(tc.db, data.RoundTournamentId)
+		if err != nil {
+			return e.BadRequestError("the round's tournament was not found", nil)
+		}
+		for _, match := range data.Matches {
+			for _, playerId := range append(append([]string{}, match.SideA...), match.SideB...) {
+				_, err = models.GetTeamPlayer(tc.db, data.RoundTournamentId, playerId)
+				if err != nil {
+					return e.BadRequestError(fmt.Sprintf("player %s is not playing in that round", playerId), nil)
+				}
+			}
+		}
+	}
+
+	sideTeams := map[string]string{
+		models.MATCH_SIDE_A: sides[0].Id,
+		models.MATCH_SIDE_B: sides[1].Id,
+	}
+
+	var session *models.CupSession
+	err = tc.app.RunInTransaction(func(txDb core.App) error {
+		session, err = models.CreateCupSession(txDb.DB(), tournamentId, data, sideTeams)
+		if err != nil {
+			return err
+		}
+
+		_, err = models.CreateAuditLog(txDb.DB(), tournamentId, "cup.session_create", e.RealIP(), data)
+		return err
+	})
+
+	if err != nil {
+		return e.Error(http.StatusInternalServerError, err.Error(), nil)
+	}
+
+	standings, err := getCupStandings(tc.db, tournamentId)
+	if err != nil {
+		return e.Error(http.StatusInternalServerError, err.Error(), nil)
+	}
+	for _, created := range standings.Sessions {
+		if created.Id == session.Id {
+			return e.JSON(http.StatusCreated, created)
+		}
+	}
+
+	return e.JSON(http.StatusCreated, session)
+}
+
+func (tc *TournamentController) HandleDeleteCupSession(e *core.RequestEvent) error {
+	tournamentId := e.Request.PathValue("tournamentId")
+	sessionId := e.Request.PathValue("sessionId")
+
+	var deleted bool
+	err := tc.app.RunInTransaction(func(txDb core.App) error {
+		var err error
+		deleted, err = models.DeleteCupSession(txDb.DB(), tournamentId, sessionId)
+		if err != nil || !deleted {
+			return err
+		}
+
+		_, err = models.CreateAuditLog(txDb.DB(), tournamentId, "cup.session_delete", e.RealIP(), map[string]any{
+			"sessionId": sessionId,
+		})
+		return err
+	})
+
+	if err != nil {
+		return e.Error(http.StatusInternalServerError, err.Error(), nil)
+	}
+	if !deleted {
+		return e.NotFoundError("session not found", sessionId)
+	}
+
+	return e.NoContent(http.StatusNoContent)
+}
+
+// HandleOverrideCupMatchHoles lets the committee decide holes on a match by
+// hand, for a ruling the scores can't show. An override stands over the
+// scores until it is cleared with an empty winner.
+func (tc *TournamentController) HandleOverrideCupMatchHoles(e *core.RequestEvent) error {
+	var holes []models.MatchHoleUpdate
+	tournamentId := e.Request.PathValue("tournamentId")
+	matchId := e.Request.PathValue("matchId")
+
+	err := json.NewDecoder(e.Request.Body).Decode(&holes)
+	if err != nil {
+		return e.BadRequestError(err.Error(), nil)
+	}
+
+	matches, err := models.GetCupMatches(tc.db, tournamentId)
+	if err != nil {
+		return e.Error(http.StatusInternalServerError, err.Error(), nil)
+	}
+	found := false
+	for _, match := range *matches {
+		if match.Id == matchId {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return e.NotFoundError("match not found", matchId)
+	}
+
+	course, err := models.GetCourseByTournamentId(tc.db, tournamentId)
+	if err != nil {
+		return e.Error(http.StatusInternalServerError, err.Error(), nil)
+	}
+	courseHoles := getHoleDataMap(course)
+
+	for _, hole := range holes {
+		if _, ok := courseHoles[hole.Number]; !ok {
+			return e.BadRequestError(fmt.Sprintf("hole %d is not on the course", hole.Number), nil)
+		}
+		switch hole.Winner {
+		case "", models.MATCH_SIDE_A, models.MATCH_SIDE_B, models.MATCH_HOLE_HALVED:
+		default:
+			return e.BadRequestError("winner must be a, b or halved", nil)
+		}
+	}
+
+	err = tc.app.RunInTransaction(func(txDb core.App) error {
+		err := models.SetCupMatchHoles(txDb.DB(), tournamentId, matchId, holes)
+		if err != nil {
+			return err
+		}
+
+		_, err = models.CreateAuditLog(txDb.DB(), tournamentId, "cup.match_override", e.RealIP(), map[string]any{
+			"matchId": matchId,
+			"holes":   holes,
+		})
+		return err
+	})
+
+	if err != nil {
+		return e.Error(http.StatusInternalServerError, err.Error(), nil)
+	}
+
+	standings, err := getCupStandings(tc.db, tournamentId)
+	if err != nil {
+		return e.Error(http.StatusInternalServerError, err.Error(), nil)
+	}
+	for _, session := range standings.Sessions {
+		for _, match := range session.Matches {
+			if match.Id == matchId {
+				return e.JSON(http.StatusOK, match)
+			}
+		}
+	}
+
+	return e.NotFoundError("match not found", matchId)
+}
+
+// getCupSides returns the tournament's two teams, which are the sides of
+// the cup, ordered by name, and each side's players.
+func getCupSides(db dbx.Builder, tournamentId string) ([]models.Team, map[string][]models.TeamPlayer, error) {
+	teams, err := models.GetTeamsByTournamentId(db, tournamentId)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(*teams) != 2 {
+		return nil, nil, fmt.Errorf("a cup is played between exactly two teams, the tournament has %d", len(*teams))
+	}
+
+	sides := *teams
+	sort.SliceStable(sides, func(i, j int) bool {
+		return sides[i].Name < sides[j].Name
+	})
+
+	teamPlayers, err := models.GetTeamPlayersByTournament(db, tournamentId)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	rosters := map[string][]models.TeamPlayer{
+		models.MATCH_SIDE_A: {},
+		models.MATCH_SIDE_B: {},
+	}
+	for _, teamPlayer := range *teamPlayers {
+		switch teamPlayer.TeamId {
+		case sides[0].Id:
+			rosters[models.MATCH_SIDE_A] = append(rosters[models.MATCH_SIDE_A], teamPlayer)
+		case sides[1].Id:
+			rosters[models.MATCH_SIDE_B] = append(rosters[models.MATCH_SIDE_B], teamPlayer)
+		}
+	}
+
+	return sides, rosters, nil
+}
+
+// pairCupMatches sends each side out lowest handicap first, size players
+// at a time, until one side runs out.
+func pairCupMatches(sideA []models.TeamPlayer, sideB []models.TeamPlayer, size int) []models.CupMatchCreate {
+	groups := [][][]string{}
+	for _, roster := range [][]models.TeamPlayer{sideA, sideB} {
+		players := make([]models.TeamPlayer, len(roster))
+		copy(players, roster)
+		sort.SliceStable(players, func(i, j int) bool {
+			if players[i].Handicap != players[j].Handicap {
+				return players[i].Handicap < players[j].Handicap
+			}
+			return players[i].PlayerName < players[j].PlayerName
+		})
+
+		group := [][]string{}
+		for i := 0; i+size <= len(players); i += size {
+			playerIds := []string{}
+			for _, player := range players[i : i+size] {
+				playerIds = append(playerIds, player.PlayerId)
+			}
+			group = append(group, playerIds)
+		}
+		groups = append(groups, group)
+	}
+
+	matches := []models.CupMatchCreate{}
+	for i := 0; i < len(groups[0]) && i < len(groups[1]); i++ {
+		matches = append(matches, models.CupMatchCreate{SideA: groups[0][i], SideB: groups[1][i]})
+	}
+
+	return matches
+}
+
+// validateCupMatches checks every match sends out the right number of
+// players from each side and no one plays twice in the session.
+func validateCupMatches(matches []models.CupMatchCreate, rosters map[string][]models.TeamPlayer, size int) error {
+	onSide := make(map[string]string)
+	for side, roster := range rosters {
+		for _, teamPlayer := range roster {
+			onSide[teamPlayer.PlayerId] = side
+		}
+	}
+
+	seen := make(map[string]bool)
+	for i, match := range matches {
+		for side, playerIds := range map[string][]string{models.MATCH_SIDE_A: match.SideA, models.MATCH_SIDE_B: match.SideB} {
+			if len(playerIds) != size {
+				return fmt.Errorf("match %d needs %d players on each side", i+1, size)
+			}
+			for _, playerId := range playerIds {
+				if onSide[playerId] != side {
+					return fmt.Errorf("player %s is not on side %s", playerId, side)
+				}
+				if seen[playerId] {
+					return fmt.Errorf("player %s plays more than one match in the session", playerId)
+				}
+				seen[playerId] = true
+			}
+		}
+	}
+
+	return nil
+}
+
+// decideCupMatch fills in a match's holes from the scores its players
+// posted in the session's round, with the committee's overrides standing
+// over them.
+func decideCupMatch(match *models.CupMatch, format string, round *matchPlayRound) {
+	playerIds := func(side []models.CupMatchPlayer) []string {
+		ids := []string{}
+		for _, player := range side {
+			ids = append(ids, player.PlayerId)
+		}
+		return ids
+	}
+
+	match.Holes = round.decideHoles(playerIds(match.SideA), playerIds(match.SideB), cupFormatScoring[format], format == models.CUP_FORMAT_FOURSOMES)
+	for number, winner := range match.Overrides {
+		match.Holes[number] = winner
+	}
+}
+
+func courseHoleNumbers(courseHoles models.CourseHoleDataMap) []int {
+	numbers := []int{}
+	for number := range courseHoles {
+		numbers = append(numbers, number)
+	}
+	sort.Ints(numbers)
+
+	return numbers
+}
+
+// playCupMatch scores a cup match. A match in progress projects a point to
+// the side ahead and half each when all square, as does one not started.
+func playCupMatch(match models.CupMatch, numbers []int) CupMatchResult {
+	result := CupMatchResult{
+		CupMatch:        match,
+		MatchPlayStatus: playMatch(match.Holes, numbers),
+		ProjectedA:      0.5,
+		ProjectedB:      0.5,
+	}
+
+	switch result.Leader {
+	case models.MATCH_SIDE_A:
+		result.ProjectedA, result.ProjectedB = 1, 0
+	case models.MATCH_SIDE_B:
+		result.ProjectedA, result.ProjectedB = 0, 1
+	}
+
+	switch result.Status {
+	case MATCH_PLAY_WON:
+		result.PointsA, result.PointsB = result.ProjectedA, result.ProjectedB
+	case MATCH_PLAY_HALVED:
+		result.PointsA, result.PointsB = 0.5, 0.5
+	}
+
+	return result
+}
+
+// playMatch plays a match over the given holes in order, stopping at the
+// first hole without a result or once one side is up by more holes than
+// are left.
+func playMatch(holes map[int]string, numbers []int) MatchPlayStatus {
+	status := MatchPlayStatus{Status: MATCH_PLAY_NOT_STARTED}
+
+	for i, number := range numbers {
+		winner, ok := holes[number]
+		if !ok {
+			break
+		}
+
+		status.Thru++
+		status.Status = MATCH_PLAY_IN_PROGRESS
+		switch winner {
+		case models.MATCH_SIDE_A:
+			status.Margin++
+		case models.MATCH_SIDE_B:
+			status.Margin--
+		}
+
+		remaining := len(numbers) - 1 - i
+		if abs(status.Margin) > remaining || remaining == 0 {
+			if status.Margin == 0 {
+				status.Status = MATCH_PLAY_HALVED
+			} else {
+				status.Status = MATCH_PLAY_WON
+			}
+			break
+		}
+	}
+
+	switch {
+	case status.Margin > 0:
+		status.Leader = models.MATCH_SIDE_A
+	case status.Margin < 0:
+		status.Leader = models.MATCH_SIDE_B
+	}
+
+	remaining := len(numbers) - status.Thru
+	switch status.Status {
+	case MATCH_PLAY_WON:
+		if remaining > 0 {
+			status.MatchPlayScore = fmt.Sprintf("%d&%d", abs(status.Margin), remaining)
+		} else {
+			status.MatchPlayScore = fmt.Sprintf("%d UP", abs(status.Margin))
+		}
+	case MATCH_PLAY_HALVED:
+		status.MatchPlayScore = "Halved"
+	case MATCH_PLAY_IN_PROGRESS:
+		if status.Margin == 0 {
+			status.MatchPlayScore = "AS"
+		} else {
+			status.MatchPlayScore = fmt.Sprintf("%d UP", abs(status.Margin))
+		}
+	}
+
+	return status
+}
+
+// matchPlayRound is a round match play holes are decided from: the holes
+// of its course in order, the stroke index of each, and every player's
+// playing handicap and gross score on the holes they have finished.
+type matchPlayRound struct {
+	numbers       []int
+	strokeIndexes map[int]int
+	handicaps     map[string]int
+	scores        map[string]map[int]int
+}
+
+func getMatchPlayRound(db dbx.Builder, tournamentId string) (*matchPlayRound, error) {
+	course, err := models.GetCourseByTournamentId(db, tournamentId)
+	if err != nil {
+		return nil, err
+	}
+
+	teams, err := models.GetTeamsByTournamentId(db, tournamentId)
+	if err != nil {
+		return nil, err
+	}
+	teamIds := []string{}
+	for _, team := range *teams {
+		teamIds = append(teamIds, team.Id)
+	}
+
+	holes, err := models.GetTournamentHoles(db, tournamentId, teamIds)
+	if err != nil {
+		return nil, err
+	}
+
+	round := newMatchPlayRound(course, *holes)
+	return &round, nil
+}
+
+func newMatchPlayRound(course *models.CourseWithData, holes []models.HoleWithMetadata) matchPlayRound {
+	courseHoles := getHoleDataMap(course)
+	round := matchPlayRound{
+		numbers:       courseHoleNumbers(courseHoles),
+		strokeIndexes: make(map[int]int),
+		handicaps:     make(map[string]int),
+		scores:        make(map[string]map[int]int),
+	}
+	for number, hole := range courseHoles {
+		round.strokeIndexes[number] = hole.Handicap
+	}
+
+	for _, hole := range holes {
+		if _, ok := round.handicaps[hole.PlayerId]; !ok {
+			if hole.StrokesFrozen {
+				round.handicaps[hole.PlayerId] = hole.PlayingHandicap
+			} else {
+				round.handicaps[hole.PlayerId] = allocateStrokes(course, hole.Tee, hole.PlayerHandicap, hole.AwardedTournamentHandicap).PlayingHandicap
+			}
+		}
+
+		gross, ok := holeGrossScore(hole.Score, courseHoles[hole.Number].Par)
+		if !ok {
+			continue
+		}
+		if _, ok := round.scores[hole.PlayerId]; !ok {
+			round.scores[hole.PlayerId] = make(map[int]int)
+		}
+		round.scores[hole.PlayerId][hole.Number] = gross
+	}
+
+	return round
+}
+
+// decideHoles gives each hole to the side with the lower score, or halves
+// it, once both sides have finished it. A side's score is the best of its
+// players', waiting on all of them unless they share a ball, when any one
+// card carries it.
+//
+// Net matches are played off the low handicap: everyone receives the
+// difference between their playing handicap and the lowest in the match,
+// given out by stroke index. A side sharing a ball plays off half its
+// players' combined handicap.
+func (round matchPlayRound) decideHoles(sideA []string, sideB []string, scoring string, sharedBall bool) map[int]string {
+	handicaps := make(map[string]int)
+	if scoring != models.TEAM_SCORING_GROSS {
+		for _, side := range [][]string{sideA, sideB} {
+			if !sharedBall {
+				for _, playerId := range side {
+					handicaps[playerId] = round.handicaps[playerId]
+				}
+				continue
+			}
+
+			combined := 0
+			for _, playerId := range side {
+				combined += round.handicaps[playerId]
+			}
+			sideHandicap := int(math.Round(float64(combined) / 2))
+			for _, playerId := range side {
+				handicaps[playerId] = sideHandicap
+			}
+		}
+
+		low := 0
+		first := true
+		for _, handicap := range handicaps {
+			if first || handicap < low {
+				low = handicap
+				first = false
+			}
+		}
+		for playerId := range handicaps {
+			handicaps[playerId] -= low
+		}
+	}
+
+	sideScore := func(playerIds []string, number int) (int, bool) {
+		best, finished := 0, 0
+		for _, playerId := range playerIds {
+			gross, ok := round.scores[playerId][number]
+			if !ok {
+				continue
+			}
+
+			value := gross - getStrokesForHole(handicaps[playerId], round.strokeIndexes[number])
+			if finished == 0 || value < best {
+				best = value
+			}
+			finished++
+		}
+
+		if len(playerIds) == 0 || finished == 0 || (!sharedBall && finished < len(playerIds)) {
+			return 0, false
+		}
+		return best, true
+	}
+
+	holes := make(map[int]string)
+	for _, number := range round.numbers {
+		a, aOk := sideScore(sideA, number)
+		b, bOk := sideScore(sideB, number)
+		if !aOk || !bOk {
+			continue
+		}
+
+		switch {
+		case a < b:
+			holes[number] = models.MATCH_SIDE_A
+		case b < a:
+			holes[number] = models.MATCH_SIDE_B
+		default:
+			holes[number] = models.MATCH_HOLE_HALVED
+		}
+	}
+
+	return holes
+}
+
+func getCupStandings(db dbx.Builder, tournamentId string) (*CupStandings, error) {
+	sides, _, err := getCupSides(db, tournamentId)
+	if err != nil {
+		return nil, err
+	}
+
+	sessions, err := models.GetCupSessions(db, tournamentId)
+	if err != nil {
+		return nil, err
+	}
+	matches, err := models.GetCupMatches(db, tournamentId)
+	if err != nil {
+		return nil, err
+	}
+
+	rounds := make(map[string]*matchPlayRound)
+	bySessionId := make(map[string]models.CupSession)
+	for _, session := range *sessions {
+		bySessionId[session.Id] = session
+		if _, ok := rounds[session.RoundTournamentId]; ok {
+			continue
+		}
+
+		rounds[session.RoundTournamentId], err = getMatchPlayRound(db, session.RoundTournamentId)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	standings := CupStandings{
+		Sides: []CupSide{
+			{LeaderboardRow: LeaderboardRow{Id: sides[0].Id, TeamName: sides[0].Name}, Side: models.MATCH_SIDE_A},
+			{LeaderboardRow: LeaderboardRow{Id: sides[1].Id, TeamName: sides[1].Name}, Side: models.MATCH_SIDE_B},
+		},
+		PointsToWin: float64(len(*matches))/2 + 0.5,
+		Sessions:    []CupSessionResult{},
+	}
+
+	bySession := make(map[string][]CupMatchResult)
+	for _, match := range *matches {
+		session := bySessionId[match.SessionId]
+		round := rounds[session.RoundTournamentId]
+		decideCupMatch(&match, session.Format, round)
+
+		result := playCupMatch(match, round.numbers)
+		bySession[match.SessionId] = append(bySession[match.SessionId], result)
+
+		a, b := &standings.Sides[0], &standings.Sides[1]
+		a.Points += result.PointsA
+		b.Points += result.PointsB
+		a.Projected += result.ProjectedA
+		b.Projected += result.ProjectedB
+		if result.Status == MATCH_PLAY_WON || result.Status == MATCH_PLAY_HALVED {
+			a.Thru++
+			b.Thru++
+		}
+	}
+
+	for i := range standings.Sides {
+		side := &standings.Sides[i]
+		side.MatchPlayScore = formatCupPoints(side.Points)
+		if len(*matches) > 0 && side.Points >= standings.PointsToWin {
+			standings.Winner = side.Id
+		}
+	}
+
+	for _, session := range *sessions {
+		results := bySession[session.Id]
+		if results == nil {
+			results = []CupMatchResult{}
+		}
+		standings.Sessions = append(standings.Sessions, CupSessionResult{CupSession: session, Matches: results})
+	}
+
+	return &standings, nil
+}
+
+// formatCupPoints writes half points the way a cup scoreboard does, as 7½.
+func formatCupPoints(points float64) string {
+	whole := math.Floor(points)
+	if points == whole {
+		return formatPoints(points)
+	}
+	if whole == 0 {
+		return "½"
+	}
+
+	return formatPoints(whole) + "½"
+}
@@ -0,0 +1,154 @@
+package controllers
+
+import (
+	"net/http"
+	"reflect"
+	"strconv"
+	"testing"
+
+	"github.com/patrick-salvatore/tournament-live-scoring/models"
+)
+
+func TestMatchPlayRoundDecidesHoles(t *testing.T) {
+	course := &models.CourseWithData{Meta: models.CourseData{Holes: []models.CourseHoleData{
+		{Number: 1, Par: 4, Handicap: 1},
+		{Number: 2, Par: 4, Handicap: 2},
+		{Number: 3, Par: 4, Handicap: 3},
+		{Number: 4, Par: 4, Handicap: 4},
+	}}}
+	handicaps := map[string]int{"al": 1, "bo": 5, "cy": 3, "di": 8}
+	hole := func(playerId string, number int, gross int) models.HoleWithMetadata {
+		return models.HoleWithMetadata{PlayerId: playerId, Number: number, Score: strconv.Itoa(gross), PlayingHandicap: handicaps[playerId], StrokesFrozen: true}
+	}
+
+	round := newMatchPlayRound(course, []models.HoleWithMetadata{
+		// hole 1: al and cy make 5, each with a stroke off their full handicap
+		hole("al", 1, 5), hole("cy", 1, 5),
+		// hole 2: bo's partner al hasn't scored
+		hole("bo", 2, 3), hole("cy", 2, 5), hole("di", 2, 5),
+		// hole 3: everyone is in
+		hole("al", 3, 4), hole("bo", 3, 5), hole("cy", 3, 5), hole("di", 3, 6),
+	})
+
+	cases := []struct {
+		name       string
+		sideA      []string
+		sideB      []string
+		scoring    string
+		sharedBall bool
+		want       map[int]string
+	}{
+		{
+			// off full handicaps al and cy would both get a stroke on 1 and
+			// cy another on 3, halving both
+			name:    "net singles gives the difference on the hardest holes",
+			sideA:   []string{"al"},
+			sideB:   []string{"cy"},
+			scoring: models.TEAM_SCORING_NET,
+			want:    map[int]string{1: models.MATCH_SIDE_B, 3: models.MATCH_SIDE_A},
+		},
+		{
+			name:    "gross singles ignores strokes",
+			sideA:   []string{"al"},
+			sideB:   []string{"cy"},
+			scoring: models.TEAM_SCORING_GROSS,
+			want:    map[int]string{1: models.MATCH_HOLE_HALVED, 3: models.MATCH_SIDE_A},
+		},
+		{
+			name:    "fourballs waits on every player and counts the best",
+			sideA:   []string{"al", "bo"},
+			sideB:   []string{"cy", "di"},
+			scoring: models.TEAM_SCORING_NET,
+			want:    map[int]string{3: models.MATCH_SIDE_A},
+		},
+		{
+			// the sides play off 3 and 6, so cy and di's ball gets a
+			// stroke on each of the first three holes
+			name:       "foursomes plays off half the pair's combined handicap",
+			sideA:      []string{"al", "bo"},
+			sideB:      []string{"cy", "di"},
+			scoring:    models.TEAM_SCORING_NET,
+			sharedBall: true,
+			want:       map[int]string{1: models.MATCH_SIDE_B, 2: models.MATCH_SIDE_A, 3: models.MATCH_HOLE_HALVED},
+		},
+		{
+			name:       "gross foursomes takes the ball from either card",
+			sideA:      []string{"al", "bo"},
+			sideB:      []string{"cy", "di"},
+			scoring:    models.TEAM_SCORING_GROSS,
+			sharedBall: true,
+			want:       map[int]string{1: models.MATCH_HOLE_HALVED, 2: models.MATCH_SIDE_A, 3: models.MATCH_SIDE_A},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got := round.decideHoles(c.sideA, c.sideB, c.scoring, c.sharedBall)
+			if !reflect.DeepEqual(got, c.want) {
+				t.Fatalf("expected %v, got %v", c.want, got)
+			}
+		})
+	}
+}
+
+func TestCupOverridesStandOverScores(t *testing.T) {
+	app := newTestApp(t)
+	tc := NewTournamentController(app)
+
+	tournament, _ := newTestTournament(t, app, models.CreateTournamentData{Name: "Cup", TeamCount: 2, AwardedHandicap: 1, IsMatchPlay: true}, [][]models.Player{
+		{{Name: "Al", Handicap: 4}, {Name: "Bo", Handicap: 10}},
+		{{Name: "Cy", Handicap: 6}, {Name: "Di", Handicap: 12}},
+	})
+
+	rec := serveTest(app, tc.HandleCreateCupSession, testRequest{
+		method:     "POST",
+		body:       `{"format": "foursomes"}`,
+		pathValues: map[string]string{"tournamentId": tournament.Id},
+	})
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("creating the session: expected 201, got %d: %s", rec.Code, rec.Body)
+	}
+
+	matches, err := models.GetCupMatches(app.DB(), tournament.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	match := (*matches)[0]
+	setTestScores(t, app, tournament.Id, match.SideA[0].PlayerId, map[int]string{1: "4"})
+	// the sides play off 6 and 8, so side B's 6 is a net 5 on the hardest hole
+	setTestScores(t, app, tournament.Id, match.SideB[1].PlayerId, map[int]string{1: "6"})
+
+	score := func() string {
+		t.Helper()
+
+		standings, err := getCupStandings(app.DB(), tournament.Id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return standings.Sessions[0].Matches[0].MatchPlayScore
+	}
+	override := func(winner string) {
+		t.Helper()
+
+		rec := serveTest(app, tc.HandleOverrideCupMatchHoles, testRequest{
+			method:     "PUT",
+			body:       `[{"number": 1, "winner": "` + winner + `"}]`,
+			pathValues: map[string]string{"tournamentId": tournament.Id, "matchId": match.Id},
+		})
+		if rec.Code != http.StatusOK {
+			t.Fatalf("overriding: expected 200, got %d: %s", rec.Code, rec.Body)
+		}
+	}
+
+	if got := score(); got != "1 UP" {
+		t.Fatalf("from the scores: expected 1 UP, got %q", got)
+	}
+	override(models.MATCH_HOLE_HALVED)
+	if got := score(); got != "AS" {
+		t.Fatalf("with the committee halving the hole: expected AS, got %q", got)
+	}
+	override("")
+	if got := score(); got != "1 UP" {
+		t.Fatalf("with the override cleared: expected 1 UP, got %q", got)
+	}
+}
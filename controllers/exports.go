@@ -0,0 +1,487 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/jung-kurt/gofpdf"
+	"github.com/patrick-salvatore/tournament-live-scoring/models"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+type scorecardPlayer struct {
+	Id              string
+	Name            string
+	Tee             string
+	HandicapIndex   float64
+	CourseHandicap  int
+	PlayingHandicap int
+	Strokes         map[int]int
+	Scores          map[int]string
+}
+
+type scorecardTeam struct {
+	Id      string
+	Name    string
+	Start   models.TeamStart
+	Players []scorecardPlayer
+}
+
+func (tc *TournamentController) HandleGetTeamSheetFromTournament(e *core.RequestEvent) error {
+	tournamentId := e.Request.PathValue("tournamentId")
+
+	tournament, err := models.GetTournamentById(tc.db, tournamentId)
+	if err != nil {
+		return e.NotFoundError(err.Error(), tournamentId)
+	}
+	teams, err := models.GetTeamsByTournamentId(tc.db, tournamentId)
+	if err != nil {
+		return e.Error(http.StatusInternalServerError, err.Error(), nil)
+	}
+
+	pdf := newExportPdf("P", tournament.Name, "Team List")
+	pdf.AddPage()
+
+	widths := []float64{20, 170}
+	aligns := []string{"C", "L"}
+	pdfTableHeader(pdf, widths, []string{"#", "Team"})
+	for i, team := range *teams {
+		pdfTableRow(pdf, i, widths, aligns, []string{strconv.Itoa(i + 1), team.Name})
+	}
+
+	return writePdf(e, pdf, exportFileName(tournament.Name, "teams.pdf"))
+}
+
+// HandleGetScorecardsPdf prints one scorecard per team with a dot in every
+// hole a player receives a stroke. Pass ?teamId= to print a single card.
+func (tc *TournamentController) HandleGetScorecardsPdf(e *core.RequestEvent) error {
+	tournamentId := e.Request.PathValue("tournamentId")
+	teamId := e.Request.URL.Query().Get("teamId")
+
+	tournament, err := models.GetTournamentById(tc.db, tournamentId)
+	if err != nil {
+		return e.NotFoundError(err.Error(), tournamentId)
+	}
+
+	course, err := models.GetCourseByTournamentId(tc.db, tournamentId)
+	if err != nil {
+		return e.Error(http.StatusInternalServerError, err.Error(), nil)
+	}
+
+	teams, err := tc.getScorecardTeams(tournament, course)
+	if err != nil {
+		return e.Error(http.StatusInternalServerError, err.Error(), nil)
+	}
+
+	pdf := newExportPdf("L", tournament.Name, "Scorecards")
+	for _, team := range teams {
+		if len(teamId) > 0 && team.Id != teamId {
+			continue
+		}
+
+		pdf.AddPage()
+		drawScorecard(pdf, course, team)
+	}
+
+	if pdf.PageNo() == 0 {
+		return e.NotFoundError("no teams to print", teamId)
+	}
+
+	return writePdf(e, pdf, exportFileName(tournament.Name, "scorecards.pdf"))
+}
+
+func (tc *TournamentController) HandleGetPairingsPdf(e *core.RequestEvent) error {
+	tournamentId := e.Request.PathValue("tournamentId")
+
+	tournament, err := models.GetTournamentById(tc.db, tournamentId)
+	if err != nil {
+		return e.NotFoundError(err.Error(), tournamentId)
+	}
+
+	course, err := models.GetCourseByTournamentId(tc.db, tournamentId)
+	if err != nil {
+		return e.Error(http.StatusInternalServerError, err.Error(), nil)
+	}
+
+	teams, err := tc.getScorecardTeams(tournament, course)
+	if err != nil {
+		return e.Error(http.StatusInternalServerError, err.Error(), nil)
+	}
+
+	pdf := newExportPdf("P", tournament.Name, "Pairings")
+	pdf.AddPage()
+
+	widths := []float64{80, 30, 25, 25, 30}
+	aligns := []string{"L", "C", "C", "C", "C"}
+	for _, team := range teams {
+		pdf.SetFont("Arial", "B", 12)
+		pdf.CellFormat(130, pdfRowHeight, team.Name, "", 0, "L", false, 0, "")
+		pdf.SetFont("Arial", "", 10)
+		pdf.CellFormat(60, pdfRowHeight, formatTeamStart(team.Start), "", 1, "R", false, 0, "")
+
+		pdfTableHeader(pdf, widths, []string{"Player", "Tee", "Index", "Course", "Playing"})
+		for i, player := range team.Players {
+			pdfTableRow(pdf, i, widths, aligns, []string{
+				player.Name,
+				player.Tee,
+				strconv.FormatFloat(player.HandicapIndex, 'f', -1, 64),
+				strconv.Itoa(player.CourseHandicap),
+				strconv.Itoa(player.PlayingHandicap),
+			})
+		}
+		pdf.Ln(6)
+	}
+
+	return writePdf(e, pdf, exportFileName(tournament.Name, "pairings.pdf"))
+}
+
+// HandleGetResultsPdf prints the final standings by net score together with
+// the low gross and low net prize winners (?places=, default 3).
+func (tc *TournamentController) HandleGetResultsPdf(e *core.RequestEvent) error {
+	tournamentId := e.Request.PathValue("tournamentId")
+	individuals := e.Request.URL.Query().Get("individuals") == "true"
+
+	places := 3
+	if value := e.Request.URL.Query().Get("places"); len(value) > 0 {
+		var err error
+		places, err = strconv.Atoi(value)
+		if err != nil || places < 1 {
+			return e.BadRequestError("places must be a positive number", nil)
+		}
+	}
+
+	tournament, err := models.GetTournamentById(tc.db, tournamentId)
+	if err != nil {
+		return e.NotFoundError(err.Error(), tournamentId)
+	}
+
+	snapshot, err := leaderboards.Get(tc.db, tournamentId, individuals)
+	if err != nil {
+		return e.Error(http.StatusInternalServerError, err.Error(), nil)
+	}
+
+	pdf := newExportPdf("P", tournament.Name, "Results")
+	pdf.AddPage()
+
+	// rows that haven't started would rank at even par, so they are listed
+	// after the finishers instead
+	started, notStarted := []LeaderboardRow{}, []LeaderboardRow{}
+	for _, row := range snapshot.Rows {
+		if row.Thru > 0 {
+			started = append(started, row)
+		} else {
+			notStarted = append(notStarted, row)
+		}
+	}
+
+	gross := rankLeaderboardRows(started, grossScore)
+	net := rankLeaderboardRows(started, netScore)
+
+	prizeWidths := []float64{20, 140, 30}
+	prizeAligns := []string{"C", "L", "C"}
+	for _, prize := range []struct {
+		title string
+		rows  []RankedRow
+		score func(LeaderboardRow) int
+	}{
+		{"Low Gross", gross, grossScore},
+		{"Low Net", net, netScore},
+	} {
+		pdf.SetFont("Arial", "B", 12)
+		pdf.CellFormat(190, pdfRowHeight, prize.title, "", 1, "L", false, 0, "")
+		pdfTableHeader(pdf, prizeWidths, []string{"Pos", "Name", "Score"})
+		for i, row := range prize.rows {
+			if row.Position > places {
+				break
+			}
+			pdfTableRow(pdf, i, prizeWidths, prizeAligns, []string{formatPosition(row), row.TeamName, formatToPar(prize.score(row.LeaderboardRow))})
+		}
+		pdf.Ln(6)
+	}
+
+	contests, err := getContestResults(tc.db, tournamentId, false)
+	if err != nil {
+		return e.Error(http.StatusInternalServerError, err.Error(), nil)
+	}
+	if len(contests) > 0 {
+		pdf.SetFont("Arial", "B", 12)
+		pdf.CellFormat(190, pdfRowHeight, "Contests", "", 1, "L", false, 0, "")
+
+		contestWidths := []float64{70, 90, 30}
+		contestAligns := []string{"L", "L", "C"}
+		pdfTableHeader(pdf, contestWidths, []string{"Contest", "Winner", "Distance"})
+		for i, contest := range contests {
+			winner, distance := "-", "-"
+			if contest.Leader != nil {
+				winner = contest.Leader.PlayerName
+				distance = formatPoints(contest.Leader.Distance) + " " + contest.Unit
+			}
+			pdfTableRow(pdf, i, contestWidths, contestAligns, []string{contest.Name, winner, distance})
+		}
+		pdf.Ln(6)
+	}
+
+	pdf.SetFont("Arial", "B", 12)
+	pdf.CellFormat(190, pdfRowHeight, "Final Standings", "", 1, "L", false, 0, "")
+
+	widths := []float64{20, 110, 20, 20, 20}
+	aligns := []string{"C", "L", "C", "C", "C"}
+	pdfTableHeader(pdf, widths, []string{"Pos", "Name", "Thru", "Gross", "Net"})
+	for i, row := range net {
+		pdfTableRow(pdf, i, widths, aligns, []string{
+			formatPosition(row),
+			row.TeamName,
+			strconv.Itoa(row.Thru),
+			formatToPar(row.Gross),
+			formatToPar(row.Net),
+		})
+	}
+	for i, row := range notStarted {
+		pdfTableRow(pdf, len(net)+i, widths, aligns, []string{"-", row.TeamName, "0", "-", "-"})
+	}
+
+	return writePdf(e, pdf, exportFileName(tournament.Name, "results.pdf"))
+}
+
+// getScorecardTeams gathers every team's players with their handicaps,
+// strokes and any scores entered. Teams that haven't started are allocated
+// strokes from the players' current indexes.
+func (tc *TournamentController) getScorecardTeams(tournament *models.Tournament, course *models.CourseWithData) ([]scorecardTeam, error) {
+	roster, err := models.GetTournamentRoster(tc.db, tournament.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	teamIds := []string{}
+	teamsById := make(map[string]*scorecardTeam)
+	for _, entry := range *roster {
+		if _, ok := teamsById[entry.TeamId]; !ok {
+			teamIds = append(teamIds, entry.TeamId)
+			teamsById[entry.TeamId] = &scorecardTeam{Id: entry.TeamId, Name: entry.TeamName}
+		}
+	}
+
+	holes, err := models.GetTournamentHoles(tc.db, tournament.Id, teamIds)
+	if err != nil {
+		return nil, err
+	}
+	applyStrokeHoles(course, holes)
+
+	holesByPlayer := make(map[string][]models.HoleWithMetadata)
+	for _, hole := range *holes {
+		holesByPlayer[hole.PlayerId] = append(holesByPlayer[hole.PlayerId], hole)
+	}
+
+	for _, entry := range *roster {
+		allocation := allocateStrokes(course, entry.Tee, entry.Handicap, tournament.AwardedHandicap)
+		player := scorecardPlayer{
+			Id:              entry.PlayerId,
+			Name:            entry.Name,
+			Tee:             entry.Tee,
+			HandicapIndex:   allocation.HandicapIndex,
+			CourseHandicap:  allocation.CourseHandicap,
+			PlayingHandicap: allocation.PlayingHandicap,
+			Strokes:         allocation.Strokes,
+			Scores:          make(map[int]string),
+		}
+
+		if playerHoles, ok := holesByPlayer[entry.PlayerId]; ok {
+			player.Strokes = make(map[int]int)
+			for _, hole := range playerHoles {
+				player.Strokes[hole.Number] = hole.StrokeHole
+				player.Scores[hole.Number] = hole.Score
+				if hole.StrokesFrozen {
+					player.HandicapIndex = entry.TournamentHandicap
+					player.CourseHandicap = hole.CourseHandicap
+					player.PlayingHandicap = hole.PlayingHandicap
+				}
+			}
+		}
+
+		team := teamsById[entry.TeamId]
+		team.Players = append(team.Players, player)
+	}
+
+	tournamentTeams, err := models.GetTeamsByTournamentId(tc.db, tournament.Id)
+	if err != nil {
+		return nil, err
+	}
+	for _, team := range *tournamentTeams {
+		if scorecard, ok := teamsById[team.Id]; ok {
+			scorecard.Start = team.TeamStart
+		}
+	}
+
+	teams := []scorecardTeam{}
+	for _, teamId := range teamIds {
+		teams = append(teams, *teamsById[teamId])
+	}
+	// scheduled teams come out in tee order, the rest by name after them
+	sort.SliceStable(teams, func(i, j int) bool {
+		a, b := teams[i].Start.TeeGroup, teams[j].Start.TeeGroup
+		if a != b && (a == 0 || b == 0) {
+			return b == 0
+		}
+		if a != b {
+			return a < b
+		}
+		return teams[i].Name < teams[j].Name
+	})
+
+	return teams, nil
+}
+
+func drawScorecard(pdf *gofpdf.Fpdf, course *models.CourseWithData, team scorecardTeam) {
+	const (
+		nameWidth  = 55.0
+		holeWidth  = 10.0
+		totalWidth = 13.0
+		cardHeight = 10.0
+	)
+
+	pdf.SetFont("Arial", "B", 14)
+	pdf.CellFormat(0, 10, team.Name, "", 1, "L", false, 0, "")
+
+	front := []models.CourseHoleData{}
+	back := []models.CourseHoleData{}
+	for _, hole := range course.Meta.Holes {
+		if hole.Number <= 9 {
+			front = append(front, hole)
+		} else {
+			back = append(back, hole)
+		}
+	}
+
+	type cell struct {
+		text   string
+		width  float64
+		number int
+	}
+	columns := []cell{}
+	for _, hole := range front {
+		columns = append(columns, cell{text: strconv.Itoa(hole.Number), width: holeWidth, number: hole.Number})
+	}
+	columns = append(columns, cell{text: "Out", width: totalWidth})
+	if len(back) > 0 {
+		for _, hole := range back {
+			columns = append(columns, cell{text: strconv.Itoa(hole.Number), width: holeWidth, number: hole.Number})
+		}
+		columns = append(columns, cell{text: "In", width: totalWidth})
+		columns = append(columns, cell{text: "Tot", width: totalWidth})
+	}
+
+	sumPar := func(holes []models.CourseHoleData) int {
+		total := 0
+		for _, hole := range holes {
+			total += hole.Par
+		}
+		return total
+	}
+
+	pdf.SetFont("Arial", "B", 10)
+	pdf.SetFillColor(220, 220, 220)
+	pdf.CellFormat(nameWidth, pdfRowHeight, "Hole", "1", 0, "L", true, 0, "")
+	for _, column := range columns {
+		pdf.CellFormat(column.width, pdfRowHeight, column.text, "1", 0, "C", true, 0, "")
+	}
+	pdf.Ln(-1)
+
+	pdf.SetFont("Arial", "", 10)
+	pdf.SetFillColor(245, 245, 245)
+	pdf.CellFormat(nameWidth, pdfRowHeight, "Par", "1", 0, "L", true, 0, "")
+	holeData := getHoleDataMap(course)
+	for _, column := range columns {
+		text := ""
+		switch column.text {
+		case "Out":
+			text = strconv.Itoa(sumPar(front))
+		case "In":
+			text = strconv.Itoa(sumPar(back))
+		case "Tot":
+			text = strconv.Itoa(sumPar(front) + sumPar(back))
+		default:
+			text = strconv.Itoa(holeData[column.number].Par)
+		}
+		pdf.CellFormat(column.width, pdfRowHeight, text, "1", 0, "C", true, 0, "")
+	}
+	pdf.Ln(-1)
+
+	pdf.CellFormat(nameWidth, pdfRowHeight, "Handicap", "1", 0, "L", true, 0, "")
+	for _, column := range columns {
+		text := ""
+		if column.number > 0 {
+			text = strconv.Itoa(holeData[column.number].Handicap)
+		}
+		pdf.CellFormat(column.width, pdfRowHeight, text, "1", 0, "C", true, 0, "")
+	}
+	pdf.Ln(-1)
+
+	for _, player := range team.Players {
+		pdf.SetFont("Arial", "", 10)
+		pdf.CellFormat(nameWidth, cardHeight, fmt.Sprintf("%s (%d)", player.Name, player.PlayingHandicap), "1", 0, "L", false, 0, "")
+
+		out, in := 0, 0
+		frontComplete, backComplete := true, true
+		for _, column := range columns {
+			x, y := pdf.GetXY()
+
+			text := ""
+			switch column.text {
+			case "Out":
+				if frontComplete {
+					text = strconv.Itoa(out)
+				}
+			case "In":
+				if backComplete {
+					text = strconv.Itoa(in)
+				}
+			case "Tot":
+				if frontComplete && backComplete {
+					text = strconv.Itoa(out + in)
+				}
+			default:
+				text = player.Scores[column.number]
+				score, err := strconv.Atoi(text)
+				if err != nil && column.number <= 9 {
+					frontComplete = false
+				} else if err != nil {
+					backComplete = false
+				} else if column.number <= 9 {
+					out += score
+				} else {
+					in += score
+				}
+			}
+
+			pdf.SetFont("Arial", "B", 11)
+			pdf.CellFormat(column.width, cardHeight, text, "1", 0, "C", false, 0, "")
+
+			for dot := 0; dot < player.Strokes[column.number]; dot++ {
+				pdf.SetFillColor(0, 0, 0)
+				pdf.Circle(x+column.width-1.8-float64(dot)*2.2, y+1.8, 0.7, "F")
+			}
+		}
+		pdf.Ln(-1)
+	}
+
+	pdf.Ln(4)
+	pdf.SetFont("Arial", "I", 8)
+	pdf.CellFormat(0, 5, "Dots mark holes where a player receives a stroke. Handicap shown is the playing handicap.", "", 1, "L", false, 0, "")
+}
+
+// formatTeamStart prints a scheduled start like "8:10 AM - Hole 10".
+func formatTeamStart(start models.TeamStart) string {
+	if len(start.StartTime) == 0 {
+		return ""
+	}
+
+	startTime, err := time.Parse(time.RFC3339, start.StartTime)
+	if err != nil {
+		return ""
+	}
+
+	return fmt.Sprintf("%s - Hole %d", startTime.Format("3:04 PM"), start.StartHole)
+}
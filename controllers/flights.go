@@ -0,0 +1,679 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/patrick-salvatore/tournament-live-scoring/models"
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+type FlightMember struct {
+	PlayerId      string  `json:"playerId"`
+	PlayerName    string  `json:"playerName"`
+	HandicapIndex float64 `json:"handicapIndex"`
+	Assigned      bool    `json:"assigned"`
+}
+
+type FlightDetail struct {
+	models.Flight
+	Members []FlightMember `json:"members"`
+}
+
+type FlightLeaderboardRow struct {
+	Position string `json:"position"`
+	Prize    bool   `json:"prize"`
+	LeaderboardRow
+
+	place int
+}
+
+type FlightLeaderboard struct {
+	Flight models.Flight          `json:"flight"`
+	Rows   []FlightLeaderboardRow `json:"rows"`
+}
+
+type FlightGenerateRequest struct {
+	Count    int    `json:"count"`
+	Scoring  string `json:"scoring,omitempty"`
+	Places   int    `json:"places,omitempty"`
+	Tiebreak string `json:"tiebreak,omitempty"`
+}
+
+// HandleGetFlights lists the tournament's flights and divisions with the
+// players that currently fall in each.
+func (tc *TournamentController) HandleGetFlights(e *core.RequestEvent) error {
+	tournamentId := e.Request.PathValue("tournamentId")
+
+	details, err := getFlightDetails(tc.db, tournamentId)
+	if err != nil {
+		return e.Error(http.StatusInternalServerError, err.Error(), nil)
+	}
+
+	return e.JSON(http.StatusOK, details)
+}
+
+// HandleCreateFlight adds a handicap flight covering [minHandicap,
+// maxHandicap], or a division. Either can also take players assigned by
+// hand.
+func (tc *TournamentController) HandleCreateFlight(e *core.RequestEvent) error {
+	var data models.FlightCreate
+	tournamentId := e.Request.PathValue("tournamentId")
+
+	err := json.NewDecoder(e.Request.Body).Decode(&data)
+	if err != nil {
+		return e.BadRequestError(err.Error(), nil)
+	}
+
+	_, err = models.GetTournamentById(tc.db, tournamentId)
+	if err != nil {
+		return e.NotFoundError(err.Error(), tournamentId)
+	}
+
+	if data.Kind == "" {
+		data.Kind = models.FLIGHT_KIND_HANDICAP
+	}
+	if data.Scoring == "" {
+		data.Scoring = models.FLIGHT_SCORING_NET
+	}
+	if data.Places == 0 {
+		data.Places = 3
+	}
+	if data.Tiebreak == "" {
+		data.Tiebreak = models.FLIGHT_TIEBREAK_NONE
+	}
+	if data.PlayerIds == nil {
+		data.PlayerIds = []string{}
+	}
+
+	err = validateFlight(data.Name, data.Kind, data.MinHandicap, data.MaxHandicap, data.Scoring, data.Places, data.Tiebreak)
+	if err != nil {
+		return e.BadRequestError(err.Error(), nil)
+	}
+	err = tc.validateFlightPlayers(tournamentId, data.PlayerIds)
+	if err != nil {
+		return e.BadRequestError(err.Error(), nil)
+	}
+
+	var flight *models.Flight
+	err = tc.app.RunInTransaction(func(txDb core.App) error {
+		err := clearHandicapFlightPlayers(txDb.DB(), tournamentId, data.Kind, data.PlayerIds)
+		if err != nil {
+			return err
+		}
+
+		flight, err = models.CreateFlight(txDb.DB(), tournamentId, data)
+		if err != nil {
+			return err
+		}
+
+		_, err = models.CreateAuditLog(txDb.DB(), tournamentId, "flight.create", e.RealIP(), flight)
+		return err
+	})
+
+	if err != nil {
+		return e.Error(http.StatusInternalServerError, err.Error(), nil)
+	}
+
+	return e.JSON(http.StatusCreated, flight)
+}
+
+// HandleGenerateFlights replaces the handicap flights with count flights of
+// about the same size, A being the lowest handicaps. Players sharing an
+// index always land in the same flight.
+func (tc *TournamentController) HandleGenerateFlights(e *core.RequestEvent) error {
+	var data FlightGenerateRequest
+	tournamentId := e.Request.PathValue("tournamentId")
+
+	err := json.NewDecoder(e.Request.Body).Decode(&data)
+	if err != nil {
+		return e.BadRequestError(err.Error(), nil)
+	}
+	if data.Count < 1 || data.Count > 26 {
+		return e.BadRequestError("count must be between 1 and 26", nil)
+	}
+	if data.Scoring == "" {
+		data.Scoring = models.FLIGHT_SCORING_NET
+	}
+	if data.Places == 0 {
+		data.Places = 3
+	}
+	if data.Tiebreak == "" {
+		data.Tiebreak = models.FLIGHT_TIEBREAK_NONE
+	}
+
+	err = validateFlight("generated", models.FLIGHT_KIND_HANDICAP, 0, 0, data.Scoring, data.Places, data.Tiebreak)
+	if err != nil {
+		return e.BadRequestError(err.Error(), nil)
+	}
+
+	teamPlayers, err := models.GetTeamPlayersByTournament(tc.db, tournamentId)
+	if err != nil {
+		return e.Error(http.StatusInternalServerError, err.Error(), nil)
+	}
+	if len(*teamPlayers) < data.Count {
+		return e.BadRequestError(fmt.Sprintf("can't split %d players into %d flights", len(*teamPlayers), data.Count), nil)
+	}
+
+	indexes := []float64{}
+	for _, teamPlayer := range *teamPlayers {
+		indexes = append(indexes, teamPlayer.HandicapIndex)
+	}
+	sort.Float64s(indexes)
+
+	// each flight starts at the first index of its share of the field,
+	// pushed past any players tied with the flight before
+	starts := []int{0}
+	for i := 1; i < data.Count; i++ {
+		start := i * len(indexes) / data.Count
+		if start < starts[len(starts)-1]+1 {
+			start = starts[len(starts)-1] + 1
+		}
+		for start < len(indexes) && indexes[start] == indexes[start-1] {
+			start++
+		}
+		if start >= len(indexes) {
+			break
+		}
+		starts = append(starts, start)
+	}
+
+	flights := []models.FlightCreate{}
+	for i, start := range starts {
+		minHandicap := FLIGHT_HANDICAP_FLOOR
+		if i > 0 {
+			minHandicap = indexes[start]
+		}
+		maxHandicap := FLIGHT_HANDICAP_CEILING
+		if i+1 < len(starts) {
+			maxHandicap = roundTo(indexes[starts[i+1]]-0.1, 1)
+		}
+
+		flights = append(flights, models.FlightCreate{
+			Name:        fmt.Sprintf("%c Flight", 'A'+i),
+			Kind:        models.FLIGHT_KIND_HANDICAP,
+			MinHandicap: minHandicap,
+			MaxHandicap: maxHandicap,
+			Scoring:     data.Scoring,
+			Places:      data.Places,
+			Tiebreak:    data.Tiebreak,
+			PlayerIds:   []string{},
+		})
+	}
+
+	err = tc.app.RunInTransaction(func(txDb core.App) error {
+		existing, err := models.GetFlights(txDb.DB(), tournamentId)
+		if err != nil {
+			return err
+		}
+		for _, flight := range *existing {
+			if flight.Kind == models.FLIGHT_KIND_HANDICAP {
+				err = models.DeleteFlight(txDb.DB(), flight.Id)
+				if err != nil {
+					return err
+				}
+			}
+		}
+
+		for _, flight := range flights {
+			_, err = models.CreateFlight(txDb.DB(), tournamentId, flight)
+			if err != nil {
+				return err
+			}
+		}
+
+		_, err = models.CreateAuditLog(txDb.DB(), tournamentId, "flight.generate", e.RealIP(), data)
+		return err
+	})
+
+	if err != nil {
+		return e.Error(http.StatusInternalServerError, err.Error(), nil)
+	}
+
+	details, err := getFlightDetails(tc.db, tournamentId)
+	if err != nil {
+		return e.Error(http.StatusInternalServerError, err.Error(), nil)
+	}
+
+	return e.JSON(http.StatusCreated, details)
+}
+
+// HandleUpdateFlight changes a flight's range or settings. playerIds, when
+// sent, replaces the players assigned by hand.
+func (tc *TournamentController) HandleUpdateFlight(e *core.RequestEvent) error {
+	var data models.FlightUpdate
+	tournamentId := e.Request.PathValue("tournamentId")
+	flightId := e.Request.PathValue("flightId")
+
+	err := json.NewDecoder(e.Request.Body).Decode(&data)
+	if err != nil {
+		return e.BadRequestError(err.Error(), nil)
+	}
+
+	flight, err := models.GetFlight(tc.db, tournamentId, flightId)
+	if err != nil {
+		return e.NotFoundError(err.Error(), flightId)
+	}
+
+	name := flight.Name
+	if data.Name != nil {
+		name = *data.Name
+	}
+	minHandicap := flight.MinHandicap
+	if data.MinHandicap != nil {
+		minHandicap = *data.MinHandicap
+	}
+	maxHandicap := flight.MaxHandicap
+	if data.MaxHandicap != nil {
+		maxHandicap = *data.MaxHandicap
+	}
+	scoring := flight.Scoring
+	if data.Scoring != nil {
+		scoring = *data.Scoring
+	}
+	places := flight.Places
+	if data.Places != nil {
+		places = *data.Places
+	}
+	tiebreak := flight.Tiebreak
+	if data.Tiebreak != nil {
+		tiebreak = *data.Tiebreak
+	}
+
+	err = validateFlight(name, flight.Kind, minHandicap, maxHandicap, scoring, places, tiebreak)
+	if err != nil {
+		return e.BadRequestError(err.Error(), nil)
+	}
+	if data.PlayerIds != nil {
+		err = tc.validateFlightPlayers(tournamentId, *data.PlayerIds)
+		if err != nil {
+			return e.BadRequestError(err.Error(), nil)
+		}
+	}
+
+	err = tc.app.RunInTransaction(func(txDb core.App) error {
+		if data.PlayerIds != nil {
+			err := clearHandicapFlightPlayers(txDb.DB(), tournamentId, flight.Kind, *data.PlayerIds)
+			if err != nil {
+				return err
+			}
+		}
+
+		_, err := models.UpdateFlight(txDb.DB(), tournamentId, flightId, data)
+		if err != nil {
+			return err
+		}
+
+		_, err = models.CreateAuditLog(txDb.DB(), tournamentId, "flight.update", e.RealIP(), map[string]any{
+			"flightId": flightId,
+			"update":   data,
+		})
+		return err
+	})
+
+	if err != nil {
+		return e.BadRequestError(err.Error(), nil)
+	}
+
+	flight, err = models.GetFlight(tc.db, tournamentId, flightId)
+	if err != nil {
+		return e.Error(http.StatusInternalServerError, err.Error(), nil)
+	}
+
+	return e.JSON(http.StatusOK, flight)
+}
+
+func (tc *TournamentController) HandleDeleteFlight(e *core.RequestEvent) error {
+	tournamentId := e.Request.PathValue("tournamentId")
+	flightId := e.Request.PathValue("flightId")
+
+	flight, err := models.GetFlight(tc.db, tournamentId, flightId)
+	if err != nil {
+		return e.NotFoundError(err.Error(), flightId)
+	}
+
+	err = tc.app.RunInTransaction(func(txDb core.App) error {
+		err := models.DeleteFlight(txDb.DB(), flightId)
+		if err != nil {
+			return err
+		}
+
+		_, err = models.CreateAuditLog(txDb.DB(), tournamentId, "flight.delete", e.RealIP(), flight)
+		return err
+	})
+
+	if err != nil {
+		return e.Error(http.StatusInternalServerError, err.Error(), nil)
+	}
+
+	return e.NoContent(http.StatusNoContent)
+}
+
+// HandleGetFlightLeaderboards returns a ranked leaderboard for every flight
+// and division, each using its own scoring and tiebreak.
+func (tc *TournamentController) HandleGetFlightLeaderboards(e *core.RequestEvent) error {
+	tournamentId := e.Request.PathValue("tournamentId")
+
+	boards, err := getFlightLeaderboards(tc.db, tournamentId)
+	if err != nil {
+		return e.Error(http.StatusInternalServerError, err.Error(), nil)
+	}
+
+	return e.JSON(http.StatusOK, boards)
+}
+
+const (
+	FLIGHT_HANDICAP_FLOOR   = -10.0
+	FLIGHT_HANDICAP_CEILING = 54.0
+)
+
+func validateFlight(name string, kind string, minHandicap float64, maxHandicap float64, scoring string, places int, tiebreak string) error {
+	if len(name) == 0 {
+		return fmt.Errorf("name is required")
+	}
+	if kind != models.FLIGHT_KIND_HANDICAP && kind != models.FLIGHT_KIND_DIVISION {
+		return fmt.Errorf("kind must be handicap or division")
+	}
+	if kind == models.FLIGHT_KIND_HANDICAP && minHandicap > maxHandicap {
+		return fmt.Errorf("minHandicap can't be above maxHandicap")
+	}
+	if scoring != models.FLIGHT_SCORING_GROSS && scoring != models.FLIGHT_SCORING_NET {
+		return fmt.Errorf("scoring must be gross or net")
+	}
+	if places < 0 {
+		return fmt.Errorf("places can't be negative")
+	}
+	if tiebreak != models.FLIGHT_TIEBREAK_NONE && tiebreak != models.FLIGHT_TIEBREAK_COUNTBACK {
+		return fmt.Errorf("tiebreak must be none or countback")
+	}
+
+	return nil
+}
+
+func (tc *TournamentController) validateFlightPlayers(tournamentId string, playerIds []string) error {
+	for _, playerId := range playerIds {
+		_, err := models.GetTeamPlayer(tc.db, tournamentId, playerId)
+		if err != nil {
+			return fmt.Errorf("player %s is not in this tournament", playerId)
+		}
+	}
+
+	return nil
+}
+
+// clearHandicapFlightPlayers frees players about to be assigned to a
+// handicap flight from any other, since they may only play in one.
+func clearHandicapFlightPlayers(db dbx.Builder, tournamentId string, kind string, playerIds []string) error {
+	if kind != models.FLIGHT_KIND_HANDICAP {
+		return nil
+	}
+
+	for _, playerId := range playerIds {
+		err := models.ClearFlightPlayer(db, tournamentId, kind, playerId)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func getFlightDetails(db dbx.Builder, tournamentId string) ([]FlightDetail, error) {
+	flights, err := models.GetFlights(db, tournamentId)
+	if err != nil {
+		return nil, err
+	}
+
+	teamPlayers, err := models.GetTeamPlayersByTournament(db, tournamentId)
+	if err != nil {
+		return nil, err
+	}
+
+	members := flightMembers(*flights, *teamPlayers)
+
+	details := []FlightDetail{}
+	for _, flight := range *flights {
+		assigned := make(map[string]bool)
+		for _, playerId := range flight.PlayerIds {
+			assigned[playerId] = true
+		}
+
+		detail := FlightDetail{Flight: flight, Members: []FlightMember{}}
+		for _, teamPlayer := range *teamPlayers {
+			if !members[flight.Id][teamPlayer.PlayerId] {
+				continue
+			}
+			detail.Members = append(detail.Members, FlightMember{
+				PlayerId:      teamPlayer.PlayerId,
+				PlayerName:    teamPlayer.PlayerName,
+				HandicapIndex: teamPlayer.HandicapIndex,
+				Assigned:      assigned[teamPlayer.PlayerId],
+			})
+		}
+		sort.SliceStable(detail.Members, func(i, j int) bool {
+			return detail.Members[i].HandicapIndex < detail.Members[j].HandicapIndex
+		})
+
+		details = append(details, detail)
+	}
+
+	return details, nil
+}
+
+// flightMembers works out who plays in each flight. Players assigned to a
+// handicap flight by hand stay there, everyone else goes to the first
+// handicap flight whose range holds the index they entered the tournament
+// with. Divisions only hold their assigned players.
+func flightMembers(flights []models.Flight, teamPlayers []models.TeamPlayer) map[string]map[string]bool {
+	members := make(map[string]map[string]bool)
+	placed := make(map[string]bool)
+
+	for _, flight := range flights {
+		members[flight.Id] = make(map[string]bool)
+		for _, playerId := range flight.PlayerIds {
+			members[flight.Id][playerId] = true
+			if flight.Kind == models.FLIGHT_KIND_HANDICAP {
+				placed[playerId] = true
+			}
+		}
+	}
+
+	for _, teamPlayer := range teamPlayers {
+		if placed[teamPlayer.PlayerId] {
+			continue
+		}
+		for _, flight := range flights {
+			if flight.Kind != models.FLIGHT_KIND_HANDICAP {
+				continue
+			}
+			if teamPlayer.HandicapIndex >= flight.MinHandicap && teamPlayer.HandicapIndex <= flight.MaxHandicap {
+				members[flight.Id][teamPlayer.PlayerId] = true
+				break
+			}
+		}
+	}
+
+	return members
+}
+
+func getFlightLeaderboards(db dbx.Builder, tournamentId string) ([]FlightLeaderboard, error) {
+	flights, err := models.GetFlights(db, tournamentId)
+	if err != nil {
+		return nil, err
+	}
+
+	boards := []FlightLeaderboard{}
+	if len(*flights) == 0 {
+		return boards, nil
+	}
+
+	teamPlayers, err := models.GetTeamPlayersByTournament(db, tournamentId)
+	if err != nil {
+		return nil, err
+	}
+	members := flightMembers(*flights, *teamPlayers)
+
+	snapshot, err := leaderboards.Get(db, tournamentId, true)
+	if err != nil {
+		return nil, err
+	}
+
+	var countbacks map[string]map[string][]int
+	for _, flight := range *flights {
+		if flight.Tiebreak == models.FLIGHT_TIEBREAK_COUNTBACK {
+			countbacks, err = getCountbacks(db, tournamentId)
+			if err != nil {
+				return nil, err
+			}
+			break
+		}
+	}
+
+	for _, flight := range *flights {
+		// players who haven't started would rank at even par
+		rows := []LeaderboardRow{}
+		for _, row := range snapshot.Rows {
+			if members[flight.Id][row.Id] && row.Thru > 0 {
+				rows = append(rows, row)
+			}
+		}
+
+		boards = append(boards, FlightLeaderboard{
+			Flight: flight,
+			Rows:   rankFlight(flight, rows, countbacks[flight.Scoring]),
+		})
+	}
+
+	return boards, nil
+}
+
+// rankFlight orders a flight's rows by its scoring. Ties share a position
+// unless the flight breaks them on countback.
+func rankFlight(flight models.Flight, rows []LeaderboardRow, countbacks map[string][]int) []FlightLeaderboardRow {
+	score := netScore
+	if flight.Scoring == models.FLIGHT_SCORING_GROSS {
+		score = grossScore
+	}
+	useCountback := flight.Tiebreak == models.FLIGHT_TIEBREAK_COUNTBACK
+
+	compare := func(a LeaderboardRow, b LeaderboardRow) int {
+		if score(a) != score(b) {
+			return score(a) - score(b)
+		}
+		if !useCountback {
+			return 0
+		}
+		for i := range countbacks[a.Id] {
+			if i < len(countbacks[b.Id]) && countbacks[a.Id][i] != countbacks[b.Id][i] {
+				return countbacks[a.Id][i] - countbacks[b.Id][i]
+			}
+		}
+		return 0
+	}
+
+	sorted := make([]LeaderboardRow, len(rows))
+	copy(sorted, rows)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if order := compare(sorted[i], sorted[j]); order != 0 {
+			return order < 0
+		}
+		return sorted[i].TeamName < sorted[j].TeamName
+	})
+
+	places := make([]int, len(sorted))
+	for i := range sorted {
+		places[i] = i + 1
+		if i > 0 && compare(sorted[i], sorted[i-1]) == 0 {
+			places[i] = places[i-1]
+		}
+	}
+
+	ranked := []FlightLeaderboardRow{}
+	for i, row := range sorted {
+		position := strconv.Itoa(places[i])
+		if (i > 0 && places[i-1] == places[i]) || (i+1 < len(places) && places[i+1] == places[i]) {
+			position = "T" + position
+		}
+
+		ranked = append(ranked, FlightLeaderboardRow{
+			Position:       position,
+			Prize:          places[i] <= flight.Places,
+			LeaderboardRow: row,
+			place:          places[i],
+		})
+	}
+
+	return ranked
+}
+
+// getCountbacks totals every player's last 9, 6, 3 and final hole, gross
+// and net, keyed by scoring and then player.
+func getCountbacks(db dbx.Builder, tournamentId string) (map[string]map[string][]int, error) {
+	course, err := models.GetCourseByTournamentId(db, tournamentId)
+	if err != nil {
+		return nil, err
+	}
+	courseHoles := getHoleDataMap(course)
+
+	teams, err := models.GetTeamsByTournamentId(db, tournamentId)
+	if err != nil {
+		return nil, err
+	}
+	teamIds := []string{}
+	for _, team := range *teams {
+		teamIds = append(teamIds, team.Id)
+	}
+
+	holes, err := models.GetTournamentHoles(db, tournamentId, teamIds)
+	if err != nil {
+		return nil, err
+	}
+	applyStrokeHoles(course, holes)
+
+	numbers := []int{}
+	for number := range courseHoles {
+		numbers = append(numbers, number)
+	}
+	sort.Ints(numbers)
+
+	// splits[i] is the first hole counted by the i-th countback
+	splits := []int{}
+	for _, count := range []int{9, 6, 3, 1} {
+		if count <= len(numbers) {
+			splits = append(splits, numbers[len(numbers)-count])
+		}
+	}
+
+	countbacks := map[string]map[string][]int{
+		models.FLIGHT_SCORING_GROSS: {},
+		models.FLIGHT_SCORING_NET:   {},
+	}
+	for _, hole := range *holes {
+		gross, ok := holeGrossScore(hole.Score, courseHoles[hole.Number].Par)
+		if !ok {
+			continue
+		}
+
+		for scoring, score := range map[string]int{
+			models.FLIGHT_SCORING_GROSS: gross,
+			models.FLIGHT_SCORING_NET:   gross - hole.StrokeHole,
+		} {
+			totals, ok := countbacks[scoring][hole.PlayerId]
+			if !ok {
+				totals = make([]int, len(splits))
+				countbacks[scoring][hole.PlayerId] = totals
+			}
+			for i, split := range splits {
+				if hole.Number >= split {
+					totals[i] += score
+				}
+			}
+		}
+	}
+
+	return countbacks, nil
+}
@@ -0,0 +1,50 @@
+package controllers
+
+import (
+	"testing"
+
+	"github.com/patrick-salvatore/tournament-live-scoring/models"
+)
+
+func TestFlightLeaderboardsLeaveOutPlayersWhoHaveNotStarted(t *testing.T) {
+	app := newTestApp(t)
+
+	tournament, teams := newTestTournament(t, app, models.CreateTournamentData{Name: "Club", TeamCount: 1, AwardedHandicap: 1}, [][]models.Player{
+		{{Name: "Al", Handicap: 10}},
+		{{Name: "Bo", Handicap: 12}},
+	})
+
+	_, err := models.CreateFlight(app.DB(), tournament.Id, models.FlightCreate{
+		Name:        "A",
+		Kind:        models.FLIGHT_KIND_HANDICAP,
+		MinHandicap: 0,
+		MaxHandicap: 20,
+		Scoring:     models.FLIGHT_SCORING_GROSS,
+		Places:      1,
+		Tiebreak:    models.FLIGHT_TIEBREAK_NONE,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	players, err := models.GetPlayersFromTeamId(app.DB(), teams[0].Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Al is three over after two holes, Bo hasn't teed off
+	setTestScores(t, app, tournament.Id, (*players)[0].Id, map[int]string{1: "7", 2: "7"})
+	leaderboards.Invalidate(tournament.Id)
+
+	boards, err := getFlightLeaderboards(app.DB(), tournament.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(boards) != 1 {
+		t.Fatalf("expected one flight, got %d", len(boards))
+	}
+
+	rows := boards[0].Rows
+	if len(rows) != 1 || rows[0].TeamName != "Al" || !rows[0].Prize {
+		t.Fatalf("expected only Al, in the prize place, got %+v", rows)
+	}
+}
@@ -1,6 +1,7 @@
 package controllers
 
 import (
+	"fmt"
 	"math"
 	"sort"
 	"strings"
@@ -9,27 +10,68 @@ import (
 )
 
 func getStrokeHole(playerHandicap, slopeRating, courseRating, par, awardedHandicap float64, holeHandicapIndex int) int {
+	playingHandicap := getCourseHandicap(playerHandicap*awardedHandicap, slopeRating, courseRating, par)
+
+	return getStrokesForHole(playingHandicap, holeHandicapIndex)
+}
+
+func getCourseHandicap(handicapIndex, slopeRating, courseRating, par float64) int {
 	sr113 := slopeRating / 113.0
 	crPar := courseRating - par
-	index := playerHandicap * awardedHandicap
 
-	courseHandicap := int(math.Round(index*sr113 + crPar))
+	return int(math.Round(handicapIndex*sr113 + crPar))
+}
 
-	if courseHandicap <= 0 || holeHandicapIndex < 1 || holeHandicapIndex > 18 {
+func getStrokesForHole(playingHandicap int, holeHandicapIndex int) int {
+	if playingHandicap <= 0 || holeHandicapIndex < 1 || holeHandicapIndex > 18 {
 		return 0
 	}
 
 	strokes := 0
-	if courseHandicap >= holeHandicapIndex {
+	if playingHandicap >= holeHandicapIndex {
 		strokes = 1
 	}
-	if courseHandicap > 18 && holeHandicapIndex <= (courseHandicap-18) {
+	if playingHandicap > 18 && holeHandicapIndex <= (playingHandicap-18) {
 		strokes++
 	}
 
 	return strokes
 }
 
+type strokeAllocation struct {
+	models.TeamPlayerHandicap
+	Strokes map[int]int
+}
+
+// allocateStrokes works out a player's handicaps for their tee and the strokes
+// they receive on every hole, so they can be frozen when a round starts.
+func allocateStrokes(course *models.CourseWithData, tee string, handicapIndex float64, awardedHandicap float64) strokeAllocation {
+	courseTeeData := course.Meta.Tees[tee]
+	slopeRating := float64(courseTeeData.SlopeRating)
+	par := float64(courseTeeData.Par)
+
+	allocation := strokeAllocation{
+		TeamPlayerHandicap: models.TeamPlayerHandicap{
+			HandicapIndex:   handicapIndex,
+			CourseHandicap:  getCourseHandicap(handicapIndex, slopeRating, courseTeeData.CourseRating, par),
+			PlayingHandicap: getCourseHandicap(handicapIndex*awardedHandicap, slopeRating, courseTeeData.CourseRating, par),
+		},
+		Strokes: make(map[int]int),
+	}
+
+	for _, hole := range course.Meta.Holes {
+		allocation.Strokes[hole.Number] = getStrokesForHole(allocation.PlayingHandicap, hole.Handicap)
+	}
+
+	return allocation
+}
+
+// exportFileName builds a download name like "spring_classic_players.csv"
+// from the tournament name.
+func exportFileName(tournamentName string, suffix string) string {
+	return fmt.Sprintf("%s_%s", strings.ToLower(strings.Join(strings.Split(tournamentName, " "), "_")), suffix)
+}
+
 func joinNames(names []string) string {
 	sort.Strings(names)
 	return strings.Join(names, ", ")
@@ -43,3 +85,88 @@ func getHoleDataMap(course *models.CourseWithData) models.CourseHoleDataMap {
 
 	return courseHoleMap
 }
+
+// applyStrokeHoles sets StrokeHole on every hole, preferring the allocation
+// frozen when the team started and falling back to the live handicap index.
+func applyStrokeHoles(course *models.CourseWithData, holes *[]models.HoleWithMetadata) {
+	for index, hole := range *holes {
+		if hole.StrokesFrozen {
+			hole.StrokeHole = hole.Strokes
+			(*holes)[index] = hole
+			continue
+		}
+
+		holeIndex := (course.Meta.Holes)[hole.Number-1].Handicap
+		courseTeeData := course.Meta.Tees[hole.Tee]
+
+		hole.StrokeHole = getStrokeHole(
+			hole.PlayerHandicap,
+			float64(courseTeeData.SlopeRating),
+			courseTeeData.CourseRating,
+			float64(courseTeeData.Par),
+			hole.AwardedTournamentHandicap,
+			holeIndex,
+		)
+
+		(*holes)[index] = hole
+	}
+}
+
+type RankedRow struct {
+	Position int  `json:"position"`
+	Tied     bool `json:"tied"`
+	LeaderboardRow
+}
+
+// rankLeaderboardRows orders rows by the given score, lowest first, and gives
+// tied rows the same position.
+func rankLeaderboardRows(rows []LeaderboardRow, score func(LeaderboardRow) int) []RankedRow {
+	sorted := make([]LeaderboardRow, len(rows))
+	copy(sorted, rows)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return score(sorted[i]) < score(sorted[j])
+	})
+
+	ranked := make([]RankedRow, len(sorted))
+	for i, row := range sorted {
+		ranked[i] = RankedRow{Position: i + 1, LeaderboardRow: row}
+		if i > 0 && score(row) == score(sorted[i-1]) {
+			ranked[i].Position = ranked[i-1].Position
+			ranked[i].Tied = true
+			ranked[i-1].Tied = true
+		}
+	}
+
+	return ranked
+}
+
+func netScore(row LeaderboardRow) int {
+	return row.Net
+}
+
+func grossScore(row LeaderboardRow) int {
+	return row.Gross
+}
+
+func formatPosition(row RankedRow) string {
+	if row.Tied {
+		return fmt.Sprintf("T%d", row.Position)
+	}
+	return fmt.Sprintf("%d", row.Position)
+}
+
+func formatToPar(score int) string {
+	if score == 0 {
+		return "E"
+	}
+	if score > 0 {
+		return fmt.Sprintf("+%d", score)
+	}
+	return fmt.Sprintf("%d", score)
+}
+
+// roundTo rounds a statistic to the given number of decimal places.
+func roundTo(value float64, places int) float64 {
+	scale := math.Pow(10, float64(places))
+	return math.Round(value*scale) / scale
+}
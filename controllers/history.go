@@ -0,0 +1,453 @@
+package controllers
+
+import (
+	"net/http"
+	"sort"
+
+	"github.com/patrick-salvatore/tournament-live-scoring/models"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+type PlayerRoundHole struct {
+	Number  int    `json:"number"`
+	Par     int    `json:"par"`
+	Score   string `json:"score"`
+	Gross   int    `json:"gross"`
+	Strokes int    `json:"strokes"`
+	Net     int    `json:"net"`
+}
+
+type PlayerRound struct {
+	models.PlayerTournament
+	Position   string            `json:"position"`
+	Gross      int               `json:"gross"`
+	Net        int               `json:"net"`
+	GrossToPar int               `json:"grossToPar"`
+	NetToPar   int               `json:"netToPar"`
+	Thru       int               `json:"thru"`
+	Complete   bool              `json:"complete"`
+	Holes      []PlayerRoundHole `json:"holes"`
+
+	place int
+}
+
+type ParTypeStats struct {
+	Par     int     `json:"par"`
+	Holes   int     `json:"holes"`
+	Average float64 `json:"average"`
+	ToPar   float64 `json:"toPar"`
+}
+
+type HoleAverage struct {
+	CourseId string  `json:"courseId"`
+	Number   int     `json:"number"`
+	Par      int     `json:"par"`
+	Played   int     `json:"played"`
+	Average  float64 `json:"average"`
+}
+
+// ScoreDistribution counts holes by gross score relative to par.
+type ScoreDistribution struct {
+	EaglesOrBetter int `json:"eaglesOrBetter"`
+	Birdies        int `json:"birdies"`
+	Pars           int `json:"pars"`
+	Bogeys         int `json:"bogeys"`
+	DoubleBogeys   int `json:"doubleBogeys"`
+	Worse          int `json:"worse"`
+}
+
+func (d *ScoreDistribution) add(toPar int) {
+	switch {
+	case toPar <= -2:
+		d.EaglesOrBetter++
+	case toPar == -1:
+		d.Birdies++
+	case toPar == 0:
+		d.Pars++
+	case toPar == 1:
+		d.Bogeys++
+	case toPar == 2:
+		d.DoubleBogeys++
+	default:
+		d.Worse++
+	}
+}
+
+type PlayerStats struct {
+	PlayerId          string            `json:"playerId"`
+	Rounds            int               `json:"rounds"`
+	CompletedRounds   int               `json:"completedRounds"`
+	Wins              int               `json:"wins"`
+	ScoringAverage    float64           `json:"scoringAverage"`
+	NetScoringAverage float64           `json:"netScoringAverage"`
+	HolesPlayed       int               `json:"holesPlayed"`
+	ByPar             []ParTypeStats    `json:"byPar"`
+	Distribution      ScoreDistribution `json:"distribution"`
+	Holes             []HoleAverage     `json:"holes"`
+}
+
+type HeadToHeadMeeting struct {
+	TournamentId   string `json:"tournamentId"`
+	TournamentName string `json:"tournamentName"`
+	Date           string `json:"date"`
+	Holes          int    `json:"holes"`
+	PlayerScore    int    `json:"playerScore"`
+	OpponentScore  int    `json:"opponentScore"`
+	Result         string `json:"result"`
+}
+
+type HeadToHead struct {
+	PlayerId   string              `json:"playerId"`
+	OpponentId string              `json:"opponentId"`
+	Scoring    string              `json:"scoring"`
+	Wins       int                 `json:"wins"`
+	Losses     int                 `json:"losses"`
+	Halves     int                 `json:"halves"`
+	Meetings   []HeadToHeadMeeting `json:"meetings"`
+}
+
+type PlayerRecord struct {
+	PlayerId   string `json:"playerId"`
+	PlayerName string `json:"playerName"`
+	Rounds     int    `json:"rounds"`
+	Wins       int    `json:"wins"`
+	TopThree   int    `json:"topThree"`
+}
+
+// HandleGetPlayerRounds lists a player's rounds, newest first. Only final and
+// archived tournaments count unless ?includeLive=true.
+func (pc *PlayersController) HandleGetPlayerRounds(e *core.RequestEvent) error {
+	playerId := e.Request.PathValue("playerId")
+	includeLive := e.Request.URL.Query().Get("includeLive") == "true"
+
+	rounds, err := pc.getPlayerRounds(playerId, includeLive)
+	if err != nil {
+		return e.Error(http.StatusInternalServerError, err.Error(), nil)
+	}
+
+	return e.JSON(http.StatusOK, rounds)
+}
+
+// HandleGetPlayerStats summarises a player's finished rounds from their hole
+// scores. ?courseId= limits it to one course.
+func (pc *PlayersController) HandleGetPlayerStats(e *core.RequestEvent) error {
+	playerId := e.Request.PathValue("playerId")
+	courseId := e.Request.URL.Query().Get("courseId")
+	includeLive := e.Request.URL.Query().Get("includeLive") == "true"
+
+	_, err := models.GetPlayerById(pc.db, playerId)
+	if err != nil {
+		return e.NotFoundError(err.Error(), playerId)
+	}
+
+	rounds, err := pc.getPlayerRounds(playerId, includeLive)
+	if err != nil {
+		return e.Error(http.StatusInternalServerError, err.Error(), nil)
+	}
+
+	stats := PlayerStats{
+		PlayerId: playerId,
+		ByPar:    []ParTypeStats{},
+		Holes:    []HoleAverage{},
+	}
+
+	type holeKey struct {
+		courseId string
+		number   int
+	}
+	byPar := make(map[int]*ParTypeStats)
+	holeTotals := make(map[holeKey]*HoleAverage)
+	var grossTotal, netTotal int
+	for _, round := range rounds {
+		if len(courseId) > 0 && round.CourseId != courseId {
+			continue
+		}
+		if round.Thru == 0 {
+			continue
+		}
+
+		stats.Rounds++
+		if round.place == 1 {
+			stats.Wins++
+		}
+		if round.Complete {
+			stats.CompletedRounds++
+			grossTotal += round.Gross
+			netTotal += round.Net
+		}
+
+		for _, hole := range round.Holes {
+			if len(hole.Score) == 0 {
+				continue
+			}
+
+			stats.HolesPlayed++
+
+			parStats, ok := byPar[hole.Par]
+			if !ok {
+				parStats = &ParTypeStats{Par: hole.Par}
+				byPar[hole.Par] = parStats
+			}
+			parStats.Holes++
+			parStats.Average += float64(hole.Gross)
+
+			key := holeKey{round.CourseId, hole.Number}
+			holeAverage, ok := holeTotals[key]
+			if !ok {
+				holeAverage = &HoleAverage{CourseId: round.CourseId, Number: hole.Number, Par: hole.Par}
+				holeTotals[key] = holeAverage
+			}
+			holeAverage.Played++
+			holeAverage.Average += float64(hole.Gross)
+
+			stats.Distribution.add(hole.Gross - hole.Par)
+		}
+	}
+
+	if stats.CompletedRounds > 0 {
+		stats.ScoringAverage = roundTo(float64(grossTotal)/float64(stats.CompletedRounds), 1)
+		stats.NetScoringAverage = roundTo(float64(netTotal)/float64(stats.CompletedRounds), 1)
+	}
+
+	for _, parStats := range byPar {
+		parStats.Average = parStats.Average / float64(parStats.Holes)
+		parStats.ToPar = roundTo(parStats.Average-float64(parStats.Par), 2)
+		parStats.Average = roundTo(parStats.Average, 2)
+		stats.ByPar = append(stats.ByPar, *parStats)
+	}
+	sort.Slice(stats.ByPar, func(i, j int) bool {
+		return stats.ByPar[i].Par < stats.ByPar[j].Par
+	})
+
+	for _, holeAverage := range holeTotals {
+		holeAverage.Average = roundTo(holeAverage.Average/float64(holeAverage.Played), 2)
+		stats.Holes = append(stats.Holes, *holeAverage)
+	}
+	sort.Slice(stats.Holes, func(i, j int) bool {
+		if stats.Holes[i].CourseId != stats.Holes[j].CourseId {
+			return stats.Holes[i].CourseId < stats.Holes[j].CourseId
+		}
+		return stats.Holes[i].Number < stats.Holes[j].Number
+	})
+
+	return e.JSON(http.StatusOK, stats)
+}
+
+// HandleGetHeadToHead compares two players in every finished tournament they
+// both played, over the holes both of them scored. ?scoring=gross compares
+// gross scores instead of net.
+func (pc *PlayersController) HandleGetHeadToHead(e *core.RequestEvent) error {
+	playerId := e.Request.PathValue("playerId")
+	opponentId := e.Request.PathValue("opponentId")
+	scoring := e.Request.URL.Query().Get("scoring")
+	if scoring == "" {
+		scoring = "net"
+	}
+	if scoring != "net" && scoring != "gross" {
+		return e.BadRequestError("scoring must be net or gross", nil)
+	}
+
+	playerRounds, err := pc.getPlayerRounds(playerId, false)
+	if err != nil {
+		return e.Error(http.StatusInternalServerError, err.Error(), nil)
+	}
+	opponentRounds, err := pc.getPlayerRounds(opponentId, false)
+	if err != nil {
+		return e.Error(http.StatusInternalServerError, err.Error(), nil)
+	}
+
+	opponentByTournament := make(map[string]PlayerRound)
+	for _, round := range opponentRounds {
+		opponentByTournament[round.TournamentId] = round
+	}
+
+	record := HeadToHead{
+		PlayerId:   playerId,
+		OpponentId: opponentId,
+		Scoring:    scoring,
+		Meetings:   []HeadToHeadMeeting{},
+	}
+	for _, round := range playerRounds {
+		opponent, ok := opponentByTournament[round.TournamentId]
+		if !ok {
+			continue
+		}
+
+		opponentHoles := make(map[int]PlayerRoundHole)
+		for _, hole := range opponent.Holes {
+			if len(hole.Score) > 0 {
+				opponentHoles[hole.Number] = hole
+			}
+		}
+
+		meeting := HeadToHeadMeeting{
+			TournamentId:   round.TournamentId,
+			TournamentName: round.TournamentName,
+			Date:           round.Created,
+		}
+		for _, hole := range round.Holes {
+			opponentHole, ok := opponentHoles[hole.Number]
+			if !ok || len(hole.Score) == 0 {
+				continue
+			}
+
+			meeting.Holes++
+			if scoring == "gross" {
+				meeting.PlayerScore += hole.Gross
+				meeting.OpponentScore += opponentHole.Gross
+			} else {
+				meeting.PlayerScore += hole.Net
+				meeting.OpponentScore += opponentHole.Net
+			}
+		}
+		if meeting.Holes == 0 {
+			continue
+		}
+
+		switch {
+		case meeting.PlayerScore < meeting.OpponentScore:
+			meeting.Result = "won"
+			record.Wins++
+		case meeting.PlayerScore > meeting.OpponentScore:
+			meeting.Result = "lost"
+			record.Losses++
+		default:
+			meeting.Result = "halved"
+			record.Halves++
+		}
+		record.Meetings = append(record.Meetings, meeting)
+	}
+
+	return e.JSON(http.StatusOK, record)
+}
+
+// HandleGetPlayerRecords ranks players by individual net wins in finished
+// tournaments created between ?from= and ?to= (dates, either optional).
+func (pc *PlayersController) HandleGetPlayerRecords(e *core.RequestEvent) error {
+	from := e.Request.URL.Query().Get("from")
+	to := e.Request.URL.Query().Get("to")
+
+	tournaments, err := models.GetFinishedTournaments(pc.db, from, to)
+	if err != nil {
+		return e.Error(http.StatusInternalServerError, err.Error(), nil)
+	}
+
+	recordsByPlayer := make(map[string]*PlayerRecord)
+	for _, tournament := range *tournaments {
+		snapshot, err := leaderboards.Get(pc.db, tournament.Id, true)
+		if err != nil {
+			return e.Error(http.StatusInternalServerError, err.Error(), nil)
+		}
+
+		for _, row := range rankLeaderboardRows(snapshot.Rows, netScore) {
+			if row.Thru == 0 {
+				continue
+			}
+
+			record, ok := recordsByPlayer[row.Id]
+			if !ok {
+				record = &PlayerRecord{PlayerId: row.Id, PlayerName: row.TeamName}
+				recordsByPlayer[row.Id] = record
+			}
+
+			record.Rounds++
+			if row.Position == 1 {
+				record.Wins++
+			}
+			if row.Position <= 3 {
+				record.TopThree++
+			}
+		}
+	}
+
+	records := []PlayerRecord{}
+	for _, record := range recordsByPlayer {
+		records = append(records, *record)
+	}
+	sort.Slice(records, func(i, j int) bool {
+		if records[i].Wins != records[j].Wins {
+			return records[i].Wins > records[j].Wins
+		}
+		if records[i].TopThree != records[j].TopThree {
+			return records[i].TopThree > records[j].TopThree
+		}
+		return records[i].PlayerName < records[j].PlayerName
+	})
+
+	return e.JSON(http.StatusOK, records)
+}
+
+func (pc *PlayersController) getPlayerRounds(playerId string, includeLive bool) ([]PlayerRound, error) {
+	tournaments, err := models.GetPlayerTournaments(pc.db, playerId)
+	if err != nil {
+		return nil, err
+	}
+
+	courses := make(map[string]*models.CourseWithData)
+	rounds := []PlayerRound{}
+	for _, tournament := range *tournaments {
+		finished := tournament.Status == models.TOURNAMENT_STATUS_FINAL || tournament.Status == models.TOURNAMENT_STATUS_ARCHIVED
+		if !finished && !includeLive {
+			continue
+		}
+
+		course, ok := courses[tournament.CourseId]
+		if !ok {
+			course, err = models.GetCourseByTournamentId(pc.db, tournament.TournamentId)
+			if err != nil {
+				return nil, err
+			}
+			courses[tournament.CourseId] = course
+		}
+		courseHoles := getHoleDataMap(course)
+
+		holes, err := models.GetPlayerHoles(pc.db, tournament.TournamentId, playerId)
+		if err != nil {
+			return nil, err
+		}
+		applyStrokeHoles(course, holes)
+
+		round := PlayerRound{
+			PlayerTournament: tournament,
+			Holes:            []PlayerRoundHole{},
+		}
+		for _, hole := range *holes {
+			par := courseHoles[hole.Number].Par
+			roundHole := PlayerRoundHole{
+				Number:  hole.Number,
+				Par:     par,
+				Score:   hole.Score,
+				Strokes: hole.StrokeHole,
+			}
+
+			if gross, ok := holeGrossScore(hole.Score, par); ok {
+				roundHole.Gross = gross
+				roundHole.Net = gross - hole.StrokeHole
+
+				round.Thru++
+				round.Gross += gross
+				round.Net += roundHole.Net
+				round.GrossToPar += gross - par
+				round.NetToPar += roundHole.Net - par
+			}
+
+			round.Holes = append(round.Holes, roundHole)
+		}
+		round.Complete = len(round.Holes) > 0 && round.Thru == len(round.Holes)
+
+		snapshot, err := leaderboards.Get(pc.db, tournament.TournamentId, true)
+		if err != nil {
+			return nil, err
+		}
+		for _, row := range rankLeaderboardRows(snapshot.Rows, netScore) {
+			if row.Id == playerId && row.Thru > 0 {
+				round.Position = formatPosition(row)
+				round.place = row.Position
+			}
+		}
+
+		rounds = append(rounds, round)
+	}
+
+	return rounds, nil
+}
@@ -2,6 +2,7 @@ package controllers
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 
@@ -32,29 +33,70 @@ func (hc *HolesController) HandleUpdateTeamHoleScores(e *core.RequestEvent) erro
 		return e.BadRequestError(err.Error(), nil)
 	}
 
+	teamId := e.Request.Context().Value(TeamId).(string)
+	tournamentId := e.Request.Context().Value(TournamentId).(string)
+
 	var updatedHoles []*models.HoleUpdate
 	err = hc.app.RunInTransaction(func(txApp core.App) error {
 		for _, holeUpdate := range holesPayload {
+			if holeUpdate.Id == nil {
+				return fmt.Errorf("%w: missing hole id", errHoleNotOnTeam)
+			}
+
+			err := checkHoleOnTeam(txApp.DB(), tournamentId, teamId, *holeUpdate.Id)
+			if err != nil {
+				return err
+			}
 
 			updatedHole, err := models.UpdateHoleForPlayer(txApp.DB(), *holeUpdate.Id, holeUpdate)
 			if err != nil {
 				return fmt.Errorf("failed to update hole %s: %w", *holeUpdate.Id, err)
 			}
+
+			// the update may move the hole, so it has to still be the team's
+			err = checkHoleOnTeam(txApp.DB(), tournamentId, teamId, *holeUpdate.Id)
+			if err != nil {
+				return err
+			}
 			updatedHoles = append(updatedHoles, updatedHole)
 		}
-		return nil
+
+		// bracket matches played in this round close as soon as the
+		// scores decide them
+		return settleBracketMatches(txApp.DB(), tournamentId)
 	})
 
+	if errors.Is(err, errHoleNotOnTeam) {
+		return e.ForbiddenError(err.Error(), nil)
+	}
 	if err != nil {
 		return e.InternalServerError(err.Error(), nil)
 	}
 
+	leaderboards.InvalidateTeam(tournamentId, teamId)
+
 	return e.JSON(http.StatusOK, map[string]interface{}{
 		"updatedHoles": updatedHoles,
 	})
 
 }
 
+// errHoleNotOnTeam stops a team writing holes that aren't its own, which
+// would also leave the owning team's cached leaderboard stale.
+var errHoleNotOnTeam = errors.New("hole does not belong to the team")
+
+func checkHoleOnTeam(db dbx.Builder, tournamentId string, teamId string, holeId string) error {
+	holeTeamId, err := models.GetHoleTeamId(db, tournamentId, holeId)
+	if err != nil {
+		return err
+	}
+	if holeTeamId != teamId {
+		return fmt.Errorf("%w: %s", errHoleNotOnTeam, holeId)
+	}
+
+	return nil
+}
+
 func (hc *HolesController) HandleGetHoles(e *core.RequestEvent) error {
 	tournamentId := e.Request.URL.Query().Get("tournamentId")
 	if len(tournamentId) > 0 {
@@ -80,7 +122,10 @@ func (hc *HolesController) HandleGetHoles(e *core.RequestEvent) error {
 
 	playerId := e.Request.URL.Query().Get("playerId")
 	if len(playerId) > 0 {
-		data, err := models.GetPlayerHoles(hc.db, playerId)
+		// players keep their holes from earlier tournaments, so only show
+		// the ones from the tournament the team is playing
+		tournamentId, _ := e.Request.Context().Value(TournamentId).(string)
+		data, err := models.GetPlayerHoles(hc.db, tournamentId, playerId)
 		if err != nil {
 			return e.Error(http.StatusInternalServerError, err.Error(), "GetPlayerHoles")
 		}
@@ -126,22 +171,7 @@ func getHolesWithStroke(db dbx.Builder, holes *[]models.HoleWithMetadata) (*[]mo
 		return nil, err
 	}
 
-	for index, hole := range *holes {
-		playerTee := hole.Tee
-		holeIndex := (course.Meta.Holes)[hole.Number-1].Handicap
-		courseTeeData := course.Meta.Tees[playerTee]
-
-		hole.StrokeHole = getStrokeHole(
-			hole.PlayerHandicap,
-			float64(courseTeeData.SlopeRating),
-			courseTeeData.CourseRating,
-			float64(courseTeeData.Par),
-			hole.AwardedTournamentHandicap,
-			holeIndex,
-		)
-
-		(*holes)[index] = hole
-	}
+	applyStrokeHoles(course, holes)
 
 	return holes, nil
 }
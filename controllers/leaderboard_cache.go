@@ -0,0 +1,381 @@
+package controllers
+
+import (
+	"crypto/sha1"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/patrick-salvatore/tournament-live-scoring/models"
+	"github.com/pocketbase/dbx"
+)
+
+// leaderboards is shared by every controller that reads or writes scores so
+// a write in one handler is visible to the next leaderboard read.
+var leaderboards = NewLeaderboardCache()
+
+type LeaderboardSnapshot struct {
+	Rows []LeaderboardRow
+	Body []byte
+	ETag string
+}
+
+type tournamentLeaderboard struct {
+	course      *models.CourseWithData
+	courseHoles models.CourseHoleDataMap
+	coursePar   int
+	teamScoring teamScoring
+	// teamBall tournaments score each team from its own holes, with no
+	// individual rows.
+	teamBall bool
+	// finished boards can't change any more and aren't cached.
+	finished bool
+
+	teamRows   map[string]LeaderboardRow
+	playerRows map[string][]LeaderboardRow
+	dirtyTeams map[string]bool
+
+	// contestLeaders maps player and team ids to the contests they lead.
+	contestLeaders map[string][]string
+	dirtyContests  bool
+
+	team       *LeaderboardSnapshot
+	individual *LeaderboardSnapshot
+}
+
+// LeaderboardCache keeps one computed leaderboard per tournament. Score
+// writes mark a single team dirty and only that team's rows are rebuilt on
+// the next read. Each tournament has its own lock so a slow load only holds
+// up reads of that tournament. Final and archived tournaments are computed
+// on demand and never kept, so the cache only grows with live events.
+type LeaderboardCache struct {
+	mu          sync.Mutex
+	tournaments map[string]*leaderboardEntry
+}
+
+type leaderboardEntry struct {
+	mu    sync.Mutex
+	board *tournamentLeaderboard
+}
+
+func NewLeaderboardCache() *LeaderboardCache {
+	return &LeaderboardCache{tournaments: make(map[string]*leaderboardEntry)}
+}
+
+// Invalidate drops everything cached for a tournament, used when the course,
+// handicap allowance, team scoring, rosters or status change.
+func (lc *LeaderboardCache) Invalidate(tournamentId string) {
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+
+	delete(lc.tournaments, tournamentId)
+}
+
+// InvalidateTeam marks a team's aggregates as stale after its scores change.
+func (lc *LeaderboardCache) InvalidateTeam(tournamentId string, teamId string) {
+	lc.update(tournamentId, func(board *tournamentLeaderboard) {
+		board.dirtyTeams[teamId] = true
+	})
+}
+
+// InvalidateContests reloads contest leaders after an entry is made or
+// changed.
+func (lc *LeaderboardCache) InvalidateContests(tournamentId string) {
+	lc.update(tournamentId, func(board *tournamentLeaderboard) {
+		board.dirtyContests = true
+	})
+}
+
+func (lc *LeaderboardCache) update(tournamentId string, mark func(board *tournamentLeaderboard)) {
+	lc.mu.Lock()
+	entry, ok := lc.tournaments[tournamentId]
+	lc.mu.Unlock()
+	if !ok {
+		return
+	}
+
+	entry.mu.Lock()
+	defer entry.mu.Unlock()
+
+	if entry.board == nil {
+		return
+	}
+	mark(entry.board)
+	entry.board.team = nil
+	entry.board.individual = nil
+}
+
+func (lc *LeaderboardCache) Get(db dbx.Builder, tournamentId string, individuals bool) (*LeaderboardSnapshot, error) {
+	lc.mu.Lock()
+	entry, ok := lc.tournaments[tournamentId]
+	if !ok {
+		entry = &leaderboardEntry{}
+		lc.tournaments[tournamentId] = entry
+	}
+	lc.mu.Unlock()
+
+	entry.mu.Lock()
+	defer entry.mu.Unlock()
+
+	if entry.board == nil {
+		board, err := loadTournamentLeaderboard(db, tournamentId)
+		if err != nil {
+			lc.evict(tournamentId, entry)
+			return nil, err
+		}
+
+		if board.finished {
+			lc.evict(tournamentId, entry)
+			return board.snapshot(individuals)
+		}
+		entry.board = board
+	}
+
+	board := entry.board
+	for teamId := range board.dirtyTeams {
+		err := board.recomputeTeam(db, tournamentId, teamId)
+		if err != nil {
+			return nil, err
+		}
+		delete(board.dirtyTeams, teamId)
+	}
+
+	if board.dirtyContests {
+		err := board.loadContestLeaders(db, tournamentId)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return board.snapshot(individuals)
+}
+
+// evict removes an entry unless it has already been replaced.
+func (lc *LeaderboardCache) evict(tournamentId string, entry *leaderboardEntry) {
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+
+	if lc.tournaments[tournamentId] == entry {
+		delete(lc.tournaments, tournamentId)
+	}
+}
+
+func (tl *tournamentLeaderboard) snapshot(individuals bool) (*LeaderboardSnapshot, error) {
+	if individuals {
+		if tl.individual == nil {
+			rows := []LeaderboardRow{}
+			for _, teamRows := range tl.playerRows {
+				for _, row := range teamRows {
+					row.Contests = tl.contestLeaders[row.Id]
+					rows = append(rows, row)
+				}
+			}
+			snapshot, err := newLeaderboardSnapshot(rows)
+			if err != nil {
+				return nil, err
+			}
+			tl.individual = snapshot
+		}
+
+		return tl.individual, nil
+	}
+
+	if tl.team == nil {
+		rows := []LeaderboardRow{}
+		for _, row := range tl.teamRows {
+			row.Contests = tl.contestLeaders[row.Id]
+			rows = append(rows, row)
+		}
+		snapshot, err := newLeaderboardSnapshot(rows)
+		if err != nil {
+			return nil, err
+		}
+		tl.team = snapshot
+	}
+
+	return tl.team, nil
+}
+
+func loadTournamentLeaderboard(db dbx.Builder, tournamentId string) (*tournamentLeaderboard, error) {
+	tournament, err := models.GetTournamentById(db, tournamentId)
+	if err != nil {
+		return nil, err
+	}
+
+	course, err := models.GetCourseByTournamentId(db, tournamentId)
+	if err != nil {
+		return nil, err
+	}
+
+	teams, err := models.GetTeamsByTournamentId(db, tournamentId)
+	if err != nil {
+		return nil, err
+	}
+	teamIds := []string{}
+	for _, team := range *teams {
+		teamIds = append(teamIds, team.Id)
+	}
+
+	entry := &tournamentLeaderboard{
+		course:      course,
+		courseHoles: getHoleDataMap(course),
+		teamScoring: newTeamScoring(tournament),
+		teamBall:    tournament.PlaysTeamBall(),
+		finished:    tournament.Status == models.TOURNAMENT_STATUS_FINAL || tournament.Status == models.TOURNAMENT_STATUS_ARCHIVED,
+		teamRows:    make(map[string]LeaderboardRow),
+		playerRows:  make(map[string][]LeaderboardRow),
+		dirtyTeams:  make(map[string]bool),
+	}
+
+	if entry.teamBall {
+		teamHoles, err := models.GetTournamentTeamHoles(db, tournamentId, teamIds)
+		if err != nil {
+			return nil, err
+		}
+
+		holesByTeam := make(map[string][]models.TeamHole)
+		for _, hole := range *teamHoles {
+			holesByTeam[hole.TeamId] = append(holesByTeam[hole.TeamId], hole)
+		}
+
+		for _, team := range *teams {
+			entry.setTeamBallHoles(team.Id, team.Name, holesByTeam[team.Id])
+		}
+	} else {
+		holes, err := models.GetTournamentHoles(db, tournamentId, teamIds)
+		if err != nil {
+			return nil, err
+		}
+
+		holesByTeam := make(map[string][]models.HoleWithMetadata)
+		for _, hole := range *holes {
+			holesByTeam[hole.TeamId] = append(holesByTeam[hole.TeamId], hole)
+		}
+
+		for teamId, teamHoles := range holesByTeam {
+			entry.setTeamHoles(teamId, teamHoles)
+		}
+	}
+
+	err = entry.loadContestLeaders(db, tournamentId)
+	if err != nil {
+		return nil, err
+	}
+
+	return entry, nil
+}
+
+func (tl *tournamentLeaderboard) loadContestLeaders(db dbx.Builder, tournamentId string) error {
+	results, err := getContestResults(db, tournamentId, false)
+	if err != nil {
+		return err
+	}
+
+	tl.contestLeaders = make(map[string][]string)
+	for _, result := range results {
+		if result.Leader == nil {
+			continue
+		}
+		tl.contestLeaders[result.Leader.PlayerId] = append(tl.contestLeaders[result.Leader.PlayerId], result.Name)
+		tl.contestLeaders[result.Leader.TeamId] = append(tl.contestLeaders[result.Leader.TeamId], result.Name)
+	}
+	tl.dirtyContests = false
+
+	return nil
+}
+
+func (tl *tournamentLeaderboard) recomputeTeam(db dbx.Builder, tournamentId string, teamId string) error {
+	if tl.teamBall {
+		team, err := models.GetTeamById(db, teamId)
+		if err != nil {
+			return err
+		}
+
+		holes, err := models.GetTournamentTeamHoles(db, tournamentId, []string{teamId})
+		if err != nil {
+			return err
+		}
+
+		tl.setTeamBallHoles(teamId, team.Name, *holes)
+
+		return nil
+	}
+
+	holes, err := models.GetTournamentHoles(db, tournamentId, []string{teamId})
+	if err != nil {
+		return err
+	}
+
+	tl.setTeamHoles(teamId, *holes)
+
+	return nil
+}
+
+func (tl *tournamentLeaderboard) setTeamHoles(teamId string, holes []models.HoleWithMetadata) {
+	delete(tl.teamRows, teamId)
+	delete(tl.playerRows, teamId)
+
+	if len(holes) == 0 {
+		return
+	}
+
+	applyStrokeHoles(tl.course, &holes)
+
+	if tl.coursePar == 0 {
+		tl.coursePar = tl.course.Meta.Tees[holes[0].Tee].Par
+	}
+
+	for _, row := range getTeamLeaderboard(&holes, tl.courseHoles, tl.coursePar, tl.teamScoring) {
+		tl.teamRows[teamId] = row
+	}
+	tl.playerRows[teamId] = getIndividualLeaderboard(&holes, tl.courseHoles, tl.coursePar)
+}
+
+func (tl *tournamentLeaderboard) setTeamBallHoles(teamId string, teamName string, holes []models.TeamHole) {
+	delete(tl.teamRows, teamId)
+
+	if len(holes) == 0 {
+		return
+	}
+
+	if tl.coursePar == 0 {
+		for _, hole := range tl.courseHoles {
+			tl.coursePar += hole.Par
+		}
+	}
+
+	tl.teamRows[teamId] = getTeamBallLeaderboardRow(teamId, teamName, holes, tl.courseHoles, tl.coursePar)
+}
+
+func newLeaderboardSnapshot(rows []LeaderboardRow) (*LeaderboardSnapshot, error) {
+	sortLeaderboardRows(rows)
+
+	body, err := json.Marshal(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	return &LeaderboardSnapshot{
+		Rows: rows,
+		Body: body,
+		ETag: fmt.Sprintf(`"%x"`, sha1.Sum(body)),
+	}, nil
+}
+
+// sortLeaderboardRows orders rows by net, then gross, so that the same
+// scores always serialize to the same body and ETag.
+func sortLeaderboardRows(rows []LeaderboardRow) {
+	sort.SliceStable(rows, func(i, j int) bool {
+		if rows[i].Net != rows[j].Net {
+			return rows[i].Net < rows[j].Net
+		}
+		if rows[i].Gross != rows[j].Gross {
+			return rows[i].Gross < rows[j].Gross
+		}
+		if rows[i].TeamName != rows[j].TeamName {
+			return rows[i].TeamName < rows[j].TeamName
+		}
+		return rows[i].Id < rows[j].Id
+	})
+}
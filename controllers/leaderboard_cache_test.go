@@ -0,0 +1,299 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/patrick-salvatore/tournament-live-scoring/models"
+)
+
+// BenchmarkLeaderboard144 measures a full 144 player field: 72 teams of two
+// with every hole scored.
+func BenchmarkLeaderboard144(b *testing.B) {
+	app := newTestApp(b)
+	db := app.DB()
+
+	teams := [][]models.Player{}
+	for i := range 72 {
+		teams = append(teams, []models.Player{
+			{Name: fmt.Sprintf("Player %d", i*2+1), Handicap: float64(i % 25)},
+			{Name: fmt.Sprintf("Player %d", i*2+2), Handicap: float64((i * 7) % 30)},
+		})
+	}
+	tournament, created := newTestTournament(b, app, models.CreateTournamentData{
+		Name:            "Benchmark Open",
+		TeamCount:       2,
+		AwardedHandicap: 1,
+		TeamScoring:     models.TEAM_SCORING_NET,
+		BestScores:      "1-2",
+	}, teams)
+
+	_, err := db.
+		NewQuery("UPDATE holes SET score = CAST(3 + (abs(random()) % 4) AS TEXT) WHERE tournament_id = {:tournament_id}").
+		Bind(map[string]any{"tournament_id": tournament.Id}).
+		Execute()
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	course, err := models.GetCourseByTournamentId(db, tournament.Id)
+	if err != nil {
+		b.Fatal(err)
+	}
+	courseHoles := getHoleDataMap(course)
+	coursePar := course.Meta.Tees["white"].Par
+
+	teamIds := []string{}
+	for _, team := range created {
+		teamIds = append(teamIds, team.Id)
+	}
+	holes, err := models.GetTournamentHoles(db, tournament.Id, teamIds)
+	if err != nil {
+		b.Fatal(err)
+	}
+	if len(*holes) != 144*len(course.Meta.Holes) {
+		b.Fatalf("expected %d holes, got %d", 144*len(course.Meta.Holes), len(*holes))
+	}
+	applyStrokeHoles(course, holes)
+
+	holesByTeam := make(map[string][]models.HoleWithMetadata)
+	for _, hole := range *holes {
+		holesByTeam[hole.TeamId] = append(holesByTeam[hole.TeamId], hole)
+	}
+
+	b.Run("getTeamLeaderboard", func(b *testing.B) {
+		scoring := newTeamScoring(tournament)
+		for range b.N {
+			getTeamLeaderboard(holes, courseHoles, coursePar, scoring)
+		}
+	})
+
+	b.Run("setTeamHoles", func(b *testing.B) {
+		board := &tournamentLeaderboard{
+			course:      course,
+			courseHoles: courseHoles,
+			teamScoring: newTeamScoring(tournament),
+			teamRows:    make(map[string]LeaderboardRow),
+			playerRows:  make(map[string][]LeaderboardRow),
+		}
+		for range b.N {
+			for teamId, teamHoles := range holesByTeam {
+				board.setTeamHoles(teamId, teamHoles)
+			}
+		}
+	})
+
+	b.Run("cold Get", func(b *testing.B) {
+		cache := NewLeaderboardCache()
+		for range b.N {
+			cache.Invalidate(tournament.Id)
+			snapshot, err := cache.Get(db, tournament.Id, false)
+			if err != nil {
+				b.Fatal(err)
+			}
+			if len(snapshot.Rows) != 72 {
+				b.Fatalf("expected 72 rows, got %d", len(snapshot.Rows))
+			}
+		}
+	})
+
+	b.Run("warm Get", func(b *testing.B) {
+		cache := NewLeaderboardCache()
+		_, err := cache.Get(db, tournament.Id, false)
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.ResetTimer()
+
+		for range b.N {
+			_, err := cache.Get(db, tournament.Id, false)
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("warm Get after one team scores", func(b *testing.B) {
+		cache := NewLeaderboardCache()
+		_, err := cache.Get(db, tournament.Id, true)
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.ResetTimer()
+
+		for i := range b.N {
+			cache.InvalidateTeam(tournament.Id, created[i%len(created)].Id)
+			_, err := cache.Get(db, tournament.Id, true)
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+// TestLeaderboardCacheSkipsFinishedTournaments checks that boards of final
+// tournaments are computed but not kept.
+func TestLeaderboardCacheSkipsFinishedTournaments(t *testing.T) {
+	app := newTestApp(t)
+
+	tournament, _ := newTestTournament(t, app, models.CreateTournamentData{Name: "Done", TeamCount: 1, AwardedHandicap: 1}, [][]models.Player{
+		{{Name: "Al", Handicap: 10}},
+		{{Name: "Bo", Handicap: 4}},
+	})
+
+	cache := NewLeaderboardCache()
+	_, err := cache.Get(app.DB(), tournament.Id, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cache.tournaments) != 1 {
+		t.Fatalf("expected the live tournament to be cached, have %d entries", len(cache.tournaments))
+	}
+
+	_, err = app.DB().
+		NewQuery("UPDATE tournaments SET status = {:status} WHERE id = {:id}").
+		Bind(map[string]any{"status": models.TOURNAMENT_STATUS_FINAL, "id": tournament.Id}).
+		Execute()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cache.Invalidate(tournament.Id)
+
+	for range 3 {
+		snapshot, err := cache.Get(app.DB(), tournament.Id, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(snapshot.Rows) != 2 {
+			t.Fatalf("expected a row per team, got %d", len(snapshot.Rows))
+		}
+	}
+	if len(cache.tournaments) != 0 {
+		t.Fatalf("expected the final tournament not to be cached, have %d entries", len(cache.tournaments))
+	}
+}
+
+// TestTeamHoleWritesStayOnTheTeam checks that a team can't write, or move a
+// hole onto, another team, whose cached rows it wouldn't mark stale.
+func TestTeamHoleWritesStayOnTheTeam(t *testing.T) {
+	app := newTestApp(t)
+	hc := NewHolesController(app)
+
+	tournament, teams := newTestTournament(t, app, models.CreateTournamentData{Name: "Open", TeamCount: 1, AwardedHandicap: 1}, [][]models.Player{
+		{{Name: "Al", Handicap: 10}},
+		{{Name: "Bo", Handicap: 4}},
+	})
+	holes := func(teamId string) []models.HoleWithMetadata {
+		t.Helper()
+
+		holes, err := models.GetTeamHoles(app.DB(), teamId)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return *holes
+	}
+	own, other := holes(teams[0].Id)[0], holes(teams[1].Id)[0]
+
+	cases := []struct {
+		name string
+		body string
+		code int
+	}{
+		{"its own hole", `[{"id": "` + own.Id + `", "score": "4"}]`, http.StatusOK},
+		{"another team's hole", `[{"id": "` + other.Id + `", "score": "4"}]`, http.StatusForbidden},
+		{"its hole onto another team's player", `[{"id": "` + own.Id + `", "playerId": "` + other.PlayerId + `"}]`, http.StatusForbidden},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			rec := serveTest(app, hc.HandleUpdateTeamHoleScores, testRequest{
+				method:       "PUT",
+				body:         c.body,
+				teamId:       teams[0].Id,
+				tournamentId: tournament.Id,
+			})
+			if rec.Code != c.code {
+				t.Fatalf("expected %d, got %d: %s", c.code, rec.Code, rec.Body)
+			}
+		})
+	}
+
+	if score := holes(teams[1].Id)[0].Score; score != other.Score {
+		t.Fatalf("expected the other team's hole untouched, got %q", score)
+	}
+	if moved := holes(teams[0].Id)[0]; moved.Id != own.Id || moved.Score != "4" {
+		t.Fatalf("expected the team to keep its scored hole, got %+v", moved)
+	}
+}
+
+func TestEtagMatches(t *testing.T) {
+	etag := `"abc"`
+	cases := []struct {
+		header string
+		want   bool
+	}{
+		{"", false},
+		{`"abc"`, true},
+		{`W/"abc"`, true},
+		{`"xyz", W/"abc"`, true},
+		{`"xyz","abc"`, true},
+		{"*", true},
+		{`"xyz"`, false},
+		{`"ab`, false},
+		{`abc`, false},
+	}
+	for _, c := range cases {
+		if got := etagMatches(c.header, etag); got != c.want {
+			t.Errorf("If-None-Match %q: expected %v, got %v", c.header, c.want, got)
+		}
+	}
+}
+
+// TestLeaderboardPollsGetNotModified checks that a poll with the current
+// ETag gets a 304 until a score is written.
+func TestLeaderboardPollsGetNotModified(t *testing.T) {
+	app := newTestApp(t)
+	tc := NewTournamentController(app)
+	hc := NewHolesController(app)
+
+	tournament, teams := newTestTournament(t, app, models.CreateTournamentData{Name: "Open", TeamCount: 1, AwardedHandicap: 1}, [][]models.Player{
+		{{Name: "Al", Handicap: 10}},
+		{{Name: "Bo", Handicap: 4}},
+	})
+	poll := func(ifNoneMatch string) (int, string) {
+		t.Helper()
+
+		rec := serveTest(app, tc.HandleGetLeaderboard, testRequest{
+			pathValues: map[string]string{"tournamentId": tournament.Id},
+			headers:    map[string]string{"If-None-Match": ifNoneMatch},
+		})
+		return rec.Code, rec.Header().Get("ETag")
+	}
+
+	code, etag := poll("")
+	if code != http.StatusOK || etag == "" {
+		t.Fatalf("expected 200 with an ETag, got %d %q", code, etag)
+	}
+	if code, _ := poll(`"stale", W/` + etag); code != http.StatusNotModified {
+		t.Fatalf("polling with the current ETag: expected 304, got %d", code)
+	}
+
+	holes, err := models.GetTeamHoles(app.DB(), teams[0].Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := serveTest(app, hc.HandleUpdateTeamHoleScores, testRequest{
+		method:       "PUT",
+		body:         `[{"id": "` + (*holes)[0].Id + `", "score": "3"}]`,
+		teamId:       teams[0].Id,
+		tournamentId: tournament.Id,
+	})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("writing a score: expected 200, got %d: %s", rec.Code, rec.Body)
+	}
+
+	code, changed := poll(etag)
+	if code != http.StatusOK || changed == etag {
+		t.Fatalf("polling after a score: expected 200 with a new ETag, got %d %q", code, changed)
+	}
+}
@@ -0,0 +1,716 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/patrick-salvatore/tournament-live-scoring/models"
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+const (
+	LEAGUE_MATCH_HOME = "home"
+	LEAGUE_MATCH_AWAY = "away"
+)
+
+type LeaguesController struct {
+	app core.App
+	db  dbx.Builder
+}
+
+func NewLeaguesController(app core.App) *LeaguesController {
+	return &LeaguesController{app: app, db: app.DB()}
+}
+
+// LeagueMatchResult scores a match hole by hole between the two teams'
+// counted scores in the week's tournament: a point for each hole won, split
+// when halved. Winner is only set once the tournament is final.
+type LeagueMatchResult struct {
+	models.LeagueMatch
+	HomePoints float64 `json:"homePoints"`
+	AwayPoints float64 `json:"awayPoints"`
+	Thru       int     `json:"thru"`
+	Final      bool    `json:"final"`
+	Winner     string  `json:"winner,omitempty"`
+}
+
+type LeagueWeek struct {
+	Week         int                 `json:"week"`
+	TournamentId string              `json:"tournamentId,omitempty"`
+	Matches      []LeagueMatchResult `json:"matches"`
+	// Bye names the team without a match, when there is an odd number.
+	Bye string `json:"bye,omitempty"`
+}
+
+type LeagueDetail struct {
+	models.League
+	Teams []models.LeagueTeam `json:"teams"`
+	Weeks []LeagueWeek        `json:"weeks"`
+}
+
+type LeagueStanding struct {
+	Position      string  `json:"position"`
+	TeamId        string  `json:"teamId"`
+	TeamName      string  `json:"teamName"`
+	Played        int     `json:"played"`
+	Won           int     `json:"won"`
+	Lost          int     `json:"lost"`
+	Halved        int     `json:"halved"`
+	PointsFor     float64 `json:"pointsFor"`
+	PointsAgainst float64 `json:"pointsAgainst"`
+	Points        float64 `json:"points"`
+
+	place int
+}
+
+type LeagueStandings struct {
+	League    models.League    `json:"league"`
+	Standings []LeagueStanding `json:"standings"`
+}
+
+type LeagueHandicapChange struct {
+	PlayerId   string  `json:"playerId"`
+	PlayerName string  `json:"playerName"`
+	From       float64 `json:"from"`
+	To         float64 `json:"to"`
+}
+
+type LeagueWeekTournament struct {
+	Week         int                    `json:"week"`
+	TournamentId string                 `json:"tournamentId"`
+	Handicaps    []LeagueHandicapChange `json:"handicaps"`
+}
+
+func (lc *LeaguesController) HandleGetLeagues(e *core.RequestEvent) error {
+	leagues, err := models.GetLeagues(lc.db)
+	if err != nil {
+		return e.Error(http.StatusInternalServerError, err.Error(), nil)
+	}
+
+	return e.JSON(http.StatusOK, leagues)
+}
+
+// HandleCreateLeague creates a league with its teams and generates the
+// round robin schedule, played once or twice through.
+func (lc *LeaguesController) HandleCreateLeague(e *core.RequestEvent) error {
+	var data models.LeagueCreate
+
+	err := json.NewDecoder(e.Request.Body).Decode(&data)
+	if err != nil {
+		return e.BadRequestError(err.Error(), nil)
+	}
+	if len(data.Name) == 0 {
+		return e.BadRequestError("name is required", nil)
+	}
+	if len(data.CourseId) == 0 {
+		return e.BadRequestError("courseId is required", nil)
+	}
+	if data.Rounds == 0 {
+		data.Rounds = models.LEAGUE_ROUNDS_SINGLE
+	}
+	if data.TeamScoring == "" {
+		data.TeamScoring = models.TEAM_SCORING_NET
+	}
+	if data.AwardedHandicap == 0 {
+		data.AwardedHandicap = 1
+	}
+	if data.PointsWin == 0 {
+		data.PointsWin = models.DEFAULT_LEAGUE_POINTS_WIN
+	}
+	if data.PointsHalve == 0 {
+		data.PointsHalve = models.DEFAULT_LEAGUE_POINTS_HALVE
+	}
+
+	err = validateLeague(data)
+	if err != nil {
+		return e.BadRequestError(err.Error(), nil)
+	}
+
+	seen := make(map[string]bool)
+	for i, team := range data.Teams {
+		for j, entry := range team.Players {
+			if seen[entry.PlayerId] {
+				return e.BadRequestError(fmt.Sprintf("player %s is on more than one team", entry.PlayerId), nil)
+			}
+			seen[entry.PlayerId] = true
+
+			player, err := models.GetPlayerById(lc.db, entry.PlayerId)
+			if err != nil {
+				return e.BadRequestError(fmt.Sprintf("player %s not found", entry.PlayerId), nil)
+			}
+			if entry.Tee == "" {
+				data.Teams[i].Players[j].Tee = player.PreferredTee
+			}
+			if data.Teams[i].Players[j].Tee == "" {
+				return e.BadRequestError(fmt.Sprintf("%s needs a tee", player.Name), nil)
+			}
+		}
+	}
+
+	fixtures := scheduleRoundRobin(len(data.Teams), data.Rounds)
+
+	var league *models.League
+	err = lc.app.RunInTransaction(func(txDb core.App) error {
+		var err error
+		league, err = models.CreateLeague(txDb.DB(), data, fixtures)
+		return err
+	})
+	if err != nil {
+		return e.Error(http.StatusInternalServerError, err.Error(), nil)
+	}
+
+	return e.JSON(http.StatusCreated, league)
+}
+
+func (lc *LeaguesController) HandleGetLeague(e *core.RequestEvent) error {
+	leagueId := e.Request.PathValue("leagueId")
+
+	league, err := models.GetLeagueById(lc.db, leagueId)
+	if err != nil {
+		return e.NotFoundError(err.Error(), leagueId)
+	}
+
+	teams, err := models.GetLeagueTeams(lc.db, leagueId)
+	if err != nil {
+		return e.Error(http.StatusInternalServerError, err.Error(), nil)
+	}
+
+	results, err := lc.getLeagueResults(league)
+	if err != nil {
+		return e.Error(http.StatusInternalServerError, err.Error(), nil)
+	}
+
+	weeks := []LeagueWeek{}
+	for _, result := range results {
+		if len(weeks) == 0 || weeks[len(weeks)-1].Week != result.Week {
+			weeks = append(weeks, LeagueWeek{Week: result.Week, Matches: []LeagueMatchResult{}})
+		}
+		week := &weeks[len(weeks)-1]
+		week.TournamentId = result.TournamentId
+		week.Matches = append(week.Matches, result)
+	}
+
+	for i := range weeks {
+		playing := make(map[string]bool)
+		for _, match := range weeks[i].Matches {
+			playing[match.HomeTeamId] = true
+			playing[match.AwayTeamId] = true
+		}
+		for _, team := range *teams {
+			if !playing[team.Id] {
+				weeks[i].Bye = team.Name
+			}
+		}
+	}
+
+	return e.JSON(http.StatusOK, LeagueDetail{League: *league, Teams: *teams, Weeks: weeks})
+}
+
+func (lc *LeaguesController) HandleGetLeagueStandings(e *core.RequestEvent) error {
+	leagueId := e.Request.PathValue("leagueId")
+
+	league, err := models.GetLeagueById(lc.db, leagueId)
+	if err != nil {
+		return e.NotFoundError(err.Error(), leagueId)
+	}
+
+	standings, err := lc.getLeagueStandings(league)
+	if err != nil {
+		return e.Error(http.StatusInternalServerError, err.Error(), nil)
+	}
+
+	return e.JSON(http.StatusOK, standings)
+}
+
+// HandleCreateLeagueWeek creates the tournament a week's matches are played
+// in, one tournament team per league team. Each player's league handicap is
+// brought up to date first and entered with them.
+func (lc *LeaguesController) HandleCreateLeagueWeek(e *core.RequestEvent) error {
+	leagueId := e.Request.PathValue("leagueId")
+	week, err := strconv.Atoi(e.Request.PathValue("week"))
+	if err != nil {
+		return e.BadRequestError("week must be a number", nil)
+	}
+
+	league, err := models.GetLeagueById(lc.db, leagueId)
+	if err != nil {
+		return e.NotFoundError(err.Error(), leagueId)
+	}
+
+	teams, err := models.GetLeagueTeams(lc.db, leagueId)
+	if err != nil {
+		return e.Error(http.StatusInternalServerError, err.Error(), nil)
+	}
+
+	allMatches, err := models.GetLeagueMatches(lc.db, leagueId)
+	if err != nil {
+		return e.Error(http.StatusInternalServerError, err.Error(), nil)
+	}
+
+	matches := []models.LeagueMatch{}
+	for _, match := range *allMatches {
+		if match.Week != week {
+			continue
+		}
+		if match.TournamentId != "" {
+			return e.Error(http.StatusConflict, fmt.Sprintf("week %d is already being played in tournament %s", week, match.TournamentId), nil)
+		}
+		matches = append(matches, match)
+	}
+	if len(matches) == 0 {
+		return e.NotFoundError(fmt.Sprintf("league has no week %d", week), week)
+	}
+
+	playing := make(map[string]bool)
+	for _, match := range matches {
+		playing[match.HomeTeamId] = true
+		playing[match.AwayTeamId] = true
+	}
+
+	handicaps, err := lc.getLeagueHandicaps(league, teams, *allMatches, week)
+	if err != nil {
+		return e.Error(http.StatusInternalServerError, err.Error(), nil)
+	}
+
+	teamSize := 0
+	for _, team := range *teams {
+		teamSize = max(teamSize, len(team.Players))
+	}
+
+	result := LeagueWeekTournament{Week: week, Handicaps: []LeagueHandicapChange{}}
+	err = lc.app.RunInTransaction(func(txDb core.App) error {
+		tournament, err := models.CreateTournament(txDb.DB(), models.CreateTournamentData{
+			Name:            fmt.Sprintf("%s week %d", league.Name, week),
+			CourseId:        league.CourseId,
+			FormatId:        league.FormatId,
+			AwardedHandicap: league.AwardedHandicap,
+			TeamCount:       teamSize,
+			TeamScoring:     league.TeamScoring,
+			BestScores:      league.BestScores,
+			BallFormat:      models.BALL_FORMAT_INDIVIDUAL,
+		})
+		if err != nil {
+			return err
+		}
+		result.TournamentId = tournament.Id
+
+		entries := make(map[string]string)
+		for _, team := range *teams {
+			if !playing[team.Id] {
+				continue
+			}
+
+			entry, err := models.CreateTeam(txDb.DB(), tournament.Id, team.Name)
+			if err != nil {
+				return err
+			}
+			entries[team.Id] = entry.Id
+
+			for _, player := range team.Players {
+				handicap := handicaps[player.PlayerId]
+
+				err = models.SetLeagueHandicap(txDb.DB(), leagueId, player.PlayerId, week, handicap)
+				if err != nil {
+					return err
+				}
+
+				_, err = models.CreateTeamPlayerLookup(txDb.DB(), entry.Id, player.PlayerId, player.Tee, tournament.Id, handicap)
+				if err != nil {
+					return err
+				}
+
+				previous := player.Handicap
+				if player.LeagueHandicap != nil {
+					previous = *player.LeagueHandicap
+				}
+				if previous != handicap {
+					result.Handicaps = append(result.Handicaps, LeagueHandicapChange{
+						PlayerId:   player.PlayerId,
+						PlayerName: player.Name,
+						From:       previous,
+						To:         handicap,
+					})
+				}
+			}
+		}
+
+		for _, match := range matches {
+			err = models.SetLeagueMatchTournament(txDb.DB(), match.Id, tournament.Id, entries[match.HomeTeamId], entries[match.AwayTeamId])
+			if err != nil {
+				return err
+			}
+		}
+
+		_, err = models.CreateAuditLog(txDb.DB(), tournament.Id, "league.week", e.RealIP(), result)
+		return err
+	})
+	if err != nil {
+		return e.Error(http.StatusInternalServerError, err.Error(), nil)
+	}
+
+	return e.JSON(http.StatusCreated, result)
+}
+
+// getLeagueHandicaps works out the index every league player carries into a
+// week. With handicapRounds set it is the average differential of their
+// latest complete rounds in earlier, finished weeks; players without one
+// keep the handicap they had.
+func (lc *LeaguesController) getLeagueHandicaps(league *models.League, teams *[]models.LeagueTeam, matches []models.LeagueMatch, week int) (map[string]float64, error) {
+	tees := make(map[string]string)
+	handicaps := make(map[string]float64)
+	for _, team := range *teams {
+		for _, player := range team.Players {
+			tees[player.PlayerId] = player.Tee
+			handicaps[player.PlayerId] = player.Handicap
+			if league.HandicapRounds > 0 && player.LeagueHandicap != nil {
+				handicaps[player.PlayerId] = *player.LeagueHandicap
+			}
+		}
+	}
+
+	if league.HandicapRounds == 0 {
+		return handicaps, nil
+	}
+
+	tournamentIds := []string{}
+	seen := make(map[string]bool)
+	for _, match := range matches {
+		if match.Week >= week || match.TournamentId == "" || seen[match.TournamentId] {
+			continue
+		}
+		if !leagueMatchFinal(match) {
+			continue
+		}
+		seen[match.TournamentId] = true
+		tournamentIds = append(tournamentIds, match.TournamentId)
+	}
+
+	differentials := make(map[string][]float64)
+	for _, tournamentId := range tournamentIds {
+		course, err := models.GetCourseByTournamentId(lc.db, tournamentId)
+		if err != nil {
+			return nil, err
+		}
+
+		snapshot, err := leaderboards.Get(lc.db, tournamentId, true)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, row := range snapshot.Rows {
+			tee, ok := tees[row.Id]
+			if !ok || row.Thru < len(course.Meta.Holes) {
+				continue
+			}
+
+			courseTeeData := course.Meta.Tees[tee]
+			if courseTeeData.SlopeRating == 0 {
+				continue
+			}
+			gross := float64(row.Gross + courseTeeData.Par)
+			differential := (gross - courseTeeData.CourseRating) * 113 / float64(courseTeeData.SlopeRating)
+			differentials[row.Id] = append(differentials[row.Id], differential)
+		}
+	}
+
+	for playerId, rounds := range differentials {
+		if len(rounds) > league.HandicapRounds {
+			rounds = rounds[len(rounds)-league.HandicapRounds:]
+		}
+
+		total := 0.0
+		for _, differential := range rounds {
+			total += differential
+		}
+		handicaps[playerId] = roundTo(total/float64(len(rounds)), 1)
+	}
+
+	return handicaps, nil
+}
+
+// getLeagueResults scores every match in the schedule from the team rows of
+// the week's tournament.
+func (lc *LeaguesController) getLeagueResults(league *models.League) ([]LeagueMatchResult, error) {
+	matches, err := models.GetLeagueMatches(lc.db, league.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	rowsByTournament := make(map[string]map[string]LeaderboardRow)
+	results := []LeagueMatchResult{}
+	for _, match := range *matches {
+		result := LeagueMatchResult{LeagueMatch: match}
+		if match.TournamentId == "" {
+			results = append(results, result)
+			continue
+		}
+
+		rows, ok := rowsByTournament[match.TournamentId]
+		if !ok {
+			snapshot, err := leaderboards.Get(lc.db, match.TournamentId, false)
+			if err != nil {
+				return nil, err
+			}
+
+			rows = make(map[string]LeaderboardRow)
+			for _, row := range snapshot.Rows {
+				rows[row.Id] = row
+			}
+			rowsByTournament[match.TournamentId] = rows
+		}
+
+		scoreLeagueMatch(&result, rows[match.HomeTournamentTeamId], rows[match.AwayTournamentTeamId], league.TeamScoring)
+		results = append(results, result)
+	}
+
+	return results, nil
+}
+
+func (lc *LeaguesController) getLeagueStandings(league *models.League) (*LeagueStandings, error) {
+	teams, err := models.GetLeagueTeams(lc.db, league.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	results, err := lc.getLeagueResults(league)
+	if err != nil {
+		return nil, err
+	}
+
+	standings := rankLeagueStandings(league, *teams, results)
+	return &standings, nil
+}
+
+// rankLeagueStandings tallies the final results for every team and orders
+// them on points, then point difference, then points scored. Teams level on
+// all three share a position.
+func rankLeagueStandings(league *models.League, teams []models.LeagueTeam, results []LeagueMatchResult) LeagueStandings {
+	byTeam := make(map[string]*LeagueStanding)
+	for _, team := range teams {
+		byTeam[team.Id] = &LeagueStanding{TeamId: team.Id, TeamName: team.Name}
+	}
+
+	for _, result := range results {
+		if !result.Final {
+			continue
+		}
+
+		home, away := byTeam[result.HomeTeamId], byTeam[result.AwayTeamId]
+		if home == nil || away == nil {
+			continue
+		}
+
+		home.Played++
+		away.Played++
+		home.PointsFor += result.HomePoints
+		home.PointsAgainst += result.AwayPoints
+		away.PointsFor += result.AwayPoints
+		away.PointsAgainst += result.HomePoints
+
+		switch result.Winner {
+		case LEAGUE_MATCH_HOME:
+			home.Won++
+			away.Lost++
+		case LEAGUE_MATCH_AWAY:
+			away.Won++
+			home.Lost++
+		default:
+			home.Halved++
+			away.Halved++
+		}
+	}
+
+	standings := LeagueStandings{League: *league, Standings: []LeagueStanding{}}
+	for _, team := range teams {
+		standing := byTeam[team.Id]
+		standing.Points = float64(standing.Won)*league.PointsWin + float64(standing.Halved)*league.PointsHalve
+		standings.Standings = append(standings.Standings, *standing)
+	}
+
+	sort.SliceStable(standings.Standings, func(i, j int) bool {
+		a, b := standings.Standings[i], standings.Standings[j]
+		if a.Points != b.Points {
+			return a.Points > b.Points
+		}
+		if a.PointsFor-a.PointsAgainst != b.PointsFor-b.PointsAgainst {
+			return a.PointsFor-a.PointsAgainst > b.PointsFor-b.PointsAgainst
+		}
+		if a.PointsFor != b.PointsFor {
+			return a.PointsFor > b.PointsFor
+		}
+		return a.TeamName < b.TeamName
+	})
+
+	for i := range standings.Standings {
+		standing := &standings.Standings[i]
+		standing.place = i + 1
+		if i > 0 {
+			above := standings.Standings[i-1]
+			if standing.Points == above.Points && standing.PointsFor == above.PointsFor && standing.PointsAgainst == above.PointsAgainst {
+				standing.place = above.place
+			}
+		}
+	}
+	for i := range standings.Standings {
+		standing := &standings.Standings[i]
+		standing.Position = strconv.Itoa(standing.place)
+
+		tiedAbove := i > 0 && standings.Standings[i-1].place == standing.place
+		tiedBelow := i+1 < len(standings.Standings) && standings.Standings[i+1].place == standing.place
+		if tiedAbove || tiedBelow {
+			standing.Position = "T" + standing.Position
+		}
+	}
+
+	return standings
+}
+
+// scoreLeagueMatch compares the holes both teams have completed, counting
+// net or gross to suit the league.
+func scoreLeagueMatch(result *LeagueMatchResult, home LeaderboardRow, away LeaderboardRow, scoring string) {
+	score := func(hole TeamHoleResult) int {
+		if scoring == models.TEAM_SCORING_GROSS {
+			return hole.Gross
+		}
+		return hole.Net
+	}
+
+	awayHoles := make(map[int]TeamHoleResult)
+	for _, hole := range away.Holes {
+		awayHoles[hole.Number] = hole
+	}
+
+	for _, hole := range home.Holes {
+		awayHole, ok := awayHoles[hole.Number]
+		if !ok {
+			continue
+		}
+
+		result.Thru++
+		switch {
+		case score(hole) < score(awayHole):
+			result.HomePoints++
+		case score(hole) > score(awayHole):
+			result.AwayPoints++
+		default:
+			result.HomePoints += 0.5
+			result.AwayPoints += 0.5
+		}
+	}
+
+	result.Final = leagueMatchFinal(result.LeagueMatch)
+	if !result.Final {
+		return
+	}
+
+	switch {
+	case result.HomePoints > result.AwayPoints:
+		result.Winner = LEAGUE_MATCH_HOME
+	case result.HomePoints < result.AwayPoints:
+		result.Winner = LEAGUE_MATCH_AWAY
+	default:
+		result.Winner = models.MATCH_HOLE_HALVED
+	}
+}
+
+func leagueMatchFinal(match models.LeagueMatch) bool {
+	return match.TournamentStatus == models.TOURNAMENT_STATUS_FINAL || match.TournamentStatus == models.TOURNAMENT_STATUS_ARCHIVED
+}
+
+// scheduleRoundRobin pairs every team with every other once per round using
+// the circle method, with a bye each week when the count is odd. Home goes
+// to whichever team has had fewer home matches, then to the team that was
+// away the week before, and a second round swaps every fixture of the first.
+func scheduleRoundRobin(teamCount int, rounds int) []models.LeagueFixture {
+	order := []int{}
+	for i := range teamCount {
+		order = append(order, i)
+	}
+	if teamCount%2 == 1 {
+		order = append(order, -1)
+	}
+	size := len(order)
+	weeks := size - 1
+
+	homeCount := make([]int, teamCount)
+	wasHome := make([]bool, teamCount)
+	fixtures := []models.LeagueFixture{}
+	for week := range weeks {
+		homeThisWeek := make(map[int]bool)
+		for i := range size / 2 {
+			a, b := order[i], order[size-1-i]
+			if a < 0 || b < 0 {
+				continue
+			}
+
+			home, away := a, b
+			switch {
+			case homeCount[b] < homeCount[a]:
+				home, away = b, a
+			case homeCount[a] < homeCount[b]:
+			case wasHome[a] && !wasHome[b]:
+				home, away = b, a
+			case wasHome[b] && !wasHome[a]:
+			case week%2 == 1:
+				home, away = b, a
+			}
+
+			homeCount[home]++
+			homeThisWeek[home] = true
+			fixtures = append(fixtures, models.LeagueFixture{Week: week + 1, Home: home, Away: away})
+		}
+
+		for team := range teamCount {
+			wasHome[team] = homeThisWeek[team]
+		}
+
+		// keep the first team fixed and rotate the rest one place
+		rotated := []int{order[0], order[size-1]}
+		order = append(rotated, order[1:size-1]...)
+	}
+
+	if rounds == models.LEAGUE_ROUNDS_DOUBLE {
+		first := fixtures
+		for _, fixture := range first {
+			fixtures = append(fixtures, models.LeagueFixture{Week: fixture.Week + weeks, Home: fixture.Away, Away: fixture.Home})
+		}
+	}
+
+	return fixtures
+}
+
+func validateLeague(data models.LeagueCreate) error {
+	if data.Rounds != models.LEAGUE_ROUNDS_SINGLE && data.Rounds != models.LEAGUE_ROUNDS_DOUBLE {
+		return fmt.Errorf("rounds must be 1 or 2")
+	}
+	if data.TeamScoring != models.TEAM_SCORING_NET && data.TeamScoring != models.TEAM_SCORING_GROSS {
+		return fmt.Errorf("teamScoring must be net or gross")
+	}
+	_, err := models.ParseBestScores(data.BestScores)
+	if err != nil {
+		return err
+	}
+	if data.PointsWin < 0 || data.PointsHalve < 0 {
+		return fmt.Errorf("points can't be negative")
+	}
+	if data.HandicapRounds < 0 {
+		return fmt.Errorf("handicapRounds can't be negative")
+	}
+	if len(data.Teams) < 2 {
+		return fmt.Errorf("a league needs at least 2 teams")
+	}
+	for i, team := range data.Teams {
+		if len(team.Name) == 0 {
+			return fmt.Errorf("team %d needs a name", i+1)
+		}
+		if len(team.Players) == 0 {
+			return fmt.Errorf("%s has no players", team.Name)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,258 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/patrick-salvatore/tournament-live-scoring/models"
+)
+
+func TestScheduleRoundRobin(t *testing.T) {
+	cases := []struct {
+		name      string
+		teamCount int
+		rounds    int
+		weeks     int
+		// byes is how many weeks each team sits out
+		byes int
+	}{
+		{"even single round", 4, models.LEAGUE_ROUNDS_SINGLE, 3, 0},
+		{"odd teams take a bye each", 5, models.LEAGUE_ROUNDS_SINGLE, 5, 1},
+		{"six teams", 6, models.LEAGUE_ROUNDS_SINGLE, 5, 0},
+		{"double round", 4, models.LEAGUE_ROUNDS_DOUBLE, 6, 0},
+		{"odd double round", 5, models.LEAGUE_ROUNDS_DOUBLE, 10, 2},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			fixtures := scheduleRoundRobin(c.teamCount, c.rounds)
+
+			pairings := make(map[[2]int]int)
+			weeksPlayed := make([]map[int]bool, c.teamCount)
+			home, away := make([]int, c.teamCount), make([]int, c.teamCount)
+			for team := range c.teamCount {
+				weeksPlayed[team] = make(map[int]bool)
+			}
+			for _, fixture := range fixtures {
+				if fixture.Week < 1 || fixture.Week > c.weeks {
+					t.Fatalf("fixture %+v falls outside weeks 1-%d", fixture, c.weeks)
+				}
+				for _, team := range []int{fixture.Home, fixture.Away} {
+					if weeksPlayed[team][fixture.Week] {
+						t.Fatalf("team %d plays twice in week %d", team, fixture.Week)
+					}
+					weeksPlayed[team][fixture.Week] = true
+				}
+				pairings[[2]int{min(fixture.Home, fixture.Away), max(fixture.Home, fixture.Away)}]++
+				home[fixture.Home]++
+				away[fixture.Away]++
+			}
+
+			for a := range c.teamCount {
+				for b := a + 1; b < c.teamCount; b++ {
+					if pairings[[2]int{a, b}] != c.rounds {
+						t.Errorf("teams %d and %d meet %d times, expected %d", a, b, pairings[[2]int{a, b}], c.rounds)
+					}
+				}
+			}
+			for team := range c.teamCount {
+				if byes := c.weeks - len(weeksPlayed[team]); byes != c.byes {
+					t.Errorf("team %d has %d byes, expected %d", team, byes, c.byes)
+				}
+				if c.rounds == models.LEAGUE_ROUNDS_DOUBLE && home[team] != away[team] {
+					t.Errorf("team %d is home %d times and away %d over a double round", team, home[team], away[team])
+				}
+				if diff := home[team] - away[team]; diff < -1 || diff > 1 {
+					t.Errorf("team %d is home %d times and away %d", team, home[team], away[team])
+				}
+			}
+		})
+	}
+}
+
+func TestScheduleRoundRobinSecondRoundSwapsTheFirst(t *testing.T) {
+	single := scheduleRoundRobin(4, models.LEAGUE_ROUNDS_SINGLE)
+	double := scheduleRoundRobin(4, models.LEAGUE_ROUNDS_DOUBLE)
+
+	if !reflect.DeepEqual(double[:len(single)], single) {
+		t.Fatalf("expected the first round unchanged, got %+v", double[:len(single)])
+	}
+	for i, fixture := range single {
+		swapped := double[len(single)+i]
+		if swapped.Week != fixture.Week+3 || swapped.Home != fixture.Away || swapped.Away != fixture.Home {
+			t.Errorf("expected %+v played the other way round in week %d, got %+v", fixture, fixture.Week+3, swapped)
+		}
+	}
+}
+
+func TestRankLeagueStandings(t *testing.T) {
+	league := &models.League{PointsWin: 2, PointsHalve: 1}
+	teams := []models.LeagueTeam{{Id: "a", Name: "Aces"}, {Id: "b", Name: "Birdies"}, {Id: "c", Name: "Condors"}, {Id: "d", Name: "Divots"}}
+	result := func(home string, away string, homePoints float64, awayPoints float64, final bool) LeagueMatchResult {
+		result := LeagueMatchResult{
+			LeagueMatch: models.LeagueMatch{HomeTeamId: home, AwayTeamId: away},
+			HomePoints:  homePoints,
+			AwayPoints:  awayPoints,
+			Final:       final,
+		}
+		switch {
+		case homePoints > awayPoints:
+			result.Winner = LEAGUE_MATCH_HOME
+		case homePoints < awayPoints:
+			result.Winner = LEAGUE_MATCH_AWAY
+		default:
+			result.Winner = models.MATCH_HOLE_HALVED
+		}
+		return result
+	}
+
+	type standing struct {
+		position string
+		teamId   string
+		points   float64
+	}
+	cases := []struct {
+		name    string
+		results []LeagueMatchResult
+		want    []standing
+	}{
+		{
+			name:    "matches in progress don't count",
+			results: []LeagueMatchResult{result("a", "b", 12, 6, false)},
+			want:    []standing{{"T1", "a", 0}, {"T1", "b", 0}, {"T1", "c", 0}, {"T1", "d", 0}},
+		},
+		{
+			name:    "level points go to the better difference",
+			results: []LeagueMatchResult{result("a", "b", 10, 8, true), result("c", "d", 14, 4, true)},
+			want:    []standing{{"1", "c", 2}, {"2", "a", 2}, {"3", "b", 0}, {"4", "d", 0}},
+		},
+		{
+			name:    "level difference goes to points scored",
+			results: []LeagueMatchResult{result("a", "b", 11, 7, true), result("d", "c", 13, 9, true)},
+			want:    []standing{{"1", "d", 2}, {"2", "a", 2}, {"3", "c", 0}, {"4", "b", 0}},
+		},
+		{
+			name:    "teams level on everything share a position",
+			results: []LeagueMatchResult{result("a", "b", 9, 9, true), result("c", "d", 9, 9, true)},
+			want:    []standing{{"T1", "a", 1}, {"T1", "b", 1}, {"T1", "c", 1}, {"T1", "d", 1}},
+		},
+		{
+			name:    "a tie below the leader",
+			results: []LeagueMatchResult{result("a", "b", 12, 6, true), result("c", "d", 9, 9, true)},
+			want:    []standing{{"1", "a", 2}, {"T2", "c", 1}, {"T2", "d", 1}, {"4", "b", 0}},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got := []standing{}
+			for _, row := range rankLeagueStandings(league, teams, c.results).Standings {
+				got = append(got, standing{row.Position, row.TeamId, row.Points})
+			}
+
+			if !reflect.DeepEqual(got, c.want) {
+				t.Fatalf("expected %v, got %v", c.want, got)
+			}
+		})
+	}
+}
+
+func TestGetLeagueHandicaps(t *testing.T) {
+	cases := []struct {
+		name           string
+		handicapRounds int
+		final          bool
+		// want is Al's then Bo's index for week 2
+		want [2]float64
+	}{
+		{"own indexes without handicapRounds", 0, true, [2]float64{10, 4}},
+		{"an unfinished week doesn't count", 2, false, [2]float64{10, 4}},
+		{"the average differential of finished weeks", 2, true, [2]float64{18, 1.7}},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			app := newTestApp(t)
+			lc := NewLeaguesController(app)
+
+			players := []string{}
+			for _, player := range []models.CreatePlayerData{{Name: "Al", Handicap: 10}, {Name: "Bo", Handicap: 4}} {
+				created, err := models.CreatePlayer(app.DB(), player)
+				if err != nil {
+					t.Fatal(err)
+				}
+				players = append(players, created.Id)
+			}
+
+			rec := serveTest(app, lc.HandleCreateLeague, testRequest{
+				method: "POST",
+				body: fmt.Sprintf(`{"name": "Tuesday", "courseId": "course1", "formatId": "format1", "handicapRounds": %d, "teams": [
+					{"name": "Aces", "players": [{"playerId": %q, "tee": "white"}]},
+					{"name": "Birdies", "players": [{"playerId": %q, "tee": "white"}]}
+				]}`, c.handicapRounds, players[0], players[1]),
+			})
+			if rec.Code != http.StatusCreated {
+				t.Fatalf("creating the league: expected 201, got %d: %s", rec.Code, rec.Body)
+			}
+			var league models.League
+			json.NewDecoder(rec.Body).Decode(&league)
+
+			rec = serveTest(app, lc.HandleCreateLeagueWeek, testRequest{
+				method:     "POST",
+				pathValues: map[string]string{"leagueId": league.Id, "week": "1"},
+			})
+			if rec.Code != http.StatusCreated {
+				t.Fatalf("creating week 1: expected 201, got %d: %s", rec.Code, rec.Body)
+			}
+			var week LeagueWeekTournament
+			json.NewDecoder(rec.Body).Decode(&week)
+
+			// Al shoots 90 and Bo 72 off the whites, rated 70.1 and 125
+			course, err := models.GetCourseByTournamentId(app.DB(), week.TournamentId)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i, score := range []string{"5", "4"} {
+				_, err = models.CreateAllHolesForPlayer(app.DB(), players[i], week.TournamentId, course.Meta.Holes, nil)
+				if err != nil {
+					t.Fatal(err)
+				}
+				scores := map[int]string{}
+				for number := 1; number <= 18; number++ {
+					scores[number] = score
+				}
+				setTestScores(t, app, week.TournamentId, players[i], scores)
+			}
+			if c.final {
+				_, err = app.DB().
+					NewQuery("UPDATE tournaments SET status = {:status} WHERE id = {:id}").
+					Bind(map[string]any{"status": models.TOURNAMENT_STATUS_FINAL, "id": week.TournamentId}).
+					Execute()
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			leaderboards.Invalidate(week.TournamentId)
+
+			teams, err := models.GetLeagueTeams(app.DB(), league.Id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			matches, err := models.GetLeagueMatches(app.DB(), league.Id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			handicaps, err := lc.getLeagueHandicaps(&league, teams, *matches, 2)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := [2]float64{handicaps[players[0]], handicaps[players[1]]}; got != c.want {
+				t.Fatalf("expected %v, got %v", c.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,194 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/patrick-salvatore/tournament-live-scoring/models"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/router"
+
+	_ "github.com/patrick-salvatore/tournament-live-scoring/migrations"
+	_ "github.com/pocketbase/pocketbase/migrations"
+)
+
+const testCourseTees = `{"white":{"par":72,"course_rating":70.1,"slope_rating":125},"blue":{"par":72,"course_rating":72.0,"slope_rating":131}}`
+
+// newTestApp boots an app in a temporary directory with the collections the
+// migrations build on, then runs the migrations and adds one course and
+// format.
+func newTestApp(tb testing.TB) core.App {
+	tb.Helper()
+
+	app := core.NewBaseApp(core.BaseAppConfig{DataDir: tb.TempDir()})
+	err := app.Bootstrap()
+	if err != nil {
+		tb.Fatal(err)
+	}
+	tb.Cleanup(func() {
+		app.ResetBootstrapState()
+	})
+
+	collection := func(name string, fields ...core.Field) *core.Collection {
+		c := core.NewBaseCollection(name)
+		c.Fields.Add(fields...)
+		c.Fields.Add(
+			&core.AutodateField{Name: "created", OnCreate: true},
+			&core.AutodateField{Name: "updated", OnCreate: true, OnUpdate: true},
+		)
+		return c
+	}
+	for _, c := range []*core.Collection{
+		collection("courses", &core.TextField{Name: "name"}, &core.JSONField{Name: "tees"}, &core.JSONField{Name: "hole_layout"}),
+		collection("tournament_formats", &core.TextField{Name: "name"}),
+		collection("tournaments", &core.TextField{Name: "name"}, &core.TextField{Name: "course_id"}, &core.TextField{Name: "tournament_format_id"}, &core.NumberField{Name: "team_count"}, &core.NumberField{Name: "hole_count"}, &core.NumberField{Name: "awarded_handicap"}, &core.BoolField{Name: "complete"}, &core.BoolField{Name: "is_match_play"}),
+		collection("players", &core.TextField{Name: "name"}, &core.NumberField{Name: "handicap"}, &core.TextField{Name: "team_id"}),
+		collection("teams", &core.TextField{Name: "name"}, &core.TextField{Name: "tournament_id"}, &core.BoolField{Name: "started"}, &core.BoolField{Name: "finished"}),
+		collection("_team_players", &core.TextField{Name: "team_id"}, &core.TextField{Name: "player_id"}, &core.TextField{Name: "tee"}, &core.TextField{Name: "tournament_id"}),
+		collection("holes", &core.TextField{Name: "score"}, &core.NumberField{Name: "number"}, &core.TextField{Name: "player_id"}, &core.TextField{Name: "tournament_id"}),
+	} {
+		err = app.Save(c)
+		if err != nil {
+			tb.Fatal(err)
+		}
+	}
+
+	_, err = core.NewMigrationsRunner(app, core.AppMigrations).Up()
+	if err != nil {
+		tb.Fatal(err)
+	}
+
+	layout, err := os.ReadFile("../data/indian-island-cc.json")
+	if err != nil {
+		tb.Fatal(err)
+	}
+	_, err = app.DB().
+		NewQuery("INSERT INTO courses (id, name, tees, hole_layout) VALUES ('course1', 'Indian Island', {:tees}, {:layout})").
+		Bind(map[string]any{"tees": testCourseTees, "layout": string(layout)}).
+		Execute()
+	if err != nil {
+		tb.Fatal(err)
+	}
+	_, err = app.DB().NewQuery("INSERT INTO tournament_formats (id, name) VALUES ('format1', 'Best Ball')").Execute()
+	if err != nil {
+		tb.Fatal(err)
+	}
+
+	return app
+}
+
+// newTestTournament creates a tournament on the test course with teams of
+// the given players, all playing the white tees, and their holes.
+func newTestTournament(tb testing.TB, app core.App, data models.CreateTournamentData, teams [][]models.Player) (*models.Tournament, []models.Team) {
+	tb.Helper()
+
+	db := app.DB()
+	data.CourseId = "course1"
+	data.FormatId = "format1"
+	tournament, err := models.CreateTournament(db, data)
+	if err != nil {
+		tb.Fatal(err)
+	}
+
+	course, err := models.GetCourseByTournamentId(db, tournament.Id)
+	if err != nil {
+		tb.Fatal(err)
+	}
+
+	created := []models.Team{}
+	for i, players := range teams {
+		team, err := models.CreateTeam(db, tournament.Id, fmt.Sprintf("Team %d", i+1))
+		if err != nil {
+			tb.Fatal(err)
+		}
+		created = append(created, *team)
+
+		for _, player := range players {
+			if player.Id == "" {
+				p, err := models.CreatePlayer(db, models.CreatePlayerData{Name: player.Name, Handicap: player.Handicap})
+				if err != nil {
+					tb.Fatal(err)
+				}
+				player.Id = p.Id
+			}
+
+			_, err = models.CreateTeamPlayerLookup(db, team.Id, player.Id, "white", tournament.Id, player.Handicap)
+			if err != nil {
+				tb.Fatal(err)
+			}
+			_, err = models.CreateAllHolesForPlayer(db, player.Id, tournament.Id, course.Meta.Holes, nil)
+			if err != nil {
+				tb.Fatal(err)
+			}
+		}
+	}
+
+	return tournament, created
+}
+
+// setTestScores enters gross scores for a player by hole number.
+func setTestScores(tb testing.TB, app core.App, tournamentId string, playerId string, scores map[int]string) {
+	tb.Helper()
+
+	for number, score := range scores {
+		_, err := app.DB().
+			NewQuery("UPDATE holes SET score = {:score} WHERE tournament_id = {:tournament_id} AND player_id = {:player_id} AND number = {:number}").
+			Bind(map[string]any{"score": score, "tournament_id": tournamentId, "player_id": playerId, "number": number}).
+			Execute()
+		if err != nil {
+			tb.Fatal(err)
+		}
+	}
+}
+
+type testRequest struct {
+	method     string
+	body       string
+	pathValues map[string]string
+	headers    map[string]string
+	// teamId and tournamentId stand in for the claims of a team's token.
+	teamId       string
+	tournamentId string
+}
+
+// serveTest runs a handler and writes any error it returns the way the
+// router would, so tests can check the status code.
+func serveTest(app core.App, handler func(*core.RequestEvent) error, request testRequest) *httptest.ResponseRecorder {
+	if request.method == "" {
+		request.method = "GET"
+	}
+
+	req := httptest.NewRequest(request.method, "/", strings.NewReader(request.body))
+	for key, value := range request.pathValues {
+		req.SetPathValue(key, value)
+	}
+	for key, value := range request.headers {
+		req.Header.Set(key, value)
+	}
+	ctx := req.Context()
+	if request.teamId != "" {
+		ctx = context.WithValue(ctx, TeamId, request.teamId)
+	}
+	if request.tournamentId != "" {
+		ctx = context.WithValue(ctx, TournamentId, request.tournamentId)
+	}
+	req = req.WithContext(ctx)
+
+	rec := httptest.NewRecorder()
+	err := handler(&core.RequestEvent{App: app, Event: router.Event{Request: req, Response: rec}})
+	if err != nil {
+		apiErr, ok := err.(*router.ApiError)
+		if !ok {
+			apiErr = router.NewInternalServerError(err.Error(), nil)
+		}
+		rec.WriteHeader(apiErr.Status)
+		json.NewEncoder(rec).Encode(apiErr)
+	}
+
+	return rec
+}
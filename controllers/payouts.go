@@ -0,0 +1,474 @@
+package controllers
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+
+	"github.com/patrick-salvatore/tournament-live-scoring/models"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// payoutReportColumns is the CSV layout of the payout report, one row per
+// prize paid.
+var payoutReportColumns = []string{
+	"board",
+	"position",
+	"player_id",
+	"player_name",
+	"score",
+	"amount",
+}
+
+type PayoutLine struct {
+	Position   string  `json:"position"`
+	PlayerId   string  `json:"playerId"`
+	PlayerName string  `json:"playerName"`
+	Score      int     `json:"score"`
+	Amount     float64 `json:"amount"`
+}
+
+// PayoutBoard is the share of the pot paid on one leaderboard, overall or a
+// flight's.
+type PayoutBoard struct {
+	Name     string       `json:"name"`
+	FlightId string       `json:"flightId,omitempty"`
+	Scoring  string       `json:"scoring"`
+	Share    float64      `json:"share"`
+	Pot      float64      `json:"pot"`
+	Payouts  []PayoutLine `json:"payouts"`
+	// Unpaid is what is left over when fewer players finished than there
+	// are places paid.
+	Unpaid float64 `json:"unpaid"`
+}
+
+type PayoutReport struct {
+	TournamentId   string `json:"tournamentId"`
+	TournamentName string `json:"tournamentName"`
+	// Final is false while the tournament is still being played, and the
+	// report only a projection from the current leaderboard.
+	Final    bool           `json:"final"`
+	EntryFee float64        `json:"entryFee"`
+	Entries  int            `json:"entries"`
+	Pot      float64        `json:"pot"`
+	Boards   []PayoutBoard  `json:"boards"`
+	Totals   []LedgerAmount `json:"totals"`
+}
+
+func (tc *TournamentController) HandleGetPayoutConfig(e *core.RequestEvent) error {
+	tournamentId := e.Request.PathValue("tournamentId")
+
+	config, err := models.GetPayoutConfig(tc.db, tournamentId)
+	if err != nil {
+		return e.Error(http.StatusInternalServerError, err.Error(), nil)
+	}
+
+	return e.JSON(http.StatusOK, config)
+}
+
+// HandleUpdatePayoutConfig replaces the tournament's payout settings. Each
+// list of splits is a percentage of its board's pot per place, first place
+// first, and must add up to 100.
+func (tc *TournamentController) HandleUpdatePayoutConfig(e *core.RequestEvent) error {
+	var data models.PayoutConfigUpdate
+	tournamentId := e.Request.PathValue("tournamentId")
+
+	err := json.NewDecoder(e.Request.Body).Decode(&data)
+	if err != nil {
+		return e.BadRequestError(err.Error(), nil)
+	}
+
+	_, err = models.GetTournamentById(tc.db, tournamentId)
+	if err != nil {
+		return e.NotFoundError(err.Error(), tournamentId)
+	}
+
+	if data.Scoring == "" {
+		data.Scoring = models.PAYOUT_SCORING_NET
+	}
+	if data.Splits == nil {
+		data.Splits = models.DEFAULT_PAYOUT_SPLITS
+	}
+	if data.Flights == nil {
+		data.Flights = []models.PayoutFlight{}
+	}
+	for i := range data.Flights {
+		if data.Flights[i].Splits == nil {
+			data.Flights[i].Splits = models.DEFAULT_PAYOUT_SPLITS
+		}
+	}
+
+	err = tc.validatePayoutConfig(tournamentId, data)
+	if err != nil {
+		return e.BadRequestError(err.Error(), nil)
+	}
+
+	err = tc.app.RunInTransaction(func(txDb core.App) error {
+		err := models.SetPayoutConfig(txDb.DB(), tournamentId, data)
+		if err != nil {
+			return err
+		}
+
+		_, err = models.CreateAuditLog(txDb.DB(), tournamentId, "payout.update", e.RealIP(), data)
+		return err
+	})
+
+	if err != nil {
+		return e.Error(http.StatusInternalServerError, err.Error(), nil)
+	}
+
+	config, err := models.GetPayoutConfig(tc.db, tournamentId)
+	if err != nil {
+		return e.Error(http.StatusInternalServerError, err.Error(), nil)
+	}
+
+	return e.JSON(http.StatusOK, config)
+}
+
+// HandleGetPayoutReport works out who is paid what as ?format=json
+// (default), csv or pdf.
+func (tc *TournamentController) HandleGetPayoutReport(e *core.RequestEvent) error {
+	tournamentId := e.Request.PathValue("tournamentId")
+	format := e.Request.URL.Query().Get("format")
+	if format == "" {
+		format = "json"
+	}
+	if format != "json" && format != "csv" && format != "pdf" {
+		return e.BadRequestError("format must be one of json, csv or pdf", nil)
+	}
+
+	tournament, err := models.GetTournamentById(tc.db, tournamentId)
+	if err != nil {
+		return e.NotFoundError(err.Error(), tournamentId)
+	}
+
+	report, err := tc.getPayoutReport(tournament)
+	if err != nil {
+		return e.Error(http.StatusInternalServerError, err.Error(), nil)
+	}
+
+	switch format {
+	case "csv":
+		var buf bytes.Buffer
+		writer := csv.NewWriter(&buf)
+		writer.Write(payoutReportColumns)
+		for _, board := range report.Boards {
+			for _, line := range board.Payouts {
+				writer.Write([]string{
+					board.Name,
+					line.Position,
+					line.PlayerId,
+					line.PlayerName,
+					formatToPar(line.Score),
+					formatMoney(line.Amount),
+				})
+			}
+		}
+		writer.Flush()
+		if err := writer.Error(); err != nil {
+			return e.Error(http.StatusInternalServerError, err.Error(), nil)
+		}
+		e.Response.Header().Set("Content-Disposition", "attachment; filename="+exportFileName(tournament.Name, "payouts.csv"))
+		return e.Blob(http.StatusOK, "text/csv", buf.Bytes())
+	case "pdf":
+		pdf := newExportPdf("P", tournament.Name, "Payouts")
+		pdf.AddPage()
+
+		pdf.SetFont("Arial", "", 10)
+		summary := fmt.Sprintf("Pot %s", formatMoney(report.Pot))
+		if report.EntryFee > 0 {
+			summary = fmt.Sprintf("%d entries at %s, pot %s", report.Entries, formatMoney(report.EntryFee), formatMoney(report.Pot))
+		}
+		pdf.CellFormat(190, 6, summary, "", 1, "L", false, 0, "")
+		if !report.Final {
+			pdf.SetFont("Arial", "I", 10)
+			pdf.CellFormat(190, 6, "Provisional: the tournament has not been finalized.", "", 1, "L", false, 0, "")
+		}
+		pdf.Ln(4)
+
+		widths := []float64{20, 110, 30, 30}
+		aligns := []string{"C", "L", "C", "R"}
+		for _, board := range report.Boards {
+			pdf.SetFont("Arial", "B", 12)
+			pdf.CellFormat(190, pdfRowHeight, fmt.Sprintf("%s (%s, %s)", board.Name, board.Scoring, formatMoney(board.Pot)), "", 1, "L", false, 0, "")
+			pdfTableHeader(pdf, widths, []string{"Pos", "Name", "Score", "Prize"})
+			for i, line := range board.Payouts {
+				pdfTableRow(pdf, i, widths, aligns, []string{line.Position, line.PlayerName, formatToPar(line.Score), formatMoney(line.Amount)})
+			}
+			if board.Unpaid > 0 {
+				pdf.SetFont("Arial", "I", 9)
+				pdf.CellFormat(190, 6, fmt.Sprintf("%s unpaid", formatMoney(board.Unpaid)), "", 1, "L", false, 0, "")
+			}
+			pdf.Ln(6)
+		}
+
+		pdf.SetFont("Arial", "B", 12)
+		pdf.CellFormat(190, pdfRowHeight, "Totals", "", 1, "L", false, 0, "")
+		totalWidths := []float64{160, 30}
+		pdfTableHeader(pdf, totalWidths, []string{"Name", "Total"})
+		for i, total := range report.Totals {
+			pdfTableRow(pdf, i, totalWidths, []string{"L", "R"}, []string{total.PlayerName, formatMoney(total.Amount)})
+		}
+
+		return writePdf(e, pdf, exportFileName(tournament.Name, "payouts.pdf"))
+	default:
+		return e.JSON(http.StatusOK, report)
+	}
+}
+
+func (tc *TournamentController) validatePayoutConfig(tournamentId string, data models.PayoutConfigUpdate) error {
+	if data.EntryFee < 0 || data.Pot < 0 || data.Entries < 0 {
+		return fmt.Errorf("entryFee, entries and pot can't be negative")
+	}
+	if data.Scoring != models.PAYOUT_SCORING_GROSS && data.Scoring != models.PAYOUT_SCORING_NET {
+		return fmt.Errorf("scoring must be gross or net")
+	}
+
+	flights, err := models.GetFlights(tc.db, tournamentId)
+	if err != nil {
+		return err
+	}
+	known := make(map[string]bool)
+	for _, flight := range *flights {
+		known[flight.Id] = true
+	}
+
+	share := 0.0
+	seen := make(map[string]bool)
+	for _, flight := range data.Flights {
+		if !known[flight.FlightId] {
+			return fmt.Errorf("flight %s is not in this tournament", flight.FlightId)
+		}
+		if seen[flight.FlightId] {
+			return fmt.Errorf("flight %s is listed twice", flight.FlightId)
+		}
+		seen[flight.FlightId] = true
+
+		if flight.Share <= 0 {
+			return fmt.Errorf("a flight's share must be greater than 0")
+		}
+		share += flight.Share
+
+		err = validatePayoutSplits(flight.Splits)
+		if err != nil {
+			return err
+		}
+	}
+	if share > 100 {
+		return fmt.Errorf("the flights can't share more than 100%% of the pot")
+	}
+
+	if share < 100 {
+		return validatePayoutSplits(data.Splits)
+	}
+
+	return nil
+}
+
+func validatePayoutSplits(splits []float64) error {
+	total := 0.0
+	for _, split := range splits {
+		if split < 0 {
+			return fmt.Errorf("splits can't be negative")
+		}
+		total += split
+	}
+	if math.Abs(total-100) > 0.01 {
+		return fmt.Errorf("splits must add up to 100, not %s", formatPoints(total))
+	}
+
+	return nil
+}
+
+// getPayoutReport pays each flight its share of the pot from its own
+// leaderboard, then the rest on the overall leaderboard. Only players who
+// have started are paid. Players tied on a place split the prizes for every
+// place they span.
+func (tc *TournamentController) getPayoutReport(tournament *models.Tournament) (*PayoutReport, error) {
+	config, err := models.GetPayoutConfig(tc.db, tournament.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	teamPlayers, err := models.GetTeamPlayersByTournament(tc.db, tournament.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := config.Entries
+	if entries == 0 {
+		entries = len(*teamPlayers)
+	}
+	potCents := int64(math.Round(config.EntryFee*100)) * int64(entries)
+	if config.Pot > 0 {
+		potCents = int64(math.Round(config.Pot * 100))
+	}
+
+	report := &PayoutReport{
+		TournamentId:   tournament.Id,
+		TournamentName: tournament.Name,
+		Final:          tournament.Status == models.TOURNAMENT_STATUS_FINAL || tournament.Status == models.TOURNAMENT_STATUS_ARCHIVED,
+		EntryFee:       config.EntryFee,
+		Entries:        entries,
+		Pot:            float64(potCents) / 100,
+		Boards:         []PayoutBoard{},
+		Totals:         []LedgerAmount{},
+	}
+
+	snapshot, err := leaderboards.Get(tc.db, tournament.Id, true)
+	if err != nil {
+		return nil, err
+	}
+	started := []LeaderboardRow{}
+	for _, row := range snapshot.Rows {
+		if row.Thru > 0 {
+			started = append(started, row)
+		}
+	}
+
+	remaining := potCents
+	if len(config.Flights) > 0 {
+		flights, err := models.GetFlights(tc.db, tournament.Id)
+		if err != nil {
+			return nil, err
+		}
+		members := flightMembers(*flights, *teamPlayers)
+
+		var countbacks map[string]map[string][]int
+		for _, flight := range *flights {
+			if flight.Tiebreak == models.FLIGHT_TIEBREAK_COUNTBACK {
+				countbacks, err = getCountbacks(tc.db, tournament.Id)
+				if err != nil {
+					return nil, err
+				}
+				break
+			}
+		}
+
+		byId := make(map[string]models.Flight)
+		for _, flight := range *flights {
+			byId[flight.Id] = flight
+		}
+
+		shared := 0.0
+		for _, payout := range config.Flights {
+			shared += payout.Share
+		}
+
+		for i, payout := range config.Flights {
+			flight := byId[payout.FlightId]
+
+			rows := []LeaderboardRow{}
+			for _, row := range started {
+				if members[flight.Id][row.Id] {
+					rows = append(rows, row)
+				}
+			}
+
+			// when the flights take the whole pot the last one keeps the
+			// rounding
+			cents := int64(math.Round(float64(potCents) * payout.Share / 100))
+			if i == len(config.Flights)-1 && shared >= 100 {
+				cents = remaining
+			}
+			remaining -= cents
+
+			board := payPlaces(rankFlight(flight, rows, countbacks[flight.Scoring]), payout.Splits, cents, flight.Scoring)
+			board.Name = flight.Name
+			board.FlightId = flight.Id
+			board.Share = payout.Share
+			report.Boards = append(report.Boards, board)
+		}
+	}
+
+	if remaining > 0 {
+		overall := models.Flight{Scoring: config.Scoring, Tiebreak: models.FLIGHT_TIEBREAK_NONE}
+		board := payPlaces(rankFlight(overall, started, nil), config.Splits, remaining, config.Scoring)
+		board.Name = "Overall"
+		board.Share = roundTo(float64(remaining)*100/float64(potCents), 2)
+		report.Boards = append([]PayoutBoard{board}, report.Boards...)
+	}
+
+	totals := make(map[string]int64)
+	names := make(map[string]string)
+	for _, board := range report.Boards {
+		for _, line := range board.Payouts {
+			totals[line.PlayerId] += int64(math.Round(line.Amount * 100))
+			names[line.PlayerId] = line.PlayerName
+		}
+	}
+	for playerId, cents := range totals {
+		report.Totals = append(report.Totals, LedgerAmount{
+			PlayerId:   playerId,
+			PlayerName: names[playerId],
+			Amount:     float64(cents) / 100,
+		})
+	}
+	sortLedgerAmounts(report.Totals)
+
+	return report, nil
+}
+
+// payPlaces shares a board's pot out over its ranked rows. The odd cents
+// left by rounding the splits go to first place.
+func payPlaces(ranked []FlightLeaderboardRow, splits []float64, potCents int64, scoring string) PayoutBoard {
+	score := netScore
+	if scoring == models.PAYOUT_SCORING_GROSS {
+		score = grossScore
+	}
+
+	prizes := make([]int64, len(splits))
+	allocated := int64(0)
+	for i, split := range splits {
+		prizes[i] = int64(math.Round(float64(potCents) * split / 100))
+		allocated += prizes[i]
+	}
+	if len(prizes) > 0 {
+		prizes[0] += potCents - allocated
+	}
+
+	board := PayoutBoard{
+		Scoring: scoring,
+		Pot:     float64(potCents) / 100,
+		Payouts: []PayoutLine{},
+	}
+
+	paid := int64(0)
+	for i := 0; i < len(ranked); {
+		j := i
+		for j < len(ranked) && ranked[j].place == ranked[i].place {
+			j++
+		}
+
+		total := int64(0)
+		for place := ranked[i].place; place < ranked[i].place+j-i && place <= len(prizes); place++ {
+			total += prizes[place-1]
+		}
+		if total == 0 {
+			break
+		}
+
+		for k, cents := range splitCents(total, j-i) {
+			row := ranked[i+k]
+			board.Payouts = append(board.Payouts, PayoutLine{
+				Position:   row.Position,
+				PlayerId:   row.Id,
+				PlayerName: row.TeamName,
+				Score:      score(row.LeaderboardRow),
+				Amount:     float64(cents) / 100,
+			})
+		}
+		paid += total
+		i = j
+	}
+	board.Unpaid = float64(potCents-paid) / 100
+
+	return board
+}
+
+func formatMoney(amount float64) string {
+	return fmt.Sprintf("%.2f", amount)
+}
@@ -0,0 +1,174 @@
+package controllers
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/patrick-salvatore/tournament-live-scoring/models"
+)
+
+func TestPayPlaces(t *testing.T) {
+	// rows takes each player's gross score, naming them a, b, c... in order
+	rows := func(scores ...int) []FlightLeaderboardRow {
+		rows := []LeaderboardRow{}
+		for i, score := range scores {
+			id := string(rune('a' + i))
+			rows = append(rows, LeaderboardRow{Id: id, TeamName: id, Gross: score, Thru: 18})
+		}
+		return rankFlight(models.Flight{Scoring: models.FLIGHT_SCORING_GROSS, Tiebreak: models.FLIGHT_TIEBREAK_NONE}, rows, nil)
+	}
+
+	type line struct {
+		position string
+		playerId string
+		amount   float64
+	}
+	cases := []struct {
+		name     string
+		ranked   []FlightLeaderboardRow
+		splits   []float64
+		potCents int64
+		want     []line
+		unpaid   float64
+	}{
+		{
+			name:     "one player a place",
+			ranked:   rows(70, 72, 74, 76),
+			splits:   []float64{50, 30, 20},
+			potCents: 10000,
+			want:     []line{{"1", "a", 50}, {"2", "b", 30}, {"3", "c", 20}},
+		},
+		{
+			name:     "a tie splits every place it spans",
+			ranked:   rows(70, 72, 72, 76),
+			splits:   []float64{50, 30, 20},
+			potCents: 10000,
+			want:     []line{{"1", "a", 50}, {"T2", "b", 25}, {"T2", "c", 25}},
+		},
+		{
+			name:     "a tie spanning paid and unpaid places shares only the paid one",
+			ranked:   rows(70, 72, 74, 74, 74),
+			splits:   []float64{50, 30, 20},
+			potCents: 10000,
+			want:     []line{{"1", "a", 50}, {"2", "b", 30}, {"T3", "c", 6.67}, {"T3", "d", 6.67}, {"T3", "e", 6.66}},
+		},
+		{
+			name:     "odd cents from the splits go to first place",
+			ranked:   rows(70, 72, 74),
+			splits:   []float64{40, 30, 30},
+			potCents: 10001,
+			want:     []line{{"1", "a", 40.01}, {"2", "b", 30}, {"3", "c", 30}},
+		},
+		{
+			name:     "places nobody finished in stay unpaid",
+			ranked:   rows(70, 72),
+			splits:   []float64{50, 30, 20},
+			potCents: 10000,
+			want:     []line{{"1", "a", 50}, {"2", "b", 30}},
+			unpaid:   20,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			board := payPlaces(c.ranked, c.splits, c.potCents, models.PAYOUT_SCORING_GROSS)
+
+			got := []line{}
+			for _, payout := range board.Payouts {
+				got = append(got, line{payout.Position, payout.PlayerId, payout.Amount})
+			}
+			if !reflect.DeepEqual(got, c.want) {
+				t.Errorf("expected payouts %v, got %v", c.want, got)
+			}
+			if board.Unpaid != c.unpaid {
+				t.Errorf("expected %.2f unpaid, got %.2f", c.unpaid, board.Unpaid)
+			}
+		})
+	}
+}
+
+func TestPayoutReportFlightsTakeTheWholePot(t *testing.T) {
+	app := newTestApp(t)
+	tc := NewTournamentController(app)
+
+	tournament, teams := newTestTournament(t, app, models.CreateTournamentData{Name: "Club", TeamCount: 1, AwardedHandicap: 1}, [][]models.Player{
+		{{Name: "Al", Handicap: 4}},
+		{{Name: "Bo", Handicap: 8}},
+		{{Name: "Cy", Handicap: 12}},
+	})
+
+	flight := func(name string, minHandicap float64, maxHandicap float64) string {
+		t.Helper()
+
+		flight, err := models.CreateFlight(app.DB(), tournament.Id, models.FlightCreate{
+			Name:        name,
+			Kind:        models.FLIGHT_KIND_HANDICAP,
+			MinHandicap: minHandicap,
+			MaxHandicap: maxHandicap,
+			Scoring:     models.FLIGHT_SCORING_GROSS,
+			Places:      2,
+			Tiebreak:    models.FLIGHT_TIEBREAK_NONE,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return flight.Id
+	}
+	low, high := flight("Low", 0, 9.9), flight("High", 10, 36)
+
+	// 30% of $100.05 rounds up to $30.02 and 70% to $70.04, so the last
+	// flight keeps what is left
+	err := models.SetPayoutConfig(app.DB(), tournament.Id, models.PayoutConfigUpdate{
+		Pot:     100.05,
+		Scoring: models.PAYOUT_SCORING_GROSS,
+		Flights: []models.PayoutFlight{
+			{FlightId: low, Share: 30, Splits: []float64{70, 30}},
+			{FlightId: high, Share: 70, Splits: []float64{100}},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Al and Bo tie in the low flight
+	for _, team := range teams {
+		players, err := models.GetPlayersFromTeamId(app.DB(), team.Id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		setTestScores(t, app, tournament.Id, (*players)[0].Id, map[int]string{1: "4"})
+	}
+	leaderboards.Invalidate(tournament.Id)
+
+	report, err := tc.getPayoutReport(tournament)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type board struct {
+		name    string
+		pot     float64
+		amounts []float64
+		unpaid  float64
+	}
+	got := []board{}
+	total := 0.0
+	for _, b := range report.Boards {
+		amounts := []float64{}
+		for _, payout := range b.Payouts {
+			amounts = append(amounts, payout.Amount)
+			total += payout.Amount
+		}
+		got = append(got, board{b.Name, b.Pot, amounts, b.Unpaid})
+	}
+	want := []board{
+		{"Low", 30.02, []float64{15.01, 15.01}, 0},
+		{"High", 70.03, []float64{70.03}, 0},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected boards %v, got %v", want, got)
+	}
+	if int64(total*100+0.5) != 10005 {
+		t.Fatalf("expected the whole pot paid out, got %.2f", total)
+	}
+}
@@ -0,0 +1,91 @@
+package controllers
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/jung-kurt/gofpdf"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+const (
+	pdfMargin    = 10.0
+	pdfRowHeight = 8.0
+)
+
+// newExportPdf starts a document with the header and footer every printable
+// export shares: the tournament name and sheet title on top, the print date
+// and page count at the bottom.
+func newExportPdf(orientation string, tournamentName string, title string) *gofpdf.Fpdf {
+	pdf := gofpdf.New(orientation, "mm", "A4", "")
+	pdf.SetTitle(fmt.Sprintf("%s - %s", tournamentName, title), false)
+	pdf.SetMargins(pdfMargin, pdfMargin, pdfMargin)
+	pdf.SetAutoPageBreak(true, 15)
+	pdf.AliasNbPages("")
+
+	pageWidth, _ := pdf.GetPageSize()
+	contentWidth := pageWidth - 2*pdfMargin
+
+	pdf.SetHeaderFunc(func() {
+		pdf.SetFont("Arial", "B", 18)
+		pdf.CellFormat(contentWidth, 10, tournamentName, "", 1, "L", false, 0, "")
+		pdf.SetFont("Arial", "", 12)
+		pdf.SetTextColor(90, 90, 90)
+		pdf.CellFormat(contentWidth, 7, title, "B", 1, "L", false, 0, "")
+		pdf.SetTextColor(0, 0, 0)
+		pdf.Ln(5)
+	})
+
+	pdf.SetFooterFunc(func() {
+		pdf.SetY(-12)
+		pdf.SetFont("Arial", "I", 8)
+		pdf.SetTextColor(120, 120, 120)
+		pdf.CellFormat(contentWidth/2, 5, "Printed "+time.Now().Format("Jan 2, 2006 3:04 PM"), "", 0, "L", false, 0, "")
+		pdf.CellFormat(contentWidth/2, 5, fmt.Sprintf("Page %d of {nb}", pdf.PageNo()), "", 0, "R", false, 0, "")
+		pdf.SetTextColor(0, 0, 0)
+	})
+
+	return pdf
+}
+
+// pdfTableHeader draws a shaded header row for the given column widths.
+func pdfTableHeader(pdf *gofpdf.Fpdf, widths []float64, labels []string) {
+	pdf.SetFont("Arial", "B", 10)
+	pdf.SetFillColor(220, 220, 220)
+	pdf.SetDrawColor(0, 0, 0)
+	pdf.SetLineWidth(0.2)
+
+	for i, label := range labels {
+		pdf.CellFormat(widths[i], pdfRowHeight, label, "1", 0, "C", true, 0, "")
+	}
+	pdf.Ln(-1)
+}
+
+// pdfTableRow draws a bordered row, striping every other one.
+func pdfTableRow(pdf *gofpdf.Fpdf, index int, widths []float64, aligns []string, values []string) {
+	pdf.SetFont("Arial", "", 10)
+	if index%2 == 0 {
+		pdf.SetFillColor(245, 245, 245)
+	} else {
+		pdf.SetFillColor(255, 255, 255)
+	}
+
+	for i, value := range values {
+		pdf.CellFormat(widths[i], pdfRowHeight, value, "1", 0, aligns[i], true, 0, "")
+	}
+	pdf.Ln(-1)
+}
+
+func writePdf(e *core.RequestEvent, pdf *gofpdf.Fpdf, fileName string) error {
+	var buf bytes.Buffer
+	err := pdf.Output(&buf)
+	if err != nil {
+		return e.Error(http.StatusInternalServerError, "Failed to generate PDF", nil)
+	}
+
+	e.Response.Header().Set("Content-Disposition", "inline; filename="+fileName)
+
+	return e.Blob(http.StatusOK, "application/pdf", buf.Bytes())
+}
@@ -1,24 +1,31 @@
 package controllers
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"net/http"
+	"time"
 
+	"github.com/patrick-salvatore/tournament-live-scoring/internal/handicap"
 	"github.com/patrick-salvatore/tournament-live-scoring/models"
 	"github.com/pocketbase/dbx"
 	"github.com/pocketbase/pocketbase/core"
 )
 
 type PlayersController struct {
-	app core.App
-	db  dbx.Builder
+	app              core.App
+	db               dbx.Builder
+	handicapProvider handicap.HandicapProvider
 }
 
-func NewPlayersController(app core.App) *PlayersController {
-	return &PlayersController{app: app, db: app.DB()}
+func NewPlayersController(app core.App, handicapProvider handicap.HandicapProvider) *PlayersController {
+	return &PlayersController{app: app, db: app.DB(), handicapProvider: handicapProvider}
 }
 
 func (pc *PlayersController) HandleGetPlayers(e *core.RequestEvent) error {
-	players, err := models.GetAllPlayers(pc.db)
+	includeArchived := e.Request.URL.Query().Get("archived") == "true"
+	players, err := models.GetAllPlayers(pc.db, includeArchived)
 
 	if err != nil {
 		return e.Error(http.StatusInternalServerError, err.Error(), nil)
@@ -37,3 +44,157 @@ func (pc *PlayersController) HandleGetPlayersByTournament(e *core.RequestEvent)
 
 	return e.JSON(http.StatusOK, players)
 }
+
+func (pc *PlayersController) HandleCreatePlayer(e *core.RequestEvent) error {
+	var data models.CreatePlayerData
+
+	err := json.NewDecoder(e.Request.Body).Decode(&data)
+	if err != nil {
+		return e.BadRequestError(err.Error(), nil)
+	}
+	if len(data.Name) == 0 {
+		return e.BadRequestError("name is required", nil)
+	}
+
+	var player *models.Player
+	err = pc.app.RunInTransaction(func(txDb core.App) error {
+		player, err = models.CreatePlayer(txDb.DB(), data)
+		if err != nil {
+			return err
+		}
+
+		_, err = models.CreateHandicapHistory(txDb.DB(), player.Id, player.Handicap, time.Now(), "create")
+		return err
+	})
+
+	if err != nil {
+		return e.Error(http.StatusInternalServerError, err.Error(), nil)
+	}
+
+	return e.JSON(http.StatusCreated, player)
+}
+
+type UpdatePlayerData struct {
+	models.PlayerUpdate
+	// EffectiveDate dates a handicap change, defaulting to today.
+	EffectiveDate string `json:"effectiveDate,omitempty"`
+}
+
+func (pc *PlayersController) HandleUpdatePlayer(e *core.RequestEvent) error {
+	playerId := e.Request.PathValue("playerId")
+	var data UpdatePlayerData
+
+	err := json.NewDecoder(e.Request.Body).Decode(&data)
+	if err != nil {
+		return e.BadRequestError(err.Error(), nil)
+	}
+
+	// an effective date on its own changes nothing
+	if !data.HasUpdates() {
+		return e.BadRequestError("no fields to update", nil)
+	}
+
+	effectiveDate := time.Now()
+	if len(data.EffectiveDate) > 0 {
+		effectiveDate, err = time.Parse(models.HANDICAP_DATE_LAYOUT, data.EffectiveDate)
+		if err != nil {
+			return e.BadRequestError("effectiveDate must be YYYY-MM-DD", err)
+		}
+	}
+
+	player, err := models.GetPlayerById(pc.db, playerId)
+	if err != nil {
+		return e.NotFoundError(err.Error(), playerId)
+	}
+
+	err = pc.app.RunInTransaction(func(txDb core.App) error {
+		_, err := models.UpdatePlayer(txDb.DB(), playerId, data.PlayerUpdate)
+		if err != nil {
+			return err
+		}
+
+		if data.Handicap != nil && *data.Handicap != player.Handicap {
+			_, err = models.CreateHandicapHistory(txDb.DB(), playerId, *data.Handicap, effectiveDate, "manual")
+		}
+		return err
+	})
+
+	if err != nil {
+		return e.Error(http.StatusInternalServerError, err.Error(), nil)
+	}
+
+	player, err = models.GetPlayerById(pc.db, playerId)
+	if err != nil {
+		return e.Error(http.StatusInternalServerError, err.Error(), nil)
+	}
+
+	return e.JSON(http.StatusOK, player)
+}
+
+func (pc *PlayersController) HandleArchivePlayer(e *core.RequestEvent) error {
+	playerId := e.Request.PathValue("playerId")
+
+	_, err := models.GetPlayerById(pc.db, playerId)
+	if err != nil {
+		return e.NotFoundError(err.Error(), playerId)
+	}
+
+	archived := true
+	_, err = models.UpdatePlayer(pc.db, playerId, models.PlayerUpdate{Archived: &archived})
+	if err != nil {
+		return e.Error(http.StatusInternalServerError, err.Error(), nil)
+	}
+
+	return e.JSON(http.StatusOK, "ok")
+}
+
+func (pc *PlayersController) HandleGetPlayerHandicapHistory(e *core.RequestEvent) error {
+	playerId := e.Request.PathValue("playerId")
+	history, err := models.GetHandicapHistory(pc.db, playerId)
+
+	if err != nil {
+		return e.Error(http.StatusInternalServerError, err.Error(), nil)
+	}
+
+	return e.JSON(http.StatusOK, history)
+}
+
+// RefreshHandicaps syncs indexes from the configured provider. It is run by
+// the handicap sync cron job as well as on demand. The provider is asked
+// first and the transaction only covers writing the changes.
+func (pc *PlayersController) RefreshHandicaps(ctx context.Context) ([]models.HandicapRefresh, error) {
+	refreshes, err := models.LookupHandicaps(ctx, pc.db, pc.handicapProvider)
+	if err != nil {
+		return nil, err
+	}
+
+	var refreshed []models.HandicapRefresh
+	err = pc.app.RunInTransaction(func(txDb core.App) error {
+		var err error
+		refreshed, err = models.ApplyHandicapRefreshes(txDb.DB(), refreshes, pc.handicapProvider.Name())
+		return err
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return refreshed, nil
+}
+
+func (pc *PlayersController) HandleRefreshHandicaps(e *core.RequestEvent) error {
+	if pc.handicapProvider == nil {
+		return e.BadRequestError("no handicap provider is configured", nil)
+	}
+
+	refreshed, err := pc.RefreshHandicaps(e.Request.Context())
+	var providerErr *models.HandicapProviderError
+	if errors.As(err, &providerErr) {
+		return e.Error(http.StatusBadGateway, err.Error(), nil)
+	}
+	if err != nil {
+		return e.Error(http.StatusInternalServerError, err.Error(), nil)
+	}
+
+	return e.JSON(http.StatusOK, refreshed)
+}
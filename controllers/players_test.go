@@ -0,0 +1,82 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/patrick-salvatore/tournament-live-scoring/internal/handicap"
+	"github.com/patrick-salvatore/tournament-live-scoring/models"
+)
+
+func TestRefreshHandicaps(t *testing.T) {
+	app := newTestApp(t)
+
+	player, err := models.CreatePlayer(app.DB(), models.CreatePlayerData{Name: "Al", Handicap: 12, ExternalId: "100"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stub := handicap.NewStubServer()
+	stub.Set("100", 10.4, "2026-10-01")
+	server := httptest.NewServer(stub)
+	defer server.Close()
+
+	down := httptest.NewServer(http.NotFoundHandler())
+	down.Close()
+
+	rec := serveTest(app, NewPlayersController(app, handicap.NewHTTPProvider(down.URL, "")).HandleRefreshHandicaps, testRequest{method: "POST"})
+	if rec.Code != http.StatusBadGateway {
+		t.Fatalf("provider down: expected 502, got %d %s", rec.Code, rec.Body)
+	}
+
+	rec = serveTest(app, NewPlayersController(app, handicap.NewHTTPProvider(server.URL, "")).HandleRefreshHandicaps, testRequest{method: "POST"})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d %s", rec.Code, rec.Body)
+	}
+
+	var refreshed []models.HandicapRefresh
+	err = json.Unmarshal(rec.Body.Bytes(), &refreshed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(refreshed) != 1 || refreshed[0].From != 12 || refreshed[0].To != 10.4 {
+		t.Fatalf("expected Al to move from 12 to 10.4, got %+v", refreshed)
+	}
+
+	history, err := models.GetHandicapHistory(app.DB(), player.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(*history) != 1 || (*history)[0].EffectiveDate != "2026-10-01" || (*history)[0].Source != "http" {
+		t.Fatalf("expected one http history entry dated 2026-10-01, got %+v", *history)
+	}
+}
+
+func TestUpdatePlayerWithoutFields(t *testing.T) {
+	app := newTestApp(t)
+	pc := NewPlayersController(app, nil)
+
+	player, err := models.CreatePlayer(app.DB(), models.CreatePlayerData{Name: "Al", Handicap: 12})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	update := func(body string) int {
+		return serveTest(app, pc.HandleUpdatePlayer, testRequest{
+			method:     "PATCH",
+			body:       body,
+			pathValues: map[string]string{"playerId": player.Id},
+		}).Code
+	}
+
+	for _, body := range []string{`{}`, `{"effectiveDate": "2026-10-01"}`} {
+		if code := update(body); code != http.StatusBadRequest {
+			t.Fatalf("%s: expected 400, got %d", body, code)
+		}
+	}
+	if code := update(`{"name": "Alan"}`); code != http.StatusOK {
+		t.Fatalf("renaming: expected 200, got %d", code)
+	}
+}
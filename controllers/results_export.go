@@ -0,0 +1,326 @@
+package controllers
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/patrick-salvatore/tournament-live-scoring/internal/xlsx"
+	"github.com/patrick-salvatore/tournament-live-scoring/models"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// resultsExportColumns is the long format every tabular export uses, one row
+// per player per hole. Downstream scripts depend on these names and their
+// order, so only ever append to it.
+var resultsExportColumns = []string{
+	"position",
+	"player_id",
+	"player_name",
+	"team_id",
+	"team_name",
+	"team_position",
+	"tee",
+	"handicap_index",
+	"playing_handicap",
+	"hole",
+	"par",
+	"stroke_index",
+	"score",
+	"gross",
+	"strokes_received",
+	"net",
+	"player_gross_total",
+	"player_net_total",
+	"player_gross_to_par",
+	"player_net_to_par",
+	"player_thru",
+	"team_gross_to_par",
+	"team_net_to_par",
+	"contests_won",
+}
+
+type ResultsExportHole struct {
+	Number          int    `json:"number"`
+	Par             int    `json:"par"`
+	StrokeIndex     int    `json:"strokeIndex"`
+	Score           string `json:"score"`
+	Gross           *int   `json:"gross"`
+	StrokesReceived int    `json:"strokesReceived"`
+	Net             *int   `json:"net"`
+}
+
+type ResultsExportPlayer struct {
+	Position        string              `json:"position"`
+	PlayerId        string              `json:"playerId"`
+	PlayerName      string              `json:"playerName"`
+	TeamId          string              `json:"teamId"`
+	TeamName        string              `json:"teamName"`
+	TeamPosition    string              `json:"teamPosition"`
+	Tee             string              `json:"tee"`
+	HandicapIndex   float64             `json:"handicapIndex"`
+	PlayingHandicap int                 `json:"playingHandicap"`
+	GrossTotal      int                 `json:"grossTotal"`
+	NetTotal        int                 `json:"netTotal"`
+	GrossToPar      int                 `json:"grossToPar"`
+	NetToPar        int                 `json:"netToPar"`
+	Thru            int                 `json:"thru"`
+	TeamGrossToPar  int                 `json:"teamGrossToPar"`
+	TeamNetToPar    int                 `json:"teamNetToPar"`
+	ContestsWon     []string            `json:"contestsWon"`
+	Holes           []ResultsExportHole `json:"holes"`
+}
+
+type ResultsExport struct {
+	TournamentId   string                `json:"tournamentId"`
+	TournamentName string                `json:"tournamentName"`
+	Players        []ResultsExportPlayer `json:"players"`
+	Contests       []ContestResult       `json:"contests"`
+}
+
+// HandleExportResults exports hole-by-hole results for every player as
+// ?format=csv (default), json or xlsx.
+func (tc *TournamentController) HandleExportResults(e *core.RequestEvent) error {
+	tournamentId := e.Request.PathValue("tournamentId")
+	format := e.Request.URL.Query().Get("format")
+	if format == "" {
+		format = "csv"
+	}
+	if format != "csv" && format != "json" && format != "xlsx" {
+		return e.BadRequestError("format must be one of csv, json or xlsx", nil)
+	}
+
+	tournament, err := models.GetTournamentById(tc.db, tournamentId)
+	if err != nil {
+		return e.NotFoundError(err.Error(), tournamentId)
+	}
+
+	results, err := tc.getResultsExport(tournament)
+	if err != nil {
+		return e.Error(http.StatusInternalServerError, err.Error(), nil)
+	}
+
+	fileName := exportFileName(tournament.Name, "results."+format)
+	e.Response.Header().Set("Content-Disposition", "attachment; filename="+fileName)
+
+	switch format {
+	case "json":
+		return e.JSON(http.StatusOK, results)
+	case "xlsx":
+		var buf bytes.Buffer
+		err = xlsx.Write(&buf, "Results", resultsExportTable(results))
+		if err != nil {
+			return e.Error(http.StatusInternalServerError, err.Error(), nil)
+		}
+		return e.Blob(http.StatusOK, "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet", buf.Bytes())
+	default:
+		var buf bytes.Buffer
+		writer := csv.NewWriter(&buf)
+		for _, row := range resultsExportTable(results) {
+			record := make([]string, len(row))
+			for i, value := range row {
+				if value != nil {
+					record[i] = fmt.Sprint(value)
+				}
+			}
+			writer.Write(record)
+		}
+		writer.Flush()
+		if err := writer.Error(); err != nil {
+			return e.Error(http.StatusInternalServerError, err.Error(), nil)
+		}
+		return e.Blob(http.StatusOK, "text/csv", buf.Bytes())
+	}
+}
+
+func (tc *TournamentController) getResultsExport(tournament *models.Tournament) (*ResultsExport, error) {
+	course, err := models.GetCourseByTournamentId(tc.db, tournament.Id)
+	if err != nil {
+		return nil, err
+	}
+	courseHoles := getHoleDataMap(course)
+
+	teams, err := models.GetTeamsByTournamentId(tc.db, tournament.Id)
+	if err != nil {
+		return nil, err
+	}
+	teamIds := []string{}
+	teamNames := make(map[string]string)
+	for _, team := range *teams {
+		teamIds = append(teamIds, team.Id)
+		teamNames[team.Id] = team.Name
+	}
+
+	holes, err := models.GetTournamentHoles(tc.db, tournament.Id, teamIds)
+	if err != nil {
+		return nil, err
+	}
+	applyStrokeHoles(course, holes)
+
+	individual, err := leaderboards.Get(tc.db, tournament.Id, true)
+	if err != nil {
+		return nil, err
+	}
+	team, err := leaderboards.Get(tc.db, tournament.Id, false)
+	if err != nil {
+		return nil, err
+	}
+
+	playerPositions := make(map[string]string)
+	for _, row := range rankLeaderboardRows(individual.Rows, netScore) {
+		playerPositions[row.Id] = formatPosition(row)
+	}
+	teamPositions := make(map[string]RankedRow)
+	for _, row := range rankLeaderboardRows(team.Rows, netScore) {
+		teamPositions[row.Id] = row
+	}
+
+	contests, err := getContestResults(tc.db, tournament.Id, false)
+	if err != nil {
+		return nil, err
+	}
+	contestsWon := make(map[string][]string)
+	for _, contest := range contests {
+		if contest.Leader != nil {
+			contestsWon[contest.Leader.PlayerId] = append(contestsWon[contest.Leader.PlayerId], contest.Name)
+		}
+	}
+
+	players := []ResultsExportPlayer{}
+	playerIndex := make(map[string]int)
+	for _, hole := range *holes {
+		index, ok := playerIndex[hole.PlayerId]
+		if !ok {
+			teamRow, ranked := teamPositions[hole.TeamId]
+			teamPosition := ""
+			if ranked {
+				teamPosition = formatPosition(teamRow)
+			}
+
+			players = append(players, ResultsExportPlayer{
+				Position:        playerPositions[hole.PlayerId],
+				PlayerId:        hole.PlayerId,
+				PlayerName:      hole.PlayerName,
+				TeamId:          hole.TeamId,
+				TeamName:        teamNames[hole.TeamId],
+				TeamPosition:    teamPosition,
+				Tee:             hole.Tee,
+				HandicapIndex:   hole.PlayerHandicap,
+				PlayingHandicap: hole.PlayingHandicap,
+				TeamGrossToPar:  teamRow.Gross,
+				TeamNetToPar:    teamRow.Net,
+				ContestsWon:     append([]string{}, contestsWon[hole.PlayerId]...),
+				Holes:           []ResultsExportHole{},
+			})
+			index = len(players) - 1
+			playerIndex[hole.PlayerId] = index
+		}
+
+		player := &players[index]
+		courseHole := courseHoles[hole.Number]
+		exportHole := ResultsExportHole{
+			Number:          hole.Number,
+			Par:             courseHole.Par,
+			StrokeIndex:     courseHole.Handicap,
+			Score:           hole.Score,
+			StrokesReceived: hole.StrokeHole,
+		}
+
+		if gross, ok := holeGrossScore(hole.Score, courseHole.Par); ok {
+			net := gross - hole.StrokeHole
+			exportHole.Gross = &gross
+			exportHole.Net = &net
+
+			player.Thru++
+			player.GrossTotal += gross
+			player.NetTotal += net
+			player.GrossToPar += gross - courseHole.Par
+			player.NetToPar += net - courseHole.Par
+		}
+
+		player.Holes = append(player.Holes, exportHole)
+	}
+
+	sort.SliceStable(players, func(i, j int) bool {
+		if players[i].NetToPar != players[j].NetToPar {
+			return players[i].NetToPar < players[j].NetToPar
+		}
+		return players[i].PlayerName < players[j].PlayerName
+	})
+
+	return &ResultsExport{
+		TournamentId:   tournament.Id,
+		TournamentName: tournament.Name,
+		Players:        players,
+		Contests:       contests,
+	}, nil
+}
+
+func resultsExportTable(results *ResultsExport) [][]any {
+	header := make([]any, len(resultsExportColumns))
+	for i, column := range resultsExportColumns {
+		header[i] = column
+	}
+
+	table := [][]any{header}
+	for _, player := range results.Players {
+		for _, hole := range player.Holes {
+			var gross, net any
+			if hole.Gross != nil {
+				gross = *hole.Gross
+				net = *hole.Net
+			}
+
+			table = append(table, []any{
+				player.Position,
+				player.PlayerId,
+				player.PlayerName,
+				player.TeamId,
+				player.TeamName,
+				player.TeamPosition,
+				player.Tee,
+				player.HandicapIndex,
+				player.PlayingHandicap,
+				hole.Number,
+				hole.Par,
+				hole.StrokeIndex,
+				hole.Score,
+				gross,
+				hole.StrokesReceived,
+				net,
+				player.GrossTotal,
+				player.NetTotal,
+				player.GrossToPar,
+				player.NetToPar,
+				player.Thru,
+				player.TeamGrossToPar,
+				player.TeamNetToPar,
+				strings.Join(player.ContestsWon, "; "),
+			})
+		}
+	}
+
+	return table
+}
+
+// holeGrossScore reads an entered score, counting a pick up ("X") as a
+// triple bogey the same way the leaderboard does.
+func holeGrossScore(score string, par int) (int, bool) {
+	if len(score) == 0 {
+		return 0, false
+	}
+	if score == "X" {
+		return 3 + par, true
+	}
+
+	gross, err := strconv.Atoi(score)
+	if err != nil {
+		return 0, false
+	}
+
+	return gross, true
+}
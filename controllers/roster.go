@@ -0,0 +1,393 @@
+package controllers
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/patrick-salvatore/tournament-live-scoring/models"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// rosterColumns maps accepted CSV headers, lowercased with spaces and
+// underscores removed, onto the player field they populate.
+var rosterColumns = map[string]string{
+	"name":          "name",
+	"handicap":      "handicap",
+	"handicapindex": "handicap",
+	"index":         "handicap",
+	"tee":           "preferredTee",
+	"preferredtee":  "preferredTee",
+	"email":         "email",
+	"externalid":    "externalId",
+	"ghin":          "externalId",
+	"ghinid":        "externalId",
+}
+
+var rosterExportHeader = []string{
+	"team_id",
+	"team_name",
+	"player_id",
+	"external_id",
+	"name",
+	"email",
+	"handicap_index",
+	"tournament_handicap_index",
+	"tee",
+}
+
+type RosterFieldChange struct {
+	Field string `json:"field"`
+	From  string `json:"from"`
+	To    string `json:"to"`
+}
+
+type RosterImportChange struct {
+	Line       int                 `json:"line"`
+	Action     string              `json:"action"`
+	PlayerId   string              `json:"playerId,omitempty"`
+	ExternalId string              `json:"externalId"`
+	Name       string              `json:"name"`
+	Changes    []RosterFieldChange `json:"changes,omitempty"`
+}
+
+type RosterImportConflict struct {
+	Line       int    `json:"line"`
+	ExternalId string `json:"externalId,omitempty"`
+	Name       string `json:"name,omitempty"`
+	Reason     string `json:"reason"`
+}
+
+type RosterImportReport struct {
+	DryRun    bool                   `json:"dryRun"`
+	Created   int                    `json:"created"`
+	Updated   int                    `json:"updated"`
+	Unchanged int                    `json:"unchanged"`
+	Changes   []RosterImportChange   `json:"changes"`
+	Conflicts []RosterImportConflict `json:"conflicts"`
+}
+
+type rosterImportRow struct {
+	line        int
+	data        models.CreatePlayerData
+	hasHandicap bool
+}
+
+// HandleImportPlayers upserts players from a CSV keyed on external id.
+// Rows that conflict are reported and skipped; with ?dryRun=true nothing is
+// written and the report describes what would change.
+func (pc *PlayersController) HandleImportPlayers(e *core.RequestEvent) error {
+	dryRun := e.Request.URL.Query().Get("dryRun") == "true"
+
+	body, err := readRosterUpload(e)
+	if err != nil {
+		return e.BadRequestError(err.Error(), nil)
+	}
+	defer body.Close()
+
+	rows, conflicts, err := parseRosterCsv(body)
+	if err != nil {
+		return e.BadRequestError(err.Error(), nil)
+	}
+
+	existing, err := models.GetAllPlayers(pc.db, true)
+	if err != nil {
+		return e.Error(http.StatusInternalServerError, err.Error(), nil)
+	}
+
+	playersByExternalId := make(map[string]models.Player)
+	playersByName := make(map[string]models.Player)
+	for _, player := range *existing {
+		if len(player.ExternalId) > 0 {
+			playersByExternalId[player.ExternalId] = player
+		}
+		playersByName[strings.ToLower(player.Name)] = player
+	}
+
+	report := RosterImportReport{
+		DryRun:    dryRun,
+		Changes:   []RosterImportChange{},
+		Conflicts: conflicts,
+	}
+
+	creates := []rosterImportRow{}
+	updates := map[string]models.PlayerUpdate{}
+	for _, row := range rows {
+		change := RosterImportChange{
+			Line:       row.line,
+			ExternalId: row.data.ExternalId,
+			Name:       row.data.Name,
+		}
+
+		player, ok := playersByExternalId[row.data.ExternalId]
+		if !ok {
+			if match, ok := playersByName[strings.ToLower(row.data.Name)]; ok {
+				report.Conflicts = append(report.Conflicts, RosterImportConflict{
+					Line:       row.line,
+					ExternalId: row.data.ExternalId,
+					Name:       row.data.Name,
+					Reason:     fmt.Sprintf("player %s already exists with external id %q", match.Id, match.ExternalId),
+				})
+				continue
+			}
+
+			change.Action = "create"
+			creates = append(creates, row)
+			report.Created++
+			report.Changes = append(report.Changes, change)
+			continue
+		}
+
+		change.PlayerId = player.Id
+		update, fieldChanges := diffRosterPlayer(player, row)
+		if len(fieldChanges) == 0 {
+			change.Action = "unchanged"
+			report.Unchanged++
+			report.Changes = append(report.Changes, change)
+			continue
+		}
+
+		change.Action = "update"
+		change.Changes = fieldChanges
+		updates[player.Id] = update
+		report.Updated++
+		report.Changes = append(report.Changes, change)
+	}
+
+	if dryRun {
+		return e.JSON(http.StatusOK, report)
+	}
+
+	err = pc.app.RunInTransaction(func(txDb core.App) error {
+		for _, row := range creates {
+			player, err := models.CreatePlayer(txDb.DB(), row.data)
+			if err != nil {
+				return fmt.Errorf("line %d: %w", row.line, err)
+			}
+
+			_, err = models.CreateHandicapHistory(txDb.DB(), player.Id, player.Handicap, time.Now(), "import")
+			if err != nil {
+				return err
+			}
+		}
+
+		for playerId, update := range updates {
+			_, err := models.UpdatePlayer(txDb.DB(), playerId, update)
+			if err != nil {
+				return err
+			}
+
+			if update.Handicap != nil {
+				_, err = models.CreateHandicapHistory(txDb.DB(), playerId, *update.Handicap, time.Now(), "import")
+				if err != nil {
+					return err
+				}
+			}
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return e.Error(http.StatusInternalServerError, err.Error(), nil)
+	}
+
+	return e.JSON(http.StatusOK, report)
+}
+
+func (pc *PlayersController) HandleExportTournamentPlayers(e *core.RequestEvent) error {
+	tournamentId := e.Request.PathValue("tournamentId")
+
+	tournament, err := models.GetTournamentById(pc.db, tournamentId)
+	if err != nil {
+		return e.NotFoundError(err.Error(), tournamentId)
+	}
+
+	roster, err := models.GetTournamentRoster(pc.db, tournamentId)
+	if err != nil {
+		return e.Error(http.StatusInternalServerError, err.Error(), nil)
+	}
+
+	var buf bytes.Buffer
+	writer := csv.NewWriter(&buf)
+	writer.Write(rosterExportHeader)
+	for _, entry := range *roster {
+		writer.Write([]string{
+			entry.TeamId,
+			entry.TeamName,
+			entry.PlayerId,
+			entry.ExternalId,
+			entry.Name,
+			entry.Email,
+			strconv.FormatFloat(entry.Handicap, 'f', -1, 64),
+			strconv.FormatFloat(entry.TournamentHandicap, 'f', -1, 64),
+			entry.Tee,
+		})
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return e.Error(http.StatusInternalServerError, err.Error(), nil)
+	}
+
+	e.Response.Header().Set("Content-Disposition", "attachment; filename="+exportFileName(tournament.Name, "players.csv"))
+
+	return e.Blob(http.StatusOK, "text/csv", buf.Bytes())
+}
+
+func readRosterUpload(e *core.RequestEvent) (io.ReadCloser, error) {
+	if !strings.HasPrefix(e.Request.Header.Get("Content-Type"), "multipart/form-data") {
+		return e.Request.Body, nil
+	}
+
+	file, _, err := e.Request.FormFile("file")
+	if errors.Is(err, http.ErrMissingFile) {
+		return nil, fmt.Errorf("multipart upload must include a \"file\" field")
+	}
+
+	return file, err
+}
+
+func parseRosterCsv(r io.Reader) ([]rosterImportRow, []RosterImportConflict, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, nil, fmt.Errorf("csv is empty")
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	columns := make(map[string]int)
+	for i, name := range header {
+		key := strings.NewReplacer(" ", "", "_", "", "-", "").Replace(strings.ToLower(strings.TrimSpace(name)))
+		if field, ok := rosterColumns[key]; ok {
+			columns[field] = i
+		}
+	}
+	for _, required := range []string{"name", "externalId"} {
+		if _, ok := columns[required]; !ok {
+			return nil, nil, fmt.Errorf("csv is missing a %s column", required)
+		}
+	}
+
+	value := func(record []string, field string) string {
+		i, ok := columns[field]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+
+	rows := []rosterImportRow{}
+	conflicts := []RosterImportConflict{}
+	seen := make(map[string]int)
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+
+		row := rosterImportRow{
+			line: line,
+			data: models.CreatePlayerData{
+				Name:         value(record, "name"),
+				Email:        value(record, "email"),
+				PreferredTee: value(record, "preferredTee"),
+				ExternalId:   value(record, "externalId"),
+			},
+		}
+
+		conflict := RosterImportConflict{Line: line, ExternalId: row.data.ExternalId, Name: row.data.Name}
+		if len(row.data.ExternalId) == 0 {
+			conflict.Reason = "missing external id"
+			conflicts = append(conflicts, conflict)
+			continue
+		}
+		if len(row.data.Name) == 0 {
+			conflict.Reason = "missing name"
+			conflicts = append(conflicts, conflict)
+			continue
+		}
+		if previous, ok := seen[row.data.ExternalId]; ok {
+			conflict.Reason = fmt.Sprintf("external id already used on line %d", previous)
+			conflicts = append(conflicts, conflict)
+			continue
+		}
+
+		if handicap := value(record, "handicap"); len(handicap) > 0 {
+			row.data.Handicap, err = parseHandicapIndex(handicap)
+			if err != nil {
+				conflict.Reason = err.Error()
+				conflicts = append(conflicts, conflict)
+				continue
+			}
+			row.hasHandicap = true
+		}
+
+		seen[row.data.ExternalId] = line
+		rows = append(rows, row)
+	}
+
+	return rows, conflicts, nil
+}
+
+// parseHandicapIndex accepts plus handicaps written as "+2.1" and stores
+// them as negative indexes.
+func parseHandicapIndex(value string) (float64, error) {
+	plus := strings.HasPrefix(value, "+")
+
+	index, err := strconv.ParseFloat(strings.TrimPrefix(value, "+"), 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid handicap index %q", value)
+	}
+	if plus {
+		index = -index
+	}
+
+	return index, nil
+}
+
+func diffRosterPlayer(player models.Player, row rosterImportRow) (models.PlayerUpdate, []RosterFieldChange) {
+	data := row.data
+	update := models.PlayerUpdate{}
+	changes := []RosterFieldChange{}
+
+	if data.Name != player.Name {
+		update.Name = &data.Name
+		changes = append(changes, RosterFieldChange{Field: "name", From: player.Name, To: data.Name})
+	}
+	if row.hasHandicap && data.Handicap != player.Handicap {
+		update.Handicap = &data.Handicap
+		changes = append(changes, RosterFieldChange{
+			Field: "handicap",
+			From:  strconv.FormatFloat(player.Handicap, 'f', -1, 64),
+			To:    strconv.FormatFloat(data.Handicap, 'f', -1, 64),
+		})
+	}
+	if len(data.Email) > 0 && data.Email != player.Email {
+		update.Email = &data.Email
+		changes = append(changes, RosterFieldChange{Field: "email", From: player.Email, To: data.Email})
+	}
+	if len(data.PreferredTee) > 0 && data.PreferredTee != player.PreferredTee {
+		update.PreferredTee = &data.PreferredTee
+		changes = append(changes, RosterFieldChange{Field: "preferredTee", From: player.PreferredTee, To: data.PreferredTee})
+	}
+	if player.Archived {
+		archived := false
+		update.Archived = &archived
+		changes = append(changes, RosterFieldChange{Field: "archived", From: "true", To: "false"})
+	}
+
+	return update, changes
+}
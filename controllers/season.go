@@ -0,0 +1,536 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/patrick-salvatore/tournament-live-scoring/models"
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+type SeasonsController struct {
+	app core.App
+	db  dbx.Builder
+}
+
+func NewSeasonsController(app core.App) *SeasonsController {
+	return &SeasonsController{app: app, db: app.DB()}
+}
+
+type SeasonDetail struct {
+	models.Season
+	Tournaments []models.SeasonTournament `json:"tournaments"`
+}
+
+type SeasonTournamentRequest struct {
+	Major bool `json:"major"`
+}
+
+// SeasonResult is the points one player took from one season tournament.
+type SeasonResult struct {
+	TournamentId string  `json:"tournamentId"`
+	Position     string  `json:"position"`
+	Points       float64 `json:"points"`
+	Dropped      bool    `json:"dropped"`
+}
+
+type SeasonStanding struct {
+	Position   string         `json:"position"`
+	PlayerId   string         `json:"playerId"`
+	PlayerName string         `json:"playerName"`
+	Points     float64        `json:"points"`
+	Dropped    float64        `json:"droppedPoints"`
+	Played     int            `json:"played"`
+	Wins       int            `json:"wins"`
+	Results    []SeasonResult `json:"results"`
+
+	place int
+}
+
+type SeasonStandingsTournament struct {
+	models.SeasonTournament
+	Counted bool `json:"counted"`
+}
+
+type SeasonStandings struct {
+	Season      models.Season               `json:"season"`
+	Tournaments []SeasonStandingsTournament `json:"tournaments"`
+	// Counting is how many results each player keeps once the worst are
+	// dropped.
+	Counting  int              `json:"counting"`
+	Standings []SeasonStanding `json:"standings"`
+}
+
+func (sc *SeasonsController) HandleGetSeasons(e *core.RequestEvent) error {
+	seasons, err := models.GetSeasons(sc.db)
+	if err != nil {
+		return e.Error(http.StatusInternalServerError, err.Error(), nil)
+	}
+
+	return e.JSON(http.StatusOK, seasons)
+}
+
+// HandleCreateSeason starts a season. The points table, major multiplier and
+// scoring fall back to the defaults when left out.
+func (sc *SeasonsController) HandleCreateSeason(e *core.RequestEvent) error {
+	var data models.SeasonCreate
+
+	err := json.NewDecoder(e.Request.Body).Decode(&data)
+	if err != nil {
+		return e.BadRequestError(err.Error(), nil)
+	}
+	if len(data.Name) == 0 {
+		return e.BadRequestError("name is required", nil)
+	}
+	if data.PointsTable == nil {
+		data.PointsTable = models.DEFAULT_SEASON_POINTS_TABLE
+	}
+	if data.MajorMultiplier == 0 {
+		data.MajorMultiplier = models.DEFAULT_SEASON_MAJOR_MULTIPLIER
+	}
+	if data.Scoring == "" {
+		data.Scoring = models.SEASON_SCORING_NET
+	}
+
+	err = validateSeason(data.PointsTable, data.MajorMultiplier, data.DropWorst, data.Scoring)
+	if err != nil {
+		return e.BadRequestError(err.Error(), nil)
+	}
+
+	season, err := models.CreateSeason(sc.db, data)
+	if err != nil {
+		return e.Error(http.StatusInternalServerError, err.Error(), nil)
+	}
+
+	return e.JSON(http.StatusCreated, season)
+}
+
+func (sc *SeasonsController) HandleGetSeason(e *core.RequestEvent) error {
+	seasonId := e.Request.PathValue("seasonId")
+
+	season, err := models.GetSeasonById(sc.db, seasonId)
+	if err != nil {
+		return e.NotFoundError(err.Error(), seasonId)
+	}
+
+	tournaments, err := models.GetSeasonTournaments(sc.db, seasonId)
+	if err != nil {
+		return e.Error(http.StatusInternalServerError, err.Error(), nil)
+	}
+
+	return e.JSON(http.StatusOK, SeasonDetail{Season: *season, Tournaments: *tournaments})
+}
+
+func (sc *SeasonsController) HandleUpdateSeason(e *core.RequestEvent) error {
+	var data models.SeasonUpdate
+	seasonId := e.Request.PathValue("seasonId")
+
+	err := json.NewDecoder(e.Request.Body).Decode(&data)
+	if err != nil {
+		return e.BadRequestError(err.Error(), nil)
+	}
+
+	season, err := models.GetSeasonById(sc.db, seasonId)
+	if err != nil {
+		return e.NotFoundError(err.Error(), seasonId)
+	}
+
+	if data.Name != nil && len(*data.Name) == 0 {
+		return e.BadRequestError("name is required", nil)
+	}
+
+	pointsTable := season.PointsTable
+	if data.PointsTable != nil {
+		pointsTable = *data.PointsTable
+	}
+	majorMultiplier := season.MajorMultiplier
+	if data.MajorMultiplier != nil {
+		majorMultiplier = *data.MajorMultiplier
+	}
+	dropWorst := season.DropWorst
+	if data.DropWorst != nil {
+		dropWorst = *data.DropWorst
+	}
+	scoring := season.Scoring
+	if data.Scoring != nil {
+		scoring = *data.Scoring
+	}
+
+	err = validateSeason(pointsTable, majorMultiplier, dropWorst, scoring)
+	if err != nil {
+		return e.BadRequestError(err.Error(), nil)
+	}
+
+	_, err = models.UpdateSeason(sc.db, seasonId, data)
+	if err != nil {
+		return e.BadRequestError(err.Error(), nil)
+	}
+
+	season, err = models.GetSeasonById(sc.db, seasonId)
+	if err != nil {
+		return e.Error(http.StatusInternalServerError, err.Error(), nil)
+	}
+
+	return e.JSON(http.StatusOK, season)
+}
+
+// HandleSetSeasonTournament adds a tournament to a season, or flags it as a
+// major (or not) when it is already there.
+func (sc *SeasonsController) HandleSetSeasonTournament(e *core.RequestEvent) error {
+	var data SeasonTournamentRequest
+	seasonId := e.Request.PathValue("seasonId")
+	tournamentId := e.Request.PathValue("tournamentId")
+
+	if e.Request.ContentLength != 0 {
+		err := json.NewDecoder(e.Request.Body).Decode(&data)
+		if err != nil {
+			return e.BadRequestError(err.Error(), nil)
+		}
+	}
+
+	_, err := models.GetSeasonById(sc.db, seasonId)
+	if err != nil {
+		return e.NotFoundError(err.Error(), seasonId)
+	}
+	_, err = models.GetTournamentById(sc.db, tournamentId)
+	if err != nil {
+		return e.NotFoundError(err.Error(), tournamentId)
+	}
+
+	err = sc.app.RunInTransaction(func(txDb core.App) error {
+		err := models.SetSeasonTournament(txDb.DB(), seasonId, tournamentId, data.Major)
+		if err != nil {
+			return err
+		}
+
+		_, err = models.CreateAuditLog(txDb.DB(), tournamentId, "season.tournament.set", e.RealIP(), map[string]any{
+			"seasonId": seasonId,
+			"major":    data.Major,
+		})
+		return err
+	})
+
+	if err != nil {
+		return e.Error(http.StatusInternalServerError, err.Error(), nil)
+	}
+
+	tournaments, err := models.GetSeasonTournaments(sc.db, seasonId)
+	if err != nil {
+		return e.Error(http.StatusInternalServerError, err.Error(), nil)
+	}
+
+	return e.JSON(http.StatusOK, tournaments)
+}
+
+func (sc *SeasonsController) HandleRemoveSeasonTournament(e *core.RequestEvent) error {
+	seasonId := e.Request.PathValue("seasonId")
+	tournamentId := e.Request.PathValue("tournamentId")
+
+	var removed bool
+	err := sc.app.RunInTransaction(func(txDb core.App) error {
+		var err error
+		removed, err = models.RemoveSeasonTournament(txDb.DB(), seasonId, tournamentId)
+		if err != nil || !removed {
+			return err
+		}
+
+		_, err = models.CreateAuditLog(txDb.DB(), tournamentId, "season.tournament.remove", e.RealIP(), map[string]any{
+			"seasonId": seasonId,
+		})
+		return err
+	})
+
+	if err != nil {
+		return e.Error(http.StatusInternalServerError, err.Error(), nil)
+	}
+	if !removed {
+		return e.NotFoundError("tournament is not part of this season", tournamentId)
+	}
+
+	return e.NoContent(http.StatusNoContent)
+}
+
+func (sc *SeasonsController) HandleGetSeasonStandings(e *core.RequestEvent) error {
+	seasonId := e.Request.PathValue("seasonId")
+
+	season, err := models.GetSeasonById(sc.db, seasonId)
+	if err != nil {
+		return e.NotFoundError(err.Error(), seasonId)
+	}
+
+	standings, err := sc.getSeasonStandings(season)
+	if err != nil {
+		return e.Error(http.StatusInternalServerError, err.Error(), nil)
+	}
+
+	return e.JSON(http.StatusOK, standings)
+}
+
+// HandleGetSeasonStandingsPdf prints the order of merit with each
+// tournament's points when they fit across the page. Dropped results are
+// shown in brackets.
+func (sc *SeasonsController) HandleGetSeasonStandingsPdf(e *core.RequestEvent) error {
+	seasonId := e.Request.PathValue("seasonId")
+
+	season, err := models.GetSeasonById(sc.db, seasonId)
+	if err != nil {
+		return e.NotFoundError(err.Error(), seasonId)
+	}
+
+	standings, err := sc.getSeasonStandings(season)
+	if err != nil {
+		return e.Error(http.StatusInternalServerError, err.Error(), nil)
+	}
+
+	counted := []SeasonStandingsTournament{}
+	for _, tournament := range standings.Tournaments {
+		if tournament.Counted {
+			counted = append(counted, tournament)
+		}
+	}
+
+	const maxTournamentColumns = 12
+	showTournaments := len(counted) <= maxTournamentColumns
+
+	pdf := newExportPdf("L", season.Name, "Order of Merit")
+	pdf.AddPage()
+
+	widths := []float64{15, 0, 18, 15, 22}
+	labels := []string{"Pos", "Name", "Played", "Wins", "Points"}
+	aligns := []string{"C", "L", "C", "C", "C"}
+	if showTournaments {
+		for i := range counted {
+			widths = append(widths, 13)
+			labels = append(labels, strconv.Itoa(i+1))
+			aligns = append(aligns, "C")
+		}
+	}
+
+	nameWidth := 277.0
+	for _, width := range widths {
+		nameWidth -= width
+	}
+	widths[1] = nameWidth
+
+	pdfTableHeader(pdf, widths, labels)
+	for i, standing := range standings.Standings {
+		values := []string{
+			standing.Position,
+			standing.PlayerName,
+			strconv.Itoa(standing.Played),
+			strconv.Itoa(standing.Wins),
+			formatPoints(standing.Points),
+		}
+
+		if showTournaments {
+			results := make(map[string]SeasonResult)
+			for _, result := range standing.Results {
+				results[result.TournamentId] = result
+			}
+			for _, tournament := range counted {
+				result, ok := results[tournament.TournamentId]
+				switch {
+				case !ok:
+					values = append(values, "-")
+				case result.Dropped:
+					values = append(values, "("+formatPoints(result.Points)+")")
+				default:
+					values = append(values, formatPoints(result.Points))
+				}
+			}
+		}
+
+		pdfTableRow(pdf, i, widths, aligns, values)
+	}
+
+	pdf.Ln(6)
+	pdf.SetFont("Arial", "B", 12)
+	pdf.CellFormat(277, pdfRowHeight, "Tournaments", "", 1, "L", false, 0, "")
+
+	pdf.SetFont("Arial", "", 10)
+	for i, tournament := range counted {
+		line := fmt.Sprintf("%d. %s", i+1, tournament.TournamentName)
+		if tournament.Major {
+			line += fmt.Sprintf(" (major, x%s)", formatPoints(season.MajorMultiplier))
+		}
+		pdf.CellFormat(277, 6, line, "", 1, "L", false, 0, "")
+	}
+
+	pdf.Ln(3)
+	pdf.SetFont("Arial", "I", 9)
+	pdf.CellFormat(277, 6, fmt.Sprintf("Best %d of %d results count, by %s score.", standings.Counting, len(counted), season.Scoring), "", 1, "L", false, 0, "")
+
+	return writePdf(e, pdf, exportFileName(season.Name, "standings.pdf"))
+}
+
+// getSeasonStandings awards points from the final individual leaderboard of
+// every finished tournament in the season. Players tied on a position split
+// the points for the places they span. Each player keeps their best results
+// once dropWorst are taken off the number of finished tournaments, so missed
+// tournaments are the first to be dropped.
+func (sc *SeasonsController) getSeasonStandings(season *models.Season) (*SeasonStandings, error) {
+	tournaments, err := models.GetSeasonTournaments(sc.db, season.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	score := netScore
+	if season.Scoring == models.SEASON_SCORING_GROSS {
+		score = grossScore
+	}
+
+	standings := SeasonStandings{
+		Season:      *season,
+		Tournaments: []SeasonStandingsTournament{},
+		Standings:   []SeasonStanding{},
+	}
+
+	byPlayer := make(map[string]*SeasonStanding)
+	finished := 0
+	for _, tournament := range *tournaments {
+		counted := tournament.Status == models.TOURNAMENT_STATUS_FINAL || tournament.Status == models.TOURNAMENT_STATUS_ARCHIVED
+		standings.Tournaments = append(standings.Tournaments, SeasonStandingsTournament{SeasonTournament: tournament, Counted: counted})
+		if !counted {
+			continue
+		}
+		finished++
+
+		multiplier := 1.0
+		if tournament.Major {
+			multiplier = season.MajorMultiplier
+		}
+
+		snapshot, err := leaderboards.Get(sc.db, tournament.TournamentId, true)
+		if err != nil {
+			return nil, err
+		}
+
+		rows := []LeaderboardRow{}
+		for _, row := range snapshot.Rows {
+			if row.Thru > 0 {
+				rows = append(rows, row)
+			}
+		}
+
+		ranked := rankLeaderboardRows(rows, score)
+		tied := make(map[int]int)
+		for _, row := range ranked {
+			tied[row.Position]++
+		}
+
+		for _, row := range ranked {
+			standing, ok := byPlayer[row.Id]
+			if !ok {
+				standing = &SeasonStanding{PlayerId: row.Id, PlayerName: row.TeamName, Results: []SeasonResult{}}
+				byPlayer[row.Id] = standing
+			}
+
+			standing.Played++
+			if row.Position == 1 {
+				standing.Wins++
+			}
+			standing.Results = append(standing.Results, SeasonResult{
+				TournamentId: tournament.TournamentId,
+				Position:     formatPosition(row),
+				Points:       roundTo(positionPoints(season.PointsTable, row.Position, tied[row.Position])*multiplier, 2),
+			})
+		}
+	}
+
+	standings.Counting = finished - season.DropWorst
+	if standings.Counting < 1 {
+		standings.Counting = 1
+	}
+
+	for _, standing := range byPlayer {
+		best := make([]int, len(standing.Results))
+		for i := range best {
+			best[i] = i
+		}
+		sort.SliceStable(best, func(i, j int) bool {
+			return standing.Results[best[i]].Points > standing.Results[best[j]].Points
+		})
+
+		for rank, index := range best {
+			result := &standing.Results[index]
+			if rank < standings.Counting {
+				standing.Points += result.Points
+			} else {
+				result.Dropped = true
+				standing.Dropped += result.Points
+			}
+		}
+		standing.Points = roundTo(standing.Points, 2)
+		standing.Dropped = roundTo(standing.Dropped, 2)
+
+		standings.Standings = append(standings.Standings, *standing)
+	}
+
+	sort.SliceStable(standings.Standings, func(i, j int) bool {
+		a, b := standings.Standings[i], standings.Standings[j]
+		if a.Points != b.Points {
+			return a.Points > b.Points
+		}
+		return a.PlayerName < b.PlayerName
+	})
+
+	for i := range standings.Standings {
+		standing := &standings.Standings[i]
+		standing.place = i + 1
+		if i > 0 && standing.Points == standings.Standings[i-1].Points {
+			standing.place = standings.Standings[i-1].place
+		}
+	}
+	for i := range standings.Standings {
+		standing := &standings.Standings[i]
+		standing.Position = strconv.Itoa(standing.place)
+
+		tiedAbove := i > 0 && standings.Standings[i-1].place == standing.place
+		tiedBelow := i+1 < len(standings.Standings) && standings.Standings[i+1].place == standing.place
+		if tiedAbove || tiedBelow {
+			standing.Position = "T" + standing.Position
+		}
+	}
+
+	return &standings, nil
+}
+
+// positionPoints averages the table's points over the places a tie spans.
+// Places past the end of the table are worth nothing.
+func positionPoints(table []float64, position int, tied int) float64 {
+	total := 0.0
+	for place := position; place < position+tied; place++ {
+		if place-1 < len(table) {
+			total += table[place-1]
+		}
+	}
+
+	return total / float64(tied)
+}
+
+func formatPoints(points float64) string {
+	return strconv.FormatFloat(points, 'f', -1, 64)
+}
+
+func validateSeason(pointsTable []float64, majorMultiplier float64, dropWorst int, scoring string) error {
+	for _, points := range pointsTable {
+		if points < 0 {
+			return fmt.Errorf("pointsTable can't award negative points")
+		}
+	}
+	if majorMultiplier <= 0 {
+		return fmt.Errorf("majorMultiplier must be greater than 0")
+	}
+	if dropWorst < 0 {
+		return fmt.Errorf("dropWorst can't be negative")
+	}
+	if scoring != models.SEASON_SCORING_NET && scoring != models.SEASON_SCORING_GROSS {
+		return fmt.Errorf("scoring must be net or gross")
+	}
+
+	return nil
+}
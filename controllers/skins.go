@@ -0,0 +1,413 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+
+	"github.com/patrick-salvatore/tournament-live-scoring/models"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+const (
+	SKIN_STATUS_WON     = "won"
+	SKIN_STATUS_CARRIED = "carried"
+	SKIN_STATUS_VOID    = "void"
+	SKIN_STATUS_PENDING = "pending"
+)
+
+type SkinsHole struct {
+	Number int `json:"number"`
+	Par    int `json:"par"`
+	// Skins is what the hole is worth including anything carried onto it.
+	Skins  int    `json:"skins"`
+	Status string `json:"status"`
+	// Reason explains a hole that wasn't won: tied, minScore or notValidated.
+	Reason     string `json:"reason,omitempty"`
+	WinnerId   string `json:"winnerId,omitempty"`
+	WinnerName string `json:"winnerName,omitempty"`
+	Score      *int   `json:"score"`
+}
+
+type SkinsWinner struct {
+	PlayerId   string  `json:"playerId"`
+	PlayerName string  `json:"playerName"`
+	Skins      int     `json:"skins"`
+	Holes      []int   `json:"holes"`
+	Payout     float64 `json:"payout"`
+}
+
+type SkinsBoard struct {
+	Game      models.SkinsGame `json:"game"`
+	Entrants  int              `json:"entrants"`
+	Holes     []SkinsHole      `json:"holes"`
+	Winners   []SkinsWinner    `json:"winners"`
+	SkinsWon  int              `json:"skinsWon"`
+	SkinValue float64          `json:"skinValue"`
+	// Carryover counts skins still riding on an unfinished or final tie.
+	Carryover int `json:"carryover"`
+}
+
+type skinsScore struct {
+	playerId   string
+	playerName string
+	score      int
+}
+
+func (tc *TournamentController) HandleGetSkinsGames(e *core.RequestEvent) error {
+	tournamentId := e.Request.PathValue("tournamentId")
+
+	games, err := models.GetSkinsGames(tc.db, tournamentId)
+	if err != nil {
+		return e.Error(http.StatusInternalServerError, err.Error(), nil)
+	}
+
+	return e.JSON(http.StatusOK, games)
+}
+
+// HandleCreateSkinsGame sets up a skins game for the whole field, gross
+// unless asked otherwise.
+func (tc *TournamentController) HandleCreateSkinsGame(e *core.RequestEvent) error {
+	var data models.SkinsGameCreate
+	tournamentId := e.Request.PathValue("tournamentId")
+
+	err := json.NewDecoder(e.Request.Body).Decode(&data)
+	if err != nil {
+		return e.BadRequestError(err.Error(), nil)
+	}
+
+	_, err = models.GetTournamentById(tc.db, tournamentId)
+	if err != nil {
+		return e.NotFoundError(err.Error(), tournamentId)
+	}
+
+	if data.Scoring == "" {
+		data.Scoring = models.SKINS_SCORING_GROSS
+	}
+	if data.Name == "" {
+		data.Name = fmt.Sprintf("%s skins", data.Scoring)
+	}
+
+	err = validateSkinsGame(data.Scoring, data.MinScore, data.Pot)
+	if err != nil {
+		return e.BadRequestError(err.Error(), nil)
+	}
+
+	var game *models.SkinsGame
+	err = tc.app.RunInTransaction(func(txDb core.App) error {
+		game, err = models.CreateSkinsGame(txDb.DB(), tournamentId, data)
+		if err != nil {
+			return err
+		}
+
+		_, err = models.CreateAuditLog(txDb.DB(), tournamentId, "skins.create", e.RealIP(), game)
+		return err
+	})
+
+	if err != nil {
+		return e.Error(http.StatusInternalServerError, err.Error(), nil)
+	}
+
+	return e.JSON(http.StatusCreated, game)
+}
+
+func (tc *TournamentController) HandleUpdateSkinsGame(e *core.RequestEvent) error {
+	var data models.SkinsGameUpdate
+	tournamentId := e.Request.PathValue("tournamentId")
+	skinsId := e.Request.PathValue("skinsId")
+
+	err := json.NewDecoder(e.Request.Body).Decode(&data)
+	if err != nil {
+		return e.BadRequestError(err.Error(), nil)
+	}
+
+	game, err := models.GetSkinsGame(tc.db, tournamentId, skinsId)
+	if err != nil {
+		return e.NotFoundError(err.Error(), skinsId)
+	}
+
+	scoring := game.Scoring
+	if data.Scoring != nil {
+		scoring = *data.Scoring
+	}
+	minScore := game.MinScore
+	if data.MinScore != nil {
+		minScore = *data.MinScore
+	}
+	pot := game.Pot
+	if data.Pot != nil {
+		pot = *data.Pot
+	}
+
+	err = validateSkinsGame(scoring, minScore, pot)
+	if err != nil {
+		return e.BadRequestError(err.Error(), nil)
+	}
+
+	err = tc.app.RunInTransaction(func(txDb core.App) error {
+		_, err := models.UpdateSkinsGame(txDb.DB(), skinsId, data)
+		if err != nil {
+			return err
+		}
+
+		_, err = models.CreateAuditLog(txDb.DB(), tournamentId, "skins.update", e.RealIP(), map[string]any{
+			"skinsId": skinsId,
+			"update":  data,
+		})
+		return err
+	})
+
+	if err != nil {
+		return e.BadRequestError(err.Error(), nil)
+	}
+
+	game, err = models.GetSkinsGame(tc.db, tournamentId, skinsId)
+	if err != nil {
+		return e.Error(http.StatusInternalServerError, err.Error(), nil)
+	}
+
+	return e.JSON(http.StatusOK, game)
+}
+
+func (tc *TournamentController) HandleDeleteSkinsGame(e *core.RequestEvent) error {
+	tournamentId := e.Request.PathValue("tournamentId")
+	skinsId := e.Request.PathValue("skinsId")
+
+	game, err := models.GetSkinsGame(tc.db, tournamentId, skinsId)
+	if err != nil {
+		return e.NotFoundError(err.Error(), skinsId)
+	}
+
+	err = tc.app.RunInTransaction(func(txDb core.App) error {
+		err := models.DeleteSkinsGame(txDb.DB(), skinsId)
+		if err != nil {
+			return err
+		}
+
+		_, err = models.CreateAuditLog(txDb.DB(), tournamentId, "skins.delete", e.RealIP(), game)
+		return err
+	})
+
+	if err != nil {
+		return e.Error(http.StatusInternalServerError, err.Error(), nil)
+	}
+
+	return e.NoContent(http.StatusNoContent)
+}
+
+// HandleGetSkinsBoard returns the live board for every skins game in the
+// tournament. Holes not everyone has finished stay pending, so the board is
+// provisional until the last group is in.
+func (tc *TournamentController) HandleGetSkinsBoard(e *core.RequestEvent) error {
+	tournamentId := e.Request.PathValue("tournamentId")
+
+	games, err := models.GetSkinsGames(tc.db, tournamentId)
+	if err != nil {
+		return e.Error(http.StatusInternalServerError, err.Error(), nil)
+	}
+
+	boards, err := tc.getSkinsBoards(tournamentId, *games)
+	if err != nil {
+		return e.Error(http.StatusInternalServerError, err.Error(), nil)
+	}
+
+	return e.JSON(http.StatusOK, boards)
+}
+
+func (tc *TournamentController) getSkinsBoards(tournamentId string, games []models.SkinsGame) ([]SkinsBoard, error) {
+	boards := []SkinsBoard{}
+	if len(games) == 0 {
+		return boards, nil
+	}
+
+	course, err := models.GetCourseByTournamentId(tc.db, tournamentId)
+	if err != nil {
+		return nil, err
+	}
+
+	teams, err := models.GetTeamsByTournamentId(tc.db, tournamentId)
+	if err != nil {
+		return nil, err
+	}
+	teamIds := []string{}
+	for _, team := range *teams {
+		teamIds = append(teamIds, team.Id)
+	}
+
+	holes, err := models.GetTournamentHoles(tc.db, tournamentId, teamIds)
+	if err != nil {
+		return nil, err
+	}
+	applyStrokeHoles(course, holes)
+
+	for _, game := range games {
+		boards = append(boards, buildSkinsBoard(game, course, *holes))
+	}
+
+	return boards, nil
+}
+
+// buildSkinsBoard plays the holes in order. An outright low score wins the
+// hole's skins as long as it meets the game's min score and, when the game
+// validates, the winner matches or beats the best score on the next hole.
+// Anything else carries to the next hole when the game has carryovers and is
+// lost otherwise.
+func buildSkinsBoard(game models.SkinsGame, course *models.CourseWithData, holes []models.HoleWithMetadata) SkinsBoard {
+	courseHoles := getHoleDataMap(course)
+
+	entrants := make(map[string]bool)
+	scores := make(map[int][]skinsScore)
+	for _, hole := range holes {
+		entrants[hole.PlayerId] = true
+
+		gross, ok := holeGrossScore(hole.Score, courseHoles[hole.Number].Par)
+		if !ok {
+			continue
+		}
+
+		score := gross
+		if game.Scoring == models.SKINS_SCORING_NET {
+			score -= hole.StrokeHole
+		}
+		scores[hole.Number] = append(scores[hole.Number], skinsScore{playerId: hole.PlayerId, playerName: hole.PlayerName, score: score})
+	}
+
+	numbers := []int{}
+	for number := range courseHoles {
+		numbers = append(numbers, number)
+	}
+	sort.Ints(numbers)
+
+	decided := func(number int) bool {
+		return len(entrants) > 0 && len(scores[number]) == len(entrants)
+	}
+	best := func(number int) []skinsScore {
+		low := []skinsScore{}
+		for _, score := range scores[number] {
+			if len(low) == 0 || score.score < low[0].score {
+				low = []skinsScore{score}
+			} else if score.score == low[0].score {
+				low = append(low, score)
+			}
+		}
+		return low
+	}
+
+	board := SkinsBoard{
+		Game:     game,
+		Entrants: len(entrants),
+		Holes:    []SkinsHole{},
+		Winners:  []SkinsWinner{},
+	}
+	winners := make(map[string]*SkinsWinner)
+	winnerOrder := []string{}
+
+	carry := 0
+	for i, number := range numbers {
+		par := courseHoles[number].Par
+		skinsHole := SkinsHole{Number: number, Par: par, Skins: 1 + carry}
+
+		if !decided(number) {
+			// a carry waits on the unfinished hole rather than jumping past it
+			skinsHole.Status = SKIN_STATUS_PENDING
+			board.Holes = append(board.Holes, skinsHole)
+			board.Carryover += carry
+			carry = 0
+			continue
+		}
+
+		low := best(number)
+		score := low[0].score
+		skinsHole.Score = &score
+
+		switch {
+		case len(low) > 1:
+			skinsHole.Reason = "tied"
+		case game.MinScore != "" && score-par > models.SKINS_MIN_SCORES[game.MinScore]:
+			skinsHole.Reason = "minScore"
+		case game.Validate && i+1 < len(numbers):
+			next := numbers[i+1]
+			if !decided(next) {
+				skinsHole.Status = SKIN_STATUS_PENDING
+				skinsHole.WinnerId = low[0].playerId
+				skinsHole.WinnerName = low[0].playerName
+				board.Holes = append(board.Holes, skinsHole)
+				board.Carryover += skinsHole.Skins
+				carry = 0
+				continue
+			}
+
+			nextBest := best(next)[0].score
+			for _, nextScore := range scores[next] {
+				if nextScore.playerId == low[0].playerId && nextScore.score > nextBest {
+					skinsHole.Reason = "notValidated"
+				}
+			}
+		}
+
+		if skinsHole.Reason == "" {
+			skinsHole.Status = SKIN_STATUS_WON
+			skinsHole.WinnerId = low[0].playerId
+			skinsHole.WinnerName = low[0].playerName
+			board.SkinsWon += skinsHole.Skins
+
+			winner, ok := winners[low[0].playerId]
+			if !ok {
+				winner = &SkinsWinner{PlayerId: low[0].playerId, PlayerName: low[0].playerName, Holes: []int{}}
+				winners[low[0].playerId] = winner
+				winnerOrder = append(winnerOrder, low[0].playerId)
+			}
+			winner.Skins += skinsHole.Skins
+			winner.Holes = append(winner.Holes, number)
+			carry = 0
+		} else if game.Carryover {
+			skinsHole.Status = SKIN_STATUS_CARRIED
+			carry = skinsHole.Skins
+		} else {
+			skinsHole.Status = SKIN_STATUS_VOID
+			carry = 0
+		}
+
+		board.Holes = append(board.Holes, skinsHole)
+	}
+	board.Carryover += carry
+
+	// the pot is shared out in cents, skin by skin, so the payouts add up to
+	// it exactly with the odd cents going to the earliest winners
+	if board.SkinsWon > 0 {
+		board.SkinValue = roundTo(game.Pot/float64(board.SkinsWon), 2)
+	}
+	skinCents := splitCents(int64(math.Round(game.Pot*100)), board.SkinsWon)
+	for _, playerId := range winnerOrder {
+		winner := winners[playerId]
+		var cents int64
+		for _, skin := range skinCents[:winner.Skins] {
+			cents += skin
+		}
+		skinCents = skinCents[winner.Skins:]
+
+		winner.Payout = float64(cents) / 100
+		board.Winners = append(board.Winners, *winner)
+	}
+	sort.SliceStable(board.Winners, func(i, j int) bool {
+		return board.Winners[i].Skins > board.Winners[j].Skins
+	})
+
+	return board
+}
+
+func validateSkinsGame(scoring string, minScore string, pot float64) error {
+	if scoring != models.SKINS_SCORING_GROSS && scoring != models.SKINS_SCORING_NET {
+		return fmt.Errorf("scoring must be gross or net")
+	}
+	if _, ok := models.SKINS_MIN_SCORES[minScore]; minScore != "" && !ok {
+		return fmt.Errorf("minScore must be par, birdie, eagle or empty")
+	}
+	if pot < 0 {
+		return fmt.Errorf("pot can't be negative")
+	}
+
+	return nil
+}
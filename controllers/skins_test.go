@@ -0,0 +1,124 @@
+package controllers
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/patrick-salvatore/tournament-live-scoring/models"
+)
+
+func TestBuildSkinsBoard(t *testing.T) {
+	course := &models.CourseWithData{Meta: models.CourseData{Holes: []models.CourseHoleData{
+		{Number: 1, Par: 4, Handicap: 1},
+		{Number: 2, Par: 4, Handicap: 2},
+		{Number: 3, Par: 4, Handicap: 3},
+		{Number: 4, Par: 4, Handicap: 4},
+	}}}
+	// scores lists al's then bo's gross score on each hole, empty when the
+	// hole hasn't been played
+	holes := func(scores ...[2]string) []models.HoleWithMetadata {
+		holes := []models.HoleWithMetadata{}
+		for i, pair := range scores {
+			holes = append(holes,
+				models.HoleWithMetadata{PlayerId: "al", PlayerName: "Al", Number: i + 1, Score: pair[0]},
+				models.HoleWithMetadata{PlayerId: "bo", PlayerName: "Bo", Number: i + 1, Score: pair[1]},
+			)
+		}
+		return holes
+	}
+
+	type hole struct {
+		status string
+		reason string
+		skins  int
+	}
+	cases := []struct {
+		name      string
+		game      models.SkinsGame
+		holes     []models.HoleWithMetadata
+		want      []hole
+		skinsWon  int
+		carryover int
+		payouts   map[string]float64
+	}{
+		{
+			name:  "a carry waits on a pending hole",
+			game:  models.SkinsGame{Scoring: models.SKINS_SCORING_GROSS, Carryover: true},
+			holes: holes([2]string{"4", "4"}, [2]string{"4", ""}, [2]string{"3", "4"}, [2]string{"", ""}),
+			want: []hole{
+				{SKIN_STATUS_CARRIED, "tied", 1},
+				{SKIN_STATUS_PENDING, "", 2},
+				{SKIN_STATUS_WON, "", 1},
+				{SKIN_STATUS_PENDING, "", 1},
+			},
+			skinsWon:  1,
+			carryover: 1,
+		},
+		{
+			name:  "a par doesn't win a birdie game",
+			game:  models.SkinsGame{Scoring: models.SKINS_SCORING_GROSS, MinScore: "birdie"},
+			holes: holes([2]string{"4", "5"}, [2]string{"3", "4"}, [2]string{"4", "4"}, [2]string{"5", "3"}),
+			want: []hole{
+				{SKIN_STATUS_VOID, "minScore", 1},
+				{SKIN_STATUS_WON, "", 1},
+				{SKIN_STATUS_VOID, "tied", 1},
+				{SKIN_STATUS_WON, "", 1},
+			},
+			skinsWon: 2,
+		},
+		{
+			name:  "a skin the winner doesn't validate on the next hole carries",
+			game:  models.SkinsGame{Scoring: models.SKINS_SCORING_GROSS, Carryover: true, Validate: true},
+			holes: holes([2]string{"3", "4"}, [2]string{"6", "4"}, [2]string{"4", "4"}, [2]string{"", ""}),
+			want: []hole{
+				{SKIN_STATUS_CARRIED, "notValidated", 1},
+				{SKIN_STATUS_WON, "", 2},
+				{SKIN_STATUS_CARRIED, "tied", 1},
+				{SKIN_STATUS_PENDING, "", 2},
+			},
+			skinsWon:  2,
+			carryover: 1,
+		},
+		{
+			name:  "payouts add up to the pot",
+			game:  models.SkinsGame{Scoring: models.SKINS_SCORING_GROSS, Pot: 100},
+			holes: holes([2]string{"3", "4"}, [2]string{"3", "4"}, [2]string{"5", "4"}, [2]string{"4", "4"}),
+			want: []hole{
+				{SKIN_STATUS_WON, "", 1},
+				{SKIN_STATUS_WON, "", 1},
+				{SKIN_STATUS_WON, "", 1},
+				{SKIN_STATUS_VOID, "tied", 1},
+			},
+			skinsWon: 3,
+			payouts:  map[string]float64{"al": 66.67, "bo": 33.33},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			board := buildSkinsBoard(c.game, course, c.holes)
+
+			if len(board.Holes) != len(c.want) {
+				t.Fatalf("expected %d holes, got %d", len(c.want), len(board.Holes))
+			}
+			for i, want := range c.want {
+				got := board.Holes[i]
+				if got.Status != want.status || got.Reason != want.reason || got.Skins != want.skins {
+					t.Errorf("hole %d: expected %s %q for %d skins, got %s %q for %d", i+1, want.status, want.reason, want.skins, got.Status, got.Reason, got.Skins)
+				}
+			}
+			if board.SkinsWon != c.skinsWon || board.Carryover != c.carryover {
+				t.Errorf("expected %d skins won and %d carried over, got %d and %d", c.skinsWon, c.carryover, board.SkinsWon, board.Carryover)
+			}
+			if c.payouts != nil {
+				payouts := make(map[string]float64)
+				for _, winner := range board.Winners {
+					payouts[winner.PlayerId] = winner.Payout
+				}
+				if !reflect.DeepEqual(payouts, c.payouts) {
+					t.Errorf("expected payouts %v, got %v", c.payouts, payouts)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,167 @@
+package controllers
+
+import (
+	"net/http"
+	"sort"
+
+	"github.com/patrick-salvatore/tournament-live-scoring/models"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// HoleStatLine sums up the scores on a hole, from every tee or one of them.
+// Rank orders the holes from hardest to easiest by average; holes nobody has
+// scored on yet are left unranked.
+type HoleStatLine struct {
+	Scores       int               `json:"scores"`
+	Average      float64           `json:"average"`
+	Rank         int               `json:"rank,omitempty"`
+	Distribution ScoreDistribution `json:"distribution"`
+
+	total int
+}
+
+type HoleStats struct {
+	Number   int `json:"number"`
+	Par      int `json:"par"`
+	Handicap int `json:"handicap"`
+	HoleStatLine
+	Tees map[string]HoleStatLine `json:"tees"`
+}
+
+type TournamentStats struct {
+	TournamentId string      `json:"tournamentId"`
+	Tees         []string    `json:"tees"`
+	Holes        []HoleStats `json:"holes"`
+}
+
+// HandleGetTournamentStats reports how each hole is playing from the scores
+// entered so far, overall and by tee.
+func (tc *TournamentController) HandleGetTournamentStats(e *core.RequestEvent) error {
+	tournamentId := e.Request.PathValue("tournamentId")
+
+	course, err := models.GetCourseByTournamentId(tc.db, tournamentId)
+	if err != nil {
+		return e.NotFoundError(err.Error(), tournamentId)
+	}
+
+	teams, err := models.GetTeamsByTournamentId(tc.db, tournamentId)
+	if err != nil {
+		return e.Error(http.StatusInternalServerError, err.Error(), nil)
+	}
+	teamIds := []string{}
+	for _, team := range *teams {
+		teamIds = append(teamIds, team.Id)
+	}
+
+	holes, err := models.GetTournamentHoles(tc.db, tournamentId, teamIds)
+	if err != nil {
+		return e.Error(http.StatusInternalServerError, err.Error(), nil)
+	}
+
+	return e.JSON(http.StatusOK, buildTournamentStats(tournamentId, course, *holes))
+}
+
+func buildTournamentStats(tournamentId string, course *models.CourseWithData, holes []models.HoleWithMetadata) TournamentStats {
+	stats := TournamentStats{TournamentId: tournamentId, Tees: []string{}, Holes: []HoleStats{}}
+
+	byNumber := make(map[int]*HoleStats)
+	for _, courseHole := range course.Meta.Holes {
+		stats.Holes = append(stats.Holes, HoleStats{
+			Number:   courseHole.Number,
+			Par:      courseHole.Par,
+			Handicap: courseHole.Handicap,
+			Tees:     make(map[string]HoleStatLine),
+		})
+	}
+	sort.Slice(stats.Holes, func(i, j int) bool {
+		return stats.Holes[i].Number < stats.Holes[j].Number
+	})
+	for i := range stats.Holes {
+		byNumber[stats.Holes[i].Number] = &stats.Holes[i]
+	}
+
+	tees := make(map[string]bool)
+	for _, hole := range holes {
+		stat, ok := byNumber[hole.Number]
+		if !ok {
+			continue
+		}
+
+		gross, ok := holeGrossScore(hole.Score, stat.Par)
+		if !ok {
+			continue
+		}
+		toPar := gross - stat.Par
+
+		stat.HoleStatLine.add(toPar)
+		line := stat.Tees[hole.Tee]
+		line.add(toPar)
+		stat.Tees[hole.Tee] = line
+		tees[hole.Tee] = true
+	}
+
+	for tee := range tees {
+		stats.Tees = append(stats.Tees, tee)
+	}
+	sort.Strings(stats.Tees)
+
+	rankHoles(stats.Holes, func(stat *HoleStats) *HoleStatLine {
+		return &stat.HoleStatLine
+	})
+	for _, tee := range stats.Tees {
+		lines := make(map[int]*HoleStatLine)
+		for i := range stats.Holes {
+			line := stats.Holes[i].Tees[tee]
+			lines[stats.Holes[i].Number] = &line
+		}
+
+		rankHoles(stats.Holes, func(stat *HoleStats) *HoleStatLine {
+			return lines[stat.Number]
+		})
+
+		for i := range stats.Holes {
+			stats.Holes[i].Tees[tee] = *lines[stats.Holes[i].Number]
+		}
+	}
+
+	return stats
+}
+
+func (line *HoleStatLine) add(toPar int) {
+	line.Scores++
+	line.total += toPar
+	line.Average = roundTo(float64(line.total)/float64(line.Scores), 2)
+
+	line.Distribution.add(toPar)
+}
+
+// rankHoles ranks the lines picked from each hole by average to par, highest
+// first, sharing a rank when averages tie. The stroke index settles the
+// order between tied holes but not their rank.
+func rankHoles(holes []HoleStats, pick func(*HoleStats) *HoleStatLine) {
+	scored := []int{}
+	for i := range holes {
+		if pick(&holes[i]).Scores > 0 {
+			scored = append(scored, i)
+		}
+	}
+
+	sort.SliceStable(scored, func(i, j int) bool {
+		a, b := pick(&holes[scored[i]]), pick(&holes[scored[j]])
+		if a.Average != b.Average {
+			return a.Average > b.Average
+		}
+		return holes[scored[i]].Handicap < holes[scored[j]].Handicap
+	})
+
+	for position, index := range scored {
+		line := pick(&holes[index])
+		line.Rank = position + 1
+		if position > 0 {
+			above := pick(&holes[scored[position-1]])
+			if above.Average == line.Average {
+				line.Rank = above.Rank
+			}
+		}
+	}
+}
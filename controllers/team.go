@@ -58,21 +58,6 @@ func (tc *TeamsController) HandleGetTeamHoles(e *core.RequestEvent) error {
 		return e.Error(http.StatusInternalServerError, err.Error(), nil)
 	}
 
-	tournament, err := models.GetTournamentById(tc.db, team.TournamentId)
-	if err != nil {
-		return e.Error(http.StatusInternalServerError, err.Error(), nil)
-	}
-
-	players, err := models.GetPlayersFromTeamId(tc.db, team.Id)
-	if err != nil {
-		return e.Error(http.StatusInternalServerError, err.Error(), nil)
-	}
-
-	var playerMap = make(map[string]models.Player)
-	for _, player := range *players {
-		playerMap[player.Id] = player
-	}
-
 	course, err := models.GetCourseByTournamentId(tc.db, team.TournamentId)
 	if err != nil {
 		return err
@@ -83,18 +68,9 @@ func (tc *TeamsController) HandleGetTeamHoles(e *core.RequestEvent) error {
 		return e.Error(http.StatusInternalServerError, err.Error(), nil)
 	}
 
-	holesWithStrokeHole := []models.HoleWithMetadata{}
-	for _, hole := range *holes {
-		playerTee := playerMap[hole.PlayerId].Tee
-		holeIndex := (course.Meta.Holes)[hole.Number-1].Handicap
-		courseTeeData := course.Meta.Tees[playerTee]
-
-		hole.StrokeHole = getStrokeHole(hole.PlayerHandicap, float64(courseTeeData.SlopeRating), courseTeeData.CourseRating, float64(courseTeeData.Par), tournament.AwardedHandicap, holeIndex)
+	applyStrokeHoles(course, holes)
 
-		holesWithStrokeHole = append(holesWithStrokeHole, hole)
-	}
-
-	return e.JSON(http.StatusOK, holesWithStrokeHole)
+	return e.JSON(http.StatusOK, holes)
 }
 
 func (tc *TeamsController) HandleGetTeamPlayers(e *core.RequestEvent) error {
@@ -128,6 +104,11 @@ func (tc *TeamsController) HandleUpdateTeam(e *core.RequestEvent) error {
 		return e.BadRequestError(err.Error(), nil)
 	}
 
+	// locks and tee time requests are admin decisions made through the
+	// tournament team editor
+	teamPayload.Locked = nil
+	teamPayload.TeeTimeRequest = nil
+
 	team, err := models.UpdateTeam(tc.db, teamId, teamPayload)
 	if err != nil {
 		return err
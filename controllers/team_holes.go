@@ -0,0 +1,365 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/patrick-salvatore/tournament-live-scoring/models"
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+type TeamDriveCount struct {
+	PlayerId   string `json:"playerId"`
+	PlayerName string `json:"playerName"`
+	Drives     int    `json:"drives"`
+	// Needed is how many more drives the player must have used to meet the
+	// tournament's minimum.
+	Needed int `json:"needed"`
+}
+
+// TeamBallCard is a team's scorecard in a team ball format.
+type TeamBallCard struct {
+	BallFormat   string            `json:"ballFormat"`
+	TeamHandicap int               `json:"teamHandicap"`
+	MinDrives    int               `json:"minDrives"`
+	Holes        []models.TeamHole `json:"holes"`
+	Drives       []TeamDriveCount  `json:"drives"`
+}
+
+// HandleGetTeamBallHoles returns the team's own holes, with the drives each
+// player has had used so far.
+func (hc *HolesController) HandleGetTeamBallHoles(e *core.RequestEvent) error {
+	teamId := e.Request.Context().Value(TeamId).(string)
+	tournamentId := e.Request.Context().Value(TournamentId).(string)
+
+	tournament, err := models.GetTournamentById(hc.db, tournamentId)
+	if err != nil {
+		return e.NotFoundError(err.Error(), tournamentId)
+	}
+	if !tournament.PlaysTeamBall() && !tournament.TracksDrives() {
+		return e.BadRequestError("the tournament isn't played in a team ball format", nil)
+	}
+
+	card, err := getTeamBallCard(hc.db, tournament, teamId)
+	if err != nil {
+		return e.Error(http.StatusInternalServerError, err.Error(), nil)
+	}
+
+	return e.JSON(http.StatusOK, card)
+}
+
+// HandleUpdateTeamBallHoles posts the team's score and chosen drive on its
+// holes. A drive is refused once it would leave too few holes for every
+// player to reach the minimum number of drives.
+func (hc *HolesController) HandleUpdateTeamBallHoles(e *core.RequestEvent) error {
+	var holesPayload []models.TeamHoleUpdate
+	teamId := e.Request.Context().Value(TeamId).(string)
+	tournamentId := e.Request.Context().Value(TournamentId).(string)
+
+	err := json.NewDecoder(e.Request.Body).Decode(&holesPayload)
+	if err != nil {
+		return e.BadRequestError(err.Error(), nil)
+	}
+
+	tournament, err := models.GetTournamentById(hc.db, tournamentId)
+	if err != nil {
+		return e.NotFoundError(err.Error(), tournamentId)
+	}
+	if !tournament.PlaysTeamBall() && !tournament.TracksDrives() {
+		return e.BadRequestError("the tournament isn't played in a team ball format", nil)
+	}
+
+	holes, err := models.GetTournamentTeamHoles(hc.db, tournamentId, []string{teamId})
+	if err != nil {
+		return e.Error(http.StatusInternalServerError, err.Error(), nil)
+	}
+	teamPlayers, err := getTeamPlayers(hc.db, tournamentId, teamId)
+	if err != nil {
+		return e.Error(http.StatusInternalServerError, err.Error(), nil)
+	}
+	onTeam := make(map[string]bool)
+	for _, teamPlayer := range teamPlayers {
+		onTeam[teamPlayer.PlayerId] = true
+	}
+
+	byId := make(map[string]*models.TeamHole)
+	for i := range *holes {
+		byId[(*holes)[i].Id] = &(*holes)[i]
+	}
+
+	for _, update := range holesPayload {
+		if update.Id == nil {
+			return e.BadRequestError("every hole needs an id", nil)
+		}
+		hole, ok := byId[*update.Id]
+		if !ok {
+			return e.BadRequestError(fmt.Sprintf("hole %s is not one of the team's", *update.Id), nil)
+		}
+
+		if update.Score != nil {
+			if !tournament.PlaysTeamBall() {
+				return e.BadRequestError("scores are posted per player in a shamble", nil)
+			}
+			if !validTeamHoleScore(*update.Score) {
+				return e.BadRequestError(fmt.Sprintf("%q is not a score", *update.Score), nil)
+			}
+			hole.Score = *update.Score
+		}
+		if update.DrivePlayerId != nil {
+			if !tournament.TracksDrives() {
+				return e.BadRequestError("drives are only recorded in scrambles and shambles", nil)
+			}
+			if *update.DrivePlayerId != "" && !onTeam[*update.DrivePlayerId] {
+				return e.BadRequestError("the drive must be a player on your team", nil)
+			}
+			hole.DrivePlayerId = *update.DrivePlayerId
+		}
+	}
+
+	err = checkMinDrives(*holes, teamPlayers, tournament.MinDrives)
+	if err != nil {
+		return e.Error(http.StatusConflict, err.Error(), nil)
+	}
+
+	err = hc.app.RunInTransaction(func(txApp core.App) error {
+		for _, update := range holesPayload {
+			_, err := models.UpdateTeamHole(txApp.DB(), teamId, *update.Id, update)
+			if err != nil {
+				return fmt.Errorf("failed to update hole %s: %w", *update.Id, err)
+			}
+		}
+		return nil
+	})
+
+	if err != nil {
+		return e.InternalServerError(err.Error(), nil)
+	}
+
+	leaderboards.InvalidateTeam(tournamentId, teamId)
+
+	card, err := getTeamBallCard(hc.db, tournament, teamId)
+	if err != nil {
+		return e.Error(http.StatusInternalServerError, err.Error(), nil)
+	}
+
+	return e.JSON(http.StatusOK, card)
+}
+
+// startTeamBall creates a team's own holes when it tees off. Team ball
+// formats receive strokes from the team handicap, shambles only use the
+// holes to record drives.
+func startTeamBall(db dbx.Builder, tournament *models.Tournament, course *models.CourseWithData, teamId string, players []models.Player) error {
+	strokes := make(map[int]int)
+
+	if tournament.PlaysTeamBall() {
+		courseHandicaps := []int{}
+		for _, player := range players {
+			allocation := allocateStrokes(course, player.Tee, player.Handicap, tournament.AwardedHandicap)
+			courseHandicaps = append(courseHandicaps, allocation.CourseHandicap)
+		}
+
+		handicap := teamHandicap(courseHandicaps, handicapWeights(tournament, len(players)))
+		for _, hole := range course.Meta.Holes {
+			strokes[hole.Number] = getStrokesForHole(handicap, hole.Handicap)
+		}
+	}
+
+	return models.CreateTeamHoles(db, tournament.Id, teamId, course.Meta.Holes, strokes)
+}
+
+func handicapWeights(tournament *models.Tournament, teamSize int) []float64 {
+	weights, err := models.ParseHandicapWeights(tournament.HandicapWeights)
+	if err != nil || weights == nil {
+		return models.DefaultHandicapWeights(tournament.BallFormat, teamSize)
+	}
+
+	return weights
+}
+
+// teamHandicap adds up the weighted course handicaps, the first weight going
+// to the lowest handicap. Players beyond the weights given count for nothing.
+func teamHandicap(courseHandicaps []int, weights []float64) int {
+	sorted := make([]int, len(courseHandicaps))
+	copy(sorted, courseHandicaps)
+	sort.Ints(sorted)
+
+	total := 0.0
+	for i, handicap := range sorted {
+		if i < len(weights) {
+			total += float64(handicap) * weights[i] / 100
+		}
+	}
+
+	return int(math.Round(total))
+}
+
+func validateBallFormat(ballFormat string, handicapWeights string, minDrives int) error {
+	switch ballFormat {
+	case "", models.BALL_FORMAT_INDIVIDUAL, models.BALL_FORMAT_SCRAMBLE, models.BALL_FORMAT_ALTERNATE_SHOT, models.BALL_FORMAT_SHAMBLE:
+	default:
+		return fmt.Errorf("ballFormat must be individual, scramble, alternate_shot or shamble")
+	}
+
+	_, err := models.ParseHandicapWeights(handicapWeights)
+	if err != nil {
+		return err
+	}
+
+	if minDrives < 0 {
+		return fmt.Errorf("minDrives can't be negative")
+	}
+	if minDrives > 0 && ballFormat != models.BALL_FORMAT_SCRAMBLE && ballFormat != models.BALL_FORMAT_SHAMBLE {
+		return fmt.Errorf("minDrives only applies to scrambles and shambles")
+	}
+
+	return nil
+}
+
+func validTeamHoleScore(score string) bool {
+	if score == "" || score == "X" {
+		return true
+	}
+
+	value, err := strconv.Atoi(score)
+	return err == nil && value > 0
+}
+
+// checkMinDrives makes sure the holes without a drive chosen yet are enough
+// for every player to still reach the minimum.
+func checkMinDrives(holes []models.TeamHole, teamPlayers []models.TeamPlayer, minDrives int) error {
+	if minDrives == 0 {
+		return nil
+	}
+
+	drives, open := countDrives(holes)
+
+	needed := 0
+	short := []string{}
+	for _, teamPlayer := range teamPlayers {
+		if missing := minDrives - drives[teamPlayer.PlayerId]; missing > 0 {
+			needed += missing
+			short = append(short, fmt.Sprintf("%s needs %d more", teamPlayer.PlayerName, missing))
+		}
+	}
+
+	if needed > open {
+		return fmt.Errorf("every player needs %d drives but only %d holes are left: %s", minDrives, open, strings.Join(short, ", "))
+	}
+
+	return nil
+}
+
+func countDrives(holes []models.TeamHole) (map[string]int, int) {
+	drives := make(map[string]int)
+	open := 0
+	for _, hole := range holes {
+		if hole.DrivePlayerId == "" {
+			open++
+			continue
+		}
+		drives[hole.DrivePlayerId]++
+	}
+
+	return drives, open
+}
+
+func getTeamPlayers(db dbx.Builder, tournamentId string, teamId string) ([]models.TeamPlayer, error) {
+	teamPlayers, err := models.GetTeamPlayersByTournament(db, tournamentId)
+	if err != nil {
+		return nil, err
+	}
+
+	players := []models.TeamPlayer{}
+	for _, teamPlayer := range *teamPlayers {
+		if teamPlayer.TeamId == teamId {
+			players = append(players, teamPlayer)
+		}
+	}
+
+	return players, nil
+}
+
+func getTeamBallCard(db dbx.Builder, tournament *models.Tournament, teamId string) (*TeamBallCard, error) {
+	holes, err := models.GetTournamentTeamHoles(db, tournament.Id, []string{teamId})
+	if err != nil {
+		return nil, err
+	}
+
+	teamPlayers, err := getTeamPlayers(db, tournament.Id, teamId)
+	if err != nil {
+		return nil, err
+	}
+
+	card := &TeamBallCard{
+		BallFormat: tournament.BallFormat,
+		MinDrives:  tournament.MinDrives,
+		Holes:      *holes,
+		Drives:     []TeamDriveCount{},
+	}
+
+	if tournament.PlaysTeamBall() {
+		courseHandicaps := []int{}
+		for _, teamPlayer := range teamPlayers {
+			courseHandicaps = append(courseHandicaps, teamPlayer.CourseHandicap)
+		}
+		card.TeamHandicap = teamHandicap(courseHandicaps, handicapWeights(tournament, len(teamPlayers)))
+	}
+
+	if tournament.TracksDrives() {
+		drives, _ := countDrives(*holes)
+		for _, teamPlayer := range teamPlayers {
+			card.Drives = append(card.Drives, TeamDriveCount{
+				PlayerId:   teamPlayer.PlayerId,
+				PlayerName: teamPlayer.PlayerName,
+				Drives:     drives[teamPlayer.PlayerId],
+				Needed:     max(0, tournament.MinDrives-drives[teamPlayer.PlayerId]),
+			})
+		}
+	}
+
+	return card, nil
+}
+
+// getTeamBallLeaderboardRow scores a team ball team the same way as any
+// other team row, from the one score it posts on each hole.
+func getTeamBallLeaderboardRow(teamId string, teamName string, holes []models.TeamHole, courseHoles models.CourseHoleDataMap, coursePar int) LeaderboardRow {
+	row := LeaderboardRow{
+		Id:        teamId,
+		TeamName:  teamName,
+		CoursePar: coursePar,
+		Holes:     []TeamHoleResult{},
+	}
+
+	for _, hole := range holes {
+		holePar := courseHoles[hole.Number].Par
+
+		gross, ok := holeGrossScore(hole.Score, holePar)
+		if !ok {
+			continue
+		}
+		net := gross
+		if hole.Strokes > 0 {
+			net = gross - hole.Strokes
+		}
+
+		row.Thru++
+		row.Gross += gross - holePar
+		row.Net += net - holePar
+		row.Holes = append(row.Holes, TeamHoleResult{
+			Number:       hole.Number,
+			Count:        1,
+			Gross:        gross - holePar,
+			Net:          net - holePar,
+			GrossPlayers: []string{},
+			NetPlayers:   []string{},
+		})
+	}
+
+	return row
+}
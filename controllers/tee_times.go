@@ -0,0 +1,455 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/patrick-salvatore/tournament-live-scoring/models"
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+const (
+	SCHEDULE_MODE_TEE_TIMES = "tee_times"
+	SCHEDULE_MODE_SHOTGUN   = "shotgun"
+
+	TEE_TIME_REQUEST_EARLY = "early"
+	TEE_TIME_REQUEST_LATE  = "late"
+)
+
+type TeeTimeScheduleRequest struct {
+	// Mode is "tee_times" (default) or "shotgun".
+	Mode         string `json:"mode"`
+	FirstTeeTime string `json:"firstTeeTime"`
+	// IntervalMinutes separates consecutive tee times, default 10.
+	IntervalMinutes int `json:"intervalMinutes"`
+	// TeamsPerGroup is how many teams go off together, default 1.
+	TeamsPerGroup int `json:"teamsPerGroup"`
+	// StartingHoles are the tees groups go off from. Tee times rotate
+	// through them (e.g. [1, 10] for a two tee start); a shotgun uses every
+	// hole on the course when left empty.
+	StartingHoles []int `json:"startingHoles"`
+	// KeepTogether lists sets of team ids that must share a group.
+	KeepTogether [][]string `json:"keepTogether"`
+}
+
+type TeeGroup struct {
+	Group     int           `json:"group"`
+	StartTime string        `json:"startTime"`
+	StartHole int           `json:"startHole"`
+	TeedOff   bool          `json:"teedOff"`
+	Teams     []models.Team `json:"teams"`
+}
+
+// HandleScheduleTeeTimes assigns a start time, starting hole and tee group to
+// every team that hasn't teed off yet. Teams asking for an early or late
+// time go first or last, and KeepTogether sets always share a group. Pass
+// ?dryRun=true to preview the schedule without saving it.
+func (tc *TournamentController) HandleScheduleTeeTimes(e *core.RequestEvent) error {
+	var data TeeTimeScheduleRequest
+	tournamentId := e.Request.PathValue("tournamentId")
+	dryRun := e.Request.URL.Query().Get("dryRun") == "true"
+
+	err := json.NewDecoder(e.Request.Body).Decode(&data)
+	if err != nil {
+		return e.BadRequestError(err.Error(), nil)
+	}
+
+	if len(data.Mode) == 0 {
+		data.Mode = SCHEDULE_MODE_TEE_TIMES
+	}
+	if data.Mode != SCHEDULE_MODE_TEE_TIMES && data.Mode != SCHEDULE_MODE_SHOTGUN {
+		return e.BadRequestError("mode must be tee_times or shotgun", nil)
+	}
+	if data.IntervalMinutes == 0 {
+		data.IntervalMinutes = 10
+	}
+	if data.TeamsPerGroup == 0 {
+		data.TeamsPerGroup = 1
+	}
+	if data.IntervalMinutes < 0 || data.TeamsPerGroup < 0 {
+		return e.BadRequestError("intervalMinutes and teamsPerGroup must be positive", nil)
+	}
+
+	firstTeeTime, err := time.Parse(time.RFC3339, data.FirstTeeTime)
+	if err != nil {
+		return e.BadRequestError("firstTeeTime must be an RFC3339 timestamp", nil)
+	}
+
+	_, err = models.GetTournamentById(tc.db, tournamentId)
+	if err != nil {
+		return e.NotFoundError(err.Error(), tournamentId)
+	}
+
+	course, err := models.GetCourseByTournamentId(tc.db, tournamentId)
+	if err != nil {
+		return e.Error(http.StatusInternalServerError, err.Error(), nil)
+	}
+
+	startingHoles, err := scheduleStartingHoles(course, data)
+	if err != nil {
+		return e.BadRequestError(err.Error(), nil)
+	}
+
+	teams, err := models.GetTeamsByTournamentId(tc.db, tournamentId)
+	if err != nil {
+		return e.Error(http.StatusInternalServerError, err.Error(), nil)
+	}
+
+	groups, err := buildTeeGroups(*teams, data.TeamsPerGroup, data.KeepTogether)
+	if err != nil {
+		return e.BadRequestError(err.Error(), nil)
+	}
+	if data.Mode == SCHEDULE_MODE_SHOTGUN && len(groups) > 2*len(startingHoles) {
+		return e.BadRequestError(fmt.Sprintf("%d groups don't fit a shotgun start on %d holes", len(groups), len(startingHoles)), nil)
+	}
+
+	// teams already out on the course keep their group numbers
+	firstGroup := 1
+	for _, team := range *teams {
+		if team.Started && team.TeeGroup >= firstGroup {
+			firstGroup = team.TeeGroup + 1
+		}
+	}
+
+	schedule := []TeeGroup{}
+	for i, groupTeams := range groups {
+		startTime := firstTeeTime
+		if data.Mode == SCHEDULE_MODE_TEE_TIMES {
+			startTime = firstTeeTime.Add(time.Duration(i/len(startingHoles)*data.IntervalMinutes) * time.Minute)
+		}
+
+		group := TeeGroup{
+			Group:     firstGroup + i,
+			StartTime: startTime.Format(time.RFC3339),
+			StartHole: startingHoles[i%len(startingHoles)],
+			Teams:     groupTeams,
+		}
+		for j := range group.Teams {
+			group.Teams[j].TeamStart = models.TeamStart{
+				StartTime: group.StartTime,
+				StartHole: group.StartHole,
+				TeeGroup:  group.Group,
+			}
+		}
+
+		schedule = append(schedule, group)
+	}
+
+	if dryRun {
+		return e.JSON(http.StatusOK, schedule)
+	}
+
+	err = tc.app.RunInTransaction(func(txDb core.App) error {
+		for _, group := range schedule {
+			for _, team := range group.Teams {
+				err := models.SetTeamStart(txDb.DB(), team.Id, team.TeamStart)
+				if err != nil {
+					return err
+				}
+			}
+		}
+
+		_, err := models.CreateAuditLog(txDb.DB(), tournamentId, "tee_times.schedule", e.RealIP(), data)
+		return err
+	})
+
+	if err != nil {
+		return e.Error(http.StatusInternalServerError, err.Error(), nil)
+	}
+
+	return e.JSON(http.StatusOK, schedule)
+}
+
+// HandleGetTeeSheet lists the tournament's tee groups in starting order.
+// Teams that haven't been scheduled are returned under group 0.
+func (tc *TournamentController) HandleGetTeeSheet(e *core.RequestEvent) error {
+	tournamentId := e.Request.PathValue("tournamentId")
+
+	teams, err := models.GetTeamsByTournamentId(tc.db, tournamentId)
+	if err != nil {
+		return e.Error(http.StatusInternalServerError, err.Error(), nil)
+	}
+
+	return e.JSON(http.StatusOK, getTeeSheet(*teams))
+}
+
+// HandleTeeOffGroup is used by the starter to send a tee group off. Every
+// team in it is started, which creates their holes and freezes their
+// strokes.
+func (tc *TournamentController) HandleTeeOffGroup(e *core.RequestEvent) error {
+	tournamentId := e.Request.PathValue("tournamentId")
+
+	groupNumber, err := strconv.Atoi(e.Request.PathValue("group"))
+	if err != nil {
+		return e.BadRequestError("group must be a number", nil)
+	}
+
+	tournament, err := models.GetTournamentById(tc.db, tournamentId)
+	if err != nil {
+		return e.NotFoundError(err.Error(), tournamentId)
+	}
+
+	course, err := models.GetCourseByTournamentId(tc.db, tournamentId)
+	if err != nil {
+		return e.Error(http.StatusInternalServerError, err.Error(), nil)
+	}
+
+	teams, err := models.GetTeamsByTournamentId(tc.db, tournamentId)
+	if err != nil {
+		return e.Error(http.StatusInternalServerError, err.Error(), nil)
+	}
+
+	var group *TeeGroup
+	for _, teeGroup := range getTeeSheet(*teams) {
+		if teeGroup.Group == groupNumber && groupNumber > 0 {
+			group = &teeGroup
+			break
+		}
+	}
+	if group == nil {
+		return e.NotFoundError(fmt.Sprintf("tee group %d not found", groupNumber), nil)
+	}
+	if group.TeedOff {
+		return e.Error(http.StatusConflict, "tee group has already teed off", nil)
+	}
+	if !tournament.AcceptsScores() {
+		return e.Error(http.StatusConflict, fmt.Sprintf("tournament is %s", tournament.Status), nil)
+	}
+
+	teedOffAt := time.Now()
+	err = tc.app.RunInTransaction(func(txDb core.App) error {
+		err := beginPlay(txDb.DB(), tournament, e.RealIP())
+		if err != nil {
+			return err
+		}
+
+		teamIds := []string{}
+		for _, team := range group.Teams {
+			if team.Started {
+				continue
+			}
+
+			err = teeOffTeam(txDb.DB(), tournament, course, team.Id, teedOffAt)
+			if err != nil {
+				return fmt.Errorf("team %s: %w", team.Name, err)
+			}
+			teamIds = append(teamIds, team.Id)
+		}
+
+		_, err = models.CreateAuditLog(txDb.DB(), tournamentId, "tee_times.tee_off", e.RealIP(), map[string]any{
+			"group": groupNumber,
+			"teams": teamIds,
+		})
+		return err
+	})
+
+	if errors.Is(err, errTeamStarted) {
+		return e.Error(http.StatusConflict, err.Error(), nil)
+	}
+	if err != nil {
+		return e.Error(http.StatusInternalServerError, err.Error(), nil)
+	}
+
+	leaderboards.Invalidate(tournamentId)
+
+	teams, err = models.GetTeamsByTournamentId(tc.db, tournamentId)
+	if err != nil {
+		return e.Error(http.StatusInternalServerError, err.Error(), nil)
+	}
+	for _, teeGroup := range getTeeSheet(*teams) {
+		if teeGroup.Group == groupNumber {
+			return e.JSON(http.StatusOK, teeGroup)
+		}
+	}
+
+	return e.NoContent(http.StatusNoContent)
+}
+
+// errTeamStarted stops a team's holes being created a second time.
+var errTeamStarted = errors.New("team has already teed off")
+
+// teeOffTeam starts a team's round: it creates every player's holes with
+// their strokes frozen, or the team's own holes in team ball formats, and
+// records when the team went off.
+func teeOffTeam(db dbx.Builder, tournament *models.Tournament, course *models.CourseWithData, teamId string, teedOffAt time.Time) error {
+	team, err := models.GetTeamById(db, teamId)
+	if err != nil {
+		return err
+	}
+	if team.Started {
+		return errTeamStarted
+	}
+
+	players, err := models.GetPlayersFromTeamId(db, teamId)
+	if err != nil {
+		return err
+	}
+
+	if len(*players) == 0 {
+		return fmt.Errorf("team has no players")
+	}
+
+	for _, player := range *players {
+		err = startTeamPlayer(db, tournament, course, teamId, player.Id, player.Tee, player.Handicap)
+		if err != nil {
+			return err
+		}
+	}
+
+	if tournament.PlaysTeamBall() || tournament.TracksDrives() {
+		err = startTeamBall(db, tournament, course, teamId, *players)
+		if err != nil {
+			return err
+		}
+	}
+
+	return models.MarkTeamTeedOff(db, teamId, teedOffAt)
+}
+
+func scheduleStartingHoles(course *models.CourseWithData, data TeeTimeScheduleRequest) ([]int, error) {
+	courseHoles := getHoleDataMap(course)
+
+	if len(data.StartingHoles) == 0 {
+		if data.Mode == SCHEDULE_MODE_TEE_TIMES {
+			return []int{1}, nil
+		}
+
+		holes := []int{}
+		for _, hole := range course.Meta.Holes {
+			holes = append(holes, hole.Number)
+		}
+		sort.Ints(holes)
+		return holes, nil
+	}
+
+	for _, hole := range data.StartingHoles {
+		if _, ok := courseHoles[hole]; !ok {
+			return nil, fmt.Errorf("course has no hole %d", hole)
+		}
+	}
+
+	return data.StartingHoles, nil
+}
+
+// buildTeeGroups packs the teams that still have to tee off into groups of
+// up to teamsPerGroup, early requests first and late requests last.
+func buildTeeGroups(teams []models.Team, teamsPerGroup int, keepTogether [][]string) ([][]models.Team, error) {
+	pending := make(map[string]models.Team)
+	order := []string{}
+	for _, team := range teams {
+		if team.Started {
+			continue
+		}
+		pending[team.Id] = team
+		order = append(order, team.Id)
+	}
+
+	// keep the previous schedule's order where there was one
+	sort.SliceStable(order, func(i, j int) bool {
+		a, b := pending[order[i]], pending[order[j]]
+		if a.TeeGroup != b.TeeGroup {
+			return a.TeeGroup < b.TeeGroup
+		}
+		return a.Name < b.Name
+	})
+
+	units := [][]models.Team{}
+	placed := make(map[string]bool)
+	for _, teamIds := range keepTogether {
+		if len(teamIds) > teamsPerGroup {
+			return nil, fmt.Errorf("%d teams can't be kept together in groups of %d", len(teamIds), teamsPerGroup)
+		}
+
+		unit := []models.Team{}
+		for _, teamId := range teamIds {
+			team, ok := pending[teamId]
+			if !ok {
+				return nil, fmt.Errorf("team %s is not waiting to tee off in this tournament", teamId)
+			}
+			if placed[teamId] {
+				return nil, fmt.Errorf("team %s is listed in keepTogether more than once", teamId)
+			}
+			placed[teamId] = true
+			unit = append(unit, team)
+		}
+		units = append(units, unit)
+	}
+	for _, teamId := range order {
+		if !placed[teamId] {
+			units = append(units, []models.Team{pending[teamId]})
+		}
+	}
+
+	sort.SliceStable(units, func(i, j int) bool {
+		return teeTimePriority(units[i]) < teeTimePriority(units[j])
+	})
+
+	groups := [][]models.Team{}
+	for _, unit := range units {
+		last := len(groups) - 1
+		if last >= 0 && len(groups[last])+len(unit) <= teamsPerGroup {
+			groups[last] = append(groups[last], unit...)
+			continue
+		}
+		groups = append(groups, unit)
+	}
+
+	return groups, nil
+}
+
+func teeTimePriority(teams []models.Team) int {
+	priority := 1
+	for _, team := range teams {
+		switch team.TeeTimeRequest {
+		case TEE_TIME_REQUEST_EARLY:
+			return 0
+		case TEE_TIME_REQUEST_LATE:
+			priority = 2
+		}
+	}
+
+	return priority
+}
+
+func getTeeSheet(teams []models.Team) []TeeGroup {
+	groupsByNumber := make(map[int]*TeeGroup)
+	numbers := []int{}
+	for _, team := range teams {
+		group, ok := groupsByNumber[team.TeeGroup]
+		if !ok {
+			group = &TeeGroup{
+				Group:     team.TeeGroup,
+				StartTime: team.StartTime,
+				StartHole: team.StartHole,
+				TeedOff:   true,
+				Teams:     []models.Team{},
+			}
+			groupsByNumber[team.TeeGroup] = group
+			numbers = append(numbers, team.TeeGroup)
+		}
+
+		group.Teams = append(group.Teams, team)
+		group.TeedOff = group.TeedOff && team.Started
+	}
+
+	// unscheduled teams go last
+	sort.Slice(numbers, func(i, j int) bool {
+		if numbers[i] == 0 || numbers[j] == 0 {
+			return numbers[j] == 0 && numbers[i] != 0
+		}
+		return numbers[i] < numbers[j]
+	})
+
+	sheet := []TeeGroup{}
+	for _, number := range numbers {
+		sheet = append(sheet, *groupsByNumber[number])
+	}
+
+	return sheet
+}
@@ -0,0 +1,49 @@
+package controllers
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/patrick-salvatore/tournament-live-scoring/models"
+)
+
+func TestStartTournamentForTeamOnlyOnce(t *testing.T) {
+	app := newTestApp(t)
+	tc := NewTournamentController(app)
+
+	tournament, teams := newTestTournament(t, app, models.CreateTournamentData{Name: "Spring", TeamCount: 1, AwardedHandicap: 1}, [][]models.Player{
+		{{Name: "Al", Handicap: 10}},
+	})
+	other, _ := newTestTournament(t, app, models.CreateTournamentData{Name: "Other", TeamCount: 1, AwardedHandicap: 1}, nil)
+
+	// holes only exist once a team tees off
+	_, err := app.DB().NewQuery("DELETE FROM holes").Execute()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := func(tournamentId string) int {
+		return serveTest(app, tc.HandleStartTournamentForTeam, testRequest{
+			method:     "POST",
+			pathValues: map[string]string{"tournamentId": tournamentId, "teamId": teams[0].Id},
+		}).Code
+	}
+
+	if code := start(other.Id); code != http.StatusNotFound {
+		t.Fatalf("starting a team through another tournament: expected 404, got %d", code)
+	}
+	if code := start(tournament.Id); code != http.StatusCreated {
+		t.Fatalf("first start: expected 201, got %d", code)
+	}
+	if code := start(tournament.Id); code != http.StatusConflict {
+		t.Fatalf("second start: expected 409, got %d", code)
+	}
+
+	holes, err := models.GetTeamHoles(app.DB(), teams[0].Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(*holes) != 18 {
+		t.Fatalf("expected one set of 18 holes, got %d", len(*holes))
+	}
+}
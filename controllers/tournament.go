@@ -1,15 +1,15 @@
 package controllers
 
 import (
-	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"sort"
 	"strconv"
 	"strings"
+	"time"
 
-	"github.com/jung-kurt/gofpdf"
 	"github.com/patrick-salvatore/tournament-live-scoring/models"
 	"github.com/pocketbase/dbx"
 	"github.com/pocketbase/pocketbase/core"
@@ -55,109 +55,151 @@ func (tc *TournamentController) HandleGetAllTournamentFormats(e *core.RequestEve
 	return e.JSON(http.StatusOK, formats)
 }
 
+// HandleStartTournamentForTeam lets a team's scoring device start its own
+// round. It runs the same steps as the starter's HandleTeeOffGroup for a
+// single team, so a team can only ever tee off once.
 func (tc *TournamentController) HandleStartTournamentForTeam(e *core.RequestEvent) error {
 	tournamentId := e.Request.PathValue("tournamentId")
 	teamId := e.Request.PathValue("teamId")
 
-	course, err := models.GetCourseByTournamentId(tc.db, tournamentId)
+	tournament, err := models.GetTournamentById(tc.db, tournamentId)
 	if err != nil {
-		return err
+		return e.NotFoundError(err.Error(), tournamentId)
+	}
+
+	team, err := models.GetTeamById(tc.db, teamId)
+	if err != nil || team.TournamentId != tournamentId {
+		return e.NotFoundError("team not found in tournament", teamId)
+	}
+	if team.Started {
+		return e.Error(http.StatusConflict, errTeamStarted.Error(), nil)
 	}
 
-	players, err := models.GetPlayersFromTeamId(tc.db, teamId)
+	course, err := models.GetCourseByTournamentId(tc.db, tournamentId)
 	if err != nil {
 		return e.Error(http.StatusInternalServerError, err.Error(), nil)
 	}
 
-	if len(*players) == 0 {
-		return e.Error(http.StatusInternalServerError, err.Error(), nil)
+	if !tournament.AcceptsScores() {
+		return e.Error(http.StatusConflict, fmt.Sprintf("tournament is %s", tournament.Status), nil)
 	}
 
 	err = tc.app.RunInTransaction(func(txDb core.App) error {
-		for _, player := range *players {
-			_, err = models.CreateAllHolesForPlayer(txDb.DB(), player.Id, tournamentId, course.Meta.Holes)
-			if err != nil {
-				return err
-			}
+		err := beginPlay(txDb.DB(), tournament, e.RealIP())
+		if err != nil {
+			return err
+		}
 
-			started := true
-			_, err = models.UpdateTeam(txDb.DB(), teamId, models.TeamUpdate{Started: &started})
-			if err != nil {
-				return err
-			}
+		err = teeOffTeam(txDb.DB(), tournament, course, teamId, time.Now())
+		if err != nil {
+			return err
 		}
 
-		return nil
+		_, err = models.CreateAuditLog(txDb.DB(), tournamentId, "tee_times.tee_off", e.RealIP(), map[string]any{
+			"teams": []string{teamId},
+		})
+		return err
 	})
 
+	if errors.Is(err, errTeamStarted) {
+		return e.Error(http.StatusConflict, err.Error(), nil)
+	}
 	if err != nil {
 		return e.Error(http.StatusInternalServerError, err.Error(), nil)
 	}
 
+	leaderboards.InvalidateTeam(tournamentId, teamId)
+
 	return e.JSON(http.StatusCreated, "OK")
 }
 
-func (tc *TournamentController) HandleGetTeamSheetFromTournament(e *core.RequestEvent) error {
+type HandicapRecalculation struct {
+	TeamId     string                    `json:"teamId"`
+	PlayerId   string                    `json:"playerId"`
+	PlayerName string                    `json:"playerName"`
+	Before     models.TeamPlayerHandicap `json:"before"`
+	After      models.TeamPlayerHandicap `json:"after"`
+}
+
+// HandleRecalculateHandicaps re-freezes stroke allocations for every started
+// team from the players' current handicap indexes and records the change.
+func (tc *TournamentController) HandleRecalculateHandicaps(e *core.RequestEvent) error {
 	tournamentId := e.Request.PathValue("tournamentId")
 
 	tournament, err := models.GetTournamentById(tc.db, tournamentId)
+	if err != nil {
+		return e.NotFoundError(err.Error(), tournamentId)
+	}
+
+	course, err := models.GetCourseByTournamentId(tc.db, tournamentId)
 	if err != nil {
 		return e.Error(http.StatusInternalServerError, err.Error(), nil)
 	}
-	teams, err := models.GetTeamsByTournamentId(tc.db, tournamentId)
+
+	teamPlayers, err := models.GetTeamPlayersByTournament(tc.db, tournamentId)
 	if err != nil {
 		return e.Error(http.StatusInternalServerError, err.Error(), nil)
 	}
 
-	pdf := gofpdf.New("P", "mm", "A4", "")
-	pdf.AddPage()
+	changes := []HandicapRecalculation{}
+	err = tc.app.RunInTransaction(func(txDb core.App) error {
+		for _, teamPlayer := range *teamPlayers {
+			if !teamPlayer.StrokesFrozen {
+				continue
+			}
 
-	pdf.SetFont("Arial", "B", 20)
-	pdf.Cell(190, 15, tournament.Name)
-	pdf.Ln(20)
+			allocation := allocateStrokes(course, teamPlayer.Tee, teamPlayer.Handicap, tournament.AwardedHandicap)
+			if allocation.TeamPlayerHandicap == teamPlayer.TeamPlayerHandicap {
+				continue
+			}
 
-	pdf.SetFont("Arial", "B", 14)
-	pdf.Cell(190, 10, "Team List")
-	pdf.Ln(15)
+			err := models.FreezeTeamPlayerHandicap(txDb.DB(), teamPlayer.TeamId, teamPlayer.PlayerId, allocation.TeamPlayerHandicap)
+			if err != nil {
+				return err
+			}
 
-	pdf.SetFont("Arial", "", 12)
+			for number, strokes := range allocation.Strokes {
+				err = models.UpdateHoleStrokes(txDb.DB(), tournamentId, teamPlayer.PlayerId, number, strokes)
+				if err != nil {
+					return err
+				}
+			}
 
-	for i, team := range *teams {
-		if i%2 == 0 {
-			pdf.SetFillColor(240, 240, 240)
-		} else {
-			pdf.SetFillColor(255, 255, 255)
+			changes = append(changes, HandicapRecalculation{
+				TeamId:     teamPlayer.TeamId,
+				PlayerId:   teamPlayer.PlayerId,
+				PlayerName: teamPlayer.PlayerName,
+				Before:     teamPlayer.TeamPlayerHandicap,
+				After:      allocation.TeamPlayerHandicap,
+			})
 		}
 
-		pdf.SetDrawColor(0, 0, 0)
-		pdf.SetLineWidth(0.2)
-
-		pdf.CellFormat(20, 10, fmt.Sprintf("%d", i+1), "1", 0, "C", true, 0, "")
-		pdf.CellFormat(170, 10, team.Name, "1", 1, "L", true, 0, "")
-	}
+		_, err := models.CreateAuditLog(txDb.DB(), tournamentId, "handicaps.recalculate", e.RealIP(), changes)
+		return err
+	})
 
-	var buf bytes.Buffer
-	err = pdf.Output(&buf)
 	if err != nil {
-		return e.Error(http.StatusInternalServerError, "Failed to generate PDF", nil)
+		return e.Error(http.StatusInternalServerError, err.Error(), nil)
 	}
 
-	fileName := fmt.Sprintf("%s_teams.pdf", strings.ToLower(strings.Join(strings.Split(tournament.Name, " "), "_")))
+	leaderboards.Invalidate(tournamentId)
 
-	e.Response.Header().Set("Content-Type", "application/pdf")
-	e.Response.Header().Set("Content-Disposition", "inline; filename="+fileName)
-	e.Response.Header().Set("Content-Length", fmt.Sprintf("%d", buf.Len()))
-
-	_, err = e.Response.Write(buf.Bytes())
-	if err != nil {
-		return e.Error(http.StatusInternalServerError, "Failed to write PDF response", nil)
-	}
+	return e.JSON(http.StatusOK, changes)
+}
 
-	return e.JSON(http.StatusOK, "OK")
+type TournamentUpdateRequest struct {
+	models.TournamentUpdate
+	// ConfirmDestructive must be set to regenerate teams once scores have
+	// been entered, since regenerating deletes them.
+	ConfirmDestructive bool `json:"confirmDestructive,omitempty"`
 }
 
+// HandleUpdateTournament only touches the fields that were sent. Sending
+// players or teamCount regenerates every unlocked team, which needs
+// confirmDestructive when any of them has entered a score; single roster
+// changes go through the tournament player endpoints instead.
 func (tc *TournamentController) HandleUpdateTournament(e *core.RequestEvent) error {
-	var data models.TournamentUpdate
+	var data TournamentUpdateRequest
 	tournamentId := e.Request.PathValue("tournamentId")
 
 	err := json.NewDecoder(e.Request.Body).Decode(&data)
@@ -165,47 +207,147 @@ func (tc *TournamentController) HandleUpdateTournament(e *core.RequestEvent) err
 		return e.BadRequestError(err.Error(), nil)
 	}
 
-	err = tc.app.RunInTransaction(func(txDb core.App) error {
-		_, err := models.UpdateTournament(txDb.DB(), tournamentId, data)
+	tournament, err := models.GetTournamentById(tc.db, tournamentId)
+	if err != nil {
+		return e.NotFoundError(err.Error(), tournamentId)
+	}
+
+	regenerate := data.Players != nil || data.TeamCount != nil
+	if !regenerate && !data.HasColumnUpdates() {
+		return e.BadRequestError("no fields to update", nil)
+	}
+
+	if data.TeamScoring != nil || data.BestScores != nil {
+		scoring, bestScores := tournament.TeamScoring, tournament.BestScores
+		if data.TeamScoring != nil {
+			scoring = *data.TeamScoring
+		}
+		if data.BestScores != nil {
+			bestScores = *data.BestScores
+		}
+		err = validateTeamScoring(scoring, bestScores)
 		if err != nil {
-			return err
+			return e.BadRequestError(err.Error(), nil)
 		}
+	}
 
-		_, err = models.DeleteHolesForTeam(txDb.DB(), tournamentId)
+	if data.BallFormat != nil || data.HandicapWeights != nil || data.MinDrives != nil {
+		ballFormat, weights, minDrives := tournament.BallFormat, tournament.HandicapWeights, tournament.MinDrives
+		if data.BallFormat != nil {
+			ballFormat = *data.BallFormat
+		}
+		if data.HandicapWeights != nil {
+			weights = *data.HandicapWeights
+		}
+		if data.MinDrives != nil {
+			minDrives = *data.MinDrives
+		}
+		err = validateBallFormat(ballFormat, weights, minDrives)
 		if err != nil {
-			return err
+			return e.BadRequestError(err.Error(), nil)
 		}
-		_, err = models.DeleteTournamentTeams(txDb.DB(), tournamentId)
+
+		// started teams already have the holes of the format they teed
+		// off in
+		if ballFormat != tournament.BallFormat || weights != tournament.HandicapWeights {
+			teams, err := models.GetTeamsByTournamentId(tc.db, tournamentId)
+			if err != nil {
+				return e.Error(http.StatusInternalServerError, err.Error(), nil)
+			}
+			for _, team := range *teams {
+				if team.Started {
+					return e.Error(http.StatusConflict, "the ball format and handicap weights can't change once a team has teed off", nil)
+				}
+			}
+		}
+	}
+
+	var players []models.Player
+	teamCount := int(tournament.TeamCount)
+	if regenerate {
+		if data.Players != nil {
+			players = *data.Players
+		} else {
+			current, err := models.GetPlayersByTournament(tc.db, tournamentId)
+			if err != nil {
+				return e.Error(http.StatusInternalServerError, err.Error(), nil)
+			}
+			players = *current
+		}
+
+		// players on locked teams keep their places
+		locked, err := models.GetLockedTeamPlayerIds(tc.db, tournamentId)
 		if err != nil {
-			return err
+			return e.Error(http.StatusInternalServerError, err.Error(), nil)
+		}
+		unlocked := []models.Player{}
+		for _, player := range players {
+			if !locked[player.Id] {
+				unlocked = append(unlocked, player)
+			}
+		}
+		players = unlocked
+		if data.TeamCount != nil {
+			teamCount = *data.TeamCount
 		}
 
-		teams, err := generateTeams(tournamentId, *data.Players, *data.TeamCount)
+		hasScores, err := models.UnlockedTeamsHaveScores(tc.db, tournamentId)
 		if err != nil {
-			return err
+			return e.Error(http.StatusInternalServerError, err.Error(), nil)
+		}
+		if hasScores && !data.ConfirmDestructive {
+			return e.Error(http.StatusConflict, "regenerating teams deletes entered scores, resend with confirmDestructive to continue", nil)
 		}
+	}
 
-		for _, team := range *teams {
-			newTeam, err := models.CreateTeam(txDb.DB(), team.Team.TournamentId, team.Team.Name)
+	err = tc.app.RunInTransaction(func(txDb core.App) error {
+		if data.HasColumnUpdates() {
+			_, err := models.UpdateTournament(txDb.DB(), tournamentId, data.TournamentUpdate)
 			if err != nil {
 				return err
 			}
+		}
 
-			for _, player := range team.Players {
-				_, err = models.CreateTeamPlayerLookup(txDb.DB(), newTeam.Id, player.Id, player.Tee, tournamentId)
+		if regenerate {
+			err := models.DeleteUnlockedTeams(txDb.DB(), tournamentId)
+			if err != nil {
+				return err
+			}
+
+			teams, err := generateTeams(tournamentId, players, teamCount)
+			if err != nil {
+				return err
+			}
+
+			for _, team := range *teams {
+				newTeam, err := models.CreateTeam(txDb.DB(), team.Team.TournamentId, team.Team.Name)
 				if err != nil {
 					return err
 				}
+
+				for _, player := range team.Players {
+					_, err = models.CreateTeamPlayerLookup(txDb.DB(), newTeam.Id, player.Id, player.Tee, tournamentId, player.Handicap)
+					if err != nil {
+						return err
+					}
+				}
 			}
 		}
 
-		return nil
+		action := "tournament.update"
+		if regenerate {
+			action = "tournament.regenerate"
+		}
+		_, err := models.CreateAuditLog(txDb.DB(), tournamentId, action, e.RealIP(), data)
+		return err
 	})
 
 	if err != nil {
 		return e.InternalServerError(err.Error(), nil)
 	}
 
+	leaderboards.Invalidate(tournamentId)
+
 	return e.JSON(http.StatusCreated, "ok")
 }
 
@@ -217,8 +359,23 @@ func (tc *TournamentController) HandleCreateTournament(e *core.RequestEvent) err
 		return e.BadRequestError(err.Error(), nil)
 	}
 
+	if data.TeamScoring == "" {
+		data.TeamScoring = models.TEAM_SCORING_INDEPENDENT
+	}
+	err = validateTeamScoring(data.TeamScoring, data.BestScores)
+	if err != nil {
+		return e.BadRequestError(err.Error(), nil)
+	}
+	if data.BallFormat == "" {
+		data.BallFormat = models.BALL_FORMAT_INDIVIDUAL
+	}
+	err = validateBallFormat(data.BallFormat, data.HandicapWeights, data.MinDrives)
+	if err != nil {
+		return e.BadRequestError(err.Error(), nil)
+	}
+
 	err = tc.app.RunInTransaction(func(txDb core.App) error {
-		tournament, err := models.CreateTournament(txDb.DB(), data.CourseId, data.FormatId, data.Name, data.TeamCount, data.AwardedHandicap, data.IsMatchPlay)
+		tournament, err := models.CreateTournament(txDb.DB(), data)
 		if err != nil {
 			return err
 		}
@@ -235,7 +392,7 @@ func (tc *TournamentController) HandleCreateTournament(e *core.RequestEvent) err
 			}
 
 			for _, player := range team.Players {
-				_, err = models.CreateTeamPlayerLookup(txDb.DB(), newTeam.Id, player.Id, player.Tee, tournament.Id)
+				_, err = models.CreateTeamPlayerLookup(txDb.DB(), newTeam.Id, player.Id, player.Tee, tournament.Id, player.Handicap)
 				if err != nil {
 					return err
 				}
@@ -288,16 +445,21 @@ func generateTeams(tournamentId string, players []models.Player, teamCount int)
 	}
 
 	for i, team := range teams {
-		playerNames := []string{}
-		for _, player := range team.Players {
-			playerNames = append(playerNames, fmt.Sprintf(`%s (%v)`, player.Name, player.Handicap))
-		}
-		teams[i].Team.Name = strings.Join(playerNames, " + ")
+		teams[i].Team.Name = generatedTeamName(team.Players)
 	}
 
 	return &teams, nil
 }
 
+func generatedTeamName(players []models.Player) string {
+	playerNames := []string{}
+	for _, player := range players {
+		playerNames = append(playerNames, fmt.Sprintf(`%s (%v)`, player.Name, player.Handicap))
+	}
+
+	return strings.Join(playerNames, " + ")
+}
+
 type LeaderboardRow struct {
 	Id             string `json:"id"`
 	TeamName       string `json:"teamName"`
@@ -306,66 +468,74 @@ type LeaderboardRow struct {
 	MatchPlayScore string `json:"matchPlayScore,omitempty"`
 	Thru           int    `json:"thru"`
 	CoursePar      int    `json:"coursePar"`
+	// Contests names the contests the player, or anyone on the team, leads.
+	Contests []string `json:"contests,omitempty"`
+	// Holes lists what a team counted on each hole it has completed.
+	Holes []TeamHoleResult `json:"holes,omitempty"`
 }
 
 func (tc *TournamentController) HandleGetLeaderboard(e *core.RequestEvent) error {
 	tournamentId := e.Request.PathValue("tournamentId")
 	individuals := e.Request.URL.Query().Get("individuals")
 
-	course, err := models.GetCourseByTournamentId(tc.db, tournamentId)
-	if err != nil {
-		return e.Error(http.StatusInternalServerError, err.Error(), "GetCourseByTournamentId")
-	}
-
-	var courseHoles = make(models.CourseHoleDataMap)
-	for _, hole := range course.Meta.Holes {
-		courseHoles[hole.Number] = hole
+	// a flight's board is ranked by its own scoring and tiebreak, so it is
+	// built from the cached snapshot rather than served from it
+	if flightId := e.Request.URL.Query().Get("flight"); flightId != "" {
+		boards, err := getFlightLeaderboards(tc.db, tournamentId)
+		if err != nil {
+			return e.Error(http.StatusInternalServerError, err.Error(), nil)
+		}
+		for _, board := range boards {
+			if board.Flight.Id == flightId {
+				return e.JSON(http.StatusOK, board)
+			}
+		}
+		return e.NotFoundError("flight not found", flightId)
 	}
 
-	teams, err := models.GetTeamsByTournamentId(tc.db, tournamentId)
+	snapshot, err := leaderboards.Get(tc.db, tournamentId, individuals != "false")
 	if err != nil {
-		return e.Error(http.StatusInternalServerError, err.Error(), "GetTeamsByTournamentId")
-	}
-	teamIds := []string{}
-	for _, team := range *teams {
-		teamIds = append(teamIds, team.Id)
+		return e.Error(http.StatusInternalServerError, err.Error(), "leaderboards.Get")
 	}
 
-	holes, err := models.GetTournamentHoles(tc.db, tournamentId, teamIds)
-	if err != nil {
-		return e.Error(http.StatusInternalServerError, err.Error(), "GetHolesForLeaderboard")
+	e.Response.Header().Set("ETag", snapshot.ETag)
+	e.Response.Header().Set("Cache-Control", "no-cache")
+
+	if etagMatches(e.Request.Header.Get("If-None-Match"), snapshot.ETag) {
+		return e.NoContent(http.StatusNotModified)
 	}
 
-	var coursePar int
+	return e.Blob(http.StatusOK, "application/json", snapshot.Body)
+}
 
-	for index, hole := range *holes {
-		playerTee := hole.Tee
-		holeIndex := (course.Meta.Holes)[hole.Number-1].Handicap
-		courseTeeData := course.Meta.Tees[playerTee]
+// etagMatches reports whether an If-None-Match header names etag. The
+// header is "*" or a comma separated list of entity tags, compared weakly
+// as RFC 9110 asks, so a W/ prefix on either side is ignored.
+func etagMatches(header string, etag string) bool {
+	etag = strings.TrimPrefix(etag, "W/")
 
-		if coursePar == 0 {
-			coursePar = course.Meta.Tees[playerTee].Par
+	for {
+		header = strings.TrimLeft(header, " \t,")
+		if header == "" {
+			return false
+		}
+		if header[0] == '*' {
+			return true
 		}
 
-		hole.StrokeHole = getStrokeHole(
-			hole.PlayerHandicap,
-			float64(courseTeeData.SlopeRating),
-			courseTeeData.CourseRating,
-			float64(courseTeeData.Par),
-			hole.AwardedTournamentHandicap,
-			holeIndex)
-		(*holes)[index] = hole
-	}
-
-	var leaderboardRows []LeaderboardRow
-
-	if individuals == "false" {
-		leaderboardRows = getTeamLeaderboard(holes, courseHoles, coursePar)
-	} else {
-		leaderboardRows = getIndividualLeaderboard(holes, courseHoles, coursePar)
+		header = strings.TrimPrefix(header, "W/")
+		if header == "" || header[0] != '"' {
+			return false
+		}
+		end := strings.IndexByte(header[1:], '"')
+		if end < 0 {
+			return false
+		}
+		if header[:end+2] == etag {
+			return true
+		}
+		header = header[end+2:]
 	}
-
-	return e.JSON(http.StatusOK, leaderboardRows)
 }
 
 func groupHolesByPlayerByTeam(holes []models.HoleWithMetadata) map[string]map[int][]models.HoleWithMetadata {
@@ -384,55 +554,154 @@ func groupHolesByPlayerByTeam(holes []models.HoleWithMetadata) map[string]map[in
 	return result
 }
 
-func getTeamLeaderboard(holes *[]models.HoleWithMetadata, courseHoles models.CourseHoleDataMap, coursePar int) []LeaderboardRow {
+// TeamHoleResult is what a team counted on one hole, to par, with the
+// players whose scores make up each total.
+type TeamHoleResult struct {
+	Number       int      `json:"number"`
+	Count        int      `json:"count"`
+	Gross        int      `json:"gross"`
+	Net          int      `json:"net"`
+	GrossPlayers []string `json:"grossPlayers"`
+	NetPlayers   []string `json:"netPlayers"`
+}
+
+// teamScoring is how a tournament's team rows add up: the best counts[i]
+// scores on the i-th hole, repeating the pattern around the course.
+type teamScoring struct {
+	scoring string
+	counts  []int
+}
+
+func newTeamScoring(tournament *models.Tournament) teamScoring {
+	counts, err := models.ParseBestScores(tournament.BestScores)
+	if err != nil {
+		counts = []int{1}
+	}
+
+	scoring := tournament.TeamScoring
+	if scoring == "" {
+		scoring = models.TEAM_SCORING_INDEPENDENT
+	}
+
+	return teamScoring{scoring: scoring, counts: counts}
+}
+
+func (ts teamScoring) countOnHole(number int) int {
+	return ts.counts[(number-1)%len(ts.counts)]
+}
+
+func validateTeamScoring(scoring string, bestScores string) error {
+	switch scoring {
+	case "", models.TEAM_SCORING_NET, models.TEAM_SCORING_GROSS, models.TEAM_SCORING_INDEPENDENT:
+	default:
+		return fmt.Errorf("teamScoring must be net, gross or independent")
+	}
+
+	_, err := models.ParseBestScores(bestScores)
+	return err
+}
+
+// getTeamLeaderboard adds up the best scores each team counts on a hole. A
+// hole only counts once enough players have scored on it, or all of them
+// when the team is smaller than the count.
+func getTeamLeaderboard(holes *[]models.HoleWithMetadata, courseHoles models.CourseHoleDataMap, coursePar int, scoring teamScoring) []LeaderboardRow {
+	type playerScore struct {
+		playerId string
+		name     string
+		gross    int
+		net      int
+	}
+
 	holesByTeamAndPlayer := groupHolesByPlayerByTeam(*holes)
 	leaderboardRows := []LeaderboardRow{}
 
 	for teamId, teamHolesMap := range holesByTeamAndPlayer {
 		leaderboardRow := LeaderboardRow{
+			Id:        teamId,
 			CoursePar: coursePar,
+			Holes:     []TeamHoleResult{},
 		}
 		players := map[string]bool{}
 
-		leaderboardRow.Id = teamId
+		numbers := []int{}
+		for number := range teamHolesMap {
+			numbers = append(numbers, number)
+		}
+		sort.Ints(numbers)
 
-		thruCount := 0
-		for _, teamScoreOnHole := range teamHolesMap {
-			var holePar int
-			var netScore int
-			var grossScore int
+		for _, number := range numbers {
+			holePar := courseHoles[number].Par
 
-			for _, hole := range teamScoreOnHole {
+			scores := []playerScore{}
+			for _, hole := range teamHolesMap[number] {
 				players[hole.PlayerName] = true
 
-				if len(hole.Score) > 0 {
-					holePar = courseHoles[hole.Number].Par
-
-					var holeScore int
-					if hole.Score == "X" {
-						holeScore = 3 + holePar
-					} else {
-						holeScore, _ = strconv.Atoi(hole.Score)
-					}
+				gross, ok := holeGrossScore(hole.Score, holePar)
+				if !ok {
+					continue
+				}
+				net := gross
+				if hole.StrokeHole > 0 {
+					net = gross - hole.StrokeHole
+				}
+				scores = append(scores, playerScore{hole.PlayerId, hole.PlayerName, gross, net})
+			}
 
-					if holeScore <= grossScore || grossScore == 0 {
-						netScore = holeScore
-						grossScore = holeScore
+			count := min(scoring.countOnHole(number), len(teamHolesMap[number]))
+			if count == 0 || len(scores) < count {
+				continue
+			}
 
-						if hole.StrokeHole > 0 {
-							netScore = holeScore - hole.StrokeHole
-						}
-					}
+			byNet := make([]playerScore, len(scores))
+			copy(byNet, scores)
+			sort.SliceStable(byNet, func(i, j int) bool {
+				if byNet[i].net != byNet[j].net {
+					return byNet[i].net < byNet[j].net
 				}
+				if byNet[i].gross != byNet[j].gross {
+					return byNet[i].gross < byNet[j].gross
+				}
+				return byNet[i].name < byNet[j].name
+			})
+			byGross := make([]playerScore, len(scores))
+			copy(byGross, scores)
+			sort.SliceStable(byGross, func(i, j int) bool {
+				if byGross[i].gross != byGross[j].gross {
+					return byGross[i].gross < byGross[j].gross
+				}
+				if byGross[i].net != byGross[j].net {
+					return byGross[i].net < byGross[j].net
+				}
+				return byGross[i].name < byGross[j].name
+			})
+
+			netCounted, grossCounted := byNet[:count], byGross[:count]
+			switch scoring.scoring {
+			case models.TEAM_SCORING_NET:
+				grossCounted = netCounted
+			case models.TEAM_SCORING_GROSS:
+				netCounted = grossCounted
 			}
 
-			if netScore > 0 || grossScore > 0 {
-				thruCount++
+			result := TeamHoleResult{
+				Number:       number,
+				Count:        count,
+				GrossPlayers: []string{},
+				NetPlayers:   []string{},
+			}
+			for _, score := range grossCounted {
+				result.Gross += score.gross - holePar
+				result.GrossPlayers = append(result.GrossPlayers, score.playerId)
+			}
+			for _, score := range netCounted {
+				result.Net += score.net - holePar
+				result.NetPlayers = append(result.NetPlayers, score.playerId)
 			}
 
-			leaderboardRow.Thru = thruCount
-			leaderboardRow.Gross -= holePar - grossScore
-			leaderboardRow.Net -= holePar - netScore
+			leaderboardRow.Thru++
+			leaderboardRow.Gross += result.Gross
+			leaderboardRow.Net += result.Net
+			leaderboardRow.Holes = append(leaderboardRow.Holes, result)
 		}
 
 		names := []string{}
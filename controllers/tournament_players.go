@@ -0,0 +1,386 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/patrick-salvatore/tournament-live-scoring/models"
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+type TournamentPlayerAdd struct {
+	PlayerId string `json:"playerId"`
+	TeamId   string `json:"teamId"`
+	Tee      string `json:"tee,omitempty"`
+}
+
+type TournamentPlayerMove struct {
+	TeamId string `json:"teamId"`
+	// Tee optionally changes the tee the player plays from, which is only
+	// allowed before their holes exist.
+	Tee string `json:"tee,omitempty"`
+}
+
+type TournamentPlayerSwap struct {
+	PlayerIds []string `json:"playerIds"`
+}
+
+// HandleAddTournamentPlayer adds one player to an existing team. Joining a
+// team that has already teed off creates their holes straight away.
+func (tc *TournamentController) HandleAddTournamentPlayer(e *core.RequestEvent) error {
+	var data TournamentPlayerAdd
+	tournamentId := e.Request.PathValue("tournamentId")
+
+	err := json.NewDecoder(e.Request.Body).Decode(&data)
+	if err != nil {
+		return e.BadRequestError(err.Error(), nil)
+	}
+
+	tournament, err := models.GetTournamentById(tc.db, tournamentId)
+	if err != nil {
+		return e.NotFoundError(err.Error(), tournamentId)
+	}
+
+	team, err := getTournamentTeam(tc.db, tournamentId, data.TeamId)
+	if err != nil {
+		return e.BadRequestError(err.Error(), nil)
+	}
+
+	player, err := models.GetPlayerById(tc.db, data.PlayerId)
+	if err != nil {
+		return e.NotFoundError(err.Error(), data.PlayerId)
+	}
+	if player.Archived {
+		return e.BadRequestError("player is archived", nil)
+	}
+
+	_, err = models.GetTeamPlayer(tc.db, tournamentId, player.Id)
+	if err == nil {
+		return e.Error(http.StatusConflict, "player is already in this tournament", nil)
+	}
+
+	course, err := models.GetCourseByTournamentId(tc.db, tournamentId)
+	if err != nil {
+		return e.Error(http.StatusInternalServerError, err.Error(), nil)
+	}
+
+	tee := data.Tee
+	if len(tee) == 0 {
+		tee = player.PreferredTee
+	}
+	if _, ok := course.Meta.Tees[tee]; !ok {
+		return e.BadRequestError(fmt.Sprintf("unknown tee %q", tee), nil)
+	}
+
+	err = tc.app.RunInTransaction(func(txDb core.App) error {
+		_, err := models.CreateTeamPlayerLookup(txDb.DB(), team.Id, player.Id, tee, tournamentId, player.Handicap)
+		if err != nil {
+			return err
+		}
+
+		if team.Started {
+			err = startTeamPlayer(txDb.DB(), tournament, course, team.Id, player.Id, tee, player.Handicap)
+			if err != nil {
+				return err
+			}
+		}
+
+		err = refreshTeamNames(txDb.DB(), team.Id)
+		if err != nil {
+			return err
+		}
+
+		_, err = models.CreateAuditLog(txDb.DB(), tournamentId, "roster.add", e.RealIP(), map[string]string{
+			"playerId": player.Id,
+			"teamId":   team.Id,
+			"tee":      tee,
+		})
+		return err
+	})
+
+	if err != nil {
+		return e.Error(http.StatusInternalServerError, err.Error(), nil)
+	}
+
+	leaderboards.InvalidateTeam(tournamentId, team.Id)
+
+	return e.JSON(http.StatusCreated, "ok")
+}
+
+// HandleRemoveTournamentPlayer takes a player out of the tournament along
+// with their holes. Removing a player who has entered scores needs
+// ?confirmDestructive=true.
+func (tc *TournamentController) HandleRemoveTournamentPlayer(e *core.RequestEvent) error {
+	tournamentId := e.Request.PathValue("tournamentId")
+	playerId := e.Request.PathValue("playerId")
+	confirmed := e.Request.URL.Query().Get("confirmDestructive") == "true"
+
+	teamPlayer, err := models.GetTeamPlayer(tc.db, tournamentId, playerId)
+	if err != nil {
+		return e.NotFoundError("player is not in this tournament", playerId)
+	}
+
+	_, hasScores, err := models.PlayerHasHoles(tc.db, tournamentId, playerId)
+	if err != nil {
+		return e.Error(http.StatusInternalServerError, err.Error(), nil)
+	}
+	if hasScores && !confirmed {
+		return e.Error(http.StatusConflict, "removing this player deletes their entered scores, resend with confirmDestructive=true to continue", nil)
+	}
+
+	err = tc.app.RunInTransaction(func(txDb core.App) error {
+		err := models.DeleteHolesForPlayer(txDb.DB(), tournamentId, playerId)
+		if err != nil {
+			return err
+		}
+
+		err = models.DeleteTeamPlayerLookup(txDb.DB(), tournamentId, playerId)
+		if err != nil {
+			return err
+		}
+
+		err = refreshTeamNames(txDb.DB(), teamPlayer.TeamId)
+		if err != nil {
+			return err
+		}
+
+		_, err = models.CreateAuditLog(txDb.DB(), tournamentId, "roster.remove", e.RealIP(), map[string]any{
+			"playerId":      playerId,
+			"teamId":        teamPlayer.TeamId,
+			"scoresDeleted": hasScores,
+		})
+		return err
+	})
+
+	if err != nil {
+		return e.Error(http.StatusInternalServerError, err.Error(), nil)
+	}
+
+	leaderboards.InvalidateTeam(tournamentId, teamPlayer.TeamId)
+
+	return e.NoContent(http.StatusNoContent)
+}
+
+// HandleMoveTournamentPlayer moves a player to another team. Holes belong to
+// the player, so any scores they have entered move with them.
+func (tc *TournamentController) HandleMoveTournamentPlayer(e *core.RequestEvent) error {
+	var data TournamentPlayerMove
+	tournamentId := e.Request.PathValue("tournamentId")
+	playerId := e.Request.PathValue("playerId")
+
+	err := json.NewDecoder(e.Request.Body).Decode(&data)
+	if err != nil {
+		return e.BadRequestError(err.Error(), nil)
+	}
+
+	tournament, err := models.GetTournamentById(tc.db, tournamentId)
+	if err != nil {
+		return e.NotFoundError(err.Error(), tournamentId)
+	}
+
+	teamPlayer, err := models.GetTeamPlayer(tc.db, tournamentId, playerId)
+	if err != nil {
+		return e.NotFoundError("player is not in this tournament", playerId)
+	}
+	if teamPlayer.TeamId == data.TeamId {
+		return e.BadRequestError("player is already on this team", nil)
+	}
+
+	team, err := getTournamentTeam(tc.db, tournamentId, data.TeamId)
+	if err != nil {
+		return e.BadRequestError(err.Error(), nil)
+	}
+
+	hasHoles, _, err := models.PlayerHasHoles(tc.db, tournamentId, playerId)
+	if err != nil {
+		return e.Error(http.StatusInternalServerError, err.Error(), nil)
+	}
+
+	course, err := models.GetCourseByTournamentId(tc.db, tournamentId)
+	if err != nil {
+		return e.Error(http.StatusInternalServerError, err.Error(), nil)
+	}
+
+	tee := teamPlayer.Tee
+	if len(data.Tee) > 0 && data.Tee != tee {
+		if hasHoles {
+			return e.BadRequestError("tee can't change once the player's holes exist", nil)
+		}
+		if _, ok := course.Meta.Tees[data.Tee]; !ok {
+			return e.BadRequestError(fmt.Sprintf("unknown tee %q", data.Tee), nil)
+		}
+		tee = data.Tee
+	}
+
+	err = tc.app.RunInTransaction(func(txDb core.App) error {
+		err := models.MoveTeamPlayer(txDb.DB(), tournamentId, playerId, team.Id)
+		if err != nil {
+			return err
+		}
+
+		if tee != teamPlayer.Tee {
+			err = models.UpdateTeamPlayerTee(txDb.DB(), tournamentId, playerId, tee)
+			if err != nil {
+				return err
+			}
+		}
+
+		if team.Started && !hasHoles {
+			err = startTeamPlayer(txDb.DB(), tournament, course, team.Id, playerId, tee, teamPlayer.HandicapIndex)
+			if err != nil {
+				return err
+			}
+		}
+
+		err = refreshTeamNames(txDb.DB(), teamPlayer.TeamId, team.Id)
+		if err != nil {
+			return err
+		}
+
+		_, err = models.CreateAuditLog(txDb.DB(), tournamentId, "roster.move", e.RealIP(), map[string]string{
+			"playerId": playerId,
+			"fromTeam": teamPlayer.TeamId,
+			"toTeam":   team.Id,
+			"tee":      tee,
+		})
+		return err
+	})
+
+	if err != nil {
+		return e.Error(http.StatusInternalServerError, err.Error(), nil)
+	}
+
+	leaderboards.InvalidateTeam(tournamentId, teamPlayer.TeamId)
+	leaderboards.InvalidateTeam(tournamentId, team.Id)
+
+	return e.JSON(http.StatusOK, "ok")
+}
+
+// HandleSwapTournamentPlayers puts two players on each other's teams. Like
+// a move, scores already entered stay with the player.
+func (tc *TournamentController) HandleSwapTournamentPlayers(e *core.RequestEvent) error {
+	var data TournamentPlayerSwap
+	tournamentId := e.Request.PathValue("tournamentId")
+
+	err := json.NewDecoder(e.Request.Body).Decode(&data)
+	if err != nil {
+		return e.BadRequestError(err.Error(), nil)
+	}
+	if len(data.PlayerIds) != 2 {
+		return e.BadRequestError("playerIds must name exactly two players", nil)
+	}
+
+	tournament, err := models.GetTournamentById(tc.db, tournamentId)
+	if err != nil {
+		return e.NotFoundError(err.Error(), tournamentId)
+	}
+
+	course, err := models.GetCourseByTournamentId(tc.db, tournamentId)
+	if err != nil {
+		return e.Error(http.StatusInternalServerError, err.Error(), nil)
+	}
+
+	swapped := make([]*models.TeamPlayer, 2)
+	for i, playerId := range data.PlayerIds {
+		swapped[i], err = models.GetTeamPlayer(tc.db, tournamentId, playerId)
+		if err != nil {
+			return e.NotFoundError("player is not in this tournament", playerId)
+		}
+	}
+	if swapped[0].TeamId == swapped[1].TeamId {
+		return e.BadRequestError("players are already on the same team", nil)
+	}
+
+	err = tc.app.RunInTransaction(func(txDb core.App) error {
+		for i, teamPlayer := range swapped {
+			other := swapped[1-i]
+
+			err := models.MoveTeamPlayer(txDb.DB(), tournamentId, teamPlayer.PlayerId, other.TeamId)
+			if err != nil {
+				return err
+			}
+
+			team, err := getTournamentTeam(txDb.DB(), tournamentId, other.TeamId)
+			if err != nil {
+				return err
+			}
+
+			hasHoles, _, err := models.PlayerHasHoles(txDb.DB(), tournamentId, teamPlayer.PlayerId)
+			if err != nil {
+				return err
+			}
+
+			if team.Started && !hasHoles {
+				err = startTeamPlayer(txDb.DB(), tournament, course, team.Id, teamPlayer.PlayerId, teamPlayer.Tee, teamPlayer.HandicapIndex)
+				if err != nil {
+					return err
+				}
+			}
+		}
+
+		err := refreshTeamNames(txDb.DB(), swapped[0].TeamId, swapped[1].TeamId)
+		if err != nil {
+			return err
+		}
+
+		_, err = models.CreateAuditLog(txDb.DB(), tournamentId, "roster.swap", e.RealIP(), map[string]string{
+			swapped[0].PlayerId: swapped[1].TeamId,
+			swapped[1].PlayerId: swapped[0].TeamId,
+		})
+		return err
+	})
+
+	if err != nil {
+		return e.Error(http.StatusInternalServerError, err.Error(), nil)
+	}
+
+	leaderboards.InvalidateTeam(tournamentId, swapped[0].TeamId)
+	leaderboards.InvalidateTeam(tournamentId, swapped[1].TeamId)
+
+	return e.JSON(http.StatusOK, "ok")
+}
+
+// startTeamPlayer creates holes and freezes strokes for a player joining a
+// team that has already teed off.
+func startTeamPlayer(db dbx.Builder, tournament *models.Tournament, course *models.CourseWithData, teamId string, playerId string, tee string, handicapIndex float64) error {
+	allocation := allocateStrokes(course, tee, handicapIndex, tournament.AwardedHandicap)
+
+	// team ball formats score on the team's holes instead
+	if !tournament.PlaysTeamBall() {
+		_, err := models.CreateAllHolesForPlayer(db, playerId, tournament.Id, course.Meta.Holes, allocation.Strokes)
+		if err != nil {
+			return err
+		}
+	}
+
+	return models.FreezeTeamPlayerHandicap(db, teamId, playerId, allocation.TeamPlayerHandicap)
+}
+
+// refreshTeamNames rebuilds generated names after a roster change, the same
+// way generateTeams names new teams.
+func refreshTeamNames(db dbx.Builder, teamIds ...string) error {
+	for _, teamId := range teamIds {
+		players, err := models.GetPlayersFromTeamId(db, teamId)
+		if err != nil {
+			return err
+		}
+
+		err = models.RefreshTeamName(db, teamId, generatedTeamName(*players))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func getTournamentTeam(db dbx.Builder, tournamentId string, teamId string) (*models.Team, error) {
+	team, err := models.GetTeamById(db, teamId)
+	if err != nil || team.TournamentId != tournamentId {
+		return nil, fmt.Errorf("team %q is not part of this tournament", teamId)
+	}
+
+	return team, nil
+}
@@ -0,0 +1,92 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/patrick-salvatore/tournament-live-scoring/models"
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+type TournamentStatusUpdate struct {
+	Status string `json:"status"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// HandleUpdateTournamentStatus moves a tournament through its lifecycle,
+// e.g. suspending play for weather, finalizing results or reopening them.
+func (tc *TournamentController) HandleUpdateTournamentStatus(e *core.RequestEvent) error {
+	var data TournamentStatusUpdate
+	tournamentId := e.Request.PathValue("tournamentId")
+
+	err := json.NewDecoder(e.Request.Body).Decode(&data)
+	if err != nil {
+		return e.BadRequestError(err.Error(), nil)
+	}
+
+	tournament, err := models.GetTournamentById(tc.db, tournamentId)
+	if err != nil {
+		return e.NotFoundError(err.Error(), tournamentId)
+	}
+
+	if !models.CanTransitionTournament(tournament.Status, data.Status) {
+		return e.Error(http.StatusConflict, fmt.Sprintf("tournament can't move from %s to %s", tournament.Status, data.Status), nil)
+	}
+
+	var event *models.TournamentEvent
+	err = tc.app.RunInTransaction(func(txDb core.App) error {
+		event, err = models.TransitionTournament(txDb.DB(), tournament, data.Status, data.Reason, e.RealIP())
+		return err
+	})
+
+	if errors.Is(err, models.ErrTournamentStatusChanged) {
+		return e.Error(http.StatusConflict, err.Error(), nil)
+	}
+	if err != nil {
+		return e.Error(http.StatusInternalServerError, err.Error(), nil)
+	}
+
+	leaderboards.Invalidate(tournamentId)
+
+	return e.JSON(http.StatusOK, event)
+}
+
+func (tc *TournamentController) HandleGetTournamentEvents(e *core.RequestEvent) error {
+	tournamentId := e.Request.PathValue("tournamentId")
+
+	events, err := models.GetTournamentEvents(tc.db, tournamentId)
+	if err != nil {
+		return e.Error(http.StatusInternalServerError, err.Error(), nil)
+	}
+
+	return e.JSON(http.StatusOK, events)
+}
+
+// beginPlay checks that teams may tee off and moves a tournament that is
+// still being set up to in progress.
+func beginPlay(db dbx.Builder, tournament *models.Tournament, actor string) error {
+	if !tournament.AcceptsScores() {
+		return fmt.Errorf("tournament is %s", tournament.Status)
+	}
+	if tournament.Status == models.TOURNAMENT_STATUS_IN_PROGRESS {
+		return nil
+	}
+
+	_, err := models.TransitionTournament(db, tournament, models.TOURNAMENT_STATUS_IN_PROGRESS, "first team teed off", actor)
+	if !errors.Is(err, models.ErrTournamentStatusChanged) {
+		return err
+	}
+
+	// another team teeing off at the same time may have moved it on, so
+	// start again from where it is now
+	current, err := models.GetTournamentById(db, tournament.Id)
+	if err != nil {
+		return err
+	}
+	*tournament = *current
+
+	return beginPlay(db, tournament, actor)
+}
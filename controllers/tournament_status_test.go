@@ -0,0 +1,43 @@
+package controllers
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/patrick-salvatore/tournament-live-scoring/models"
+)
+
+func TestTransitionTournamentFromStaleStatus(t *testing.T) {
+	app := newTestApp(t)
+
+	tournament, _ := newTestTournament(t, app, models.CreateTournamentData{Name: "Spring", TeamCount: 1, AwardedHandicap: 1}, nil)
+	stale := *tournament
+
+	_, err := models.TransitionTournament(app.DB(), tournament, models.TOURNAMENT_STATUS_REGISTRATION, "", "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = models.TransitionTournament(app.DB(), &stale, models.TOURNAMENT_STATUS_IN_PROGRESS, "", "test")
+	if !errors.Is(err, models.ErrTournamentStatusChanged) {
+		t.Fatalf("expected ErrTournamentStatusChanged, got %v", err)
+	}
+
+	events, err := models.GetTournamentEvents(app.DB(), tournament.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(*events) != 1 {
+		t.Fatalf("expected only the first transition recorded, got %d events", len(*events))
+	}
+
+	// teeing off on the stale copy finds the tournament already moved on
+	// and starts play from where it is
+	err = beginPlay(app.DB(), &stale, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stale.Status != models.TOURNAMENT_STATUS_IN_PROGRESS {
+		t.Fatalf("expected the tournament in progress, got %s", stale.Status)
+	}
+}
@@ -0,0 +1,176 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/patrick-salvatore/tournament-live-scoring/models"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+type TournamentTeamPlayer struct {
+	PlayerId string `json:"playerId"`
+	Tee      string `json:"tee,omitempty"`
+}
+
+type TournamentTeamCreate struct {
+	// Name is generated from the players when left empty.
+	Name    string                 `json:"name,omitempty"`
+	Locked  bool                   `json:"locked,omitempty"`
+	Players []TournamentTeamPlayer `json:"players"`
+}
+
+// HandleCreateTournamentTeam builds a team by hand from players who are not
+// yet in the tournament.
+func (tc *TournamentController) HandleCreateTournamentTeam(e *core.RequestEvent) error {
+	var data TournamentTeamCreate
+	tournamentId := e.Request.PathValue("tournamentId")
+
+	err := json.NewDecoder(e.Request.Body).Decode(&data)
+	if err != nil {
+		return e.BadRequestError(err.Error(), nil)
+	}
+
+	_, err = models.GetTournamentById(tc.db, tournamentId)
+	if err != nil {
+		return e.NotFoundError(err.Error(), tournamentId)
+	}
+
+	course, err := models.GetCourseByTournamentId(tc.db, tournamentId)
+	if err != nil {
+		return e.Error(http.StatusInternalServerError, err.Error(), nil)
+	}
+
+	players := []models.Player{}
+	for _, teamPlayer := range data.Players {
+		player, err := models.GetPlayerById(tc.db, teamPlayer.PlayerId)
+		if err != nil {
+			return e.NotFoundError(err.Error(), teamPlayer.PlayerId)
+		}
+		if player.Archived {
+			return e.BadRequestError(fmt.Sprintf("player %s is archived", player.Name), nil)
+		}
+
+		_, err = models.GetTeamPlayer(tc.db, tournamentId, player.Id)
+		if err == nil {
+			return e.Error(http.StatusConflict, fmt.Sprintf("player %s is already in this tournament", player.Name), nil)
+		}
+
+		player.Tee = teamPlayer.Tee
+		if len(player.Tee) == 0 {
+			player.Tee = player.PreferredTee
+		}
+		if _, ok := course.Meta.Tees[player.Tee]; !ok {
+			return e.BadRequestError(fmt.Sprintf("unknown tee %q for player %s", player.Tee, player.Name), nil)
+		}
+
+		players = append(players, *player)
+	}
+
+	name := data.Name
+	if len(name) == 0 {
+		name = generatedTeamName(players)
+	}
+
+	var team *models.Team
+	err = tc.app.RunInTransaction(func(txDb core.App) error {
+		team, err = models.CreateTeamWithOptions(txDb.DB(), models.TeamCreate{
+			TournamentId: tournamentId,
+			Name:         name,
+			Locked:       data.Locked,
+			NameLocked:   len(data.Name) > 0,
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, player := range players {
+			_, err = models.CreateTeamPlayerLookup(txDb.DB(), team.Id, player.Id, player.Tee, tournamentId, player.Handicap)
+			if err != nil {
+				return err
+			}
+		}
+
+		_, err = models.CreateAuditLog(txDb.DB(), tournamentId, "team.create", e.RealIP(), map[string]any{
+			"teamId":  team.Id,
+			"locked":  data.Locked,
+			"players": data.Players,
+		})
+		return err
+	})
+
+	if err != nil {
+		return e.Error(http.StatusInternalServerError, err.Error(), nil)
+	}
+
+	leaderboards.Invalidate(tournamentId)
+
+	return e.JSON(http.StatusCreated, team)
+}
+
+// HandleUpdateTournamentTeam renames, locks or unlocks a team and records
+// its tee time request. Clearing nameLocked goes back to a name generated
+// from the roster.
+func (tc *TournamentController) HandleUpdateTournamentTeam(e *core.RequestEvent) error {
+	var data models.TeamUpdate
+	tournamentId := e.Request.PathValue("tournamentId")
+	teamId := e.Request.PathValue("teamId")
+
+	err := json.NewDecoder(e.Request.Body).Decode(&data)
+	if err != nil {
+		return e.BadRequestError(err.Error(), nil)
+	}
+
+	_, err = getTournamentTeam(tc.db, tournamentId, teamId)
+	if err != nil {
+		return e.NotFoundError(err.Error(), teamId)
+	}
+
+	if data.TeeTimeRequest != nil {
+		request := *data.TeeTimeRequest
+		if request != "" && request != TEE_TIME_REQUEST_EARLY && request != TEE_TIME_REQUEST_LATE {
+			return e.BadRequestError("teeTimeRequest must be early, late or empty", nil)
+		}
+	}
+
+	update := models.TeamUpdate{
+		Name:           data.Name,
+		Locked:         data.Locked,
+		NameLocked:     data.NameLocked,
+		TeeTimeRequest: data.TeeTimeRequest,
+	}
+
+	err = tc.app.RunInTransaction(func(txDb core.App) error {
+		_, err := models.UpdateTeam(txDb.DB(), teamId, update)
+		if err != nil {
+			return err
+		}
+
+		if update.NameLocked != nil && !*update.NameLocked {
+			err = refreshTeamNames(txDb.DB(), teamId)
+			if err != nil {
+				return err
+			}
+		}
+
+		_, err = models.CreateAuditLog(txDb.DB(), tournamentId, "team.update", e.RealIP(), map[string]any{
+			"teamId": teamId,
+			"update": update,
+		})
+		return err
+	})
+
+	if err != nil {
+		return e.BadRequestError(err.Error(), nil)
+	}
+
+	leaderboards.InvalidateTeam(tournamentId, teamId)
+
+	team, err := models.GetTeamById(tc.db, teamId)
+	if err != nil {
+		return e.Error(http.StatusInternalServerError, err.Error(), nil)
+	}
+
+	return e.JSON(http.StatusOK, team)
+}
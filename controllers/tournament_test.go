@@ -0,0 +1,102 @@
+package controllers
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/patrick-salvatore/tournament-live-scoring/models"
+)
+
+func TestGetTeamLeaderboard(t *testing.T) {
+	courseHoles := models.CourseHoleDataMap{
+		1: {Number: 1, Par: 4, Handicap: 1},
+		2: {Number: 2, Par: 4, Handicap: 2},
+		3: {Number: 3, Par: 4, Handicap: 3},
+	}
+	hole := func(playerId string, number int, score string, strokes int) models.HoleWithMetadata {
+		return models.HoleWithMetadata{TeamId: "team", PlayerId: playerId, PlayerName: playerId, Number: number, Score: score, StrokeHole: strokes}
+	}
+
+	// on the first hole al has the best gross and bo, with two strokes, the
+	// best net
+	firstHole := []models.HoleWithMetadata{hole("al", 1, "4", 0), hole("bo", 1, "5", 2)}
+	threeHoles := []models.HoleWithMetadata{
+		hole("al", 1, "4", 0), hole("bo", 1, "5", 2), hole("cy", 1, "6", 0),
+		hole("al", 2, "5", 0), hole("bo", 2, "4", 0), hole("cy", 2, "3", 0),
+		hole("al", 3, "4", 0), hole("bo", 3, "4", 0), hole("cy", 3, "5", 0),
+	}
+
+	cases := []struct {
+		name    string
+		holes   []models.HoleWithMetadata
+		scoring teamScoring
+		want    []TeamHoleResult
+	}{
+		{
+			name:    "independent counts the best gross and best net apart",
+			holes:   firstHole,
+			scoring: teamScoring{scoring: models.TEAM_SCORING_INDEPENDENT, counts: []int{1}},
+			want: []TeamHoleResult{
+				{Number: 1, Count: 1, Gross: 0, Net: -1, GrossPlayers: []string{"al"}, NetPlayers: []string{"bo"}},
+			},
+		},
+		{
+			name:    "net counts the best net ball for both",
+			holes:   firstHole,
+			scoring: teamScoring{scoring: models.TEAM_SCORING_NET, counts: []int{1}},
+			want: []TeamHoleResult{
+				{Number: 1, Count: 1, Gross: 1, Net: -1, GrossPlayers: []string{"bo"}, NetPlayers: []string{"bo"}},
+			},
+		},
+		{
+			name:    "gross counts the best gross ball for both",
+			holes:   firstHole,
+			scoring: teamScoring{scoring: models.TEAM_SCORING_GROSS, counts: []int{1}},
+			want: []TeamHoleResult{
+				{Number: 1, Count: 1, Gross: 0, Net: 0, GrossPlayers: []string{"al"}, NetPlayers: []string{"al"}},
+			},
+		},
+		{
+			name:    "1-2-3 counts one, two then three scores",
+			holes:   threeHoles,
+			scoring: teamScoring{scoring: models.TEAM_SCORING_INDEPENDENT, counts: []int{1, 2, 3}},
+			want: []TeamHoleResult{
+				{Number: 1, Count: 1, Gross: 0, Net: -1, GrossPlayers: []string{"al"}, NetPlayers: []string{"bo"}},
+				{Number: 2, Count: 2, Gross: -1, Net: -1, GrossPlayers: []string{"cy", "bo"}, NetPlayers: []string{"cy", "bo"}},
+				{Number: 3, Count: 3, Gross: 1, Net: 1, GrossPlayers: []string{"al", "bo", "cy"}, NetPlayers: []string{"al", "bo", "cy"}},
+			},
+		},
+		{
+			name:    "1-2-3 waits for every score when all three count",
+			holes:   append(threeHoles[:8:8], hole("cy", 3, "", 0)),
+			scoring: teamScoring{scoring: models.TEAM_SCORING_INDEPENDENT, counts: []int{1, 2, 3}},
+			want: []TeamHoleResult{
+				{Number: 1, Count: 1, Gross: 0, Net: -1, GrossPlayers: []string{"al"}, NetPlayers: []string{"bo"}},
+				{Number: 2, Count: 2, Gross: -1, Net: -1, GrossPlayers: []string{"cy", "bo"}, NetPlayers: []string{"cy", "bo"}},
+			},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			rows := getTeamLeaderboard(&c.holes, courseHoles, 12, c.scoring)
+			if len(rows) != 1 {
+				t.Fatalf("expected one team row, got %d", len(rows))
+			}
+
+			row := rows[0]
+			if !reflect.DeepEqual(row.Holes, c.want) {
+				t.Fatalf("expected holes %+v, got %+v", c.want, row.Holes)
+			}
+
+			gross, net := 0, 0
+			for _, hole := range c.want {
+				gross += hole.Gross
+				net += hole.Net
+			}
+			if row.Thru != len(c.want) || row.Gross != gross || row.Net != net {
+				t.Fatalf("expected %d/%d thru %d, got %d/%d thru %d", gross, net, len(c.want), row.Gross, row.Net, row.Thru)
+			}
+		})
+	}
+}
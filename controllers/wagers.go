@@ -0,0 +1,607 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/patrick-salvatore/tournament-live-scoring/models"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+const (
+	WAGER_BET_IN_PROGRESS = "in_progress"
+	WAGER_BET_WON         = "won"
+	WAGER_BET_HALVED      = "halved"
+)
+
+// WagerBet is one match inside a wager: the front, back or overall bet of a
+// Nassau, or a press started partway through a nine.
+type WagerBet struct {
+	Segment string `json:"segment"`
+	// Press counts the presses on the segment, 0 being the original bet.
+	Press     int `json:"press"`
+	StartHole int `json:"startHole"`
+	EndHole   int `json:"endHole"`
+	Thru      int `json:"thru"`
+	// Margin is how many holes side A is up.
+	Margin int    `json:"margin"`
+	Status string `json:"status"`
+	Winner string `json:"winner,omitempty"`
+	// Amount is what side A has won, negative when side B won.
+	Amount float64 `json:"amount"`
+
+	startIndex int
+	endIndex   int
+}
+
+type WagerResult struct {
+	models.Wager
+	Bets []WagerBet `json:"bets"`
+	// Net is side A's winnings across every decided bet.
+	Net float64 `json:"net"`
+}
+
+type LedgerAmount struct {
+	PlayerId   string  `json:"playerId"`
+	PlayerName string  `json:"playerName"`
+	Amount     float64 `json:"amount"`
+}
+
+// LedgerGame is what every player won or lost on one wager or skins game.
+type LedgerGame struct {
+	Kind    string         `json:"kind"`
+	Id      string         `json:"id"`
+	Name    string         `json:"name"`
+	Amounts []LedgerAmount `json:"amounts"`
+}
+
+type LedgerSettlement struct {
+	FromId   string  `json:"fromId"`
+	FromName string  `json:"fromName"`
+	ToId     string  `json:"toId"`
+	ToName   string  `json:"toName"`
+	Amount   float64 `json:"amount"`
+}
+
+type Ledger struct {
+	Wagers      []WagerResult      `json:"wagers"`
+	Games       []LedgerGame       `json:"games"`
+	Balances    []LedgerAmount     `json:"balances"`
+	Settlements []LedgerSettlement `json:"settlements"`
+}
+
+func (tc *TournamentController) HandleGetWagers(e *core.RequestEvent) error {
+	tournamentId := e.Request.PathValue("tournamentId")
+
+	wagers, err := models.GetWagers(tc.db, tournamentId)
+	if err != nil {
+		return e.Error(http.StatusInternalServerError, err.Error(), nil)
+	}
+
+	return e.JSON(http.StatusOK, wagers)
+}
+
+// HandleCreateWager sets up a Nassau between two sides, each a list of
+// players or of teams from the tournament. pressAfter starts an automatic
+// press on a nine whenever its latest bet goes that many holes down; 0
+// turns presses off.
+func (tc *TournamentController) HandleCreateWager(e *core.RequestEvent) error {
+	var data models.WagerCreate
+	tournamentId := e.Request.PathValue("tournamentId")
+
+	err := json.NewDecoder(e.Request.Body).Decode(&data)
+	if err != nil {
+		return e.BadRequestError(err.Error(), nil)
+	}
+
+	_, err = models.GetTournamentById(tc.db, tournamentId)
+	if err != nil {
+		return e.NotFoundError(err.Error(), tournamentId)
+	}
+
+	if data.Kind == "" {
+		data.Kind = models.WAGER_KIND_NASSAU
+	}
+	if data.Kind != models.WAGER_KIND_NASSAU {
+		return e.BadRequestError("kind must be nassau", nil)
+	}
+	if data.Scoring == "" {
+		data.Scoring = models.WAGER_SCORING_NET
+	}
+	if data.Scoring != models.WAGER_SCORING_GROSS && data.Scoring != models.WAGER_SCORING_NET {
+		return e.BadRequestError("scoring must be gross or net", nil)
+	}
+	if data.Stake <= 0 {
+		return e.BadRequestError("stake must be greater than 0", nil)
+	}
+	if data.PressAfter < 0 {
+		return e.BadRequestError("pressAfter can't be negative", nil)
+	}
+
+	labels, err := tc.validateWagerSides(tournamentId, data.SideA, data.SideB)
+	if err != nil {
+		return e.BadRequestError(err.Error(), nil)
+	}
+	if data.Name == "" {
+		data.Name = strings.Join(labels, " v ")
+	}
+
+	var wager *models.Wager
+	err = tc.app.RunInTransaction(func(txDb core.App) error {
+		wager, err = models.CreateWager(txDb.DB(), tournamentId, data)
+		if err != nil {
+			return err
+		}
+
+		_, err = models.CreateAuditLog(txDb.DB(), tournamentId, "wager.create", e.RealIP(), data)
+		return err
+	})
+
+	if err != nil {
+		return e.Error(http.StatusInternalServerError, err.Error(), nil)
+	}
+
+	wagers, err := models.GetWagers(tc.db, tournamentId)
+	if err != nil {
+		return e.Error(http.StatusInternalServerError, err.Error(), nil)
+	}
+	for _, created := range *wagers {
+		if created.Id == wager.Id {
+			return e.JSON(http.StatusCreated, created)
+		}
+	}
+
+	return e.JSON(http.StatusCreated, wager)
+}
+
+func (tc *TournamentController) HandleDeleteWager(e *core.RequestEvent) error {
+	tournamentId := e.Request.PathValue("tournamentId")
+	wagerId := e.Request.PathValue("wagerId")
+
+	var deleted bool
+	err := tc.app.RunInTransaction(func(txDb core.App) error {
+		var err error
+		deleted, err = models.DeleteWager(txDb.DB(), tournamentId, wagerId)
+		if err != nil || !deleted {
+			return err
+		}
+
+		_, err = models.CreateAuditLog(txDb.DB(), tournamentId, "wager.delete", e.RealIP(), map[string]any{
+			"wagerId": wagerId,
+		})
+		return err
+	})
+
+	if err != nil {
+		return e.Error(http.StatusInternalServerError, err.Error(), nil)
+	}
+	if !deleted {
+		return e.NotFoundError("wager not found", wagerId)
+	}
+
+	return e.NoContent(http.StatusNoContent)
+}
+
+// HandleGetLedger evaluates every wager and skins game in the tournament and
+// nets them into one settle-up list of who pays whom. Bets still being
+// played only count once they are decided.
+func (tc *TournamentController) HandleGetLedger(e *core.RequestEvent) error {
+	tournamentId := e.Request.PathValue("tournamentId")
+
+	_, err := models.GetTournamentById(tc.db, tournamentId)
+	if err != nil {
+		return e.NotFoundError(err.Error(), tournamentId)
+	}
+
+	ledger, err := tc.getLedger(tournamentId)
+	if err != nil {
+		return e.Error(http.StatusInternalServerError, err.Error(), nil)
+	}
+
+	return e.JSON(http.StatusOK, ledger)
+}
+
+// validateWagerSides checks every player and team is in the tournament and
+// on one side only, and returns a label for each side.
+func (tc *TournamentController) validateWagerSides(tournamentId string, sides ...models.WagerSideCreate) ([]string, error) {
+	labels := []string{}
+	seen := make(map[string]bool)
+	for _, side := range sides {
+		if len(side.PlayerIds) == 0 && len(side.TeamIds) == 0 {
+			return nil, fmt.Errorf("each side needs at least one player or team")
+		}
+		if len(side.PlayerIds) > 0 && len(side.TeamIds) > 0 {
+			return nil, fmt.Errorf("a side is either players or teams")
+		}
+
+		names := []string{}
+		for _, playerId := range side.PlayerIds {
+			teamPlayer, err := models.GetTeamPlayer(tc.db, tournamentId, playerId)
+			if err != nil {
+				return nil, fmt.Errorf("player %s is not in this tournament", playerId)
+			}
+			if seen[playerId] {
+				return nil, fmt.Errorf("player %s is on both sides", playerId)
+			}
+			seen[playerId] = true
+			names = append(names, teamPlayer.PlayerName)
+		}
+		for _, teamId := range side.TeamIds {
+			team, err := getTournamentTeam(tc.db, tournamentId, teamId)
+			if err != nil {
+				return nil, fmt.Errorf("team %s is not in this tournament", teamId)
+			}
+			if seen[teamId] {
+				return nil, fmt.Errorf("team %s is on both sides", teamId)
+			}
+			seen[teamId] = true
+			names = append(names, team.Name)
+		}
+		labels = append(labels, strings.Join(names, " & "))
+	}
+
+	return labels, nil
+}
+
+func (tc *TournamentController) getLedger(tournamentId string) (*Ledger, error) {
+	course, err := models.GetCourseByTournamentId(tc.db, tournamentId)
+	if err != nil {
+		return nil, err
+	}
+	courseHoles := getHoleDataMap(course)
+
+	teamPlayers, err := models.GetTeamPlayersByTournament(tc.db, tournamentId)
+	if err != nil {
+		return nil, err
+	}
+	playerNames := make(map[string]string)
+	teamRosters := make(map[string][]string)
+	teamIds := []string{}
+	for _, teamPlayer := range *teamPlayers {
+		playerNames[teamPlayer.PlayerId] = teamPlayer.PlayerName
+		if _, ok := teamRosters[teamPlayer.TeamId]; !ok {
+			teamIds = append(teamIds, teamPlayer.TeamId)
+		}
+		teamRosters[teamPlayer.TeamId] = append(teamRosters[teamPlayer.TeamId], teamPlayer.PlayerId)
+	}
+
+	holes, err := models.GetTournamentHoles(tc.db, tournamentId, teamIds)
+	if err != nil {
+		return nil, err
+	}
+	applyStrokeHoles(course, holes)
+
+	gross := make(map[string]map[int]int)
+	net := make(map[string]map[int]int)
+	for _, hole := range *holes {
+		if _, ok := gross[hole.PlayerId]; !ok {
+			gross[hole.PlayerId] = make(map[int]int)
+			net[hole.PlayerId] = make(map[int]int)
+		}
+		score, ok := holeGrossScore(hole.Score, courseHoles[hole.Number].Par)
+		if !ok {
+			continue
+		}
+		gross[hole.PlayerId][hole.Number] = score
+		net[hole.PlayerId][hole.Number] = score - hole.StrokeHole
+	}
+
+	numbers := []int{}
+	for number := range courseHoles {
+		numbers = append(numbers, number)
+	}
+	sort.Ints(numbers)
+
+	ledger := Ledger{
+		Wagers:      []WagerResult{},
+		Games:       []LedgerGame{},
+		Balances:    []LedgerAmount{},
+		Settlements: []LedgerSettlement{},
+	}
+	balances := make(map[string]int64)
+
+	addGame := func(kind string, id string, name string, amounts map[string]int64) {
+		game := LedgerGame{Kind: kind, Id: id, Name: name, Amounts: []LedgerAmount{}}
+		for playerId, cents := range amounts {
+			balances[playerId] += cents
+			game.Amounts = append(game.Amounts, LedgerAmount{PlayerId: playerId, PlayerName: playerNames[playerId], Amount: float64(cents) / 100})
+		}
+		sortLedgerAmounts(game.Amounts)
+		ledger.Games = append(ledger.Games, game)
+	}
+
+	wagers, err := models.GetWagers(tc.db, tournamentId)
+	if err != nil {
+		return nil, err
+	}
+	for _, wager := range *wagers {
+		scores := gross
+		if wager.Scoring == models.WAGER_SCORING_NET {
+			scores = net
+		}
+
+		sideA := wagerSidePlayers(wager.SideA, teamRosters)
+		sideB := wagerSidePlayers(wager.SideB, teamRosters)
+		result := WagerResult{
+			Wager: wager,
+			Bets:  playNassau(wager, numbers, sideScore(sideA, scores), sideScore(sideB, scores)),
+		}
+		for _, bet := range result.Bets {
+			result.Net += bet.Amount
+		}
+		ledger.Wagers = append(ledger.Wagers, result)
+
+		amounts := make(map[string]int64)
+		netCents := int64(math.Round(result.Net * 100))
+		for i, cents := range splitCents(netCents, len(sideA)) {
+			amounts[sideA[i]] += cents
+		}
+		for i, cents := range splitCents(-netCents, len(sideB)) {
+			amounts[sideB[i]] += cents
+		}
+		addGame(wager.Kind, wager.Id, wager.Name, amounts)
+	}
+
+	games, err := models.GetSkinsGames(tc.db, tournamentId)
+	if err != nil {
+		return nil, err
+	}
+	for _, game := range *games {
+		board := buildSkinsBoard(game, course, *holes)
+		if board.SkinsWon == 0 {
+			continue
+		}
+
+		// everyone in the skins pays in what the winners take out
+		amounts := make(map[string]int64)
+		var paidOut int64
+		for _, winner := range board.Winners {
+			cents := int64(math.Round(winner.Payout * 100))
+			amounts[winner.PlayerId] += cents
+			paidOut += cents
+		}
+
+		entrants := []string{}
+		for playerId := range gross {
+			entrants = append(entrants, playerId)
+		}
+		sort.Strings(entrants)
+		for i, cents := range splitCents(-paidOut, len(entrants)) {
+			amounts[entrants[i]] += cents
+		}
+		addGame("skins", game.Id, game.Name, amounts)
+	}
+
+	for playerId, cents := range balances {
+		ledger.Balances = append(ledger.Balances, LedgerAmount{PlayerId: playerId, PlayerName: playerNames[playerId], Amount: float64(cents) / 100})
+	}
+	sortLedgerAmounts(ledger.Balances)
+	ledger.Settlements = settleBalances(balances, playerNames)
+
+	return &ledger, nil
+}
+
+// wagerSidePlayers expands a side's teams into their players.
+func wagerSidePlayers(entries []models.WagerEntry, teamRosters map[string][]string) []string {
+	players := []string{}
+	for _, entry := range entries {
+		if entry.TeamId != "" {
+			players = append(players, teamRosters[entry.TeamId]...)
+		} else {
+			players = append(players, entry.PlayerId)
+		}
+	}
+
+	return players
+}
+
+// sideScore plays a side as a best ball. A hole only counts once every
+// player on the side has a score for it.
+func sideScore(players []string, scores map[string]map[int]int) func(number int) (int, bool) {
+	return func(number int) (int, bool) {
+		best := 0
+		for i, playerId := range players {
+			score, ok := scores[playerId][number]
+			if !ok {
+				return 0, false
+			}
+			if i == 0 || score < best {
+				best = score
+			}
+		}
+
+		return best, len(players) > 0
+	}
+}
+
+// playNassau plays the front nine, back nine and overall bets hole by hole,
+// stopping at the first hole both sides haven't finished. On each nine a
+// new press starts from the next hole whenever the latest bet goes
+// pressAfter down.
+func playNassau(wager models.Wager, numbers []int, sideA func(int) (int, bool), sideB func(int) (int, bool)) []WagerBet {
+	front, back := []int{}, []int{}
+	for _, number := range numbers {
+		if number <= 9 {
+			front = append(front, number)
+		} else {
+			back = append(back, number)
+		}
+	}
+
+	bets := []WagerBet{}
+	bets = append(bets, playWagerSegment(wager, "front", front, true, sideA, sideB)...)
+	if len(back) > 0 {
+		bets = append(bets, playWagerSegment(wager, "back", back, true, sideA, sideB)...)
+		bets = append(bets, playWagerSegment(wager, "overall", numbers, false, sideA, sideB)...)
+	}
+
+	return bets
+}
+
+func playWagerSegment(wager models.Wager, segment string, numbers []int, presses bool, sideA func(int) (int, bool), sideB func(int) (int, bool)) []WagerBet {
+	if len(numbers) == 0 {
+		return []WagerBet{}
+	}
+
+	last := len(numbers) - 1
+	bets := []WagerBet{{
+		Segment:   segment,
+		StartHole: numbers[0],
+		EndHole:   numbers[last],
+		Status:    WAGER_BET_IN_PROGRESS,
+		endIndex:  last,
+	}}
+
+	for i, number := range numbers {
+		a, aOk := sideA(number)
+		b, bOk := sideB(number)
+		if !aOk || !bOk {
+			break
+		}
+
+		for j := range bets {
+			bet := &bets[j]
+			if bet.startIndex > i || bet.Status != WAGER_BET_IN_PROGRESS {
+				continue
+			}
+
+			bet.Thru++
+			if a < b {
+				bet.Margin++
+			} else if b < a {
+				bet.Margin--
+			}
+
+			remaining := bet.endIndex - i
+			if abs(bet.Margin) > remaining || remaining == 0 {
+				settleWagerBet(bet, wager.Stake)
+			}
+		}
+
+		latest := bets[len(bets)-1]
+		if presses && wager.PressAfter > 0 && a != b && i < last && abs(latest.Margin) == wager.PressAfter && latest.Thru > 0 {
+			bets = append(bets, WagerBet{
+				Segment:    segment,
+				Press:      latest.Press + 1,
+				StartHole:  numbers[i+1],
+				EndHole:    numbers[last],
+				Status:     WAGER_BET_IN_PROGRESS,
+				startIndex: i + 1,
+				endIndex:   last,
+			})
+		}
+	}
+
+	return bets
+}
+
+func settleWagerBet(bet *WagerBet, stake float64) {
+	switch {
+	case bet.Margin > 0:
+		bet.Status = WAGER_BET_WON
+		bet.Winner = models.WAGER_SIDE_A
+		bet.Amount = stake
+	case bet.Margin < 0:
+		bet.Status = WAGER_BET_WON
+		bet.Winner = models.WAGER_SIDE_B
+		bet.Amount = -stake
+	default:
+		bet.Status = WAGER_BET_HALVED
+	}
+}
+
+// splitCents shares an amount between n players so the parts add back up
+// exactly, handing the odd cents to the first players.
+func splitCents(total int64, n int) []int64 {
+	parts := make([]int64, n)
+	if n == 0 {
+		return parts
+	}
+
+	share := total / int64(n)
+	remainder := total - share*int64(n)
+	for i := range parts {
+		parts[i] = share
+		if remainder > 0 {
+			parts[i]++
+			remainder--
+		} else if remainder < 0 {
+			parts[i]--
+			remainder++
+		}
+	}
+
+	return parts
+}
+
+// settleBalances pays the biggest winner from the biggest loser until
+// everyone is square, which keeps the number of payments low.
+func settleBalances(balances map[string]int64, playerNames map[string]string) []LedgerSettlement {
+	type balance struct {
+		playerId string
+		cents    int64
+	}
+
+	creditors, debtors := []balance{}, []balance{}
+	for playerId, cents := range balances {
+		if cents > 0 {
+			creditors = append(creditors, balance{playerId, cents})
+		} else if cents < 0 {
+			debtors = append(debtors, balance{playerId, -cents})
+		}
+	}
+	byAmount := func(list []balance) func(i, j int) bool {
+		return func(i, j int) bool {
+			if list[i].cents != list[j].cents {
+				return list[i].cents > list[j].cents
+			}
+			return list[i].playerId < list[j].playerId
+		}
+	}
+	sort.Slice(creditors, byAmount(creditors))
+	sort.Slice(debtors, byAmount(debtors))
+
+	settlements := []LedgerSettlement{}
+	for i, j := 0, 0; i < len(debtors) && j < len(creditors); {
+		cents := min(debtors[i].cents, creditors[j].cents)
+		settlements = append(settlements, LedgerSettlement{
+			FromId:   debtors[i].playerId,
+			FromName: playerNames[debtors[i].playerId],
+			ToId:     creditors[j].playerId,
+			ToName:   playerNames[creditors[j].playerId],
+			Amount:   float64(cents) / 100,
+		})
+
+		debtors[i].cents -= cents
+		creditors[j].cents -= cents
+		if debtors[i].cents == 0 {
+			i++
+		}
+		if creditors[j].cents == 0 {
+			j++
+		}
+	}
+
+	return settlements
+}
+
+func sortLedgerAmounts(amounts []LedgerAmount) {
+	sort.SliceStable(amounts, func(i, j int) bool {
+		if amounts[i].Amount != amounts[j].Amount {
+			return amounts[i].Amount > amounts[j].Amount
+		}
+		return amounts[i].PlayerName < amounts[j].PlayerName
+	})
+}
+
+func abs(value int) int {
+	if value < 0 {
+		return -value
+	}
+
+	return value
+}
@@ -0,0 +1,156 @@
+package controllers
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/patrick-salvatore/tournament-live-scoring/models"
+)
+
+func TestPlayNassau(t *testing.T) {
+	// side plays a fixed score on every hole through thru, except where
+	// scores says otherwise
+	side := func(thru int, scores map[int]int) func(int) (int, bool) {
+		return func(number int) (int, bool) {
+			if number > thru {
+				return 0, false
+			}
+			if score, ok := scores[number]; ok {
+				return score, true
+			}
+			return 4, true
+		}
+	}
+	holes := func(n int) []int {
+		numbers := []int{}
+		for number := 1; number <= n; number++ {
+			numbers = append(numbers, number)
+		}
+		return numbers
+	}
+
+	type bet struct {
+		segment   string
+		press     int
+		startHole int
+		thru      int
+		margin    int
+		status    string
+		amount    float64
+	}
+	cases := []struct {
+		name    string
+		pressAt int
+		numbers []int
+		sideA   func(int) (int, bool)
+		sideB   func(int) (int, bool)
+		want    []bet
+	}{
+		{
+			name:    "a press starts after going two down",
+			pressAt: 2,
+			numbers: holes(9),
+			sideA:   side(9, nil),
+			sideB:   side(9, map[int]int{1: 3, 2: 3, 3: 3, 4: 3}),
+			want: []bet{
+				{"front", 0, 1, 6, -4, WAGER_BET_WON, -10},
+				{"front", 1, 3, 6, -2, WAGER_BET_WON, -10},
+				{"front", 2, 5, 5, 0, WAGER_BET_HALVED, 0},
+			},
+		},
+		{
+			name:    "no press without pressAfter",
+			numbers: holes(9),
+			sideA:   side(9, nil),
+			sideB:   side(9, map[int]int{1: 3, 2: 3, 3: 3, 4: 3}),
+			want: []bet{
+				{"front", 0, 1, 6, -4, WAGER_BET_WON, -10},
+			},
+		},
+		{
+			name:    "play stops at the first hole a side hasn't finished",
+			pressAt: 2,
+			numbers: holes(18),
+			sideA:   side(12, map[int]int{1: 3, 10: 3}),
+			sideB:   side(11, nil),
+			want: []bet{
+				{"front", 0, 1, 9, 1, WAGER_BET_WON, 10},
+				{"back", 0, 10, 2, 1, WAGER_BET_IN_PROGRESS, 0},
+				{"overall", 0, 1, 11, 2, WAGER_BET_IN_PROGRESS, 0},
+			},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			wager := models.Wager{Kind: models.WAGER_KIND_NASSAU, Stake: 10, PressAfter: c.pressAt}
+			got := []bet{}
+			for _, b := range playNassau(wager, c.numbers, c.sideA, c.sideB) {
+				got = append(got, bet{b.Segment, b.Press, b.StartHole, b.Thru, b.Margin, b.Status, b.Amount})
+			}
+
+			if !reflect.DeepEqual(got, c.want) {
+				t.Errorf("expected bets %+v, got %+v", c.want, got)
+			}
+		})
+	}
+}
+
+func TestSettleBalances(t *testing.T) {
+	names := map[string]string{"al": "Al", "bo": "Bo", "cy": "Cy"}
+
+	type payment struct {
+		from   string
+		to     string
+		amount float64
+	}
+	cases := []struct {
+		name     string
+		balances map[string]int64
+		want     []payment
+	}{
+		{
+			name:     "one winner paid by two losers",
+			balances: map[string]int64{"al": 1500, "bo": -1000, "cy": -500},
+			want:     []payment{{"bo", "al", 10}, {"cy", "al", 5}},
+		},
+		{
+			name:     "one loser pays two winners",
+			balances: map[string]int64{"al": 700, "bo": 301, "cy": -1001},
+			want:     []payment{{"cy", "al", 7}, {"cy", "bo", 3.01}},
+		},
+		{
+			name:     "everyone square",
+			balances: map[string]int64{"al": 0, "bo": 0, "cy": 0},
+			want:     []payment{},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			settlements := settleBalances(c.balances, names)
+
+			got := []payment{}
+			net := make(map[string]int64)
+			for _, settlement := range settlements {
+				got = append(got, payment{settlement.FromId, settlement.ToId, settlement.Amount})
+				if settlement.FromName != names[settlement.FromId] || settlement.ToName != names[settlement.ToId] {
+					t.Errorf("expected names on %+v", settlement)
+				}
+
+				cents := int64(settlement.Amount*100 + 0.5)
+				net[settlement.FromId] += cents
+				net[settlement.ToId] -= cents
+			}
+			if !reflect.DeepEqual(got, c.want) {
+				t.Errorf("expected payments %+v, got %+v", c.want, got)
+			}
+
+			for playerId, cents := range c.balances {
+				if cents+net[playerId] != 0 {
+					t.Errorf("%s is left %d cents off square", playerId, cents+net[playerId])
+				}
+			}
+		})
+	}
+}
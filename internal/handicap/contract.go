@@ -0,0 +1,51 @@
+package handicap
+
+import (
+	"fmt"
+	"time"
+)
+
+// The JSON contract shared by the file provider, the HTTP provider and the
+// stub server:
+//
+//	{"handicaps": [{"externalId": "1234567", "handicapIndex": 12.4, "effectiveDate": "2026-10-01"}]}
+type indexResponse struct {
+	Handicaps []indexRecord `json:"handicaps"`
+}
+
+type indexRecord struct {
+	ExternalId    string  `json:"externalId"`
+	HandicapIndex float64 `json:"handicapIndex"`
+	EffectiveDate string  `json:"effectiveDate,omitempty"`
+}
+
+func (r indexResponse) indexes(externalIds []string) ([]Index, error) {
+	wanted := make(map[string]bool)
+	for _, id := range externalIds {
+		wanted[id] = true
+	}
+
+	indexes := []Index{}
+	for _, record := range r.Handicaps {
+		if !wanted[record.ExternalId] {
+			continue
+		}
+
+		effectiveDate := time.Now()
+		if record.EffectiveDate != "" {
+			var err error
+			effectiveDate, err = time.Parse(DATE_LAYOUT, record.EffectiveDate)
+			if err != nil {
+				return nil, fmt.Errorf("invalid effectiveDate for %s: %w", record.ExternalId, err)
+			}
+		}
+
+		indexes = append(indexes, Index{
+			ExternalId:    record.ExternalId,
+			HandicapIndex: record.HandicapIndex,
+			EffectiveDate: effectiveDate,
+		})
+	}
+
+	return indexes, nil
+}
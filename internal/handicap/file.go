@@ -0,0 +1,36 @@
+package handicap
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+)
+
+// FileProvider reads indexes from a JSON file in the provider contract, for
+// clubs that export a handicap list rather than exposing a service.
+type FileProvider struct {
+	path string
+}
+
+func NewFileProvider(path string) *FileProvider {
+	return &FileProvider{path: path}
+}
+
+func (p *FileProvider) Name() string {
+	return "file"
+}
+
+func (p *FileProvider) Lookup(ctx context.Context, externalIds []string) ([]Index, error) {
+	data, err := os.ReadFile(p.path)
+	if err != nil {
+		return nil, err
+	}
+
+	var response indexResponse
+	err = json.Unmarshal(data, &response)
+	if err != nil {
+		return nil, err
+	}
+
+	return response.indexes(externalIds)
+}
@@ -0,0 +1,62 @@
+package handicap
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// HTTPProvider queries any service that answers
+// GET {baseUrl}/handicaps?ids=a,b,c with the provider JSON contract.
+type HTTPProvider struct {
+	baseUrl string
+	token   string
+	client  *http.Client
+}
+
+func NewHTTPProvider(baseUrl string, token string) *HTTPProvider {
+	return &HTTPProvider{
+		baseUrl: strings.TrimSuffix(baseUrl, "/"),
+		token:   token,
+		client:  &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+func (p *HTTPProvider) Name() string {
+	return "http"
+}
+
+func (p *HTTPProvider) Lookup(ctx context.Context, externalIds []string) ([]Index, error) {
+	query := url.Values{"ids": {strings.Join(externalIds, ",")}}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, p.baseUrl+"/handicaps?"+query.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Accept", "application/json")
+	if p.token != "" {
+		request.Header.Set("Authorization", "Bearer "+p.token)
+	}
+
+	response, err := p.client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("handicap provider responded %s", response.Status)
+	}
+
+	var body indexResponse
+	err = json.NewDecoder(response.Body).Decode(&body)
+	if err != nil {
+		return nil, err
+	}
+
+	return body.indexes(externalIds)
+}
@@ -0,0 +1,75 @@
+package handicap
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestHTTPProviderAgainstStubServer(t *testing.T) {
+	stub := NewStubServer()
+	stub.Set("100", 10.2, "2026-10-01")
+	stub.Set("101", -1.5, "")
+	stub.Set("999", 30, "2026-10-01")
+
+	var authorization string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorization = r.Header.Get("Authorization")
+		stub.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	provider := NewHTTPProvider(server.URL+"/", "secret")
+	indexes, err := provider.Lookup(context.Background(), []string{"100", "101", "102"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if authorization != "Bearer secret" {
+		t.Errorf("expected the token as a bearer header, got %q", authorization)
+	}
+
+	byId := make(map[string]Index)
+	for _, index := range indexes {
+		byId[index.ExternalId] = index
+	}
+	if len(byId) != 2 {
+		t.Fatalf("expected indexes for 100 and 101 only, got %+v", indexes)
+	}
+
+	if byId["100"].HandicapIndex != 10.2 {
+		t.Errorf("100: expected 10.2, got %v", byId["100"].HandicapIndex)
+	}
+	if got := byId["100"].EffectiveDate.Format(DATE_LAYOUT); got != "2026-10-01" {
+		t.Errorf("100: expected effective date 2026-10-01, got %s", got)
+	}
+
+	if byId["101"].HandicapIndex != -1.5 {
+		t.Errorf("101: expected plus handicaps as negative indexes, got %v", byId["101"].HandicapIndex)
+	}
+	if byId["101"].EffectiveDate.Format(DATE_LAYOUT) != time.Now().Format(DATE_LAYOUT) {
+		t.Errorf("101: expected a missing effective date to default to today, got %s", byId["101"].EffectiveDate)
+	}
+}
+
+func TestHTTPProviderErrors(t *testing.T) {
+	server := httptest.NewServer(NewStubServer())
+	defer server.Close()
+
+	_, err := NewHTTPProvider(server.URL+"/missing", "").Lookup(context.Background(), []string{"100"})
+	if err == nil {
+		t.Fatal("expected an error when the provider doesn't answer 200")
+	}
+
+	invalid := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"handicaps": [{"externalId": "100", "handicapIndex": 4, "effectiveDate": "10/01/2026"}]}`))
+	}))
+	defer invalid.Close()
+
+	_, err = NewHTTPProvider(invalid.URL, "").Lookup(context.Background(), []string{"100"})
+	if err == nil {
+		t.Fatal("expected an error for an effective date outside the contract")
+	}
+}
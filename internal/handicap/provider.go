@@ -0,0 +1,59 @@
+package handicap
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+)
+
+const DATE_LAYOUT = "2006-01-02"
+
+// Index is a single handicap index reported by a provider for the player
+// with the matching external (GHIN-style) id.
+type Index struct {
+	ExternalId    string
+	HandicapIndex float64
+	EffectiveDate time.Time
+}
+
+// HandicapProvider looks up current handicap indexes for a set of players.
+// Ids the provider doesn't know about are left out of the result.
+type HandicapProvider interface {
+	Name() string
+	Lookup(ctx context.Context, externalIds []string) ([]Index, error)
+}
+
+// NewProviderFromEnv builds the provider configured through
+// HANDICAP_PROVIDER ("file" or "http"). It returns nil when syncing is not
+// configured.
+func NewProviderFromEnv() (HandicapProvider, error) {
+	switch kind := os.Getenv("HANDICAP_PROVIDER"); kind {
+	case "":
+		return nil, nil
+	case "file":
+		path := os.Getenv("HANDICAP_PROVIDER_FILE")
+		if path == "" {
+			return nil, fmt.Errorf("HANDICAP_PROVIDER_FILE is not set")
+		}
+		return NewFileProvider(path), nil
+	case "http":
+		url := os.Getenv("HANDICAP_PROVIDER_URL")
+		if url == "" {
+			return nil, fmt.Errorf("HANDICAP_PROVIDER_URL is not set")
+		}
+		return NewHTTPProvider(url, os.Getenv("HANDICAP_PROVIDER_TOKEN")), nil
+	default:
+		return nil, fmt.Errorf("unknown HANDICAP_PROVIDER %q", kind)
+	}
+}
+
+// SyncSchedule is the cron expression refreshes run on, daily at 06:00 unless
+// HANDICAP_SYNC_CRON overrides it.
+func SyncSchedule() string {
+	if schedule := os.Getenv("HANDICAP_SYNC_CRON"); schedule != "" {
+		return schedule
+	}
+
+	return "0 6 * * *"
+}
@@ -0,0 +1,49 @@
+package handicap
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// StubServer speaks the HTTP provider contract from an in-memory table so
+// the HTTP provider can be exercised locally, e.g. behind httptest.NewServer.
+type StubServer struct {
+	mu        sync.RWMutex
+	handicaps map[string]indexRecord
+}
+
+func NewStubServer() *StubServer {
+	return &StubServer{handicaps: make(map[string]indexRecord)}
+}
+
+func (s *StubServer) Set(externalId string, handicapIndex float64, effectiveDate string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.handicaps[externalId] = indexRecord{
+		ExternalId:    externalId,
+		HandicapIndex: handicapIndex,
+		EffectiveDate: effectiveDate,
+	}
+}
+
+func (s *StubServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet || r.URL.Path != "/handicaps" {
+		http.NotFound(w, r)
+		return
+	}
+
+	s.mu.RLock()
+	response := indexResponse{Handicaps: []indexRecord{}}
+	for _, id := range strings.Split(r.URL.Query().Get("ids"), ",") {
+		if record, ok := s.handicaps[id]; ok {
+			response.Handicaps = append(response.Handicaps, record)
+		}
+	}
+	s.mu.RUnlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
@@ -1,12 +1,15 @@
 package main
 
 import (
+	"context"
 	"html"
 	"log"
 
 	"github.com/joho/godotenv"
 	"github.com/patrick-salvatore/tournament-live-scoring/controllers"
+	"github.com/patrick-salvatore/tournament-live-scoring/internal/handicap"
 	"github.com/patrick-salvatore/tournament-live-scoring/middleware"
+	_ "github.com/patrick-salvatore/tournament-live-scoring/migrations"
 	"github.com/patrick-salvatore/tournament-live-scoring/ui"
 	"github.com/pocketbase/pocketbase"
 	"github.com/pocketbase/pocketbase/apis"
@@ -19,9 +22,16 @@ func main() {
 
 	app := pocketbase.New()
 
+	handicapProvider, err := handicap.NewProviderFromEnv()
+	if err != nil {
+		log.Fatal(err)
+	}
+
 	app.OnServe().BindFunc(func(se *core.ServeEvent) error {
 		router := se.Router.Group("/")
 		protectedRouter := se.Router.Group("/").BindFunc(middleware.WithJWTVerify(app))
+		editableRouter := se.Router.Group("/").BindFunc(middleware.WithEditableTournament(app))
+		adminRouter := se.Router.Group("/").BindFunc(middleware.WithAdminSecret())
 
 		router.GET("v1/heathz", controllers.HandleHealthzRequest)
 
@@ -51,12 +61,64 @@ func main() {
 		protectedRouter.GET("v1/tournament/{tournamentId}", tournamentCtr.HandleGetTournamentById)
 		protectedRouter.POST("v1/tournament/{tournamentId}/team/{teamId}/start", tournamentCtr.HandleStartTournamentForTeam)
 		protectedRouter.GET("v1/tournament/{tournamentId}/leaderboard", tournamentCtr.HandleGetLeaderboard)
+		protectedRouter.GET("v1/tournament/{tournamentId}/stats", tournamentCtr.HandleGetTournamentStats)
+		protectedRouter.GET("v1/tournament/{tournamentId}/skins", tournamentCtr.HandleGetSkinsBoard)
+		protectedRouter.GET("v1/tournament/{tournamentId}/flights", tournamentCtr.HandleGetFlightLeaderboards)
+		protectedRouter.GET("v1/contests", tournamentCtr.HandleGetContestBoard)
+		protectedRouter.POST("v1/contests/{contestId}/entries", tournamentCtr.HandleSubmitContestEntry)
 		router.GET("v1/tournaments", tournamentCtr.HandleGetTournaments)
 		router.POST("v1/tournaments", tournamentCtr.HandleCreateTournament)
-		router.PUT("v1/tournaments/{tournamentId}", tournamentCtr.HandleUpdateTournament)
+		editableRouter.PUT("v1/tournaments/{tournamentId}", tournamentCtr.HandleUpdateTournament)
+		editableRouter.POST("v1/tournaments/{tournamentId}/handicaps/recalculate", tournamentCtr.HandleRecalculateHandicaps)
+		editableRouter.POST("v1/tournaments/{tournamentId}/players", tournamentCtr.HandleAddTournamentPlayer)
+		editableRouter.DELETE("v1/tournaments/{tournamentId}/players/{playerId}", tournamentCtr.HandleRemoveTournamentPlayer)
+		editableRouter.POST("v1/tournaments/{tournamentId}/players/{playerId}/move", tournamentCtr.HandleMoveTournamentPlayer)
+		editableRouter.POST("v1/tournaments/{tournamentId}/players/swap", tournamentCtr.HandleSwapTournamentPlayers)
+		editableRouter.POST("v1/tournaments/{tournamentId}/teams", tournamentCtr.HandleCreateTournamentTeam)
+		editableRouter.PUT("v1/tournaments/{tournamentId}/teams/{teamId}", tournamentCtr.HandleUpdateTournamentTeam)
+		router.GET("v1/tournaments/{tournamentId}/tee-times", tournamentCtr.HandleGetTeeSheet)
+		editableRouter.POST("v1/tournaments/{tournamentId}/tee-times", tournamentCtr.HandleScheduleTeeTimes)
+		editableRouter.POST("v1/tournaments/{tournamentId}/tee-times/{group}/tee-off", tournamentCtr.HandleTeeOffGroup)
+		router.GET("v1/tournaments/{tournamentId}/skins", tournamentCtr.HandleGetSkinsGames)
+		editableRouter.POST("v1/tournaments/{tournamentId}/skins", tournamentCtr.HandleCreateSkinsGame)
+		editableRouter.PUT("v1/tournaments/{tournamentId}/skins/{skinsId}", tournamentCtr.HandleUpdateSkinsGame)
+		editableRouter.DELETE("v1/tournaments/{tournamentId}/skins/{skinsId}", tournamentCtr.HandleDeleteSkinsGame)
+		router.GET("v1/tournaments/{tournamentId}/contests", tournamentCtr.HandleGetContests)
+		editableRouter.POST("v1/tournaments/{tournamentId}/contests", tournamentCtr.HandleCreateContest)
+		editableRouter.DELETE("v1/tournaments/{tournamentId}/contests/{contestId}", tournamentCtr.HandleDeleteContest)
+		editableRouter.POST("v1/tournaments/{tournamentId}/contests/{contestId}/override", tournamentCtr.HandleOverrideContest)
+		editableRouter.PUT("v1/tournaments/{tournamentId}/contests/{contestId}/entries/{entryId}", tournamentCtr.HandleVoidContestEntry)
+		router.GET("v1/tournaments/{tournamentId}/wagers", tournamentCtr.HandleGetWagers)
+		editableRouter.POST("v1/tournaments/{tournamentId}/wagers", tournamentCtr.HandleCreateWager)
+		editableRouter.DELETE("v1/tournaments/{tournamentId}/wagers/{wagerId}", tournamentCtr.HandleDeleteWager)
+		router.GET("v1/tournaments/{tournamentId}/ledger", tournamentCtr.HandleGetLedger)
+		router.GET("v1/tournaments/{tournamentId}/flights", tournamentCtr.HandleGetFlights)
+		editableRouter.POST("v1/tournaments/{tournamentId}/flights", tournamentCtr.HandleCreateFlight)
+		editableRouter.POST("v1/tournaments/{tournamentId}/flights/generate", tournamentCtr.HandleGenerateFlights)
+		editableRouter.PUT("v1/tournaments/{tournamentId}/flights/{flightId}", tournamentCtr.HandleUpdateFlight)
+		editableRouter.DELETE("v1/tournaments/{tournamentId}/flights/{flightId}", tournamentCtr.HandleDeleteFlight)
+		router.GET("v1/tournaments/{tournamentId}/payouts", tournamentCtr.HandleGetPayoutConfig)
+		editableRouter.PUT("v1/tournaments/{tournamentId}/payouts", tournamentCtr.HandleUpdatePayoutConfig)
+		router.GET("v1/tournaments/{tournamentId}/payouts/report", tournamentCtr.HandleGetPayoutReport)
+		router.GET("v1/tournaments/{tournamentId}/cup", tournamentCtr.HandleGetCup)
+		editableRouter.POST("v1/tournaments/{tournamentId}/cup/sessions", tournamentCtr.HandleCreateCupSession)
+		editableRouter.DELETE("v1/tournaments/{tournamentId}/cup/sessions/{sessionId}", tournamentCtr.HandleDeleteCupSession)
+		editableRouter.PUT("v1/tournaments/{tournamentId}/cup/matches/{matchId}/holes", tournamentCtr.HandleOverrideCupMatchHoles)
+		router.GET("v1/tournaments/{tournamentId}/brackets", tournamentCtr.HandleGetBrackets)
+		editableRouter.POST("v1/tournaments/{tournamentId}/brackets", tournamentCtr.HandleCreateBracket)
+		editableRouter.DELETE("v1/tournaments/{tournamentId}/brackets/{bracketId}", tournamentCtr.HandleDeleteBracket)
+		editableRouter.PUT("v1/tournaments/{tournamentId}/brackets/matches/{matchId}", tournamentCtr.HandleUpdateBracketMatch)
+		adminRouter.POST("v1/tournaments/{tournamentId}/status", tournamentCtr.HandleUpdateTournamentStatus)
+		router.GET("v1/tournaments/{tournamentId}/events", tournamentCtr.HandleGetTournamentEvents)
+		adminRouter.GET("v1/tournaments/{tournamentId}/archive", tournamentCtr.HandleGetTournamentArchive)
+		adminRouter.POST("v1/tournaments/import", tournamentCtr.HandleImportTournamentArchive)
 
 		// /tournament - misc.
-		router.GET("v1/tournament/{tournamentUuId}/team-sheet", tournamentCtr.HandleGetTeamSheetFromTournament)
+		router.GET("v1/tournament/{tournamentId}/team-sheet", tournamentCtr.HandleGetTeamSheetFromTournament)
+		router.GET("v1/tournament/{tournamentId}/scorecards", tournamentCtr.HandleGetScorecardsPdf)
+		router.GET("v1/tournament/{tournamentId}/pairings", tournamentCtr.HandleGetPairingsPdf)
+		router.GET("v1/tournament/{tournamentId}/results", tournamentCtr.HandleGetResultsPdf)
+		router.GET("v1/tournament/{tournamentId}/export", tournamentCtr.HandleExportResults)
 		router.GET("v1/tournament_formats", tournamentCtr.HandleGetAllTournamentFormats)
 
 		// /course
@@ -65,14 +127,68 @@ func main() {
 		router.GET("v1/courses", courseCtr.HandleGetCourses)
 
 		// /players
-		playersCtr := controllers.NewPlayersController(app)
+		playersCtr := controllers.NewPlayersController(app, handicapProvider)
 		router.GET("v1/players", playersCtr.HandleGetPlayers)
+		router.POST("v1/players", playersCtr.HandleCreatePlayer)
+		router.PUT("v1/players/{playerId}", playersCtr.HandleUpdatePlayer)
+		router.POST("v1/players/{playerId}/archive", playersCtr.HandleArchivePlayer)
+		router.GET("v1/players/{playerId}/handicaps", playersCtr.HandleGetPlayerHandicapHistory)
+		router.GET("v1/players/{playerId}/rounds", playersCtr.HandleGetPlayerRounds)
+		router.GET("v1/players/{playerId}/stats", playersCtr.HandleGetPlayerStats)
+		router.GET("v1/players/{playerId}/head-to-head/{opponentId}", playersCtr.HandleGetHeadToHead)
+		router.GET("v1/players/records", playersCtr.HandleGetPlayerRecords)
+		router.POST("v1/players/import", playersCtr.HandleImportPlayers)
+		router.POST("v1/players/handicaps/refresh", playersCtr.HandleRefreshHandicaps)
 		router.GET("v1/tournament/{tournamentId}/players", playersCtr.HandleGetPlayersByTournament)
+		router.GET("v1/tournament/{tournamentId}/players/export", playersCtr.HandleExportTournamentPlayers)
+
+		// /seasons
+		seasonsCtr := controllers.NewSeasonsController(app)
+		router.GET("v1/seasons", seasonsCtr.HandleGetSeasons)
+		router.POST("v1/seasons", seasonsCtr.HandleCreateSeason)
+		router.GET("v1/seasons/{seasonId}", seasonsCtr.HandleGetSeason)
+		router.PUT("v1/seasons/{seasonId}", seasonsCtr.HandleUpdateSeason)
+		router.PUT("v1/seasons/{seasonId}/tournaments/{tournamentId}", seasonsCtr.HandleSetSeasonTournament)
+		router.DELETE("v1/seasons/{seasonId}/tournaments/{tournamentId}", seasonsCtr.HandleRemoveSeasonTournament)
+		router.GET("v1/seasons/{seasonId}/standings", seasonsCtr.HandleGetSeasonStandings)
+		router.GET("v1/seasons/{seasonId}/standings/pdf", seasonsCtr.HandleGetSeasonStandingsPdf)
+
+		// /leagues
+		leaguesCtr := controllers.NewLeaguesController(app)
+		router.GET("v1/leagues", leaguesCtr.HandleGetLeagues)
+		router.POST("v1/leagues", leaguesCtr.HandleCreateLeague)
+		router.GET("v1/leagues/{leagueId}", leaguesCtr.HandleGetLeague)
+		router.GET("v1/leagues/{leagueId}/standings", leaguesCtr.HandleGetLeagueStandings)
+		router.POST("v1/leagues/{leagueId}/weeks/{week}/tournament", leaguesCtr.HandleCreateLeagueWeek)
 
 		// /holes
 		holesCtr := controllers.NewHolesController(app)
 		protectedRouter.PUT("v1/holes", holesCtr.HandleUpdateTeamHoleScores)
 		protectedRouter.GET("v1/holes", holesCtr.HandleGetHoles)
+		protectedRouter.GET("v1/team-holes", holesCtr.HandleGetTeamBallHoles)
+		protectedRouter.PUT("v1/team-holes", holesCtr.HandleUpdateTeamBallHoles)
+
+		if handicapProvider != nil {
+			app.Cron().MustAdd("handicapSync", handicap.SyncSchedule(), func() {
+				refreshed, err := playersCtr.RefreshHandicaps(context.Background())
+				if err != nil {
+					app.Logger().Error("handicap sync failed", "error", err)
+					return
+				}
+				app.Logger().Info("handicap sync complete", "updated", len(refreshed))
+			})
+		}
+
+		app.Cron().MustAdd("bracketDeadlines", "0 * * * *", func() {
+			defaulted, err := tournamentCtr.ApplyBracketDeadlines()
+			if err != nil {
+				app.Logger().Error("bracket deadlines failed", "error", err)
+				return
+			}
+			if defaulted > 0 {
+				app.Logger().Info("bracket deadlines applied", "defaulted", defaulted)
+			}
+		})
 
 		// APP
 		se.Router.GET("/{path...}", apis.Static(ui.DistDirFS, true)).
@@ -1,10 +1,13 @@
 package middleware
 
 import (
+	"crypto/subtle"
+	"net/http"
 	"strings"
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/patrick-salvatore/tournament-live-scoring/controllers"
+	"github.com/patrick-salvatore/tournament-live-scoring/internal/utils"
 	"github.com/patrick-salvatore/tournament-live-scoring/models"
 	"github.com/pocketbase/pocketbase/core"
 
@@ -38,8 +41,14 @@ func WithJWTVerify(app core.App) func(e *core.RequestEvent) error {
 		}
 
 		tourney, err := models.GetTournamentById(app.DB(), tournamentId)
-		if tourney.IsComplete {
-			return e.Error(308, "unauthorized", "tournament has completed")
+		if err != nil {
+			return e.Error(308, "unauthorized", "tournament not found")
+		}
+
+		// finished tournaments stay readable, but teams can only write while
+		// play is open
+		if e.Request.Method != http.MethodGet && !tourney.AcceptsScores() {
+			return e.Error(http.StatusForbidden, "tournament is "+tourney.Status, nil)
 		}
 
 		rCtx := e.Request.Context()
@@ -53,3 +62,41 @@ func WithJWTVerify(app core.App) func(e *core.RequestEvent) error {
 		return e.Next()
 	}
 }
+
+// WithEditableTournament rejects changes to a final or archived tournament.
+// It has to be reopened through its status first.
+func WithEditableTournament(app core.App) func(e *core.RequestEvent) error {
+	return func(e *core.RequestEvent) error {
+		if e.Request.Method == http.MethodGet {
+			return e.Next()
+		}
+
+		tournamentId := e.Request.PathValue("tournamentId")
+		tourney, err := models.GetTournamentById(app.DB(), tournamentId)
+		if err != nil {
+			return e.NotFoundError("tournament not found", tournamentId)
+		}
+
+		if tourney.IsFrozen() {
+			return e.Error(http.StatusForbidden, "tournament is "+tourney.Status+", reopen it to make changes", nil)
+		}
+
+		return e.Next()
+	}
+}
+
+// WithAdminSecret keeps committee-only endpoints, like finalizing a
+// tournament, to callers sending the shared ADMIN_SECRET in the
+// X-Admin-Secret header.
+func WithAdminSecret() func(e *core.RequestEvent) error {
+	secret := []byte(utils.GetEnvVarOrPanic("ADMIN_SECRET"))
+
+	return func(e *core.RequestEvent) error {
+		given := []byte(e.Request.Header.Get("X-Admin-Secret"))
+		if subtle.ConstantTimeCompare(given, secret) != 1 {
+			return e.UnauthorizedError("admin secret required", nil)
+		}
+
+		return e.Next()
+	}
+}
@@ -0,0 +1,50 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+)
+
+func init() {
+	core.AppMigrations.Register(func(app core.App) error {
+		err := addFields(app, "holes",
+			&core.NumberField{Name: "strokes", OnlyInt: true},
+		)
+		if err != nil {
+			return err
+		}
+
+		err = addFields(app, "_team_players",
+			&core.NumberField{Name: "handicap_index"},
+			&core.NumberField{Name: "course_handicap", OnlyInt: true},
+			&core.NumberField{Name: "playing_handicap", OnlyInt: true},
+			&core.BoolField{Name: "strokes_frozen"},
+		)
+		if err != nil {
+			return err
+		}
+
+		auditLog := core.NewBaseCollection("audit_log")
+		auditLog.Fields.Add(
+			&core.TextField{Name: "tournament_id"},
+			&core.TextField{Name: "action", Required: true},
+			&core.TextField{Name: "actor"},
+			&core.JSONField{Name: "data"},
+		)
+		addTimestampFields(auditLog)
+		auditLog.AddIndex("idx_audit_log_tournament", false, "tournament_id", "")
+
+		return app.Save(auditLog)
+	}, func(app core.App) error {
+		err := deleteCollection(app, "audit_log")
+		if err != nil {
+			return err
+		}
+
+		err = removeFields(app, "_team_players", "handicap_index", "course_handicap", "playing_handicap", "strokes_frozen")
+		if err != nil {
+			return err
+		}
+
+		return removeFields(app, "holes", "strokes")
+	})
+}
@@ -0,0 +1,35 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+)
+
+func init() {
+	core.AppMigrations.Register(func(app core.App) error {
+		err := addFields(app, "players",
+			&core.BoolField{Name: "archived"},
+		)
+		if err != nil {
+			return err
+		}
+
+		history := core.NewBaseCollection("player_handicap_history")
+		history.Fields.Add(
+			&core.TextField{Name: "player_id", Required: true},
+			&core.NumberField{Name: "handicap_index"},
+			&core.TextField{Name: "effective_date", Required: true},
+			&core.TextField{Name: "source"},
+		)
+		addTimestampFields(history)
+		history.AddIndex("idx_player_handicap_history_player", false, "player_id, effective_date", "")
+
+		return app.Save(history)
+	}, func(app core.App) error {
+		err := deleteCollection(app, "player_handicap_history")
+		if err != nil {
+			return err
+		}
+
+		return removeFields(app, "players", "archived")
+	})
+}
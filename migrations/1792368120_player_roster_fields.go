@@ -0,0 +1,35 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+)
+
+func init() {
+	core.AppMigrations.Register(func(app core.App) error {
+		collection, err := app.FindCollectionByNameOrId("players")
+		if err != nil {
+			return err
+		}
+
+		collection.Fields.Add(
+			&core.TextField{Name: "email"},
+			&core.TextField{Name: "preferred_tee"},
+			&core.TextField{Name: "external_id"},
+		)
+		collection.AddIndex("idx_players_external_id", true, "external_id", "external_id != ''")
+
+		return app.Save(collection)
+	}, func(app core.App) error {
+		collection, err := app.FindCollectionByNameOrId("players")
+		if err != nil {
+			return err
+		}
+
+		collection.RemoveIndex("idx_players_external_id")
+		collection.Fields.RemoveByName("email")
+		collection.Fields.RemoveByName("preferred_tee")
+		collection.Fields.RemoveByName("external_id")
+
+		return app.Save(collection)
+	})
+}
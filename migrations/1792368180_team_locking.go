@@ -0,0 +1,16 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+)
+
+func init() {
+	core.AppMigrations.Register(func(app core.App) error {
+		return addFields(app, "teams",
+			&core.BoolField{Name: "locked"},
+			&core.BoolField{Name: "name_locked"},
+		)
+	}, func(app core.App) error {
+		return removeFields(app, "teams", "locked", "name_locked")
+	})
+}
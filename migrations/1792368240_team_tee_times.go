@@ -0,0 +1,19 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+)
+
+func init() {
+	core.AppMigrations.Register(func(app core.App) error {
+		return addFields(app, "teams",
+			&core.TextField{Name: "start_time"},
+			&core.NumberField{Name: "start_hole"},
+			&core.NumberField{Name: "tee_group"},
+			&core.TextField{Name: "tee_time_request"},
+			&core.TextField{Name: "teed_off_at"},
+		)
+	}, func(app core.App) error {
+		return removeFields(app, "teams", "start_time", "start_hole", "tee_group", "tee_time_request", "teed_off_at")
+	})
+}
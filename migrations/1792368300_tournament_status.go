@@ -0,0 +1,44 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+)
+
+func init() {
+	core.AppMigrations.Register(func(app core.App) error {
+		err := addFields(app, "tournaments",
+			&core.TextField{Name: "status"},
+		)
+		if err != nil {
+			return err
+		}
+
+		// existing tournaments were playable until marked complete
+		_, err = app.DB().
+			NewQuery("UPDATE tournaments SET status = CASE WHEN complete = 1 THEN 'final' ELSE 'in_progress' END").
+			Execute()
+		if err != nil {
+			return err
+		}
+
+		events := core.NewBaseCollection("tournament_events")
+		events.Fields.Add(
+			&core.TextField{Name: "tournament_id", Required: true},
+			&core.TextField{Name: "from_status"},
+			&core.TextField{Name: "to_status", Required: true},
+			&core.TextField{Name: "reason"},
+			&core.TextField{Name: "actor"},
+		)
+		addTimestampFields(events)
+		events.AddIndex("idx_tournament_events_tournament", false, "tournament_id", "")
+
+		return app.Save(events)
+	}, func(app core.App) error {
+		err := deleteCollection(app, "tournament_events")
+		if err != nil {
+			return err
+		}
+
+		return removeFields(app, "tournaments", "status")
+	})
+}
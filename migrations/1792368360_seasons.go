@@ -0,0 +1,42 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+)
+
+func init() {
+	core.AppMigrations.Register(func(app core.App) error {
+		seasons := core.NewBaseCollection("seasons")
+		seasons.Fields.Add(
+			&core.TextField{Name: "name", Required: true},
+			&core.TextField{Name: "points_table"},
+			&core.NumberField{Name: "major_multiplier"},
+			&core.NumberField{Name: "drop_worst"},
+			&core.TextField{Name: "scoring"},
+		)
+		addTimestampFields(seasons)
+
+		err := app.Save(seasons)
+		if err != nil {
+			return err
+		}
+
+		seasonTournaments := core.NewBaseCollection("season_tournaments")
+		seasonTournaments.Fields.Add(
+			&core.TextField{Name: "season_id", Required: true},
+			&core.TextField{Name: "tournament_id", Required: true},
+			&core.BoolField{Name: "major"},
+		)
+		addTimestampFields(seasonTournaments)
+		seasonTournaments.AddIndex("idx_season_tournaments_season_tournament", true, "season_id, tournament_id", "")
+
+		return app.Save(seasonTournaments)
+	}, func(app core.App) error {
+		err := deleteCollection(app, "season_tournaments")
+		if err != nil {
+			return err
+		}
+
+		return deleteCollection(app, "seasons")
+	})
+}
@@ -0,0 +1,26 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+)
+
+func init() {
+	core.AppMigrations.Register(func(app core.App) error {
+		skins := core.NewBaseCollection("skins_games")
+		skins.Fields.Add(
+			&core.TextField{Name: "tournament_id", Required: true},
+			&core.TextField{Name: "name", Required: true},
+			&core.TextField{Name: "scoring"},
+			&core.BoolField{Name: "carryover"},
+			&core.TextField{Name: "min_score"},
+			&core.BoolField{Name: "validate"},
+			&core.NumberField{Name: "pot"},
+		)
+		addTimestampFields(skins)
+		skins.AddIndex("idx_skins_games_tournament", false, "tournament_id", "")
+
+		return app.Save(skins)
+	}, func(app core.App) error {
+		return deleteCollection(app, "skins_games")
+	})
+}
@@ -0,0 +1,48 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+)
+
+func init() {
+	core.AppMigrations.Register(func(app core.App) error {
+		contests := core.NewBaseCollection("contests")
+		contests.Fields.Add(
+			&core.TextField{Name: "tournament_id", Required: true},
+			&core.NumberField{Name: "hole", Required: true},
+			&core.TextField{Name: "kind", Required: true},
+			&core.TextField{Name: "name"},
+			&core.TextField{Name: "unit"},
+		)
+		addTimestampFields(contests)
+		contests.AddIndex("idx_contests_tournament", false, "tournament_id", "")
+
+		err := app.Save(contests)
+		if err != nil {
+			return err
+		}
+
+		entries := core.NewBaseCollection("contest_entries")
+		entries.Fields.Add(
+			&core.TextField{Name: "contest_id", Required: true},
+			&core.TextField{Name: "tournament_id", Required: true},
+			&core.TextField{Name: "team_id"},
+			&core.TextField{Name: "player_id", Required: true},
+			&core.NumberField{Name: "distance"},
+			&core.BoolField{Name: "override"},
+			&core.BoolField{Name: "voided"},
+			&core.TextField{Name: "note"},
+		)
+		addTimestampFields(entries)
+		entries.AddIndex("idx_contest_entries_contest", false, "contest_id, created", "")
+
+		return app.Save(entries)
+	}, func(app core.App) error {
+		err := deleteCollection(app, "contest_entries")
+		if err != nil {
+			return err
+		}
+
+		return deleteCollection(app, "contests")
+	})
+}
@@ -0,0 +1,46 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+)
+
+func init() {
+	core.AppMigrations.Register(func(app core.App) error {
+		wagers := core.NewBaseCollection("wagers")
+		wagers.Fields.Add(
+			&core.TextField{Name: "tournament_id", Required: true},
+			&core.TextField{Name: "name"},
+			&core.TextField{Name: "kind", Required: true},
+			&core.TextField{Name: "scoring"},
+			&core.NumberField{Name: "stake"},
+			&core.NumberField{Name: "press_after"},
+		)
+		addTimestampFields(wagers)
+		wagers.AddIndex("idx_wagers_tournament", false, "tournament_id", "")
+
+		err := app.Save(wagers)
+		if err != nil {
+			return err
+		}
+
+		sides := core.NewBaseCollection("wager_players")
+		sides.Fields.Add(
+			&core.TextField{Name: "wager_id", Required: true},
+			&core.TextField{Name: "tournament_id", Required: true},
+			&core.TextField{Name: "side", Required: true},
+			&core.TextField{Name: "player_id"},
+			&core.TextField{Name: "team_id"},
+		)
+		addTimestampFields(sides)
+		sides.AddIndex("idx_wager_players_wager", false, "wager_id", "")
+
+		return app.Save(sides)
+	}, func(app core.App) error {
+		err := deleteCollection(app, "wager_players")
+		if err != nil {
+			return err
+		}
+
+		return deleteCollection(app, "wagers")
+	})
+}
@@ -0,0 +1,46 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+)
+
+func init() {
+	core.AppMigrations.Register(func(app core.App) error {
+		flights := core.NewBaseCollection("flights")
+		flights.Fields.Add(
+			&core.TextField{Name: "tournament_id", Required: true},
+			&core.TextField{Name: "name", Required: true},
+			&core.TextField{Name: "kind", Required: true},
+			&core.NumberField{Name: "min_handicap"},
+			&core.NumberField{Name: "max_handicap"},
+			&core.TextField{Name: "scoring"},
+			&core.NumberField{Name: "places"},
+			&core.TextField{Name: "tiebreak"},
+		)
+		addTimestampFields(flights)
+		flights.AddIndex("idx_flights_tournament", false, "tournament_id", "")
+
+		err := app.Save(flights)
+		if err != nil {
+			return err
+		}
+
+		players := core.NewBaseCollection("flight_players")
+		players.Fields.Add(
+			&core.TextField{Name: "flight_id", Required: true},
+			&core.TextField{Name: "tournament_id", Required: true},
+			&core.TextField{Name: "player_id", Required: true},
+		)
+		addTimestampFields(players)
+		players.AddIndex("idx_flight_players_flight_player", true, "flight_id, player_id", "")
+
+		return app.Save(players)
+	}, func(app core.App) error {
+		err := deleteCollection(app, "flight_players")
+		if err != nil {
+			return err
+		}
+
+		return deleteCollection(app, "flights")
+	})
+}
@@ -0,0 +1,45 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+)
+
+func init() {
+	core.AppMigrations.Register(func(app core.App) error {
+		payouts := core.NewBaseCollection("payouts")
+		payouts.Fields.Add(
+			&core.TextField{Name: "tournament_id", Required: true},
+			&core.NumberField{Name: "entry_fee"},
+			&core.NumberField{Name: "entries"},
+			&core.NumberField{Name: "pot"},
+			&core.TextField{Name: "scoring"},
+			&core.TextField{Name: "splits"},
+		)
+		addTimestampFields(payouts)
+		payouts.AddIndex("idx_payouts_tournament", true, "tournament_id", "")
+
+		err := app.Save(payouts)
+		if err != nil {
+			return err
+		}
+
+		payoutFlights := core.NewBaseCollection("payout_flights")
+		payoutFlights.Fields.Add(
+			&core.TextField{Name: "tournament_id", Required: true},
+			&core.TextField{Name: "flight_id", Required: true},
+			&core.NumberField{Name: "share"},
+			&core.TextField{Name: "splits"},
+		)
+		addTimestampFields(payoutFlights)
+		payoutFlights.AddIndex("idx_payout_flights_flight", true, "flight_id", "")
+
+		return app.Save(payoutFlights)
+	}, func(app core.App) error {
+		err := deleteCollection(app, "payout_flights")
+		if err != nil {
+			return err
+		}
+
+		return deleteCollection(app, "payouts")
+	})
+}
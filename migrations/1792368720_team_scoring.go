@@ -0,0 +1,16 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+)
+
+func init() {
+	core.AppMigrations.Register(func(app core.App) error {
+		return addFields(app, "tournaments",
+			&core.TextField{Name: "team_scoring"},
+			&core.TextField{Name: "best_scores"},
+		)
+	}, func(app core.App) error {
+		return removeFields(app, "tournaments", "team_scoring", "best_scores")
+	})
+}
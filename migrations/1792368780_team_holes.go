@@ -0,0 +1,39 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+)
+
+func init() {
+	core.AppMigrations.Register(func(app core.App) error {
+		err := addFields(app, "tournaments",
+			&core.TextField{Name: "ball_format"},
+			&core.TextField{Name: "handicap_weights"},
+			&core.NumberField{Name: "min_drives"},
+		)
+		if err != nil {
+			return err
+		}
+
+		teamHoles := core.NewBaseCollection("team_holes")
+		teamHoles.Fields.Add(
+			&core.TextField{Name: "tournament_id", Required: true},
+			&core.TextField{Name: "team_id", Required: true},
+			&core.NumberField{Name: "number"},
+			&core.TextField{Name: "score"},
+			&core.NumberField{Name: "strokes"},
+			&core.TextField{Name: "drive_player_id"},
+		)
+		addTimestampFields(teamHoles)
+		teamHoles.AddIndex("idx_team_holes_team_number", true, "team_id, number", "")
+
+		return app.Save(teamHoles)
+	}, func(app core.App) error {
+		err := deleteCollection(app, "team_holes")
+		if err != nil {
+			return err
+		}
+
+		return removeFields(app, "tournaments", "ball_format", "handicap_weights", "min_drives")
+	})
+}
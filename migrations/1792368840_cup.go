@@ -0,0 +1,75 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+)
+
+func init() {
+	core.AppMigrations.Register(func(app core.App) error {
+		sessions := core.NewBaseCollection("cup_sessions")
+		sessions.Fields.Add(
+			&core.TextField{Name: "tournament_id", Required: true},
+			&core.TextField{Name: "name"},
+			&core.TextField{Name: "format", Required: true},
+			&core.NumberField{Name: "position"},
+		)
+		addTimestampFields(sessions)
+		sessions.AddIndex("idx_cup_sessions_tournament", false, "tournament_id", "")
+
+		err := app.Save(sessions)
+		if err != nil {
+			return err
+		}
+
+		matches := core.NewBaseCollection("cup_matches")
+		matches.Fields.Add(
+			&core.TextField{Name: "tournament_id", Required: true},
+			&core.TextField{Name: "session_id", Required: true},
+			&core.NumberField{Name: "number"},
+		)
+		addTimestampFields(matches)
+		matches.AddIndex("idx_cup_matches_session", false, "session_id", "")
+
+		err = app.Save(matches)
+		if err != nil {
+			return err
+		}
+
+		players := core.NewBaseCollection("cup_match_players")
+		players.Fields.Add(
+			&core.TextField{Name: "tournament_id", Required: true},
+			&core.TextField{Name: "match_id", Required: true},
+			&core.TextField{Name: "side", Required: true},
+			&core.TextField{Name: "team_id", Required: true},
+			&core.TextField{Name: "player_id", Required: true},
+		)
+		addTimestampFields(players)
+		players.AddIndex("idx_cup_match_players_match", false, "match_id", "")
+
+		err = app.Save(players)
+		if err != nil {
+			return err
+		}
+
+		holes := core.NewBaseCollection("cup_match_holes")
+		holes.Fields.Add(
+			&core.TextField{Name: "tournament_id", Required: true},
+			&core.TextField{Name: "match_id", Required: true},
+			&core.NumberField{Name: "number", Required: true},
+			&core.TextField{Name: "winner", Required: true},
+		)
+		addTimestampFields(holes)
+		holes.AddIndex("idx_cup_match_holes_match_number", true, "match_id, number", "")
+
+		return app.Save(holes)
+	}, func(app core.App) error {
+		for _, name := range []string{"cup_match_holes", "cup_match_players", "cup_matches", "cup_sessions"} {
+			err := deleteCollection(app, name)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
@@ -0,0 +1,70 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+)
+
+func init() {
+	core.AppMigrations.Register(func(app core.App) error {
+		brackets := core.NewBaseCollection("brackets")
+		brackets.Fields.Add(
+			&core.TextField{Name: "tournament_id", Required: true},
+			&core.TextField{Name: "name"},
+			&core.TextField{Name: "seeding"},
+			&core.NumberField{Name: "size"},
+			&core.TextField{Name: "start_date"},
+			&core.NumberField{Name: "round_days"},
+		)
+		addTimestampFields(brackets)
+		brackets.AddIndex("idx_brackets_tournament", false, "tournament_id", "")
+
+		err := app.Save(brackets)
+		if err != nil {
+			return err
+		}
+
+		matches := core.NewBaseCollection("bracket_matches")
+		matches.Fields.Add(
+			&core.TextField{Name: "tournament_id", Required: true},
+			&core.TextField{Name: "bracket_id", Required: true},
+			&core.NumberField{Name: "round"},
+			&core.NumberField{Name: "position"},
+			&core.TextField{Name: "player_a_id"},
+			&core.TextField{Name: "player_b_id"},
+			&core.NumberField{Name: "seed_a"},
+			&core.NumberField{Name: "seed_b"},
+			&core.TextField{Name: "deadline"},
+			&core.TextField{Name: "winner_id"},
+			&core.TextField{Name: "result"},
+			&core.TextField{Name: "score"},
+		)
+		addTimestampFields(matches)
+		matches.AddIndex("idx_bracket_matches_slot", true, "bracket_id, round, position", "")
+
+		err = app.Save(matches)
+		if err != nil {
+			return err
+		}
+
+		holes := core.NewBaseCollection("bracket_match_holes")
+		holes.Fields.Add(
+			&core.TextField{Name: "tournament_id", Required: true},
+			&core.TextField{Name: "match_id", Required: true},
+			&core.NumberField{Name: "number", Required: true},
+			&core.TextField{Name: "winner", Required: true},
+		)
+		addTimestampFields(holes)
+		holes.AddIndex("idx_bracket_match_holes_match_number", true, "match_id, number", "")
+
+		return app.Save(holes)
+	}, func(app core.App) error {
+		for _, name := range []string{"bracket_match_holes", "bracket_matches", "brackets"} {
+			err := deleteCollection(app, name)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
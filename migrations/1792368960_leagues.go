@@ -0,0 +1,96 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+)
+
+func init() {
+	core.AppMigrations.Register(func(app core.App) error {
+		leagues := core.NewBaseCollection("leagues")
+		leagues.Fields.Add(
+			&core.TextField{Name: "name", Required: true},
+			&core.TextField{Name: "course_id"},
+			&core.TextField{Name: "format_id"},
+			&core.NumberField{Name: "awarded_handicap"},
+			&core.TextField{Name: "team_scoring"},
+			&core.TextField{Name: "best_scores"},
+			&core.NumberField{Name: "rounds"},
+			&core.NumberField{Name: "points_win"},
+			&core.NumberField{Name: "points_halve"},
+			&core.NumberField{Name: "handicap_rounds"},
+		)
+		addTimestampFields(leagues)
+
+		err := app.Save(leagues)
+		if err != nil {
+			return err
+		}
+
+		teams := core.NewBaseCollection("league_teams")
+		teams.Fields.Add(
+			&core.TextField{Name: "league_id", Required: true},
+			&core.TextField{Name: "name", Required: true},
+		)
+		addTimestampFields(teams)
+		teams.AddIndex("idx_league_teams_league", false, "league_id", "")
+
+		err = app.Save(teams)
+		if err != nil {
+			return err
+		}
+
+		players := core.NewBaseCollection("league_team_players")
+		players.Fields.Add(
+			&core.TextField{Name: "league_id", Required: true},
+			&core.TextField{Name: "team_id", Required: true},
+			&core.TextField{Name: "player_id", Required: true},
+			&core.TextField{Name: "tee"},
+		)
+		addTimestampFields(players)
+		players.AddIndex("idx_league_team_players_league_player", true, "league_id, player_id", "")
+
+		err = app.Save(players)
+		if err != nil {
+			return err
+		}
+
+		matches := core.NewBaseCollection("league_matches")
+		matches.Fields.Add(
+			&core.TextField{Name: "league_id", Required: true},
+			&core.NumberField{Name: "week"},
+			&core.TextField{Name: "home_team_id", Required: true},
+			&core.TextField{Name: "away_team_id", Required: true},
+			&core.TextField{Name: "tournament_id"},
+			&core.TextField{Name: "home_tournament_team_id"},
+			&core.TextField{Name: "away_tournament_team_id"},
+		)
+		addTimestampFields(matches)
+		matches.AddIndex("idx_league_matches_league_week", false, "league_id, week", "")
+
+		err = app.Save(matches)
+		if err != nil {
+			return err
+		}
+
+		handicaps := core.NewBaseCollection("league_handicaps")
+		handicaps.Fields.Add(
+			&core.TextField{Name: "league_id", Required: true},
+			&core.TextField{Name: "player_id", Required: true},
+			&core.NumberField{Name: "week"},
+			&core.NumberField{Name: "handicap_index"},
+		)
+		addTimestampFields(handicaps)
+		handicaps.AddIndex("idx_league_handicaps_league_player_week", true, "league_id, player_id, week", "")
+
+		return app.Save(handicaps)
+	}, func(app core.App) error {
+		for _, name := range []string{"league_handicaps", "league_matches", "league_team_players", "league_teams", "leagues"} {
+			err := deleteCollection(app, name)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
@@ -0,0 +1,50 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// bracket matches are decided from the scores of the round they are linked
+// to, so the hand entered holes go
+func init() {
+	core.AppMigrations.Register(func(app core.App) error {
+		err := addFields(app, "brackets", &core.TextField{Name: "scoring"})
+		if err != nil {
+			return err
+		}
+
+		err = addFields(app, "bracket_matches", &core.TextField{Name: "round_tournament_id"})
+		if err != nil {
+			return err
+		}
+
+		_, err = app.DB().NewQuery("UPDATE brackets SET scoring = 'net'").Execute()
+		if err != nil {
+			return err
+		}
+
+		return deleteCollection(app, "bracket_match_holes")
+	}, func(app core.App) error {
+		holes := core.NewBaseCollection("bracket_match_holes")
+		holes.Fields.Add(
+			&core.TextField{Name: "tournament_id", Required: true},
+			&core.TextField{Name: "match_id", Required: true},
+			&core.NumberField{Name: "number", Required: true},
+			&core.TextField{Name: "winner", Required: true},
+		)
+		addTimestampFields(holes)
+		holes.AddIndex("idx_bracket_match_holes_match_number", true, "match_id, number", "")
+
+		err := app.Save(holes)
+		if err != nil {
+			return err
+		}
+
+		err = removeFields(app, "bracket_matches", "round_tournament_id")
+		if err != nil {
+			return err
+		}
+
+		return removeFields(app, "brackets", "scoring")
+	})
+}
@@ -0,0 +1,20 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+)
+
+func init() {
+	core.AppMigrations.Register(func(app core.App) error {
+		err := addFields(app, "cup_sessions", &core.TextField{Name: "round_tournament_id"})
+		if err != nil {
+			return err
+		}
+
+		// sessions created so far were played in the cup's own tournament
+		_, err = app.DB().NewQuery("UPDATE cup_sessions SET round_tournament_id = tournament_id").Execute()
+		return err
+	}, func(app core.App) error {
+		return removeFields(app, "cup_sessions", "round_tournament_id")
+	})
+}
@@ -0,0 +1,45 @@
+package migrations
+
+import "github.com/pocketbase/pocketbase/core"
+
+// addTimestampFields mirrors the created/updated columns every existing
+// collection carries, which the models set explicitly on insert.
+func addTimestampFields(collection *core.Collection) {
+	collection.Fields.Add(
+		&core.AutodateField{Name: "created", OnCreate: true},
+		&core.AutodateField{Name: "updated", OnCreate: true, OnUpdate: true},
+	)
+}
+
+func addFields(app core.App, collectionName string, fields ...core.Field) error {
+	collection, err := app.FindCollectionByNameOrId(collectionName)
+	if err != nil {
+		return err
+	}
+
+	collection.Fields.Add(fields...)
+
+	return app.Save(collection)
+}
+
+func removeFields(app core.App, collectionName string, fieldNames ...string) error {
+	collection, err := app.FindCollectionByNameOrId(collectionName)
+	if err != nil {
+		return err
+	}
+
+	for _, name := range fieldNames {
+		collection.Fields.RemoveByName(name)
+	}
+
+	return app.Save(collection)
+}
+
+func deleteCollection(app core.App, collectionName string) error {
+	collection, err := app.FindCollectionByNameOrId(collectionName)
+	if err != nil {
+		return err
+	}
+
+	return app.Delete(collection)
+}
@@ -0,0 +1,364 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/patrick-salvatore/tournament-live-scoring/internal/security"
+	"github.com/pocketbase/dbx"
+)
+
+const ARCHIVE_VERSION = 1
+
+type ArchiveRecord map[string]any
+
+// TournamentArchive is a self-contained copy of a tournament. Records are
+// stored as raw rows keyed by table so columns added later travel with the
+// bundle without changes here.
+type TournamentArchive struct {
+	Version      int                        `json:"version"`
+	ExportedAt   string                     `json:"exportedAt"`
+	TournamentId string                     `json:"tournamentId"`
+	Records      map[string][]ArchiveRecord `json:"records"`
+}
+
+type archiveTable struct {
+	name string
+	// where selects the table's rows for {:tournament_id}.
+	where string
+	// references maps a column to the table whose ids it holds.
+	references map[string]string
+	// shared tables hold reference data used across tournaments. Rows that
+	// already exist on restore are reused rather than copied.
+	shared bool
+}
+
+// archiveTables lists what a bundle holds, in restore order so every
+// referenced row is inserted before the rows pointing at it.
+var archiveTables = []archiveTable{
+	{
+		name:   "tournament_formats",
+		where:  "id = (SELECT tournament_format_id FROM tournaments WHERE id = {:tournament_id})",
+		shared: true,
+	},
+	{
+		name:   "courses",
+		where:  "id = (SELECT course_id FROM tournaments WHERE id = {:tournament_id})",
+		shared: true,
+	},
+	{
+		name:   "players",
+		where:  "id IN (SELECT player_id FROM _team_players WHERE tournament_id = {:tournament_id})",
+		shared: true,
+	},
+	{
+		name:       "tournaments",
+		where:      "id = {:tournament_id}",
+		references: map[string]string{"course_id": "courses", "tournament_format_id": "tournament_formats"},
+	},
+	{
+		name:       "teams",
+		where:      "tournament_id = {:tournament_id}",
+		references: map[string]string{"tournament_id": "tournaments"},
+	},
+	{
+		name:       "_team_players",
+		where:      "tournament_id = {:tournament_id}",
+		references: map[string]string{"tournament_id": "tournaments", "team_id": "teams", "player_id": "players"},
+	},
+	{
+		name:       "holes",
+		where:      "tournament_id = {:tournament_id}",
+		references: map[string]string{"tournament_id": "tournaments", "player_id": "players"},
+	},
+	{
+		name:       "team_holes",
+		where:      "tournament_id = {:tournament_id}",
+		references: map[string]string{"tournament_id": "tournaments", "team_id": "teams", "drive_player_id": "players"},
+	},
+	{
+		name:       "tournament_events",
+		where:      "tournament_id = {:tournament_id}",
+		references: map[string]string{"tournament_id": "tournaments"},
+	},
+	{
+		name:       "skins_games",
+		where:      "tournament_id = {:tournament_id}",
+		references: map[string]string{"tournament_id": "tournaments"},
+	},
+	{
+		name:       "contests",
+		where:      "tournament_id = {:tournament_id}",
+		references: map[string]string{"tournament_id": "tournaments"},
+	},
+	{
+		name:       "contest_entries",
+		where:      "tournament_id = {:tournament_id}",
+		references: map[string]string{"tournament_id": "tournaments", "contest_id": "contests", "team_id": "teams", "player_id": "players"},
+	},
+	{
+		name:       "wagers",
+		where:      "tournament_id = {:tournament_id}",
+		references: map[string]string{"tournament_id": "tournaments"},
+	},
+	{
+		name:       "wager_players",
+		where:      "tournament_id = {:tournament_id}",
+		references: map[string]string{"tournament_id": "tournaments", "wager_id": "wagers", "team_id": "teams", "player_id": "players"},
+	},
+	{
+		name:       "flights",
+		where:      "tournament_id = {:tournament_id}",
+		references: map[string]string{"tournament_id": "tournaments"},
+	},
+	{
+		name:       "flight_players",
+		where:      "tournament_id = {:tournament_id}",
+		references: map[string]string{"tournament_id": "tournaments", "flight_id": "flights", "player_id": "players"},
+	},
+	{
+		name:       "payouts",
+		where:      "tournament_id = {:tournament_id}",
+		references: map[string]string{"tournament_id": "tournaments"},
+	},
+	{
+		name:       "payout_flights",
+		where:      "tournament_id = {:tournament_id}",
+		references: map[string]string{"tournament_id": "tournaments", "flight_id": "flights"},
+	},
+	{
+		name:       "cup_sessions",
+		where:      "tournament_id = {:tournament_id}",
+		references: map[string]string{"tournament_id": "tournaments", "round_tournament_id": "tournaments"},
+	},
+	{
+		name:       "cup_matches",
+		where:      "tournament_id = {:tournament_id}",
+		references: map[string]string{"tournament_id": "tournaments", "session_id": "cup_sessions"},
+	},
+	{
+		name:       "cup_match_players",
+		where:      "tournament_id = {:tournament_id}",
+		references: map[string]string{"tournament_id": "tournaments", "match_id": "cup_matches", "team_id": "teams", "player_id": "players"},
+	},
+	{
+		name:       "cup_match_holes",
+		where:      "tournament_id = {:tournament_id}",
+		references: map[string]string{"tournament_id": "tournaments", "match_id": "cup_matches"},
+	},
+	{
+		name:       "brackets",
+		where:      "tournament_id = {:tournament_id}",
+		references: map[string]string{"tournament_id": "tournaments"},
+	},
+	{
+		name:  "bracket_matches",
+		where: "tournament_id = {:tournament_id}",
+		references: map[string]string{
+			"tournament_id":       "tournaments",
+			"bracket_id":          "brackets",
+			"round_tournament_id": "tournaments",
+			"player_a_id":         "players",
+			"player_b_id":         "players",
+			"winner_id":           "players",
+		},
+	},
+	{
+		name:       "audit_log",
+		where:      "tournament_id = {:tournament_id}",
+		references: map[string]string{"tournament_id": "tournaments"},
+	},
+}
+
+// ArchiveTableNames lists the tables a bundle holds, in restore order.
+func ArchiveTableNames() []string {
+	names := []string{}
+	for _, table := range archiveTables {
+		names = append(names, table.name)
+	}
+
+	return names
+}
+
+func GetTournamentArchive(db dbx.Builder, tournamentId string) (*TournamentArchive, error) {
+	archive := TournamentArchive{
+		Version:      ARCHIVE_VERSION,
+		ExportedAt:   time.Now().Format(time.RFC3339),
+		TournamentId: tournamentId,
+		Records:      make(map[string][]ArchiveRecord),
+	}
+
+	for _, table := range archiveTables {
+		records, err := queryArchiveRecords(db, fmt.Sprintf("SELECT * FROM %s WHERE %s", table.name, table.where), dbx.Params{
+			"tournament_id": tournamentId,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", table.name, err)
+		}
+		archive.Records[table.name] = records
+	}
+
+	if len(archive.Records["tournaments"]) != 1 {
+		return nil, fmt.Errorf("tournament %s not found", tournamentId)
+	}
+
+	return &archive, nil
+}
+
+// RestoreTournamentArchive inserts a bundle and returns the new tournament
+// id. Tournament rows get fresh ids unless preserveIds is set, which is
+// meant for moving an event between instances or loading fixtures. columns
+// holds the columns each table defines; a record with any other is
+// rejected, since column names go into the insert as they are.
+func RestoreTournamentArchive(db dbx.Builder, archive TournamentArchive, preserveIds bool, columns map[string][]string) (string, error) {
+	if archive.Version != ARCHIVE_VERSION {
+		return "", fmt.Errorf("unsupported archive version %d", archive.Version)
+	}
+	if len(archive.Records["tournaments"]) != 1 {
+		return "", fmt.Errorf("archive must contain exactly one tournament")
+	}
+
+	for _, table := range archiveTables {
+		known := make(map[string]bool)
+		for _, column := range columns[table.name] {
+			known[column] = true
+		}
+
+		for _, record := range archive.Records[table.name] {
+			for column := range record {
+				if !known[column] {
+					return "", fmt.Errorf("%s has no column %q", table.name, column)
+				}
+			}
+		}
+	}
+
+	ids := make(map[string]map[string]string)
+	for _, table := range archiveTables {
+		ids[table.name] = make(map[string]string)
+
+		for _, record := range archive.Records[table.name] {
+			oldId, _ := record["id"].(string)
+
+			if table.shared {
+				newId, err := restoreSharedRecord(db, table.name, record)
+				if err != nil {
+					return "", fmt.Errorf("%s %s: %w", table.name, oldId, err)
+				}
+				ids[table.name][oldId] = newId
+				continue
+			}
+
+			row := dbx.Params{}
+			for column, value := range record {
+				row[column] = value
+			}
+
+			newId := oldId
+			if !preserveIds {
+				newId = newArchiveId(table.name)
+			}
+			row["id"] = newId
+
+			for column, referenced := range table.references {
+				if value, ok := row[column].(string); ok {
+					if mapped, ok := ids[referenced][value]; ok {
+						row[column] = mapped
+					}
+				}
+			}
+
+			_, err := db.Insert(table.name, row).Execute()
+			if err != nil {
+				return "", fmt.Errorf("%s %s: %w", table.name, oldId, err)
+			}
+			ids[table.name][oldId] = newId
+		}
+	}
+
+	return ids["tournaments"][archive.TournamentId], nil
+}
+
+// restoreSharedRecord reuses a row that already exists, matching players on
+// external id as well, and inserts it as-is otherwise.
+func restoreSharedRecord(db dbx.Builder, table string, record ArchiveRecord) (string, error) {
+	id, _ := record["id"].(string)
+
+	var existing struct {
+		Id string `db:"id"`
+	}
+
+	err := db.
+		NewQuery(fmt.Sprintf("SELECT id FROM %s WHERE id = {:id}", table)).
+		Bind(dbx.Params{"id": id}).
+		One(&existing)
+	if err == nil {
+		return existing.Id, nil
+	}
+
+	if externalId, ok := record["external_id"].(string); ok && table == "players" && len(externalId) > 0 {
+		err = db.
+			NewQuery("SELECT id FROM players WHERE external_id = {:external_id}").
+			Bind(dbx.Params{"external_id": externalId}).
+			One(&existing)
+		if err == nil {
+			return existing.Id, nil
+		}
+	}
+
+	_, err = db.Insert(table, dbx.Params(record)).Execute()
+	if err != nil {
+		return "", err
+	}
+
+	return id, nil
+}
+
+func newArchiveId(table string) string {
+	// team ids double as the short join code players type in
+	if table == "teams" {
+		return strings.ToLower(security.RandomString(6))
+	}
+
+	return strings.ToLower(security.RandomString(15))
+}
+
+func queryArchiveRecords(db dbx.Builder, query string, params dbx.Params) ([]ArchiveRecord, error) {
+	rows, err := db.NewQuery(query).Bind(params).Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+
+	records := []ArchiveRecord{}
+	for rows.Next() {
+		values := make([]any, len(columns))
+		pointers := make([]any, len(columns))
+		for i := range values {
+			pointers[i] = &values[i]
+		}
+
+		err = rows.Scan(pointers...)
+		if err != nil {
+			return nil, err
+		}
+
+		record := ArchiveRecord{}
+		for i, column := range columns {
+			if bytes, ok := values[i].([]byte); ok {
+				record[column] = string(bytes)
+			} else {
+				record[column] = values[i]
+			}
+		}
+		records = append(records, record)
+	}
+
+	return records, rows.Err()
+}
@@ -0,0 +1,69 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/pocketbase/dbx"
+)
+
+type AuditLog struct {
+	Id           string `db:"id" json:"id"`
+	TournamentId string `db:"tournament_id" json:"tournamentId"`
+	Action       string `db:"action" json:"action"`
+	Actor        string `db:"actor" json:"actor"`
+	Data         string `db:"data" json:"data"`
+	Created      string `db:"created" json:"created"`
+}
+
+func CreateAuditLog(db dbx.Builder, tournamentId string, action string, actor string, data any) (*AuditLog, error) {
+	var auditLog AuditLog
+
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.
+		NewQuery(`
+		INSERT INTO audit_log (tournament_id, action, actor, data, created, updated)
+		VALUES ({:tournament_id}, {:action}, {:actor}, {:data}, {:created}, {:updated})
+		RETURNING *
+	`).
+		Bind(dbx.Params{
+			"tournament_id": tournamentId,
+			"action":        action,
+			"actor":         actor,
+			"data":          string(payload),
+			"created":       time.Now().Format(time.RFC3339),
+			"updated":       time.Now().Format(time.RFC3339),
+		}).
+		One(&auditLog)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &auditLog, nil
+}
+
+func GetAuditLogByTournament(db dbx.Builder, tournamentId string) (*[]AuditLog, error) {
+	auditLogs := []AuditLog{}
+
+	err := db.
+		NewQuery(`
+			SELECT * FROM audit_log
+			WHERE tournament_id = {:tournament_id}
+			ORDER BY created
+		`).
+		Bind(dbx.Params{
+			"tournament_id": tournamentId,
+		}).
+		All(&auditLogs)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &auditLogs, nil
+}
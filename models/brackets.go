@@ -0,0 +1,386 @@
+package models
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/pocketbase/dbx"
+)
+
+const (
+	BRACKET_SEEDING_HANDICAP   = "handicap"
+	BRACKET_SEEDING_QUALIFYING = "qualifying"
+
+	// how a bracket match was decided
+	BRACKET_RESULT_PLAYED    = "played"
+	BRACKET_RESULT_BYE       = "bye"
+	BRACKET_RESULT_DEFAULT   = "default"
+	BRACKET_RESULT_COMMITTEE = "committee"
+
+	DEFAULT_BRACKET_ROUND_DAYS = 7
+)
+
+// Bracket is a knockout draw. Its size is the number of slots in the first
+// round, a power of two, with byes filling the slots left over.
+type Bracket struct {
+	Id           string `db:"id" json:"id"`
+	TournamentId string `db:"tournament_id" json:"tournamentId"`
+	Name         string `db:"name" json:"name"`
+	Seeding      string `db:"seeding" json:"seeding"`
+	Scoring      string `db:"scoring" json:"scoring"`
+	Size         int    `db:"size" json:"size"`
+	StartDate    string `db:"start_date" json:"startDate"`
+	RoundDays    int    `db:"round_days" json:"roundDays"`
+}
+
+// BracketMatch is a slot in the draw. Later rounds fill in as the matches
+// feeding them close. RoundTournamentId is the round the match is played
+// in, whose hole scores decide it.
+type BracketMatch struct {
+	Id                string `db:"id" json:"id"`
+	TournamentId      string `db:"tournament_id" json:"tournamentId"`
+	BracketId         string `db:"bracket_id" json:"bracketId"`
+	RoundTournamentId string `db:"round_tournament_id" json:"roundTournamentId"`
+	Round             int    `db:"round" json:"round"`
+	Position          int    `db:"position" json:"position"`
+	PlayerAId         string `db:"player_a_id" json:"playerAId"`
+	PlayerAName       string `db:"player_a_name" json:"playerAName"`
+	SeedA             int    `db:"seed_a" json:"seedA"`
+	PlayerBId         string `db:"player_b_id" json:"playerBId"`
+	PlayerBName       string `db:"player_b_name" json:"playerBName"`
+	SeedB             int    `db:"seed_b" json:"seedB"`
+	Deadline          string `db:"deadline" json:"deadline"`
+	WinnerId          string `db:"winner_id" json:"winnerId"`
+	Result            string `db:"result" json:"result"`
+	Score             string `db:"score" json:"score"`
+	// Holes maps a hole number to the side that won it, or halved.
+	Holes map[int]string `db:"-" json:"holes"`
+}
+
+type BracketCreate struct {
+	Name    string `json:"name,omitempty"`
+	Seeding string `json:"seeding"`
+	// Scoring decides holes on net or gross scores, defaulting to net.
+	Scoring string `json:"scoring,omitempty"`
+	// QualifyingTournamentId seeds from another tournament's stroke play,
+	// defaulting to this one.
+	QualifyingTournamentId string   `json:"qualifyingTournamentId,omitempty"`
+	PlayerIds              []string `json:"playerIds,omitempty"`
+	StartDate              string   `json:"startDate,omitempty"`
+	RoundDays              int      `json:"roundDays,omitempty"`
+}
+
+// BracketSlot puts a seeded player, or no one for a bye, in a first round
+// slot.
+type BracketSlot struct {
+	PlayerId string
+	Seed     int
+}
+
+type BracketMatchUpdate struct {
+	WinnerId          *string `json:"winnerId,omitempty"`
+	Score             *string `json:"score,omitempty"`
+	Deadline          *string `json:"deadline,omitempty"`
+	RoundTournamentId *string `json:"roundTournamentId,omitempty"`
+}
+
+func GetBrackets(db dbx.Builder, tournamentId string) (*[]Bracket, error) {
+	brackets := []Bracket{}
+
+	err := db.
+		NewQuery(`
+			SELECT * FROM brackets
+			WHERE tournament_id = {:tournament_id}
+			ORDER BY created
+		`).
+		Bind(dbx.Params{
+			"tournament_id": tournamentId,
+		}).
+		All(&brackets)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &brackets, nil
+}
+
+// GetBracketMatches loads the matches of a tournament's brackets in draw
+// order. Their holes are left for the caller to decide from each match's
+// round.
+func GetBracketMatches(db dbx.Builder, tournamentId string) (*[]BracketMatch, error) {
+	matches := []BracketMatch{}
+
+	err := db.
+		NewQuery(`
+			SELECT
+				bracket_matches.*,
+				COALESCE(player_a.name, '') AS player_a_name,
+				COALESCE(player_b.name, '') AS player_b_name
+			FROM bracket_matches
+			LEFT JOIN players AS player_a ON player_a.id = bracket_matches.player_a_id
+			LEFT JOIN players AS player_b ON player_b.id = bracket_matches.player_b_id
+			WHERE bracket_matches.tournament_id = {:tournament_id}
+			ORDER BY bracket_matches.bracket_id, bracket_matches.round, bracket_matches.position
+		`).
+		Bind(dbx.Params{
+			"tournament_id": tournamentId,
+		}).
+		All(&matches)
+
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range matches {
+		matches[i].Holes = make(map[int]string)
+	}
+
+	return &matches, nil
+}
+
+// CreateBracket draws a bracket with every match of every round, the first
+// round filled from slots taken in pairs.
+func CreateBracket(db dbx.Builder, tournamentId string, data BracketCreate, slots []BracketSlot) (*Bracket, error) {
+	var bracket Bracket
+
+	err := db.
+		NewQuery(`
+		INSERT INTO brackets (tournament_id, name, seeding, scoring, size, start_date, round_days, created, updated)
+		VALUES ({:tournament_id}, {:name}, {:seeding}, {:scoring}, {:size}, {:start_date}, {:round_days}, {:created}, {:updated})
+		RETURNING *
+	`).
+		Bind(dbx.Params{
+			"tournament_id": tournamentId,
+			"name":          data.Name,
+			"seeding":       data.Seeding,
+			"scoring":       data.Scoring,
+			"size":          len(slots),
+			"start_date":    data.StartDate,
+			"round_days":    data.RoundDays,
+			"created":       time.Now().Format(time.RFC3339),
+			"updated":       time.Now().Format(time.RFC3339),
+		}).
+		One(&bracket)
+
+	if err != nil {
+		return nil, err
+	}
+
+	deadline, err := time.Parse(time.DateOnly, data.StartDate)
+	hasDeadlines := err == nil
+
+	round := 1
+	for matches := len(slots) / 2; matches >= 1; matches /= 2 {
+		roundDeadline := ""
+		if hasDeadlines {
+			roundDeadline = deadline.AddDate(0, 0, round*data.RoundDays).Format(time.DateOnly)
+		}
+
+		for position := 0; position < matches; position++ {
+			params := dbx.Params{
+				"tournament_id": tournamentId,
+				"bracket_id":    bracket.Id,
+				"round":         round,
+				"position":      position,
+				"player_a_id":   "",
+				"seed_a":        0,
+				"player_b_id":   "",
+				"seed_b":        0,
+				"deadline":      roundDeadline,
+				"created":       time.Now().Format(time.RFC3339),
+				"updated":       time.Now().Format(time.RFC3339),
+			}
+			if round == 1 {
+				a, b := slots[position*2], slots[position*2+1]
+				params["player_a_id"], params["seed_a"] = a.PlayerId, a.Seed
+				params["player_b_id"], params["seed_b"] = b.PlayerId, b.Seed
+			}
+
+			_, err = db.
+				NewQuery(`
+				INSERT INTO bracket_matches (
+					tournament_id, bracket_id, round_tournament_id, round, position, player_a_id, seed_a, player_b_id, seed_b,
+					deadline, winner_id, result, score, created, updated
+				)
+				VALUES (
+					{:tournament_id}, {:bracket_id}, '', {:round}, {:position}, {:player_a_id}, {:seed_a}, {:player_b_id}, {:seed_b},
+					{:deadline}, '', '', '', {:created}, {:updated}
+				)
+			`).
+				Bind(params).
+				Execute()
+
+			if err != nil {
+				return nil, err
+			}
+		}
+		round++
+	}
+
+	return &bracket, nil
+}
+
+// DeleteBracket removes a bracket with its matches, reporting whether it
+// existed.
+func DeleteBracket(db dbx.Builder, tournamentId string, id string) (bool, error) {
+	result, err := db.
+		NewQuery("DELETE FROM brackets WHERE id = {:id} AND tournament_id = {:tournament_id}").
+		Bind(dbx.Params{
+			"id":            id,
+			"tournament_id": tournamentId,
+		}).
+		Execute()
+
+	if err != nil {
+		return false, err
+	}
+
+	deleted, err := result.RowsAffected()
+	if err != nil || deleted == 0 {
+		return false, err
+	}
+
+	_, err = db.
+		NewQuery("DELETE FROM bracket_matches WHERE bracket_id = {:id}").
+		Bind(dbx.Params{
+			"id": id,
+		}).
+		Execute()
+
+	return err == nil, err
+}
+
+// CloseBracketMatch records a match's winner. An empty winner reopens it.
+func CloseBracketMatch(db dbx.Builder, matchId string, winnerId string, result string, score string) error {
+	_, err := db.
+		NewQuery(`
+		UPDATE bracket_matches
+		SET winner_id = {:winner_id}, result = {:result}, score = {:score}, updated = {:updated}
+		WHERE id = {:id}
+	`).
+		Bind(dbx.Params{
+			"id":        matchId,
+			"winner_id": winnerId,
+			"result":    result,
+			"score":     score,
+			"updated":   time.Now().Format(time.RFC3339),
+		}).
+		Execute()
+
+	return err
+}
+
+// SetBracketMatchPlayer fills one side of a match in the draw.
+func SetBracketMatchPlayer(db dbx.Builder, bracketId string, round int, position int, side string, playerId string, seed int) error {
+	if side != MATCH_SIDE_A && side != MATCH_SIDE_B {
+		return fmt.Errorf("unknown side %q", side)
+	}
+
+	query := fmt.Sprintf(`
+		UPDATE bracket_matches
+		SET player_%s_id = {:player_id}, seed_%s = {:seed}, updated = {:updated}
+		WHERE bracket_id = {:bracket_id} AND round = {:round} AND position = {:position}
+	`, side, side)
+
+	_, err := db.
+		NewQuery(query).
+		Bind(dbx.Params{
+			"bracket_id": bracketId,
+			"round":      round,
+			"position":   position,
+			"player_id":  playerId,
+			"seed":       seed,
+			"updated":    time.Now().Format(time.RFC3339),
+		}).
+		Execute()
+
+	return err
+}
+
+func UpdateBracketMatchDeadline(db dbx.Builder, matchId string, deadline string) error {
+	_, err := db.
+		NewQuery("UPDATE bracket_matches SET deadline = {:deadline}, updated = {:updated} WHERE id = {:id}").
+		Bind(dbx.Params{
+			"id":       matchId,
+			"deadline": deadline,
+			"updated":  time.Now().Format(time.RFC3339),
+		}).
+		Execute()
+
+	return err
+}
+
+// SetBracketMatchRound links a match to the round it is played in. An empty
+// id unlinks it.
+func SetBracketMatchRound(db dbx.Builder, matchId string, roundTournamentId string) error {
+	_, err := db.
+		NewQuery("UPDATE bracket_matches SET round_tournament_id = {:round_tournament_id}, updated = {:updated} WHERE id = {:id}").
+		Bind(dbx.Params{
+			"id":                  matchId,
+			"round_tournament_id": roundTournamentId,
+			"updated":             time.Now().Format(time.RFC3339),
+		}).
+		Execute()
+
+	return err
+}
+
+// GetBracketTournamentsByRound lists the tournaments with an open match
+// played in the given round.
+func GetBracketTournamentsByRound(db dbx.Builder, roundTournamentId string) ([]string, error) {
+	rows := []struct {
+		TournamentId string `db:"tournament_id"`
+	}{}
+
+	err := db.
+		NewQuery(`
+			SELECT DISTINCT tournament_id FROM bracket_matches
+			WHERE round_tournament_id = {:round_tournament_id} AND winner_id = ''
+		`).
+		Bind(dbx.Params{
+			"round_tournament_id": roundTournamentId,
+		}).
+		All(&rows)
+
+	if err != nil {
+		return nil, err
+	}
+
+	tournamentIds := []string{}
+	for _, row := range rows {
+		tournamentIds = append(tournamentIds, row.TournamentId)
+	}
+
+	return tournamentIds, nil
+}
+
+// GetOverdueBracketTournaments lists the tournaments with an open match
+// whose deadline has passed.
+func GetOverdueBracketTournaments(db dbx.Builder, today string) ([]string, error) {
+	rows := []struct {
+		TournamentId string `db:"tournament_id"`
+	}{}
+
+	err := db.
+		NewQuery(`
+			SELECT DISTINCT tournament_id FROM bracket_matches
+			WHERE deadline != '' AND deadline < {:today}
+			AND winner_id = '' AND player_a_id != '' AND player_b_id != ''
+		`).
+		Bind(dbx.Params{
+			"today": today,
+		}).
+		All(&rows)
+
+	if err != nil {
+		return nil, err
+	}
+
+	tournamentIds := []string{}
+	for _, row := range rows {
+		tournamentIds = append(tournamentIds, row.TournamentId)
+	}
+
+	return tournamentIds, nil
+}
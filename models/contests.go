@@ -0,0 +1,229 @@
+package models
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/pocketbase/dbx"
+)
+
+const (
+	CONTEST_KIND_CLOSEST_TO_PIN = "closest_to_pin"
+	CONTEST_KIND_LONG_DRIVE     = "long_drive"
+)
+
+type Contest struct {
+	Id           string `db:"id" json:"id"`
+	TournamentId string `db:"tournament_id" json:"tournamentId"`
+	Hole         int    `db:"hole" json:"hole"`
+	Kind         string `db:"kind" json:"kind"`
+	Name         string `db:"name" json:"name"`
+	Unit         string `db:"unit" json:"unit"`
+}
+
+type ContestCreate struct {
+	Hole int    `json:"hole"`
+	Kind string `json:"kind"`
+	Name string `json:"name,omitempty"`
+	Unit string `json:"unit,omitempty"`
+}
+
+// ContestEntry is one measured shot. Override entries are set by the
+// committee and win over anything measured on the course.
+type ContestEntry struct {
+	Id           string  `db:"id" json:"id"`
+	ContestId    string  `db:"contest_id" json:"contestId"`
+	TournamentId string  `db:"tournament_id" json:"tournamentId"`
+	TeamId       string  `db:"team_id" json:"teamId"`
+	PlayerId     string  `db:"player_id" json:"playerId"`
+	PlayerName   string  `db:"player_name" json:"playerName"`
+	Distance     float64 `db:"distance" json:"distance"`
+	Override     bool    `db:"override" json:"override"`
+	Voided       bool    `db:"voided" json:"voided"`
+	Note         string  `db:"note" json:"note"`
+	Created      string  `db:"created" json:"created"`
+}
+
+type ContestEntryCreate struct {
+	ContestId    string
+	TournamentId string
+	TeamId       string
+	PlayerId     string
+	Distance     float64
+	Override     bool
+	Note         string
+}
+
+func GetContests(db dbx.Builder, tournamentId string) (*[]Contest, error) {
+	contests := []Contest{}
+
+	err := db.
+		NewQuery(`
+			SELECT * FROM contests
+			WHERE tournament_id = {:tournament_id}
+			ORDER BY hole, kind
+		`).
+		Bind(dbx.Params{
+			"tournament_id": tournamentId,
+		}).
+		All(&contests)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &contests, nil
+}
+
+func GetContest(db dbx.Builder, tournamentId string, id string) (*Contest, error) {
+	var contest Contest
+
+	err := db.
+		NewQuery("SELECT * FROM contests WHERE id = {:id} AND tournament_id = {:tournament_id}").
+		Bind(dbx.Params{
+			"id":            id,
+			"tournament_id": tournamentId,
+		}).
+		One(&contest)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &contest, nil
+}
+
+func CreateContest(db dbx.Builder, tournamentId string, data ContestCreate) (*Contest, error) {
+	var contest Contest
+
+	err := db.
+		NewQuery(`
+		INSERT INTO contests (tournament_id, hole, kind, name, unit, created, updated)
+		VALUES ({:tournament_id}, {:hole}, {:kind}, {:name}, {:unit}, {:created}, {:updated})
+		RETURNING *
+	`).
+		Bind(dbx.Params{
+			"tournament_id": tournamentId,
+			"hole":          data.Hole,
+			"kind":          data.Kind,
+			"name":          data.Name,
+			"unit":          data.Unit,
+			"created":       time.Now().Format(time.RFC3339),
+			"updated":       time.Now().Format(time.RFC3339),
+		}).
+		One(&contest)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &contest, nil
+}
+
+func DeleteContest(db dbx.Builder, id string) error {
+	_, err := db.
+		NewQuery("DELETE FROM contest_entries WHERE contest_id = {:id}").
+		Bind(dbx.Params{
+			"id": id,
+		}).
+		Execute()
+	if err != nil {
+		return err
+	}
+
+	_, err = db.
+		NewQuery("DELETE FROM contests WHERE id = {:id}").
+		Bind(dbx.Params{
+			"id": id,
+		}).
+		Execute()
+
+	return err
+}
+
+// GetContestEntries lists every entry in the tournament's contests, oldest
+// first.
+func GetContestEntries(db dbx.Builder, tournamentId string) (*[]ContestEntry, error) {
+	entries := []ContestEntry{}
+
+	err := db.
+		NewQuery(`
+			SELECT contest_entries.*, players.name AS player_name
+			FROM contest_entries
+			JOIN players ON players.id = contest_entries.player_id
+			WHERE contest_entries.tournament_id = {:tournament_id}
+			ORDER BY contest_entries.created, contest_entries.rowid
+		`).
+		Bind(dbx.Params{
+			"tournament_id": tournamentId,
+		}).
+		All(&entries)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &entries, nil
+}
+
+func CreateContestEntry(db dbx.Builder, data ContestEntryCreate) (*ContestEntry, error) {
+	var entry ContestEntry
+
+	err := db.
+		NewQuery(`
+		INSERT INTO contest_entries (contest_id, tournament_id, team_id, player_id, distance, override, voided, note, created, updated)
+		VALUES ({:contest_id}, {:tournament_id}, {:team_id}, {:player_id}, {:distance}, {:override}, {:voided}, {:note}, {:created}, {:updated})
+		RETURNING *
+	`).
+		Bind(dbx.Params{
+			"contest_id":    data.ContestId,
+			"tournament_id": data.TournamentId,
+			"team_id":       data.TeamId,
+			"player_id":     data.PlayerId,
+			"distance":      data.Distance,
+			"override":      data.Override,
+			"voided":        false,
+			"note":          data.Note,
+			"created":       time.Now().Format(time.RFC3339),
+			"updated":       time.Now().Format(time.RFC3339),
+		}).
+		One(&entry)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &entry, nil
+}
+
+// VoidContestEntry strikes an entry from a contest, or restores it. Entries
+// are never deleted so the history of a contest stays visible.
+func VoidContestEntry(db dbx.Builder, contestId string, entryId string, voided bool) error {
+	result, err := db.
+		NewQuery(`
+			UPDATE contest_entries
+			SET voided = {:voided}, updated = {:updated}
+			WHERE id = {:id} AND contest_id = {:contest_id}
+		`).
+		Bind(dbx.Params{
+			"id":         entryId,
+			"contest_id": contestId,
+			"voided":     voided,
+			"updated":    time.Now().Format(time.RFC3339),
+		}).
+		Execute()
+
+	if err != nil {
+		return err
+	}
+
+	updated, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if updated == 0 {
+		return fmt.Errorf("entry %s not found", entryId)
+	}
+
+	return nil
+}
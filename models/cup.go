@@ -0,0 +1,350 @@
+package models
+
+import (
+	"time"
+
+	"github.com/pocketbase/dbx"
+)
+
+const (
+	CUP_FORMAT_FOURBALLS = "fourballs"
+	CUP_FORMAT_FOURSOMES = "foursomes"
+	CUP_FORMAT_SINGLES   = "singles"
+
+	// match play holes, in cups and brackets alike, are won by a side or
+	// halved
+	MATCH_SIDE_A = "a"
+	MATCH_SIDE_B = "b"
+
+	MATCH_HOLE_HALVED = "halved"
+)
+
+// CupSession is one round of matches between the two sides of a cup. Its
+// matches are decided from the scores posted in RoundTournamentId, the cup's
+// own tournament unless the session is played in another.
+type CupSession struct {
+	Id                string `db:"id" json:"id"`
+	TournamentId      string `db:"tournament_id" json:"tournamentId"`
+	RoundTournamentId string `db:"round_tournament_id" json:"roundTournamentId"`
+	Name              string `db:"name" json:"name"`
+	Format            string `db:"format" json:"format"`
+	Position          int    `db:"position" json:"position"`
+}
+
+type CupMatch struct {
+	Id        string           `db:"id" json:"id"`
+	SessionId string           `db:"session_id" json:"sessionId"`
+	Number    int              `db:"number" json:"number"`
+	SideA     []CupMatchPlayer `db:"-" json:"sideA"`
+	SideB     []CupMatchPlayer `db:"-" json:"sideB"`
+	// Holes maps a hole number to the side that won it, or halved.
+	Holes map[int]string `db:"-" json:"holes"`
+	// Overrides are the holes the committee decided by hand, which stand
+	// over the scores.
+	Overrides map[int]string `db:"-" json:"overrides"`
+}
+
+type CupMatchPlayer struct {
+	MatchId  string `db:"match_id" json:"-"`
+	Side     string `db:"side" json:"-"`
+	TeamId   string `db:"team_id" json:"teamId"`
+	PlayerId string `db:"player_id" json:"playerId"`
+	Name     string `db:"name" json:"name"`
+}
+
+type CupMatchCreate struct {
+	SideA []string `json:"sideA"`
+	SideB []string `json:"sideB"`
+}
+
+type CupSessionCreate struct {
+	Name              string           `json:"name,omitempty"`
+	Format            string           `json:"format"`
+	RoundTournamentId string           `json:"roundTournamentId,omitempty"`
+	Matches           []CupMatchCreate `json:"matches,omitempty"`
+}
+
+// MatchHoleUpdate records who won a hole. An empty winner clears it.
+type MatchHoleUpdate struct {
+	Number int    `json:"number"`
+	Winner string `json:"winner"`
+}
+
+func GetCupSessions(db dbx.Builder, tournamentId string) (*[]CupSession, error) {
+	sessions := []CupSession{}
+
+	err := db.
+		NewQuery(`
+			SELECT * FROM cup_sessions
+			WHERE tournament_id = {:tournament_id}
+			ORDER BY position
+		`).
+		Bind(dbx.Params{
+			"tournament_id": tournamentId,
+		}).
+		All(&sessions)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &sessions, nil
+}
+
+// GetCupMatches loads every match in a tournament's cup with its players
+// and the holes the committee has decided. The rest are left for the caller
+// to decide from the session's round.
+func GetCupMatches(db dbx.Builder, tournamentId string) (*[]CupMatch, error) {
+	matches := []CupMatch{}
+
+	err := db.
+		NewQuery(`
+			SELECT * FROM cup_matches
+			WHERE tournament_id = {:tournament_id}
+			ORDER BY session_id, number
+		`).
+		Bind(dbx.Params{
+			"tournament_id": tournamentId,
+		}).
+		All(&matches)
+
+	if err != nil {
+		return nil, err
+	}
+
+	players := []CupMatchPlayer{}
+	err = db.
+		NewQuery(`
+			SELECT
+				cup_match_players.match_id AS match_id,
+				cup_match_players.side AS side,
+				cup_match_players.team_id AS team_id,
+				cup_match_players.player_id AS player_id,
+				COALESCE(players.name, '') AS name
+			FROM cup_match_players
+			LEFT JOIN players ON players.id = cup_match_players.player_id
+			WHERE cup_match_players.tournament_id = {:tournament_id}
+			ORDER BY cup_match_players.rowid
+		`).
+		Bind(dbx.Params{
+			"tournament_id": tournamentId,
+		}).
+		All(&players)
+
+	if err != nil {
+		return nil, err
+	}
+
+	holes := []struct {
+		MatchId string `db:"match_id"`
+		Number  int    `db:"number"`
+		Winner  string `db:"winner"`
+	}{}
+	err = db.
+		NewQuery(`
+			SELECT match_id, number, winner FROM cup_match_holes
+			WHERE tournament_id = {:tournament_id}
+		`).
+		Bind(dbx.Params{
+			"tournament_id": tournamentId,
+		}).
+		All(&holes)
+
+	if err != nil {
+		return nil, err
+	}
+
+	byMatch := make(map[string]*CupMatch)
+	for i := range matches {
+		matches[i].SideA = []CupMatchPlayer{}
+		matches[i].SideB = []CupMatchPlayer{}
+		matches[i].Holes = make(map[int]string)
+		matches[i].Overrides = make(map[int]string)
+		byMatch[matches[i].Id] = &matches[i]
+	}
+	for _, player := range players {
+		match, ok := byMatch[player.MatchId]
+		if !ok {
+			continue
+		}
+		if player.Side == MATCH_SIDE_A {
+			match.SideA = append(match.SideA, player)
+		} else {
+			match.SideB = append(match.SideB, player)
+		}
+	}
+	for _, hole := range holes {
+		if match, ok := byMatch[hole.MatchId]; ok {
+			match.Overrides[hole.Number] = hole.Winner
+		}
+	}
+
+	return &matches, nil
+}
+
+// CreateCupSession adds a session after the existing ones along with its
+// matches. sideTeams holds the team each side's players belong to.
+func CreateCupSession(db dbx.Builder, tournamentId string, data CupSessionCreate, sideTeams map[string]string) (*CupSession, error) {
+	var session CupSession
+
+	err := db.
+		NewQuery(`
+		INSERT INTO cup_sessions (tournament_id, round_tournament_id, name, format, position, created, updated)
+		VALUES (
+			{:tournament_id}, {:round_tournament_id}, {:name}, {:format},
+			(SELECT COALESCE(MAX(position), 0) + 1 FROM cup_sessions WHERE tournament_id = {:tournament_id}),
+			{:created}, {:updated}
+		)
+		RETURNING *
+	`).
+		Bind(dbx.Params{
+			"tournament_id":       tournamentId,
+			"round_tournament_id": data.RoundTournamentId,
+			"name":                data.Name,
+			"format":              data.Format,
+			"created":             time.Now().Format(time.RFC3339),
+			"updated":             time.Now().Format(time.RFC3339),
+		}).
+		One(&session)
+
+	if err != nil {
+		return nil, err
+	}
+
+	for i, match := range data.Matches {
+		var created CupMatch
+
+		err = db.
+			NewQuery(`
+			INSERT INTO cup_matches (tournament_id, session_id, number, created, updated)
+			VALUES ({:tournament_id}, {:session_id}, {:number}, {:created}, {:updated})
+			RETURNING id, session_id, number
+		`).
+			Bind(dbx.Params{
+				"tournament_id": tournamentId,
+				"session_id":    session.Id,
+				"number":        i + 1,
+				"created":       time.Now().Format(time.RFC3339),
+				"updated":       time.Now().Format(time.RFC3339),
+			}).
+			One(&created)
+
+		if err != nil {
+			return nil, err
+		}
+
+		for side, playerIds := range map[string][]string{MATCH_SIDE_A: match.SideA, MATCH_SIDE_B: match.SideB} {
+			for _, playerId := range playerIds {
+				_, err = db.
+					NewQuery(`
+					INSERT INTO cup_match_players (tournament_id, match_id, side, team_id, player_id, created, updated)
+					VALUES ({:tournament_id}, {:match_id}, {:side}, {:team_id}, {:player_id}, {:created}, {:updated})
+				`).
+					Bind(dbx.Params{
+						"tournament_id": tournamentId,
+						"match_id":      created.Id,
+						"side":          side,
+						"team_id":       sideTeams[side],
+						"player_id":     playerId,
+						"created":       time.Now().Format(time.RFC3339),
+						"updated":       time.Now().Format(time.RFC3339),
+					}).
+					Execute()
+
+				if err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+
+	return &session, nil
+}
+
+// DeleteCupSession removes a session with its matches and their results,
+// reporting whether it existed.
+func DeleteCupSession(db dbx.Builder, tournamentId string, id string) (bool, error) {
+	result, err := db.
+		NewQuery("DELETE FROM cup_sessions WHERE id = {:id} AND tournament_id = {:tournament_id}").
+		Bind(dbx.Params{
+			"id":            id,
+			"tournament_id": tournamentId,
+		}).
+		Execute()
+
+	if err != nil {
+		return false, err
+	}
+
+	deleted, err := result.RowsAffected()
+	if err != nil || deleted == 0 {
+		return false, err
+	}
+
+	for _, table := range []string{"cup_match_holes", "cup_match_players"} {
+		_, err = db.
+			NewQuery("DELETE FROM " + table + " WHERE match_id IN (SELECT id FROM cup_matches WHERE session_id = {:id})").
+			Bind(dbx.Params{
+				"id": id,
+			}).
+			Execute()
+
+		if err != nil {
+			return false, err
+		}
+	}
+
+	_, err = db.
+		NewQuery("DELETE FROM cup_matches WHERE session_id = {:id}").
+		Bind(dbx.Params{
+			"id": id,
+		}).
+		Execute()
+
+	return err == nil, err
+}
+
+// SetCupMatchHoles records the committee's hole winners on a match,
+// clearing the overrides given without one.
+func SetCupMatchHoles(db dbx.Builder, tournamentId string, matchId string, holes []MatchHoleUpdate) error {
+	for _, hole := range holes {
+		if hole.Winner == "" {
+			_, err := db.
+				NewQuery("DELETE FROM cup_match_holes WHERE match_id = {:match_id} AND number = {:number}").
+				Bind(dbx.Params{
+					"match_id": matchId,
+					"number":   hole.Number,
+				}).
+				Execute()
+
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		_, err := db.
+			NewQuery(`
+			INSERT INTO cup_match_holes (tournament_id, match_id, number, winner, created, updated)
+			VALUES ({:tournament_id}, {:match_id}, {:number}, {:winner}, {:created}, {:updated})
+			ON CONFLICT (match_id, number) DO UPDATE SET
+				winner = excluded.winner,
+				updated = excluded.updated
+		`).
+			Bind(dbx.Params{
+				"tournament_id": tournamentId,
+				"match_id":      matchId,
+				"number":        hole.Number,
+				"winner":        hole.Winner,
+				"created":       time.Now().Format(time.RFC3339),
+				"updated":       time.Now().Format(time.RFC3339),
+			}).
+			Execute()
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,302 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pocketbase/dbx"
+)
+
+const (
+	// FLIGHT_KIND_HANDICAP flights split the field by handicap index. Every
+	// player lands in exactly one of them.
+	FLIGHT_KIND_HANDICAP = "handicap"
+	// FLIGHT_KIND_DIVISION divisions, like seniors or women, only hold the
+	// players assigned to them and may overlap the flights.
+	FLIGHT_KIND_DIVISION = "division"
+
+	FLIGHT_SCORING_GROSS = "gross"
+	FLIGHT_SCORING_NET   = "net"
+
+	FLIGHT_TIEBREAK_NONE = "none"
+	// FLIGHT_TIEBREAK_COUNTBACK compares the last 9, 6, 3 and final hole.
+	FLIGHT_TIEBREAK_COUNTBACK = "countback"
+)
+
+type Flight struct {
+	Id           string  `db:"id" json:"id"`
+	TournamentId string  `db:"tournament_id" json:"tournamentId"`
+	Name         string  `db:"name" json:"name"`
+	Kind         string  `db:"kind" json:"kind"`
+	MinHandicap  float64 `db:"min_handicap" json:"minHandicap"`
+	MaxHandicap  float64 `db:"max_handicap" json:"maxHandicap"`
+	Scoring      string  `db:"scoring" json:"scoring"`
+	Places       int     `db:"places" json:"places"`
+	Tiebreak     string  `db:"tiebreak" json:"tiebreak"`
+	// PlayerIds are the players assigned by hand. For handicap flights they
+	// win over the handicap range.
+	PlayerIds []string `db:"-" json:"playerIds"`
+}
+
+type FlightCreate struct {
+	Name        string   `json:"name"`
+	Kind        string   `json:"kind"`
+	MinHandicap float64  `json:"minHandicap"`
+	MaxHandicap float64  `json:"maxHandicap"`
+	Scoring     string   `json:"scoring,omitempty"`
+	Places      int      `json:"places,omitempty"`
+	Tiebreak    string   `json:"tiebreak,omitempty"`
+	PlayerIds   []string `json:"playerIds,omitempty"`
+}
+
+type FlightUpdate struct {
+	Name        *string   `json:"name,omitempty"`
+	MinHandicap *float64  `json:"minHandicap,omitempty"`
+	MaxHandicap *float64  `json:"maxHandicap,omitempty"`
+	Scoring     *string   `json:"scoring,omitempty"`
+	Places      *int      `json:"places,omitempty"`
+	Tiebreak    *string   `json:"tiebreak,omitempty"`
+	PlayerIds   *[]string `json:"playerIds,omitempty"`
+}
+
+// GetFlights lists a tournament's flights, handicap flights first from the
+// lowest range up, then divisions by name.
+func GetFlights(db dbx.Builder, tournamentId string) (*[]Flight, error) {
+	flights := []Flight{}
+
+	err := db.
+		NewQuery(`
+			SELECT * FROM flights
+			WHERE tournament_id = {:tournament_id}
+			ORDER BY kind = {:division}, min_handicap, name
+		`).
+		Bind(dbx.Params{
+			"tournament_id": tournamentId,
+			"division":      FLIGHT_KIND_DIVISION,
+		}).
+		All(&flights)
+
+	if err != nil {
+		return nil, err
+	}
+
+	assignments := []struct {
+		FlightId string `db:"flight_id"`
+		PlayerId string `db:"player_id"`
+	}{}
+	err = db.
+		NewQuery(`
+			SELECT flight_id, player_id FROM flight_players
+			WHERE tournament_id = {:tournament_id}
+			ORDER BY rowid
+		`).
+		Bind(dbx.Params{
+			"tournament_id": tournamentId,
+		}).
+		All(&assignments)
+
+	if err != nil {
+		return nil, err
+	}
+
+	byFlight := make(map[string]*Flight)
+	for i := range flights {
+		flights[i].PlayerIds = []string{}
+		byFlight[flights[i].Id] = &flights[i]
+	}
+	for _, assignment := range assignments {
+		if flight, ok := byFlight[assignment.FlightId]; ok {
+			flight.PlayerIds = append(flight.PlayerIds, assignment.PlayerId)
+		}
+	}
+
+	return &flights, nil
+}
+
+func GetFlight(db dbx.Builder, tournamentId string, id string) (*Flight, error) {
+	flights, err := GetFlights(db, tournamentId)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, flight := range *flights {
+		if flight.Id == id {
+			return &flight, nil
+		}
+	}
+
+	return nil, fmt.Errorf("flight %s not found", id)
+}
+
+func CreateFlight(db dbx.Builder, tournamentId string, data FlightCreate) (*Flight, error) {
+	var flight Flight
+
+	err := db.
+		NewQuery(`
+		INSERT INTO flights (tournament_id, name, kind, min_handicap, max_handicap, scoring, places, tiebreak, created, updated)
+		VALUES ({:tournament_id}, {:name}, {:kind}, {:min_handicap}, {:max_handicap}, {:scoring}, {:places}, {:tiebreak}, {:created}, {:updated})
+		RETURNING *
+	`).
+		Bind(dbx.Params{
+			"tournament_id": tournamentId,
+			"name":          data.Name,
+			"kind":          data.Kind,
+			"min_handicap":  data.MinHandicap,
+			"max_handicap":  data.MaxHandicap,
+			"scoring":       data.Scoring,
+			"places":        data.Places,
+			"tiebreak":      data.Tiebreak,
+			"created":       time.Now().Format(time.RFC3339),
+			"updated":       time.Now().Format(time.RFC3339),
+		}).
+		One(&flight)
+
+	if err != nil {
+		return nil, err
+	}
+
+	err = SetFlightPlayers(db, tournamentId, flight.Id, data.PlayerIds)
+	if err != nil {
+		return nil, err
+	}
+	flight.PlayerIds = data.PlayerIds
+
+	return &flight, nil
+}
+
+func UpdateFlight(db dbx.Builder, tournamentId string, id string, updates FlightUpdate) (*FlightUpdate, error) {
+	var setParts []string
+	params := dbx.Params{"id": id}
+
+	if updates.Name != nil {
+		params["name"] = *updates.Name
+		setParts = append(setParts, "name = {:name}")
+	}
+	if updates.MinHandicap != nil {
+		params["min_handicap"] = *updates.MinHandicap
+		setParts = append(setParts, "min_handicap = {:min_handicap}")
+	}
+	if updates.MaxHandicap != nil {
+		params["max_handicap"] = *updates.MaxHandicap
+		setParts = append(setParts, "max_handicap = {:max_handicap}")
+	}
+	if updates.Scoring != nil {
+		params["scoring"] = *updates.Scoring
+		setParts = append(setParts, "scoring = {:scoring}")
+	}
+	if updates.Places != nil {
+		params["places"] = *updates.Places
+		setParts = append(setParts, "places = {:places}")
+	}
+	if updates.Tiebreak != nil {
+		params["tiebreak"] = *updates.Tiebreak
+		setParts = append(setParts, "tiebreak = {:tiebreak}")
+	}
+
+	if updates.PlayerIds != nil {
+		err := SetFlightPlayers(db, tournamentId, id, *updates.PlayerIds)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if len(setParts) == 0 {
+		if updates.PlayerIds != nil {
+			return &updates, nil
+		}
+		return nil, fmt.Errorf("no fields to update")
+	}
+
+	setParts = append(setParts, "updated = {:updated}")
+	params["updated"] = time.Now().Format(time.RFC3339)
+
+	query := fmt.Sprintf(`
+		UPDATE flights
+		SET %s
+		WHERE id = {:id}
+	`, strings.Join(setParts, ", "))
+
+	_, err := db.NewQuery(query).Bind(params).Execute()
+	if err != nil {
+		return nil, err
+	}
+
+	return &updates, nil
+}
+
+// SetFlightPlayers replaces the players assigned to a flight by hand.
+func SetFlightPlayers(db dbx.Builder, tournamentId string, flightId string, playerIds []string) error {
+	_, err := db.
+		NewQuery("DELETE FROM flight_players WHERE flight_id = {:flight_id}").
+		Bind(dbx.Params{
+			"flight_id": flightId,
+		}).
+		Execute()
+	if err != nil {
+		return err
+	}
+
+	for _, playerId := range playerIds {
+		_, err = db.
+			NewQuery(`
+			INSERT INTO flight_players (flight_id, tournament_id, player_id, created, updated)
+			VALUES ({:flight_id}, {:tournament_id}, {:player_id}, {:created}, {:updated})
+		`).
+			Bind(dbx.Params{
+				"flight_id":     flightId,
+				"tournament_id": tournamentId,
+				"player_id":     playerId,
+				"created":       time.Now().Format(time.RFC3339),
+				"updated":       time.Now().Format(time.RFC3339),
+			}).
+			Execute()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ClearFlightPlayer takes a player out of every flight of a kind, so a hand
+// assignment to one handicap flight doesn't leave them in another.
+func ClearFlightPlayer(db dbx.Builder, tournamentId string, kind string, playerId string) error {
+	_, err := db.
+		NewQuery(`
+			DELETE FROM flight_players
+			WHERE player_id = {:player_id}
+			AND flight_id IN (SELECT id FROM flights WHERE tournament_id = {:tournament_id} AND kind = {:kind})
+		`).
+		Bind(dbx.Params{
+			"tournament_id": tournamentId,
+			"kind":          kind,
+			"player_id":     playerId,
+		}).
+		Execute()
+
+	return err
+}
+
+func DeleteFlight(db dbx.Builder, id string) error {
+	for _, table := range []string{"flight_players", "payout_flights"} {
+		_, err := db.
+			NewQuery("DELETE FROM " + table + " WHERE flight_id = {:id}").
+			Bind(dbx.Params{
+				"id": id,
+			}).
+			Execute()
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err := db.
+		NewQuery("DELETE FROM flights WHERE id = {:id}").
+		Bind(dbx.Params{
+			"id": id,
+		}).
+		Execute()
+
+	return err
+}
@@ -0,0 +1,167 @@
+package models
+
+import (
+	"context"
+	"time"
+
+	"github.com/patrick-salvatore/tournament-live-scoring/internal/handicap"
+	"github.com/pocketbase/dbx"
+)
+
+const HANDICAP_DATE_LAYOUT = "2006-01-02"
+
+type HandicapHistory struct {
+	Id            string  `db:"id" json:"id"`
+	PlayerId      string  `db:"player_id" json:"playerId"`
+	HandicapIndex float64 `db:"handicap_index" json:"handicapIndex"`
+	EffectiveDate string  `db:"effective_date" json:"effectiveDate"`
+	Source        string  `db:"source" json:"source"`
+}
+
+func CreateHandicapHistory(db dbx.Builder, playerId string, handicapIndex float64, effectiveDate time.Time, source string) (*HandicapHistory, error) {
+	var history HandicapHistory
+
+	err := db.
+		NewQuery(`
+		INSERT INTO player_handicap_history (player_id, handicap_index, effective_date, source, created, updated)
+		VALUES ({:player_id}, {:handicap_index}, {:effective_date}, {:source}, {:created}, {:updated})
+		RETURNING *
+	`).
+		Bind(dbx.Params{
+			"player_id":      playerId,
+			"handicap_index": handicapIndex,
+			"effective_date": effectiveDate.Format(HANDICAP_DATE_LAYOUT),
+			"source":         source,
+			"created":        time.Now().Format(time.RFC3339),
+			"updated":        time.Now().Format(time.RFC3339),
+		}).
+		One(&history)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &history, nil
+}
+
+func GetHandicapHistory(db dbx.Builder, playerId string) (*[]HandicapHistory, error) {
+	history := []HandicapHistory{}
+
+	err := db.
+		NewQuery(`
+			SELECT * FROM player_handicap_history
+			WHERE player_id = {:player_id}
+			ORDER BY effective_date DESC, created DESC
+		`).
+		Bind(dbx.Params{
+			"player_id": playerId,
+		}).
+		All(&history)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &history, nil
+}
+
+type HandicapRefresh struct {
+	PlayerId      string    `json:"playerId"`
+	ExternalId    string    `json:"externalId"`
+	Name          string    `json:"name"`
+	From          float64   `json:"from"`
+	To            float64   `json:"to"`
+	EffectiveDate time.Time `json:"-"`
+}
+
+// LookupHandicaps asks the provider for the current index of every active
+// player with an external id and returns the ones that changed. It only
+// reads, so the provider call never holds a write transaction open.
+func LookupHandicaps(ctx context.Context, db dbx.Builder, provider handicap.HandicapProvider) ([]HandicapRefresh, error) {
+	players, err := GetAllPlayers(db, false)
+	if err != nil {
+		return nil, err
+	}
+
+	playersByExternalId := make(map[string]Player)
+	externalIds := []string{}
+	for _, player := range *players {
+		if len(player.ExternalId) == 0 {
+			continue
+		}
+		playersByExternalId[player.ExternalId] = player
+		externalIds = append(externalIds, player.ExternalId)
+	}
+
+	refreshes := []HandicapRefresh{}
+	if len(externalIds) == 0 {
+		return refreshes, nil
+	}
+
+	indexes, err := provider.Lookup(ctx, externalIds)
+	if err != nil {
+		return nil, &HandicapProviderError{Err: err}
+	}
+
+	for _, index := range indexes {
+		player, ok := playersByExternalId[index.ExternalId]
+		if !ok || player.Handicap == index.HandicapIndex {
+			continue
+		}
+
+		refreshes = append(refreshes, HandicapRefresh{
+			PlayerId:      player.Id,
+			ExternalId:    player.ExternalId,
+			Name:          player.Name,
+			From:          player.Handicap,
+			To:            index.HandicapIndex,
+			EffectiveDate: index.EffectiveDate,
+		})
+	}
+
+	return refreshes, nil
+}
+
+// ApplyHandicapRefreshes updates each player's index and records the change
+// in their history, skipping players already on the new index.
+func ApplyHandicapRefreshes(db dbx.Builder, refreshes []HandicapRefresh, source string) ([]HandicapRefresh, error) {
+	applied := []HandicapRefresh{}
+	for _, refresh := range refreshes {
+		player, err := GetPlayerById(db, refresh.PlayerId)
+		if err != nil {
+			return nil, err
+		}
+		if player.Handicap == refresh.To {
+			continue
+		}
+		refresh.From = player.Handicap
+
+		_, err = UpdatePlayer(db, player.Id, PlayerUpdate{Handicap: &refresh.To})
+		if err != nil {
+			return nil, err
+		}
+
+		_, err = CreateHandicapHistory(db, player.Id, refresh.To, refresh.EffectiveDate, source)
+		if err != nil {
+			return nil, err
+		}
+
+		applied = append(applied, refresh)
+	}
+
+	return applied, nil
+}
+
+// HandicapProviderError is a failure of the provider itself rather than of
+// the database.
+type HandicapProviderError struct {
+	Err error
+}
+
+func (e *HandicapProviderError) Error() string {
+	return "handicap provider: " + e.Err.Error()
+}
+
+func (e *HandicapProviderError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,74 @@
+package models
+
+import (
+	"github.com/pocketbase/dbx"
+)
+
+// PlayerTournament is one tournament a player was entered in.
+type PlayerTournament struct {
+	TournamentId   string `db:"tournament_id" json:"tournamentId"`
+	TournamentName string `db:"tournament_name" json:"tournamentName"`
+	Status         string `db:"status" json:"status"`
+	Created        string `db:"created" json:"date"`
+	CourseId       string `db:"course_id" json:"courseId"`
+	TeamId         string `db:"team_id" json:"teamId"`
+	Tee            string `db:"tee" json:"tee"`
+}
+
+func GetPlayerTournaments(db dbx.Builder, playerId string) (*[]PlayerTournament, error) {
+	tournaments := []PlayerTournament{}
+
+	err := db.
+		NewQuery(`
+			SELECT
+				tournaments.id AS tournament_id,
+				tournaments.name AS tournament_name,
+				tournaments.status AS status,
+				tournaments.created AS created,
+				tournaments.course_id AS course_id,
+				_team_players.team_id AS team_id,
+				_team_players.tee AS tee
+			FROM _team_players
+			JOIN tournaments ON _team_players.tournament_id = tournaments.id
+			WHERE _team_players.player_id = {:player_id}
+			ORDER BY tournaments.created DESC
+		`).
+		Bind(dbx.Params{
+			"player_id": playerId,
+		}).
+		All(&tournaments)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &tournaments, nil
+}
+
+// GetFinishedTournaments lists final and archived tournaments created in
+// [from, to). Empty bounds are open.
+func GetFinishedTournaments(db dbx.Builder, from string, to string) (*[]Tournament, error) {
+	tournaments := []Tournament{}
+
+	err := db.
+		NewQuery(`
+			SELECT * FROM tournaments
+			WHERE status IN ({:final}, {:archived})
+			AND ({:from} = '' OR created >= {:from})
+			AND ({:to} = '' OR created < {:to})
+			ORDER BY created
+		`).
+		Bind(dbx.Params{
+			"final":    TOURNAMENT_STATUS_FINAL,
+			"archived": TOURNAMENT_STATUS_ARCHIVED,
+			"from":     from,
+			"to":       to,
+		}).
+		All(&tournaments)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &tournaments, nil
+}
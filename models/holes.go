@@ -39,6 +39,10 @@ type HoleWithMetadata struct {
 	TournamentId              string  `db:"tournament_id" json:"tournamentId"`
 	PlayerHandicap            float64 `db:"player_handicap" json:"playerHandicap"`
 	AwardedTournamentHandicap float64 `db:"awarded_handicap" json:"awardedTournamentHandicap"`
+	CourseHandicap            int     `db:"course_handicap" json:"courseHandicap"`
+	PlayingHandicap           int     `db:"playing_handicap" json:"playingHandicap"`
+	Strokes                   int     `db:"strokes" json:"-"`
+	StrokesFrozen             bool    `db:"strokes_frozen" json:"-"`
 }
 
 func GetHoles(db dbx.Builder) (*[]HoleWithMetadata, error) {
@@ -79,7 +83,10 @@ func GetTournamentHoles(db dbx.Builder, tournamentId string, teamIds []string) (
 			players.handicap AS player_handicap, 
 			tournaments.awarded_handicap as awarded_handicap,
 			_team_players.team_id as team_id,
-			_team_players.tee as tee
+			_team_players.tee as tee,
+			_team_players.course_handicap as course_handicap,
+			_team_players.playing_handicap as playing_handicap,
+			_team_players.strokes_frozen as strokes_frozen
 		FROM holes 
 		JOIN players ON holes.player_id = players.id 
 		JOIN tournaments ON holes.tournament_id = tournaments.id 
@@ -102,7 +109,7 @@ func GetTournamentHoles(db dbx.Builder, tournamentId string, teamIds []string) (
 	return &holes, nil
 }
 
-func GetPlayerHoles(db dbx.Builder, playerId string) (*[]HoleWithMetadata, error) {
+func GetPlayerHoles(db dbx.Builder, tournamentId string, playerId string) (*[]HoleWithMetadata, error) {
 	var holes []HoleWithMetadata
 
 	err := db.
@@ -111,6 +118,9 @@ func GetPlayerHoles(db dbx.Builder, playerId string) (*[]HoleWithMetadata, error
 				holes.*,
 				_team_players.team_id AS team_id,
 				_team_players.tee AS tee,
+				_team_players.course_handicap AS course_handicap,
+				_team_players.playing_handicap AS playing_handicap,
+				_team_players.strokes_frozen AS strokes_frozen,
 				players.name AS player_name,
 				players.handicap AS player_handicap,
 				players.id AS player_id,
@@ -118,14 +128,15 @@ func GetPlayerHoles(db dbx.Builder, playerId string) (*[]HoleWithMetadata, error
 			FROM holes
 			JOIN players ON holes.player_id = players.id
 			JOIN _team_players ON _team_players.player_id = players.id
+				AND _team_players.tournament_id = holes.tournament_id
 			JOIN teams ON _team_players.team_id = teams.id
 			JOIN tournaments ON tournaments.id = teams.tournament_id
-			WHERE holes.player_id = {:player_id}
-			GROUP BY holes.player_id, holes.number
+			WHERE holes.player_id = {:player_id} AND holes.tournament_id = {:tournament_id}
 			ORDER BY holes.number
 		`).
 		Bind(dbx.Params{
-			"player_id": playerId,
+			"player_id":     playerId,
+			"tournament_id": tournamentId,
 		}).
 		All(&holes)
 
@@ -145,6 +156,9 @@ func GetTeamHoles(db dbx.Builder, teamId string) (*[]HoleWithMetadata, error) {
 				holes.*,
 				_team_players.team_id AS team_id,
 				_team_players.tee AS tee,
+				_team_players.course_handicap AS course_handicap,
+				_team_players.playing_handicap AS playing_handicap,
+				_team_players.strokes_frozen AS strokes_frozen,
 				players.name AS player_name,
 				players.handicap AS player_handicap,
 				players.id AS player_id,
@@ -152,6 +166,7 @@ func GetTeamHoles(db dbx.Builder, teamId string) (*[]HoleWithMetadata, error) {
 			FROM holes
 			JOIN players ON holes.player_id = players.id
 			JOIN _team_players ON _team_players.player_id = players.id
+				AND _team_players.tournament_id = holes.tournament_id
 			JOIN teams ON _team_players.team_id = teams.id
 			JOIN tournaments ON tournaments.id = teams.tournament_id
 			WHERE _team_players.team_id = {:team_id}
@@ -198,19 +213,46 @@ func GetTeamHoles(db dbx.Builder, teamId string) (*[]HoleWithMetadata, error) {
 // 	return &holes, nil
 // }
 
-func CreateHoleForPlayer(db dbx.Builder, playerId string, tournamentId string, courseHole CourseHoleData) (*Hole, error) {
+// GetHoleTeamId returns the team whose player a hole in the tournament
+// belongs to, or "" when the tournament has no such hole.
+func GetHoleTeamId(db dbx.Builder, tournamentId string, holeId string) (string, error) {
+	var teamIds []string
+
+	err := db.
+		NewQuery(`
+			SELECT _team_players.team_id
+			FROM holes
+			JOIN _team_players ON _team_players.player_id = holes.player_id
+				AND _team_players.tournament_id = holes.tournament_id
+			WHERE holes.id = {:hole_id} AND holes.tournament_id = {:tournament_id}
+		`).
+		Bind(dbx.Params{
+			"hole_id":       holeId,
+			"tournament_id": tournamentId,
+		}).
+		Column(&teamIds)
+
+	if err != nil || len(teamIds) == 0 {
+		return "", err
+	}
+
+	return teamIds[0], nil
+}
+
+func CreateHoleForPlayer(db dbx.Builder, playerId string, tournamentId string, courseHole CourseHoleData, strokes int) (*Hole, error) {
 	var hole Hole
 
 	err := db.
 		NewQuery(`
-		INSERT INTO holes (tournament_id, player_id, number, created, updated)
-		VALUES ({:tournament_id}, {:player_id}, {:number}, {:created}, {:updated})
+		INSERT INTO holes (tournament_id, player_id, number, strokes, created, updated)
+		VALUES ({:tournament_id}, {:player_id}, {:number}, {:strokes}, {:created}, {:updated})
 		RETURNING *
 	`).
 		Bind(dbx.Params{
 			"tournament_id": tournamentId,
 			"player_id":     playerId,
 			"number":        courseHole.Number,
+			"strokes":       strokes,
 			"created":       time.Now().Format(time.RFC3339),
 			"updated":       time.Now().Format(time.RFC3339),
 		}).
@@ -223,10 +265,12 @@ func CreateHoleForPlayer(db dbx.Builder, playerId string, tournamentId string, c
 	return &hole, nil
 }
 
-func CreateAllHolesForPlayer(db dbx.Builder, playerId, tournamentId string, courseData []CourseHoleData) (*[]Hole, error) {
+// CreateAllHolesForPlayer creates a hole row for every course hole, storing the
+// strokes the player receives on it keyed by hole number.
+func CreateAllHolesForPlayer(db dbx.Builder, playerId, tournamentId string, courseData []CourseHoleData, strokes map[int]int) (*[]Hole, error) {
 	var holes []Hole
 	for _, courseHole := range courseData {
-		hole, err := CreateHoleForPlayer(db, playerId, tournamentId, courseHole)
+		hole, err := CreateHoleForPlayer(db, playerId, tournamentId, courseHole, strokes[courseHole.Number])
 		if err != nil {
 			return nil, err
 		}
@@ -297,6 +341,25 @@ func UpdateHoleForPlayer(db dbx.Builder, holeId string, updates HoleUpdate) (*Ho
 	return &updates, nil
 }
 
+func UpdateHoleStrokes(db dbx.Builder, tournamentId string, playerId string, number int, strokes int) error {
+	_, err := db.
+		NewQuery(`
+			UPDATE holes
+			SET strokes = {:strokes}, updated = {:updated}
+			WHERE tournament_id = {:tournament_id} AND player_id = {:player_id} AND number = {:number}
+		`).
+		Bind(dbx.Params{
+			"tournament_id": tournamentId,
+			"player_id":     playerId,
+			"number":        number,
+			"strokes":       strokes,
+			"updated":       time.Now().Format(time.RFC3339),
+		}).
+		Execute()
+
+	return err
+}
+
 func DeleteHolesForTeam(db dbx.Builder, tournamentId string) (bool, error) {
 	_, err := db.
 		NewQuery(`
@@ -314,3 +377,75 @@ func DeleteHolesForTeam(db dbx.Builder, tournamentId string) (bool, error) {
 
 	return true, nil
 }
+
+// UnlockedTeamsHaveScores reports whether any score has been entered by a
+// player, or a team ball, on an unlocked team, the ones a regeneration would
+// wipe.
+func UnlockedTeamsHaveScores(db dbx.Builder, tournamentId string) (bool, error) {
+	var count int
+
+	err := db.
+		NewQuery(`
+			SELECT
+				(SELECT COUNT(*) FROM holes
+				JOIN _team_players ON _team_players.player_id = holes.player_id AND _team_players.tournament_id = holes.tournament_id
+				JOIN teams ON _team_players.team_id = teams.id
+				WHERE holes.tournament_id = {:tournament_id} AND holes.score != '' AND teams.locked = 0)
+				+
+				(SELECT COUNT(*) FROM team_holes
+				JOIN teams ON team_holes.team_id = teams.id
+				WHERE team_holes.tournament_id = {:tournament_id} AND team_holes.score != '' AND teams.locked = 0)
+		`).
+		Bind(dbx.Params{
+			"tournament_id": tournamentId,
+		}).
+		Row(&count)
+
+	if err != nil {
+		return false, err
+	}
+
+	return count > 0, nil
+}
+
+func PlayerHasHoles(db dbx.Builder, tournamentId string, playerId string) (bool, bool, error) {
+	var result struct {
+		Holes  int `db:"holes"`
+		Scores int `db:"scores"`
+	}
+
+	err := db.
+		NewQuery(`
+			SELECT
+				COUNT(*) AS holes,
+				COUNT(CASE WHEN score != '' THEN 1 END) AS scores
+			FROM holes
+			WHERE tournament_id = {:tournament_id} AND player_id = {:player_id}
+		`).
+		Bind(dbx.Params{
+			"tournament_id": tournamentId,
+			"player_id":     playerId,
+		}).
+		One(&result)
+
+	if err != nil {
+		return false, false, err
+	}
+
+	return result.Holes > 0, result.Scores > 0, nil
+}
+
+func DeleteHolesForPlayer(db dbx.Builder, tournamentId string, playerId string) error {
+	_, err := db.
+		NewQuery(`
+			DELETE FROM holes
+			WHERE tournament_id = {:tournament_id} AND player_id = {:player_id}
+		`).
+		Bind(dbx.Params{
+			"tournament_id": tournamentId,
+			"player_id":     playerId,
+		}).
+		Execute()
+
+	return err
+}
@@ -0,0 +1,383 @@
+package models
+
+import (
+	"time"
+
+	"github.com/pocketbase/dbx"
+)
+
+const (
+	LEAGUE_ROUNDS_SINGLE = 1
+	LEAGUE_ROUNDS_DOUBLE = 2
+
+	DEFAULT_LEAGUE_POINTS_WIN   = 2.0
+	DEFAULT_LEAGUE_POINTS_HALVE = 1.0
+)
+
+// League is a season of weekly head-to-head team matches. Each week is
+// played as its own tournament, created from the league's settings.
+type League struct {
+	Id              string  `db:"id" json:"id"`
+	Name            string  `db:"name" json:"name"`
+	CourseId        string  `db:"course_id" json:"courseId"`
+	FormatId        string  `db:"format_id" json:"formatId"`
+	AwardedHandicap float64 `db:"awarded_handicap" json:"awardedHandicap"`
+	TeamScoring     string  `db:"team_scoring" json:"teamScoring"`
+	BestScores      string  `db:"best_scores" json:"bestScores"`
+	Rounds          int     `db:"rounds" json:"rounds"`
+	PointsWin       float64 `db:"points_win" json:"pointsWin"`
+	PointsHalve     float64 `db:"points_halve" json:"pointsHalve"`
+	// HandicapRounds is how many recent league rounds a player's handicap
+	// is worked out from. Zero keeps the players' own handicap indexes.
+	HandicapRounds int    `db:"handicap_rounds" json:"handicapRounds"`
+	Created        string `db:"created" json:"created"`
+}
+
+type LeagueTeam struct {
+	Id       string             `db:"id" json:"id"`
+	LeagueId string             `db:"league_id" json:"leagueId"`
+	Name     string             `db:"name" json:"name"`
+	Players  []LeagueTeamPlayer `db:"-" json:"players"`
+}
+
+// LeagueTeamPlayer carries the player's current handicap index alongside the
+// league handicap they were given most recently, if any week has started.
+type LeagueTeamPlayer struct {
+	TeamId         string   `db:"team_id" json:"-"`
+	PlayerId       string   `db:"player_id" json:"playerId"`
+	Name           string   `db:"name" json:"name"`
+	Tee            string   `db:"tee" json:"tee"`
+	Handicap       float64  `db:"handicap" json:"handicap"`
+	LeagueHandicap *float64 `db:"league_handicap" json:"leagueHandicap"`
+}
+
+type LeagueMatch struct {
+	Id                   string `db:"id" json:"id"`
+	LeagueId             string `db:"league_id" json:"leagueId"`
+	Week                 int    `db:"week" json:"week"`
+	HomeTeamId           string `db:"home_team_id" json:"homeTeamId"`
+	HomeTeamName         string `db:"home_team_name" json:"homeTeamName"`
+	AwayTeamId           string `db:"away_team_id" json:"awayTeamId"`
+	AwayTeamName         string `db:"away_team_name" json:"awayTeamName"`
+	TournamentId         string `db:"tournament_id" json:"tournamentId"`
+	TournamentStatus     string `db:"tournament_status" json:"tournamentStatus"`
+	HomeTournamentTeamId string `db:"home_tournament_team_id" json:"homeTournamentTeamId"`
+	AwayTournamentTeamId string `db:"away_tournament_team_id" json:"awayTournamentTeamId"`
+}
+
+// LeagueHandicap is the handicap index a player carried into a league week.
+type LeagueHandicap struct {
+	PlayerId      string  `db:"player_id" json:"playerId"`
+	Week          int     `db:"week" json:"week"`
+	HandicapIndex float64 `db:"handicap_index" json:"handicapIndex"`
+}
+
+type LeagueTeamPlayerCreate struct {
+	PlayerId string `json:"playerId"`
+	Tee      string `json:"tee"`
+}
+
+type LeagueTeamCreate struct {
+	Name    string                   `json:"name"`
+	Players []LeagueTeamPlayerCreate `json:"players"`
+}
+
+type LeagueCreate struct {
+	Name            string             `json:"name"`
+	CourseId        string             `json:"courseId"`
+	FormatId        string             `json:"formatId,omitempty"`
+	AwardedHandicap float64            `json:"awardedHandicap,omitempty"`
+	TeamScoring     string             `json:"teamScoring,omitempty"`
+	BestScores      string             `json:"bestScores,omitempty"`
+	Rounds          int                `json:"rounds,omitempty"`
+	PointsWin       float64            `json:"pointsWin,omitempty"`
+	PointsHalve     float64            `json:"pointsHalve,omitempty"`
+	HandicapRounds  int                `json:"handicapRounds,omitempty"`
+	Teams           []LeagueTeamCreate `json:"teams"`
+}
+
+// LeagueFixture pairs two of the teams being created, by their index in
+// LeagueCreate.Teams.
+type LeagueFixture struct {
+	Week int
+	Home int
+	Away int
+}
+
+func GetLeagues(db dbx.Builder) (*[]League, error) {
+	leagues := []League{}
+
+	err := db.
+		NewQuery("SELECT * FROM leagues ORDER BY created DESC").
+		All(&leagues)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &leagues, nil
+}
+
+func GetLeagueById(db dbx.Builder, id string) (*League, error) {
+	var league League
+
+	err := db.
+		NewQuery("SELECT * FROM leagues WHERE id = {:id}").
+		Bind(dbx.Params{
+			"id": id,
+		}).
+		One(&league)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &league, nil
+}
+
+// CreateLeague creates a league with its teams and the whole schedule.
+func CreateLeague(db dbx.Builder, data LeagueCreate, fixtures []LeagueFixture) (*League, error) {
+	var league League
+
+	err := db.
+		NewQuery(`
+		INSERT INTO leagues (name, course_id, format_id, awarded_handicap, team_scoring, best_scores, rounds, points_win, points_halve, handicap_rounds, created, updated)
+		VALUES ({:name}, {:course_id}, {:format_id}, {:awarded_handicap}, {:team_scoring}, {:best_scores}, {:rounds}, {:points_win}, {:points_halve}, {:handicap_rounds}, {:created}, {:updated})
+		RETURNING *
+	`).
+		Bind(dbx.Params{
+			"name":             data.Name,
+			"course_id":        data.CourseId,
+			"format_id":        data.FormatId,
+			"awarded_handicap": data.AwardedHandicap,
+			"team_scoring":     data.TeamScoring,
+			"best_scores":      data.BestScores,
+			"rounds":           data.Rounds,
+			"points_win":       data.PointsWin,
+			"points_halve":     data.PointsHalve,
+			"handicap_rounds":  data.HandicapRounds,
+			"created":          time.Now().Format(time.RFC3339),
+			"updated":          time.Now().Format(time.RFC3339),
+		}).
+		One(&league)
+
+	if err != nil {
+		return nil, err
+	}
+
+	teamIds := make([]string, len(data.Teams))
+	for i, team := range data.Teams {
+		var created LeagueTeam
+
+		err = db.
+			NewQuery(`
+			INSERT INTO league_teams (league_id, name, created, updated)
+			VALUES ({:league_id}, {:name}, {:created}, {:updated})
+			RETURNING id, league_id, name
+		`).
+			Bind(dbx.Params{
+				"league_id": league.Id,
+				"name":      team.Name,
+				"created":   time.Now().Format(time.RFC3339),
+				"updated":   time.Now().Format(time.RFC3339),
+			}).
+			One(&created)
+
+		if err != nil {
+			return nil, err
+		}
+		teamIds[i] = created.Id
+
+		for _, player := range team.Players {
+			_, err = db.
+				NewQuery(`
+				INSERT INTO league_team_players (league_id, team_id, player_id, tee, created, updated)
+				VALUES ({:league_id}, {:team_id}, {:player_id}, {:tee}, {:created}, {:updated})
+			`).
+				Bind(dbx.Params{
+					"league_id": league.Id,
+					"team_id":   created.Id,
+					"player_id": player.PlayerId,
+					"tee":       player.Tee,
+					"created":   time.Now().Format(time.RFC3339),
+					"updated":   time.Now().Format(time.RFC3339),
+				}).
+				Execute()
+
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	for _, fixture := range fixtures {
+		_, err = db.
+			NewQuery(`
+			INSERT INTO league_matches (league_id, week, home_team_id, away_team_id, created, updated)
+			VALUES ({:league_id}, {:week}, {:home_team_id}, {:away_team_id}, {:created}, {:updated})
+		`).
+			Bind(dbx.Params{
+				"league_id":    league.Id,
+				"week":         fixture.Week,
+				"home_team_id": teamIds[fixture.Home],
+				"away_team_id": teamIds[fixture.Away],
+				"created":      time.Now().Format(time.RFC3339),
+				"updated":      time.Now().Format(time.RFC3339),
+			}).
+			Execute()
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &league, nil
+}
+
+// GetLeagueTeams loads a league's teams with their players, in the order
+// they were entered.
+func GetLeagueTeams(db dbx.Builder, leagueId string) (*[]LeagueTeam, error) {
+	teams := []LeagueTeam{}
+
+	err := db.
+		NewQuery(`
+			SELECT id, league_id, name FROM league_teams
+			WHERE league_id = {:league_id}
+			ORDER BY rowid
+		`).
+		Bind(dbx.Params{
+			"league_id": leagueId,
+		}).
+		All(&teams)
+
+	if err != nil {
+		return nil, err
+	}
+
+	players := []LeagueTeamPlayer{}
+	err = db.
+		NewQuery(`
+			SELECT
+				league_team_players.team_id AS team_id,
+				league_team_players.player_id AS player_id,
+				league_team_players.tee AS tee,
+				COALESCE(players.name, '') AS name,
+				COALESCE(players.handicap, 0) AS handicap,
+				(
+					SELECT handicap_index FROM league_handicaps
+					WHERE league_handicaps.league_id = league_team_players.league_id
+						AND league_handicaps.player_id = league_team_players.player_id
+					ORDER BY week DESC
+					LIMIT 1
+				) AS league_handicap
+			FROM league_team_players
+			LEFT JOIN players ON players.id = league_team_players.player_id
+			WHERE league_team_players.league_id = {:league_id}
+			ORDER BY league_team_players.rowid
+		`).
+		Bind(dbx.Params{
+			"league_id": leagueId,
+		}).
+		All(&players)
+
+	if err != nil {
+		return nil, err
+	}
+
+	byId := make(map[string]*LeagueTeam)
+	for i := range teams {
+		teams[i].Players = []LeagueTeamPlayer{}
+		byId[teams[i].Id] = &teams[i]
+	}
+	for _, player := range players {
+		if team, ok := byId[player.TeamId]; ok {
+			team.Players = append(team.Players, player)
+		}
+	}
+
+	return &teams, nil
+}
+
+func GetLeagueMatches(db dbx.Builder, leagueId string) (*[]LeagueMatch, error) {
+	matches := []LeagueMatch{}
+
+	err := db.
+		NewQuery(`
+			SELECT
+				league_matches.id AS id,
+				league_matches.league_id AS league_id,
+				league_matches.week AS week,
+				league_matches.home_team_id AS home_team_id,
+				COALESCE(home.name, '') AS home_team_name,
+				league_matches.away_team_id AS away_team_id,
+				COALESCE(away.name, '') AS away_team_name,
+				league_matches.tournament_id AS tournament_id,
+				COALESCE(tournaments.status, '') AS tournament_status,
+				league_matches.home_tournament_team_id AS home_tournament_team_id,
+				league_matches.away_tournament_team_id AS away_tournament_team_id
+			FROM league_matches
+			LEFT JOIN league_teams home ON home.id = league_matches.home_team_id
+			LEFT JOIN league_teams away ON away.id = league_matches.away_team_id
+			LEFT JOIN tournaments ON tournaments.id = league_matches.tournament_id
+			WHERE league_matches.league_id = {:league_id}
+			ORDER BY league_matches.week, league_matches.rowid
+		`).
+		Bind(dbx.Params{
+			"league_id": leagueId,
+		}).
+		All(&matches)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &matches, nil
+}
+
+// SetLeagueMatchTournament links a match to the week's tournament and the
+// tournament teams playing it.
+func SetLeagueMatchTournament(db dbx.Builder, matchId string, tournamentId string, homeTeamId string, awayTeamId string) error {
+	_, err := db.
+		NewQuery(`
+		UPDATE league_matches
+		SET tournament_id = {:tournament_id},
+			home_tournament_team_id = {:home_tournament_team_id},
+			away_tournament_team_id = {:away_tournament_team_id},
+			updated = {:updated}
+		WHERE id = {:id}
+	`).
+		Bind(dbx.Params{
+			"id":                      matchId,
+			"tournament_id":           tournamentId,
+			"home_tournament_team_id": homeTeamId,
+			"away_tournament_team_id": awayTeamId,
+			"updated":                 time.Now().Format(time.RFC3339),
+		}).
+		Execute()
+
+	return err
+}
+
+// SetLeagueHandicap records the handicap index a player carries into a
+// week, replacing one already recorded for it.
+func SetLeagueHandicap(db dbx.Builder, leagueId string, playerId string, week int, handicapIndex float64) error {
+	_, err := db.
+		NewQuery(`
+		INSERT INTO league_handicaps (league_id, player_id, week, handicap_index, created, updated)
+		VALUES ({:league_id}, {:player_id}, {:week}, {:handicap_index}, {:created}, {:updated})
+		ON CONFLICT (league_id, player_id, week) DO UPDATE SET
+			handicap_index = excluded.handicap_index,
+			updated = excluded.updated
+	`).
+		Bind(dbx.Params{
+			"league_id":      leagueId,
+			"player_id":      playerId,
+			"week":           week,
+			"handicap_index": handicapIndex,
+			"created":        time.Now().Format(time.RFC3339),
+			"updated":        time.Now().Format(time.RFC3339),
+		}).
+		Execute()
+
+	return err
+}
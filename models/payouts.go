@@ -0,0 +1,197 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/pocketbase/dbx"
+)
+
+const (
+	PAYOUT_SCORING_GROSS = "gross"
+	PAYOUT_SCORING_NET   = "net"
+)
+
+// DEFAULT_PAYOUT_SPLITS pays the top three, as a percentage of the pot.
+var DEFAULT_PAYOUT_SPLITS = []float64{50, 30, 20}
+
+// PayoutConfig is how a tournament's entry pot is shared out. What the
+// flights don't take is paid on the overall leaderboard.
+type PayoutConfig struct {
+	Id           string  `db:"id" json:"id"`
+	TournamentId string  `db:"tournament_id" json:"tournamentId"`
+	EntryFee     float64 `db:"entry_fee" json:"entryFee"`
+	// Entries overrides the number of players paying the entry fee, 0
+	// counting everyone in the tournament.
+	Entries int `db:"entries" json:"entries"`
+	// Pot overrides the entry fee times the entries when it isn't 0.
+	Pot        float64        `db:"pot" json:"pot"`
+	Scoring    string         `db:"scoring" json:"scoring"`
+	SplitsJson string         `db:"splits" json:"-"`
+	Splits     []float64      `db:"-" json:"splits"`
+	Flights    []PayoutFlight `db:"-" json:"flights"`
+}
+
+// PayoutFlight sets aside share percent of the pot for a flight, paid out by
+// its own splits.
+type PayoutFlight struct {
+	FlightId   string    `db:"flight_id" json:"flightId"`
+	Share      float64   `db:"share" json:"share"`
+	SplitsJson string    `db:"splits" json:"-"`
+	Splits     []float64 `db:"-" json:"splits"`
+}
+
+type PayoutConfigUpdate struct {
+	EntryFee float64        `json:"entryFee"`
+	Entries  int            `json:"entries"`
+	Pot      float64        `json:"pot"`
+	Scoring  string         `json:"scoring,omitempty"`
+	Splits   []float64      `json:"splits,omitempty"`
+	Flights  []PayoutFlight `json:"flights,omitempty"`
+}
+
+func decodePayoutSplits(data string) ([]float64, error) {
+	splits := []float64{}
+	if len(data) == 0 {
+		return splits, nil
+	}
+
+	err := json.Unmarshal([]byte(data), &splits)
+	return splits, err
+}
+
+// GetPayoutConfig returns the tournament's payout settings, or the default
+// split of an empty pot when none have been saved.
+func GetPayoutConfig(db dbx.Builder, tournamentId string) (*PayoutConfig, error) {
+	configs := []PayoutConfig{}
+
+	err := db.
+		NewQuery("SELECT * FROM payouts WHERE tournament_id = {:tournament_id}").
+		Bind(dbx.Params{
+			"tournament_id": tournamentId,
+		}).
+		All(&configs)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(configs) == 0 {
+		return &PayoutConfig{
+			TournamentId: tournamentId,
+			Scoring:      PAYOUT_SCORING_NET,
+			Splits:       DEFAULT_PAYOUT_SPLITS,
+			Flights:      []PayoutFlight{},
+		}, nil
+	}
+
+	config := configs[0]
+	config.Splits, err = decodePayoutSplits(config.SplitsJson)
+	if err != nil {
+		return nil, err
+	}
+
+	config.Flights = []PayoutFlight{}
+	err = db.
+		NewQuery(`
+			SELECT payout_flights.flight_id AS flight_id, payout_flights.share AS share, payout_flights.splits AS splits
+			FROM payout_flights
+			INNER JOIN flights ON flights.id = payout_flights.flight_id
+			WHERE payout_flights.tournament_id = {:tournament_id}
+			ORDER BY flights.kind = {:division}, flights.min_handicap, flights.name
+		`).
+		Bind(dbx.Params{
+			"tournament_id": tournamentId,
+			"division":      FLIGHT_KIND_DIVISION,
+		}).
+		All(&config.Flights)
+
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range config.Flights {
+		config.Flights[i].Splits, err = decodePayoutSplits(config.Flights[i].SplitsJson)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &config, nil
+}
+
+// SetPayoutConfig saves a tournament's payout settings, replacing the
+// per-flight shares.
+func SetPayoutConfig(db dbx.Builder, tournamentId string, data PayoutConfigUpdate) error {
+	splits, err := json.Marshal(data.Splits)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.
+		NewQuery(`
+		INSERT INTO payouts (tournament_id, entry_fee, entries, pot, scoring, splits, created, updated)
+		VALUES ({:tournament_id}, {:entry_fee}, {:entries}, {:pot}, {:scoring}, {:splits}, {:created}, {:updated})
+		ON CONFLICT (tournament_id) DO UPDATE SET
+			entry_fee = excluded.entry_fee,
+			entries = excluded.entries,
+			pot = excluded.pot,
+			scoring = excluded.scoring,
+			splits = excluded.splits,
+			updated = excluded.updated
+	`).
+		Bind(dbx.Params{
+			"tournament_id": tournamentId,
+			"entry_fee":     data.EntryFee,
+			"entries":       data.Entries,
+			"pot":           data.Pot,
+			"scoring":       data.Scoring,
+			"splits":        string(splits),
+			"created":       time.Now().Format(time.RFC3339),
+			"updated":       time.Now().Format(time.RFC3339),
+		}).
+		Execute()
+
+	if err != nil {
+		return err
+	}
+
+	_, err = db.
+		NewQuery("DELETE FROM payout_flights WHERE tournament_id = {:tournament_id}").
+		Bind(dbx.Params{
+			"tournament_id": tournamentId,
+		}).
+		Execute()
+
+	if err != nil {
+		return err
+	}
+
+	for _, flight := range data.Flights {
+		splits, err := json.Marshal(flight.Splits)
+		if err != nil {
+			return err
+		}
+
+		_, err = db.
+			NewQuery(`
+			INSERT INTO payout_flights (tournament_id, flight_id, share, splits, created, updated)
+			VALUES ({:tournament_id}, {:flight_id}, {:share}, {:splits}, {:created}, {:updated})
+		`).
+			Bind(dbx.Params{
+				"tournament_id": tournamentId,
+				"flight_id":     flight.FlightId,
+				"share":         flight.Share,
+				"splits":        string(splits),
+				"created":       time.Now().Format(time.RFC3339),
+				"updated":       time.Now().Format(time.RFC3339),
+			}).
+			Execute()
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
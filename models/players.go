@@ -14,13 +14,27 @@ type Player struct {
 	Handicap float64 `db:"handicap" json:"handicap"`
 	TeamId   string  `db:"team_id" json:"teamId,omitempty"`
 	Tee      string  `db:"tee" json:"tee,omitempty"`
+	Archived bool    `db:"archived" json:"archived"`
+
+	Email        string `db:"email" json:"email,omitempty"`
+	PreferredTee string `db:"preferred_tee" json:"preferredTee,omitempty"`
+	ExternalId   string `db:"external_id" json:"externalId,omitempty"`
+
+	// TournamentHandicap is the index snapshotted when the player was
+	// entered into a tournament, only set on tournament scoped reads.
+	TournamentHandicap float64 `db:"tournament_handicap" json:"tournamentHandicap,omitempty"`
 }
 
-func GetAllPlayers(db dbx.Builder) (*[]Player, error) {
+func GetAllPlayers(db dbx.Builder, includeArchived bool) (*[]Player, error) {
 	players := []Player{}
 
+	query := "SELECT * FROM players WHERE archived = 0"
+	if includeArchived {
+		query = "SELECT * FROM players"
+	}
+
 	err := db.
-		NewQuery("SELECT * FROM players").
+		NewQuery(query).
 		All(&players)
 
 	if err != nil {
@@ -30,6 +44,23 @@ func GetAllPlayers(db dbx.Builder) (*[]Player, error) {
 	return &players, nil
 }
 
+func GetPlayerById(db dbx.Builder, playerId string) (*Player, error) {
+	var player Player
+
+	err := db.
+		NewQuery("SELECT * FROM players WHERE id = {:id}").
+		Bind(dbx.Params{
+			"id": playerId,
+		}).
+		One(&player)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &player, nil
+}
+
 func GetPlayersFromTeamId(db dbx.Builder, teamId string) (*[]Player, error) {
 	players := []Player{}
 
@@ -61,7 +92,8 @@ func GetPlayersByTournament(db dbx.Builder, tournamentId string) (*[]Player, err
 		NewQuery(`
 			SELECT 
 				players.*, 
-				_team_players.tee AS tee 
+				_team_players.tee AS tee,
+				_team_players.handicap_index AS tournament_handicap
 			FROM players
 			JOIN _team_players ON _team_players.player_id = players.id
 			JOIN teams ON _team_players.team_id = teams.id
@@ -80,9 +112,55 @@ func GetPlayersByTournament(db dbx.Builder, tournamentId string) (*[]Player, err
 	return &players, nil
 }
 
+type CreatePlayerData struct {
+	Name         string  `json:"name"`
+	Handicap     float64 `json:"handicap"`
+	Email        string  `json:"email,omitempty"`
+	PreferredTee string  `json:"preferredTee,omitempty"`
+	ExternalId   string  `json:"externalId,omitempty"`
+}
+
+func CreatePlayer(db dbx.Builder, data CreatePlayerData) (*Player, error) {
+	var player Player
+
+	err := db.
+		NewQuery(`
+		INSERT INTO players (name, handicap, email, preferred_tee, external_id, archived, created, updated)
+		VALUES ({:name}, {:handicap}, {:email}, {:preferred_tee}, {:external_id}, {:archived}, {:created}, {:updated})
+		RETURNING *
+	`).
+		Bind(dbx.Params{
+			"name":          data.Name,
+			"handicap":      data.Handicap,
+			"email":         data.Email,
+			"preferred_tee": data.PreferredTee,
+			"external_id":   data.ExternalId,
+			"archived":      false,
+			"created":       time.Now().Format(time.RFC3339),
+			"updated":       time.Now().Format(time.RFC3339),
+		}).
+		One(&player)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &player, nil
+}
+
 type PlayerUpdate struct {
-	Name   *string `db:"name"`
-	TeamId *string `db:"team_id"`
+	Name     *string  `json:"name,omitempty"`
+	Handicap *float64 `json:"handicap,omitempty"`
+	Archived *bool    `json:"archived,omitempty"`
+
+	Email        *string `json:"email,omitempty"`
+	PreferredTee *string `json:"preferredTee,omitempty"`
+	ExternalId   *string `json:"externalId,omitempty"`
+}
+
+// HasUpdates reports whether the update sets any field at all.
+func (u PlayerUpdate) HasUpdates() bool {
+	return u.Name != nil || u.Handicap != nil || u.Archived != nil || u.Email != nil || u.PreferredTee != nil || u.ExternalId != nil
 }
 
 func UpdatePlayer(db dbx.Builder, playerId string, updates PlayerUpdate) (*PlayerUpdate, error) {
@@ -93,9 +171,25 @@ func UpdatePlayer(db dbx.Builder, playerId string, updates PlayerUpdate) (*Playe
 		params["name"] = *updates.Name
 		setParts = append(setParts, "name = {:name}")
 	}
-	if updates.TeamId != nil {
-		params["team_id"] = *updates.TeamId
-		setParts = append(setParts, "team_id = {:team_id}")
+	if updates.Handicap != nil {
+		params["handicap"] = *updates.Handicap
+		setParts = append(setParts, "handicap = {:handicap}")
+	}
+	if updates.Archived != nil {
+		params["archived"] = *updates.Archived
+		setParts = append(setParts, "archived = {:archived}")
+	}
+	if updates.Email != nil {
+		params["email"] = *updates.Email
+		setParts = append(setParts, "email = {:email}")
+	}
+	if updates.PreferredTee != nil {
+		params["preferred_tee"] = *updates.PreferredTee
+		setParts = append(setParts, "preferred_tee = {:preferred_tee}")
+	}
+	if updates.ExternalId != nil {
+		params["external_id"] = *updates.ExternalId
+		setParts = append(setParts, "external_id = {:external_id}")
 	}
 
 	if len(setParts) == 0 {
@@ -119,3 +213,48 @@ func UpdatePlayer(db dbx.Builder, playerId string, updates PlayerUpdate) (*Playe
 
 	return &updates, nil
 }
+
+type RosterEntry struct {
+	TeamId             string  `db:"team_id" json:"teamId"`
+	TeamName           string  `db:"team_name" json:"teamName"`
+	PlayerId           string  `db:"player_id" json:"playerId"`
+	ExternalId         string  `db:"external_id" json:"externalId"`
+	Name               string  `db:"name" json:"name"`
+	Email              string  `db:"email" json:"email"`
+	Handicap           float64 `db:"handicap" json:"handicap"`
+	TournamentHandicap float64 `db:"tournament_handicap" json:"tournamentHandicap"`
+	Tee                string  `db:"tee" json:"tee"`
+}
+
+func GetTournamentRoster(db dbx.Builder, tournamentId string) (*[]RosterEntry, error) {
+	roster := []RosterEntry{}
+
+	err := db.
+		NewQuery(`
+			SELECT
+				teams.id AS team_id,
+				teams.name AS team_name,
+				players.id AS player_id,
+				players.external_id AS external_id,
+				players.name AS name,
+				players.email AS email,
+				players.handicap AS handicap,
+				_team_players.handicap_index AS tournament_handicap,
+				_team_players.tee AS tee
+			FROM _team_players
+			JOIN players ON _team_players.player_id = players.id
+			JOIN teams ON _team_players.team_id = teams.id
+			WHERE teams.tournament_id = {:tournament_id}
+			ORDER BY teams.name, players.name
+		`).
+		Bind(dbx.Params{
+			"tournament_id": tournamentId,
+		}).
+		All(&roster)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &roster, nil
+}
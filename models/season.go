@@ -0,0 +1,273 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pocketbase/dbx"
+)
+
+const (
+	SEASON_SCORING_NET   = "net"
+	SEASON_SCORING_GROSS = "gross"
+)
+
+// DEFAULT_SEASON_POINTS_TABLE awards points for the top 20 finishers, first
+// place first. Anyone finishing below the table earns nothing.
+var DEFAULT_SEASON_POINTS_TABLE = []float64{100, 80, 65, 55, 50, 45, 40, 36, 32, 29, 26, 24, 22, 20, 18, 16, 14, 12, 10, 8}
+
+const DEFAULT_SEASON_MAJOR_MULTIPLIER = 2.0
+
+type Season struct {
+	Id              string    `db:"id" json:"id"`
+	Name            string    `db:"name" json:"name"`
+	PointsTableJson string    `db:"points_table" json:"-"`
+	PointsTable     []float64 `db:"-" json:"pointsTable"`
+	MajorMultiplier float64   `db:"major_multiplier" json:"majorMultiplier"`
+	DropWorst       int       `db:"drop_worst" json:"dropWorst"`
+	Scoring         string    `db:"scoring" json:"scoring"`
+	Created         string    `db:"created" json:"created"`
+}
+
+// SeasonTournament is a tournament counting towards a season.
+type SeasonTournament struct {
+	SeasonId       string `db:"season_id" json:"seasonId"`
+	TournamentId   string `db:"tournament_id" json:"tournamentId"`
+	TournamentName string `db:"tournament_name" json:"tournamentName"`
+	Status         string `db:"status" json:"status"`
+	Major          bool   `db:"major" json:"major"`
+	Created        string `db:"created" json:"date"`
+}
+
+type SeasonCreate struct {
+	Name            string    `json:"name"`
+	PointsTable     []float64 `json:"pointsTable,omitempty"`
+	MajorMultiplier float64   `json:"majorMultiplier,omitempty"`
+	DropWorst       int       `json:"dropWorst,omitempty"`
+	Scoring         string    `json:"scoring,omitempty"`
+}
+
+type SeasonUpdate struct {
+	Name            *string    `json:"name,omitempty"`
+	PointsTable     *[]float64 `json:"pointsTable,omitempty"`
+	MajorMultiplier *float64   `json:"majorMultiplier,omitempty"`
+	DropWorst       *int       `json:"dropWorst,omitempty"`
+	Scoring         *string    `json:"scoring,omitempty"`
+}
+
+func decodeSeasonPointsTable(season *Season) error {
+	season.PointsTable = []float64{}
+	if len(season.PointsTableJson) == 0 {
+		return nil
+	}
+
+	return json.Unmarshal([]byte(season.PointsTableJson), &season.PointsTable)
+}
+
+func GetSeasons(db dbx.Builder) (*[]Season, error) {
+	seasons := []Season{}
+
+	err := db.
+		NewQuery("SELECT * FROM seasons ORDER BY created DESC").
+		All(&seasons)
+
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range seasons {
+		err = decodeSeasonPointsTable(&seasons[i])
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &seasons, nil
+}
+
+func GetSeasonById(db dbx.Builder, id string) (*Season, error) {
+	var season Season
+
+	err := db.
+		NewQuery("SELECT * FROM seasons WHERE id = {:id}").
+		Bind(dbx.Params{
+			"id": id,
+		}).
+		One(&season)
+
+	if err != nil {
+		return nil, err
+	}
+
+	err = decodeSeasonPointsTable(&season)
+	if err != nil {
+		return nil, err
+	}
+
+	return &season, nil
+}
+
+func CreateSeason(db dbx.Builder, data SeasonCreate) (*Season, error) {
+	var season Season
+
+	pointsTable, err := json.Marshal(data.PointsTable)
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.
+		NewQuery(`
+		INSERT INTO seasons (name, points_table, major_multiplier, drop_worst, scoring, created, updated)
+		VALUES ({:name}, {:points_table}, {:major_multiplier}, {:drop_worst}, {:scoring}, {:created}, {:updated})
+		RETURNING *
+	`).
+		Bind(dbx.Params{
+			"name":             data.Name,
+			"points_table":     string(pointsTable),
+			"major_multiplier": data.MajorMultiplier,
+			"drop_worst":       data.DropWorst,
+			"scoring":          data.Scoring,
+			"created":          time.Now().Format(time.RFC3339),
+			"updated":          time.Now().Format(time.RFC3339),
+		}).
+		One(&season)
+
+	if err != nil {
+		return nil, err
+	}
+
+	err = decodeSeasonPointsTable(&season)
+	if err != nil {
+		return nil, err
+	}
+
+	return &season, nil
+}
+
+func UpdateSeason(db dbx.Builder, seasonId string, updates SeasonUpdate) (*SeasonUpdate, error) {
+	var setParts []string
+	params := dbx.Params{"id": seasonId}
+
+	if updates.Name != nil {
+		params["name"] = *updates.Name
+		setParts = append(setParts, "name = {:name}")
+	}
+	if updates.PointsTable != nil {
+		pointsTable, err := json.Marshal(*updates.PointsTable)
+		if err != nil {
+			return nil, err
+		}
+		params["points_table"] = string(pointsTable)
+		setParts = append(setParts, "points_table = {:points_table}")
+	}
+	if updates.MajorMultiplier != nil {
+		params["major_multiplier"] = *updates.MajorMultiplier
+		setParts = append(setParts, "major_multiplier = {:major_multiplier}")
+	}
+	if updates.DropWorst != nil {
+		params["drop_worst"] = *updates.DropWorst
+		setParts = append(setParts, "drop_worst = {:drop_worst}")
+	}
+	if updates.Scoring != nil {
+		params["scoring"] = *updates.Scoring
+		setParts = append(setParts, "scoring = {:scoring}")
+	}
+
+	if len(setParts) == 0 {
+		return nil, fmt.Errorf("no fields to update")
+	}
+
+	setParts = append(setParts, "updated = {:updated}")
+	params["updated"] = time.Now().Format(time.RFC3339)
+
+	query := fmt.Sprintf(`
+		UPDATE seasons
+		SET %s
+		WHERE id = {:id}
+	`, strings.Join(setParts, ", "))
+
+	_, err := db.NewQuery(query).Bind(params).Execute()
+	if err != nil {
+		return nil, err
+	}
+
+	return &updates, nil
+}
+
+// GetSeasonTournaments lists a season's tournaments in the order they were
+// played.
+func GetSeasonTournaments(db dbx.Builder, seasonId string) (*[]SeasonTournament, error) {
+	tournaments := []SeasonTournament{}
+
+	err := db.
+		NewQuery(`
+			SELECT
+				season_tournaments.season_id AS season_id,
+				season_tournaments.tournament_id AS tournament_id,
+				season_tournaments.major AS major,
+				tournaments.name AS tournament_name,
+				tournaments.status AS status,
+				tournaments.created AS created
+			FROM season_tournaments
+			JOIN tournaments ON tournaments.id = season_tournaments.tournament_id
+			WHERE season_tournaments.season_id = {:season_id}
+			ORDER BY tournaments.created
+		`).
+		Bind(dbx.Params{
+			"season_id": seasonId,
+		}).
+		All(&tournaments)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &tournaments, nil
+}
+
+// SetSeasonTournament adds a tournament to a season, or updates whether it
+// is a major when it is already part of it.
+func SetSeasonTournament(db dbx.Builder, seasonId string, tournamentId string, major bool) error {
+	_, err := db.
+		NewQuery(`
+		INSERT INTO season_tournaments (season_id, tournament_id, major, created, updated)
+		VALUES ({:season_id}, {:tournament_id}, {:major}, {:created}, {:updated})
+		ON CONFLICT (season_id, tournament_id) DO UPDATE SET major = excluded.major, updated = excluded.updated
+	`).
+		Bind(dbx.Params{
+			"season_id":     seasonId,
+			"tournament_id": tournamentId,
+			"major":         major,
+			"created":       time.Now().Format(time.RFC3339),
+			"updated":       time.Now().Format(time.RFC3339),
+		}).
+		Execute()
+
+	return err
+}
+
+func RemoveSeasonTournament(db dbx.Builder, seasonId string, tournamentId string) (bool, error) {
+	result, err := db.
+		NewQuery(`
+			DELETE FROM season_tournaments
+			WHERE season_id = {:season_id} AND tournament_id = {:tournament_id}
+		`).
+		Bind(dbx.Params{
+			"season_id":     seasonId,
+			"tournament_id": tournamentId,
+		}).
+		Execute()
+
+	if err != nil {
+		return false, err
+	}
+
+	removed, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return removed > 0, nil
+}
@@ -0,0 +1,181 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pocketbase/dbx"
+)
+
+const (
+	SKINS_SCORING_GROSS = "gross"
+	SKINS_SCORING_NET   = "net"
+)
+
+// SKINS_MIN_SCORES maps the lowest score that may win a skin to the score
+// relative to par it has to match or beat. An empty min score lets any
+// outright low score win.
+var SKINS_MIN_SCORES = map[string]int{
+	"par":    0,
+	"birdie": -1,
+	"eagle":  -2,
+}
+
+type SkinsGame struct {
+	Id           string  `db:"id" json:"id"`
+	TournamentId string  `db:"tournament_id" json:"tournamentId"`
+	Name         string  `db:"name" json:"name"`
+	Scoring      string  `db:"scoring" json:"scoring"`
+	Carryover    bool    `db:"carryover" json:"carryover"`
+	MinScore     string  `db:"min_score" json:"minScore"`
+	Validate     bool    `db:"validate" json:"validate"`
+	Pot          float64 `db:"pot" json:"pot"`
+}
+
+type SkinsGameCreate struct {
+	Name      string  `json:"name"`
+	Scoring   string  `json:"scoring"`
+	Carryover bool    `json:"carryover"`
+	MinScore  string  `json:"minScore,omitempty"`
+	Validate  bool    `json:"validate"`
+	Pot       float64 `json:"pot"`
+}
+
+type SkinsGameUpdate struct {
+	Name      *string  `json:"name,omitempty"`
+	Scoring   *string  `json:"scoring,omitempty"`
+	Carryover *bool    `json:"carryover,omitempty"`
+	MinScore  *string  `json:"minScore,omitempty"`
+	Validate  *bool    `json:"validate,omitempty"`
+	Pot       *float64 `json:"pot,omitempty"`
+}
+
+func GetSkinsGames(db dbx.Builder, tournamentId string) (*[]SkinsGame, error) {
+	games := []SkinsGame{}
+
+	err := db.
+		NewQuery(`
+			SELECT * FROM skins_games
+			WHERE tournament_id = {:tournament_id}
+			ORDER BY created
+		`).
+		Bind(dbx.Params{
+			"tournament_id": tournamentId,
+		}).
+		All(&games)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &games, nil
+}
+
+func GetSkinsGame(db dbx.Builder, tournamentId string, id string) (*SkinsGame, error) {
+	var game SkinsGame
+
+	err := db.
+		NewQuery("SELECT * FROM skins_games WHERE id = {:id} AND tournament_id = {:tournament_id}").
+		Bind(dbx.Params{
+			"id":            id,
+			"tournament_id": tournamentId,
+		}).
+		One(&game)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &game, nil
+}
+
+func CreateSkinsGame(db dbx.Builder, tournamentId string, data SkinsGameCreate) (*SkinsGame, error) {
+	var game SkinsGame
+
+	err := db.
+		NewQuery(`
+		INSERT INTO skins_games (tournament_id, name, scoring, carryover, min_score, validate, pot, created, updated)
+		VALUES ({:tournament_id}, {:name}, {:scoring}, {:carryover}, {:min_score}, {:validate}, {:pot}, {:created}, {:updated})
+		RETURNING *
+	`).
+		Bind(dbx.Params{
+			"tournament_id": tournamentId,
+			"name":          data.Name,
+			"scoring":       data.Scoring,
+			"carryover":     data.Carryover,
+			"min_score":     data.MinScore,
+			"validate":      data.Validate,
+			"pot":           data.Pot,
+			"created":       time.Now().Format(time.RFC3339),
+			"updated":       time.Now().Format(time.RFC3339),
+		}).
+		One(&game)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &game, nil
+}
+
+func UpdateSkinsGame(db dbx.Builder, id string, updates SkinsGameUpdate) (*SkinsGameUpdate, error) {
+	var setParts []string
+	params := dbx.Params{"id": id}
+
+	if updates.Name != nil {
+		params["name"] = *updates.Name
+		setParts = append(setParts, "name = {:name}")
+	}
+	if updates.Scoring != nil {
+		params["scoring"] = *updates.Scoring
+		setParts = append(setParts, "scoring = {:scoring}")
+	}
+	if updates.Carryover != nil {
+		params["carryover"] = *updates.Carryover
+		setParts = append(setParts, "carryover = {:carryover}")
+	}
+	if updates.MinScore != nil {
+		params["min_score"] = *updates.MinScore
+		setParts = append(setParts, "min_score = {:min_score}")
+	}
+	if updates.Validate != nil {
+		params["validate"] = *updates.Validate
+		setParts = append(setParts, "validate = {:validate}")
+	}
+	if updates.Pot != nil {
+		params["pot"] = *updates.Pot
+		setParts = append(setParts, "pot = {:pot}")
+	}
+
+	if len(setParts) == 0 {
+		return nil, fmt.Errorf("no fields to update")
+	}
+
+	setParts = append(setParts, "updated = {:updated}")
+	params["updated"] = time.Now().Format(time.RFC3339)
+
+	query := fmt.Sprintf(`
+		UPDATE skins_games
+		SET %s
+		WHERE id = {:id}
+	`, strings.Join(setParts, ", "))
+
+	_, err := db.NewQuery(query).Bind(params).Execute()
+	if err != nil {
+		return nil, err
+	}
+
+	return &updates, nil
+}
+
+func DeleteSkinsGame(db dbx.Builder, id string) error {
+	_, err := db.
+		NewQuery("DELETE FROM skins_games WHERE id = {:id}").
+		Bind(dbx.Params{
+			"id": id,
+		}).
+		Execute()
+
+	return err
+}
@@ -15,6 +15,25 @@ type Team struct {
 	TournamentId string `db:"tournament_id" json:"tournamentId"`
 	Finished     bool   `db:"finished" json:"finished"`
 	Started      bool   `db:"started" json:"started"`
+	// Locked teams are left alone when the tournament's teams are
+	// regenerated.
+	Locked bool `db:"locked" json:"locked"`
+	// NameLocked is set once a name is chosen by hand, which stops the name
+	// being rebuilt from the roster.
+	NameLocked bool `db:"name_locked" json:"nameLocked"`
+
+	TeamStart
+	// TeeTimeRequest is "early", "late" or empty, honoured by the scheduler.
+	TeeTimeRequest string `db:"tee_time_request" json:"teeTimeRequest"`
+	TeedOffAt      string `db:"teed_off_at" json:"teedOffAt"`
+}
+
+// TeamStart is where and when a team begins its round. Teams sharing a tee
+// group go off together.
+type TeamStart struct {
+	StartTime string `db:"start_time" json:"startTime"`
+	StartHole int    `db:"start_hole" json:"startHole"`
+	TeeGroup  int    `db:"tee_group" json:"teeGroup"`
 }
 
 func GetTeamById(db dbx.Builder, teamId string) (*Team, error) {
@@ -25,7 +44,7 @@ func GetTeamById(db dbx.Builder, teamId string) (*Team, error) {
 				teams.*
 			FROM teams
 			JOIN tournaments ON teams.tournament_id = tournaments.id
-			WHERE teams.id = {:team_id} AND tournaments.complete = 0
+			WHERE teams.id = {:team_id}
 		`).
 		Bind(dbx.Params{
 			"team_id": teamId,
@@ -81,11 +100,17 @@ type TeamWithPlayer struct {
 	Players []Player `json:"players"`
 }
 type TeamUpdate struct {
-	Name     *string `json:"name,omitempty"`
-	Finished *bool   `json:"finished,omitempty"`
-	Started  *bool   `json:"started,omitempty"`
+	Name       *string `json:"name,omitempty"`
+	Finished   *bool   `json:"finished,omitempty"`
+	Started    *bool   `json:"started,omitempty"`
+	Locked     *bool   `json:"locked,omitempty"`
+	NameLocked *bool   `json:"nameLocked,omitempty"`
+
+	TeeTimeRequest *string `json:"teeTimeRequest,omitempty"`
 }
 
+// UpdateTeam treats a new name as chosen by hand unless NameLocked says
+// otherwise.
 func UpdateTeam(db dbx.Builder, teamId string, updates TeamUpdate) (*TeamUpdate, error) {
 	var setParts []string
 	params := dbx.Params{"teamId": teamId}
@@ -93,6 +118,23 @@ func UpdateTeam(db dbx.Builder, teamId string, updates TeamUpdate) (*TeamUpdate,
 	if updates.Name != nil {
 		params["name"] = *updates.Name
 		setParts = append(setParts, "name = {:name}")
+
+		if updates.NameLocked == nil {
+			params["name_locked"] = true
+			setParts = append(setParts, "name_locked = {:name_locked}")
+		}
+	}
+	if updates.NameLocked != nil {
+		params["name_locked"] = *updates.NameLocked
+		setParts = append(setParts, "name_locked = {:name_locked}")
+	}
+	if updates.Locked != nil {
+		params["locked"] = *updates.Locked
+		setParts = append(setParts, "locked = {:locked}")
+	}
+	if updates.TeeTimeRequest != nil {
+		params["tee_time_request"] = *updates.TeeTimeRequest
+		setParts = append(setParts, "tee_time_request = {:tee_time_request}")
 	}
 	if updates.Finished != nil {
 		params["finished"] = *updates.Finished
@@ -126,11 +168,13 @@ func UpdateTeam(db dbx.Builder, teamId string, updates TeamUpdate) (*TeamUpdate,
 }
 
 type TeamCreate struct {
-	Id           string `json:"name"`
+	Id           string `json:"id"`
 	Name         string `db:"name" json:"name"`
 	TournamentId string `db:"tournament_id" json:"tournamentId"`
 	Finished     bool   `db:"finished" json:"finished"`
 	Started      bool   `db:"started" json:"started"`
+	Locked       bool   `db:"locked" json:"locked"`
+	NameLocked   bool   `db:"name_locked" json:"nameLocked"`
 }
 
 type TeamWithPlayerCreate struct {
@@ -139,20 +183,28 @@ type TeamWithPlayerCreate struct {
 }
 
 func CreateTeam(db dbx.Builder, tournamentId string, name string) (*Team, error) {
+	return CreateTeamWithOptions(db, TeamCreate{TournamentId: tournamentId, Name: name})
+}
+
+// CreateTeamWithOptions creates a team from an admin supplied definition,
+// keeping its lock flags.
+func CreateTeamWithOptions(db dbx.Builder, data TeamCreate) (*Team, error) {
 	var team Team
 
 	err := db.
 		NewQuery(`
-		INSERT INTO teams (id, name, tournament_id, started, finished, created, updated)
-		VALUES ({:id}, {:name}, {:tournament_id}, {:started}, {:finished}, {:created}, {:updated})
+		INSERT INTO teams (id, name, tournament_id, started, finished, locked, name_locked, created, updated)
+		VALUES ({:id}, {:name}, {:tournament_id}, {:started}, {:finished}, {:locked}, {:name_locked}, {:created}, {:updated})
 		RETURNING *
 	`).
 		Bind(dbx.Params{
 			"id":            strings.ToLower(security.RandomString(6)),
-			"name":          name,
-			"tournament_id": tournamentId,
+			"name":          data.Name,
+			"tournament_id": data.TournamentId,
 			"started":       false,
 			"finished":      false,
+			"locked":        data.Locked,
+			"name_locked":   data.NameLocked,
 			"created":       time.Now().Format(time.RFC3339),
 			"updated":       time.Now().Format(time.RFC3339),
 		}).
@@ -165,20 +217,23 @@ func CreateTeam(db dbx.Builder, tournamentId string, name string) (*Team, error)
 	return &team, nil
 }
 
-func CreateTeamPlayerLookup(db dbx.Builder, teamId string, playerId string, tee string, tournamentId string) (bool, error) {
+// CreateTeamPlayerLookup adds a player to a team, snapshotting the handicap
+// index they were entered into the tournament with.
+func CreateTeamPlayerLookup(db dbx.Builder, teamId string, playerId string, tee string, tournamentId string, handicapIndex float64) (bool, error) {
 	_, err := db.
 		NewQuery(`
-			INSERT INTO _team_players (team_id, player_id, tee, tournament_id, created, updated)
-			VALUES ({:team_id}, {:player_id}, {:tee}, {:tournament_id}, {:created}, {:updated})
+			INSERT INTO _team_players (team_id, player_id, tee, tournament_id, handicap_index, created, updated)
+			VALUES ({:team_id}, {:player_id}, {:tee}, {:tournament_id}, {:handicap_index}, {:created}, {:updated})
 			RETURNING *
 		`).
 		Bind(dbx.Params{
-			"team_id":       teamId,
-			"player_id":     playerId,
-			"tee":           tee,
-			"tournament_id": tournamentId,
-			"created":       time.Now().Format(time.RFC3339),
-			"updated":       time.Now().Format(time.RFC3339),
+			"team_id":        teamId,
+			"player_id":      playerId,
+			"tee":            tee,
+			"tournament_id":  tournamentId,
+			"handicap_index": handicapIndex,
+			"created":        time.Now().Format(time.RFC3339),
+			"updated":        time.Now().Format(time.RFC3339),
 		}).
 		Execute()
 
@@ -222,3 +277,307 @@ func DeleteTournamentTeams(db dbx.Builder, tournamentId string) (bool, error) {
 
 	return true, nil
 }
+
+type TeamPlayerHandicap struct {
+	HandicapIndex   float64 `db:"handicap_index" json:"handicapIndex"`
+	CourseHandicap  int     `db:"course_handicap" json:"courseHandicap"`
+	PlayingHandicap int     `db:"playing_handicap" json:"playingHandicap"`
+}
+
+type TeamPlayer struct {
+	TeamPlayerHandicap
+	TeamId        string  `db:"team_id" json:"teamId"`
+	PlayerId      string  `db:"player_id" json:"playerId"`
+	PlayerName    string  `db:"player_name" json:"playerName"`
+	Tee           string  `db:"tee" json:"tee"`
+	Handicap      float64 `db:"handicap" json:"handicap"`
+	StrokesFrozen bool    `db:"strokes_frozen" json:"strokesFrozen"`
+}
+
+func GetTeamPlayersByTournament(db dbx.Builder, tournamentId string) (*[]TeamPlayer, error) {
+	teamPlayers := []TeamPlayer{}
+
+	err := db.
+		NewQuery(`
+			SELECT
+				_team_players.team_id AS team_id,
+				_team_players.player_id AS player_id,
+				_team_players.tee AS tee,
+				_team_players.handicap_index AS handicap_index,
+				_team_players.course_handicap AS course_handicap,
+				_team_players.playing_handicap AS playing_handicap,
+				_team_players.strokes_frozen AS strokes_frozen,
+				players.name AS player_name,
+				players.handicap AS handicap
+			FROM _team_players
+			JOIN players ON _team_players.player_id = players.id
+			WHERE _team_players.tournament_id = {:tournament_id}
+		`).
+		Bind(dbx.Params{
+			"tournament_id": tournamentId,
+		}).
+		All(&teamPlayers)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &teamPlayers, nil
+}
+
+// FreezeTeamPlayerHandicap stores the handicaps a player's strokes were
+// allocated from so later index changes don't rewrite the round.
+func FreezeTeamPlayerHandicap(db dbx.Builder, teamId string, playerId string, handicap TeamPlayerHandicap) error {
+	_, err := db.
+		NewQuery(`
+			UPDATE _team_players
+			SET
+				handicap_index = {:handicap_index},
+				course_handicap = {:course_handicap},
+				playing_handicap = {:playing_handicap},
+				strokes_frozen = {:strokes_frozen},
+				updated = {:updated}
+			WHERE team_id = {:team_id} AND player_id = {:player_id}
+		`).
+		Bind(dbx.Params{
+			"team_id":          teamId,
+			"player_id":        playerId,
+			"handicap_index":   handicap.HandicapIndex,
+			"course_handicap":  handicap.CourseHandicap,
+			"playing_handicap": handicap.PlayingHandicap,
+			"strokes_frozen":   true,
+			"updated":          time.Now().Format(time.RFC3339),
+		}).
+		Execute()
+
+	return err
+}
+
+func GetTeamPlayer(db dbx.Builder, tournamentId string, playerId string) (*TeamPlayer, error) {
+	var teamPlayer TeamPlayer
+
+	err := db.
+		NewQuery(`
+			SELECT
+				_team_players.team_id AS team_id,
+				_team_players.player_id AS player_id,
+				_team_players.tee AS tee,
+				_team_players.handicap_index AS handicap_index,
+				_team_players.course_handicap AS course_handicap,
+				_team_players.playing_handicap AS playing_handicap,
+				_team_players.strokes_frozen AS strokes_frozen,
+				players.name AS player_name,
+				players.handicap AS handicap
+			FROM _team_players
+			JOIN players ON _team_players.player_id = players.id
+			WHERE _team_players.tournament_id = {:tournament_id} AND _team_players.player_id = {:player_id}
+		`).
+		Bind(dbx.Params{
+			"tournament_id": tournamentId,
+			"player_id":     playerId,
+		}).
+		One(&teamPlayer)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &teamPlayer, nil
+}
+
+func MoveTeamPlayer(db dbx.Builder, tournamentId string, playerId string, teamId string) error {
+	_, err := db.
+		NewQuery(`
+			UPDATE _team_players
+			SET team_id = {:team_id}, updated = {:updated}
+			WHERE tournament_id = {:tournament_id} AND player_id = {:player_id}
+		`).
+		Bind(dbx.Params{
+			"tournament_id": tournamentId,
+			"player_id":     playerId,
+			"team_id":       teamId,
+			"updated":       time.Now().Format(time.RFC3339),
+		}).
+		Execute()
+
+	return err
+}
+
+func DeleteTeamPlayerLookup(db dbx.Builder, tournamentId string, playerId string) error {
+	_, err := db.
+		NewQuery(`
+			DELETE FROM _team_players
+			WHERE tournament_id = {:tournament_id} AND player_id = {:player_id}
+		`).
+		Bind(dbx.Params{
+			"tournament_id": tournamentId,
+			"player_id":     playerId,
+		}).
+		Execute()
+
+	return err
+}
+
+// RefreshTeamName replaces a generated team name. Names set by hand are
+// left as they are.
+func RefreshTeamName(db dbx.Builder, teamId string, name string) error {
+	_, err := db.
+		NewQuery(`
+			UPDATE teams
+			SET name = {:name}, updated = {:updated}
+			WHERE id = {:team_id} AND name_locked = 0
+		`).
+		Bind(dbx.Params{
+			"team_id": teamId,
+			"name":    name,
+			"updated": time.Now().Format(time.RFC3339),
+		}).
+		Execute()
+
+	return err
+}
+
+func UpdateTeamPlayerTee(db dbx.Builder, tournamentId string, playerId string, tee string) error {
+	_, err := db.
+		NewQuery(`
+			UPDATE _team_players
+			SET tee = {:tee}, updated = {:updated}
+			WHERE tournament_id = {:tournament_id} AND player_id = {:player_id}
+		`).
+		Bind(dbx.Params{
+			"tournament_id": tournamentId,
+			"player_id":     playerId,
+			"tee":           tee,
+			"updated":       time.Now().Format(time.RFC3339),
+		}).
+		Execute()
+
+	return err
+}
+
+// GetLockedTeamPlayerIds lists the players sitting on locked teams, who stay
+// where they are when the rest of the tournament is regenerated.
+func GetLockedTeamPlayerIds(db dbx.Builder, tournamentId string) (map[string]bool, error) {
+	rows := []struct {
+		PlayerId string `db:"player_id"`
+	}{}
+
+	err := db.
+		NewQuery(`
+			SELECT _team_players.player_id AS player_id
+			FROM _team_players
+			JOIN teams ON _team_players.team_id = teams.id
+			WHERE teams.tournament_id = {:tournament_id} AND teams.locked = 1
+		`).
+		Bind(dbx.Params{
+			"tournament_id": tournamentId,
+		}).
+		All(&rows)
+
+	if err != nil {
+		return nil, err
+	}
+
+	playerIds := make(map[string]bool)
+	for _, row := range rows {
+		playerIds[row.PlayerId] = true
+	}
+
+	return playerIds, nil
+}
+
+// DeleteUnlockedTeams removes every team that is not locked along with its
+// roster and the holes of its players.
+func DeleteUnlockedTeams(db dbx.Builder, tournamentId string) error {
+	params := dbx.Params{
+		"tournament_id": tournamentId,
+	}
+
+	_, err := db.
+		NewQuery(`
+			DELETE FROM holes
+			WHERE tournament_id = {:tournament_id} AND player_id IN (
+				SELECT _team_players.player_id FROM _team_players
+				JOIN teams ON _team_players.team_id = teams.id
+				WHERE teams.tournament_id = {:tournament_id} AND teams.locked = 0
+			)
+		`).
+		Bind(params).
+		Execute()
+	if err != nil {
+		return err
+	}
+
+	_, err = db.
+		NewQuery(`
+			DELETE FROM team_holes
+			WHERE team_id IN (
+				SELECT id FROM teams WHERE tournament_id = {:tournament_id} AND locked = 0
+			)
+		`).
+		Bind(params).
+		Execute()
+	if err != nil {
+		return err
+	}
+
+	_, err = db.
+		NewQuery(`
+			DELETE FROM _team_players
+			WHERE team_id IN (
+				SELECT id FROM teams WHERE tournament_id = {:tournament_id} AND locked = 0
+			)
+		`).
+		Bind(params).
+		Execute()
+	if err != nil {
+		return err
+	}
+
+	_, err = db.
+		NewQuery(`
+			DELETE FROM teams
+			WHERE tournament_id = {:tournament_id} AND locked = 0
+		`).
+		Bind(params).
+		Execute()
+
+	return err
+}
+
+func SetTeamStart(db dbx.Builder, teamId string, start TeamStart) error {
+	_, err := db.
+		NewQuery(`
+			UPDATE teams
+			SET start_time = {:start_time}, start_hole = {:start_hole}, tee_group = {:tee_group}, updated = {:updated}
+			WHERE id = {:team_id}
+		`).
+		Bind(dbx.Params{
+			"team_id":    teamId,
+			"start_time": start.StartTime,
+			"start_hole": start.StartHole,
+			"tee_group":  start.TeeGroup,
+			"updated":    time.Now().Format(time.RFC3339),
+		}).
+		Execute()
+
+	return err
+}
+
+// MarkTeamTeedOff records when a team started and flags it as started.
+func MarkTeamTeedOff(db dbx.Builder, teamId string, teedOffAt time.Time) error {
+	_, err := db.
+		NewQuery(`
+			UPDATE teams
+			SET started = 1, teed_off_at = {:teed_off_at}, updated = {:updated}
+			WHERE id = {:team_id}
+		`).
+		Bind(dbx.Params{
+			"team_id":     teamId,
+			"teed_off_at": teedOffAt.Format(time.RFC3339),
+			"updated":     time.Now().Format(time.RFC3339),
+		}).
+		Execute()
+
+	return err
+}
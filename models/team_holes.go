@@ -0,0 +1,133 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pocketbase/dbx"
+)
+
+// TeamHole is a team's own row for a hole in team ball formats: the one
+// score the team posts, the strokes its team handicap receives, and whose
+// drive was used.
+type TeamHole struct {
+	Id            string `db:"id" json:"id"`
+	TournamentId  string `db:"tournament_id" json:"tournamentId"`
+	TeamId        string `db:"team_id" json:"teamId"`
+	Number        int    `db:"number" json:"number"`
+	Score         string `db:"score" json:"score"`
+	Strokes       int    `db:"strokes" json:"strokeHole"`
+	DrivePlayerId string `db:"drive_player_id" json:"drivePlayerId"`
+}
+
+type TeamHoleUpdate struct {
+	Id            *string `json:"id,omitempty"`
+	Score         *string `json:"score,omitempty"`
+	DrivePlayerId *string `json:"drivePlayerId,omitempty"`
+}
+
+func GetTournamentTeamHoles(db dbx.Builder, tournamentId string, teamIds []string) (*[]TeamHole, error) {
+	holes := []TeamHole{}
+
+	if len(teamIds) == 0 {
+		return &holes, nil
+	}
+
+	placeholders := make([]string, len(teamIds))
+	params := dbx.Params{"tournament_id": tournamentId}
+
+	for i, teamId := range teamIds {
+		placeholder := fmt.Sprintf("team_id_%d", i)
+		placeholders[i] = fmt.Sprintf("{:%s}", placeholder)
+		params[placeholder] = teamId
+	}
+
+	query := fmt.Sprintf(`
+		SELECT * FROM team_holes
+		WHERE tournament_id = {:tournament_id}
+		AND team_id IN (%s)
+		ORDER BY team_id, number
+	`, strings.Join(placeholders, ", "))
+
+	err := db.
+		NewQuery(query).
+		Bind(params).
+		All(&holes)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &holes, nil
+}
+
+// CreateTeamHoles gives a team a row for every course hole, storing the
+// strokes its team handicap receives keyed by hole number.
+func CreateTeamHoles(db dbx.Builder, tournamentId string, teamId string, courseData []CourseHoleData, strokes map[int]int) error {
+	for _, courseHole := range courseData {
+		_, err := db.
+			NewQuery(`
+			INSERT INTO team_holes (tournament_id, team_id, number, score, strokes, drive_player_id, created, updated)
+			VALUES ({:tournament_id}, {:team_id}, {:number}, '', {:strokes}, '', {:created}, {:updated})
+		`).
+			Bind(dbx.Params{
+				"tournament_id": tournamentId,
+				"team_id":       teamId,
+				"number":        courseHole.Number,
+				"strokes":       strokes[courseHole.Number],
+				"created":       time.Now().Format(time.RFC3339),
+				"updated":       time.Now().Format(time.RFC3339),
+			}).
+			Execute()
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// UpdateTeamHole sets a team's score or drive on one of its own holes.
+func UpdateTeamHole(db dbx.Builder, teamId string, holeId string, updates TeamHoleUpdate) (*TeamHoleUpdate, error) {
+	var setParts []string
+	params := dbx.Params{"id": holeId, "team_id": teamId}
+
+	if updates.Score != nil {
+		params["score"] = *updates.Score
+		setParts = append(setParts, "score = {:score}")
+	}
+	if updates.DrivePlayerId != nil {
+		params["drive_player_id"] = *updates.DrivePlayerId
+		setParts = append(setParts, "drive_player_id = {:drive_player_id}")
+	}
+
+	if len(setParts) == 0 {
+		return nil, fmt.Errorf("no fields to update")
+	}
+
+	setParts = append(setParts, "updated = {:updated}")
+	params["updated"] = time.Now().Format(time.RFC3339)
+
+	query := fmt.Sprintf(`
+		UPDATE team_holes
+		SET %s
+		WHERE id = {:id} AND team_id = {:team_id}
+	`, strings.Join(setParts, ", "))
+
+	result, err := db.NewQuery(query).Bind(params).Execute()
+	if err != nil {
+		return nil, err
+	}
+
+	updated, err := result.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+	if updated == 0 {
+		return nil, fmt.Errorf("hole %s is not one of the team's", holeId)
+	}
+
+	return &updates, nil
+}
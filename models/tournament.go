@@ -2,6 +2,7 @@ package models
 
 import (
 	"fmt"
+	"strconv"
 	"strings"
 	"time"
 
@@ -16,8 +17,107 @@ type Tournament struct {
 	HoleCount       float64 `db:"hole_count" json:"holeCount"`
 	AwardedHandicap float64 `db:"awarded_handicap" json:"awardedHandicap"`
 	IsComplete      bool    `db:"complete" json:"complete"`
+	Status          string  `db:"status" json:"status"`
 	IsMatchPlay     bool    `db:"is_match_play" json:"isMatchPlay"`
 	FormatId        string  `db:"format_id" json:"formatId"`
+	TeamScoring     string  `db:"team_scoring" json:"teamScoring"`
+	BestScores      string  `db:"best_scores" json:"bestScores"`
+	BallFormat      string  `db:"ball_format" json:"ballFormat"`
+	HandicapWeights string  `db:"handicap_weights" json:"handicapWeights"`
+	MinDrives       int     `db:"min_drives" json:"minDrives"`
+}
+
+const (
+	// TEAM_SCORING_NET counts the best net scores on a hole, and the gross
+	// scores of the same players.
+	TEAM_SCORING_NET = "net"
+	// TEAM_SCORING_GROSS counts the best gross scores on a hole, and the net
+	// scores of the same players.
+	TEAM_SCORING_GROSS = "gross"
+	// TEAM_SCORING_INDEPENDENT picks the best net and best gross scores
+	// separately, so they may come from different players.
+	TEAM_SCORING_INDEPENDENT = "independent"
+)
+
+const (
+	BALL_FORMAT_INDIVIDUAL = "individual"
+	// BALL_FORMAT_SCRAMBLE teams play one ball from the best shot each time
+	// and post one score per hole.
+	BALL_FORMAT_SCRAMBLE = "scramble"
+	// BALL_FORMAT_ALTERNATE_SHOT teams of two take turns hitting one ball.
+	BALL_FORMAT_ALTERNATE_SHOT = "alternate_shot"
+	// BALL_FORMAT_SHAMBLE teams pick the best drive, then everyone plays
+	// their own ball in. Scores are individual, only the drive is shared.
+	BALL_FORMAT_SHAMBLE = "shamble"
+)
+
+// PlaysTeamBall reports whether teams post one score per hole instead of
+// one per player.
+func (t Tournament) PlaysTeamBall() bool {
+	return t.BallFormat == BALL_FORMAT_SCRAMBLE || t.BallFormat == BALL_FORMAT_ALTERNATE_SHOT
+}
+
+// TracksDrives reports whether teams record whose drive they used.
+func (t Tournament) TracksDrives() bool {
+	return t.BallFormat == BALL_FORMAT_SCRAMBLE || t.BallFormat == BALL_FORMAT_SHAMBLE
+}
+
+// DefaultHandicapWeights are the percentages of each player's course
+// handicap, lowest first, that make up a team handicap.
+func DefaultHandicapWeights(ballFormat string, teamSize int) []float64 {
+	if ballFormat == BALL_FORMAT_ALTERNATE_SHOT {
+		return []float64{50, 50}
+	}
+
+	switch teamSize {
+	case 1:
+		return []float64{100}
+	case 2:
+		return []float64{35, 15}
+	case 3:
+		return []float64{20, 15, 10}
+	default:
+		return []float64{25, 20, 15, 10}
+	}
+}
+
+// ParseHandicapWeights reads team handicap percentages written like
+// "35-15". An empty value means the format's defaults.
+func ParseHandicapWeights(value string) ([]float64, error) {
+	if len(value) == 0 {
+		return nil, nil
+	}
+
+	weights := []float64{}
+	for _, part := range strings.Split(value, "-") {
+		weight, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil || weight < 0 || weight > 100 {
+			return nil, fmt.Errorf("handicap weights must be percentages separated by dashes, like 35-15")
+		}
+		weights = append(weights, weight)
+	}
+
+	return weights, nil
+}
+
+// ParseBestScores reads how many scores a team counts on each hole, written
+// as "2" for every hole or "1-2-3" for a pattern repeated around the
+// course. An empty value counts the single best score.
+func ParseBestScores(value string) ([]int, error) {
+	if len(value) == 0 {
+		return []int{1}, nil
+	}
+
+	counts := []int{}
+	for _, part := range strings.Split(value, "-") {
+		count, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil || count < 1 || count > 4 {
+			return nil, fmt.Errorf("best scores must be counts from 1 to 4 separated by dashes, like 1-2-3")
+		}
+		counts = append(counts, count)
+	}
+
+	return counts, nil
 }
 
 type TournamentFormat struct {
@@ -49,7 +149,7 @@ func GetTournamentById(db dbx.Builder, id string) (*Tournament, error) {
 	var tournament Tournament
 
 	err := db.
-		NewQuery("SELECT * FROM tournaments WHERE id = {:id} AND complete != 1").
+		NewQuery("SELECT * FROM tournaments WHERE id = {:id}").
 		Bind(dbx.Params{
 			"id": id,
 		}).
@@ -84,26 +184,37 @@ type CreateTournamentData struct {
 	AwardedHandicap float64  `json:"awardedHandicap,omitempty"`
 	TeamCount       int      `json:"teamCount,omitempty"`
 	IsMatchPlay     bool     `json:"isMatchPlay,omitempty"`
+	TeamScoring     string   `json:"teamScoring,omitempty"`
+	BestScores      string   `json:"bestScores,omitempty"`
+	BallFormat      string   `json:"ballFormat,omitempty"`
+	HandicapWeights string   `json:"handicapWeights,omitempty"`
+	MinDrives       int      `json:"minDrives,omitempty"`
 }
 
-func CreateTournament(db dbx.Builder, courseId string, formatId string, name string, teamCount int, awardedHandicap float64, isMatchPlay bool) (*Tournament, error) {
+func CreateTournament(db dbx.Builder, data CreateTournamentData) (*Tournament, error) {
 	var tournament Tournament
 
 	err := db.
 		NewQuery(`
-		INSERT INTO tournaments (course_id, tournament_format_id, name, team_count, awarded_handicap, hole_count, complete, is_match_play, created, updated)
-		VALUES ({:course_id}, {:tournament_format_id}, {:name}, {:team_count}, {:awarded_handicap}, {:hole_count}, {:complete}, {:is_match_play}, {:created}, {:updated})
+		INSERT INTO tournaments (course_id, tournament_format_id, name, team_count, awarded_handicap, hole_count, complete, status, is_match_play, team_scoring, best_scores, ball_format, handicap_weights, min_drives, created, updated)
+		VALUES ({:course_id}, {:tournament_format_id}, {:name}, {:team_count}, {:awarded_handicap}, {:hole_count}, {:complete}, {:status}, {:is_match_play}, {:team_scoring}, {:best_scores}, {:ball_format}, {:handicap_weights}, {:min_drives}, {:created}, {:updated})
 		RETURNING *
 	`).
 		Bind(dbx.Params{
-			"course_id":            courseId,
-			"tournament_format_id": formatId,
-			"name":                 name,
-			"team_count":           teamCount,
-			"awarded_handicap":     awardedHandicap,
+			"course_id":            data.CourseId,
+			"tournament_format_id": data.FormatId,
+			"name":                 data.Name,
+			"team_count":           data.TeamCount,
+			"awarded_handicap":     data.AwardedHandicap,
 			"hole_count":           18,
 			"complete":             false,
-			"is_match_play":        isMatchPlay,
+			"status":               TOURNAMENT_STATUS_DRAFT,
+			"is_match_play":        data.IsMatchPlay,
+			"team_scoring":         data.TeamScoring,
+			"best_scores":          data.BestScores,
+			"ball_format":          data.BallFormat,
+			"handicap_weights":     data.HandicapWeights,
+			"min_drives":           data.MinDrives,
 			"created":              time.Now().Format(time.RFC3339),
 			"updated":              time.Now().Format(time.RFC3339),
 		}).
@@ -124,6 +235,18 @@ type TournamentUpdate struct {
 	AwardedHandicap *float64  `json:"awardedHandicap,omitempty"`
 	TeamCount       *int      `json:"teamCount,omitempty"`
 	IsMatchPlay     *bool     `json:"isMatchPlay,omitempty"`
+	TeamScoring     *string   `json:"teamScoring,omitempty"`
+	BestScores      *string   `json:"bestScores,omitempty"`
+	BallFormat      *string   `json:"ballFormat,omitempty"`
+	HandicapWeights *string   `json:"handicapWeights,omitempty"`
+	MinDrives       *int      `json:"minDrives,omitempty"`
+}
+
+// HasColumnUpdates reports whether the update touches the tournaments row
+// itself, as opposed to only carrying a new roster.
+func (u TournamentUpdate) HasColumnUpdates() bool {
+	return u.Name != nil || u.CourseId != nil || u.FormatId != nil || u.TeamCount != nil || u.AwardedHandicap != nil || u.IsMatchPlay != nil || u.TeamScoring != nil || u.BestScores != nil ||
+		u.BallFormat != nil || u.HandicapWeights != nil || u.MinDrives != nil
 }
 
 func UpdateTournament(db dbx.Builder, tournamentId string, updates TournamentUpdate) (*TournamentUpdate, error) {
@@ -154,6 +277,26 @@ func UpdateTournament(db dbx.Builder, tournamentId string, updates TournamentUpd
 		params["is_match_play"] = *updates.IsMatchPlay
 		setParts = append(setParts, "is_match_play = {:is_match_play}")
 	}
+	if updates.TeamScoring != nil {
+		params["team_scoring"] = *updates.TeamScoring
+		setParts = append(setParts, "team_scoring = {:team_scoring}")
+	}
+	if updates.BestScores != nil {
+		params["best_scores"] = *updates.BestScores
+		setParts = append(setParts, "best_scores = {:best_scores}")
+	}
+	if updates.BallFormat != nil {
+		params["ball_format"] = *updates.BallFormat
+		setParts = append(setParts, "ball_format = {:ball_format}")
+	}
+	if updates.HandicapWeights != nil {
+		params["handicap_weights"] = *updates.HandicapWeights
+		setParts = append(setParts, "handicap_weights = {:handicap_weights}")
+	}
+	if updates.MinDrives != nil {
+		params["min_drives"] = *updates.MinDrives
+		setParts = append(setParts, "min_drives = {:min_drives}")
+	}
 
 	if len(setParts) == 0 {
 		return nil, fmt.Errorf("no fields to update")
@@ -0,0 +1,150 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/pocketbase/dbx"
+)
+
+const (
+	TOURNAMENT_STATUS_DRAFT        = "draft"
+	TOURNAMENT_STATUS_REGISTRATION = "registration"
+	TOURNAMENT_STATUS_IN_PROGRESS  = "in_progress"
+	TOURNAMENT_STATUS_SUSPENDED    = "suspended"
+	TOURNAMENT_STATUS_FINAL        = "final"
+	TOURNAMENT_STATUS_ARCHIVED     = "archived"
+)
+
+// tournamentTransitions lists the statuses each status may move to. Moving
+// from final back to in_progress reopens a tournament for corrections.
+var tournamentTransitions = map[string][]string{
+	TOURNAMENT_STATUS_DRAFT:        {TOURNAMENT_STATUS_REGISTRATION, TOURNAMENT_STATUS_IN_PROGRESS},
+	TOURNAMENT_STATUS_REGISTRATION: {TOURNAMENT_STATUS_DRAFT, TOURNAMENT_STATUS_IN_PROGRESS},
+	TOURNAMENT_STATUS_IN_PROGRESS:  {TOURNAMENT_STATUS_SUSPENDED, TOURNAMENT_STATUS_FINAL},
+	TOURNAMENT_STATUS_SUSPENDED:    {TOURNAMENT_STATUS_IN_PROGRESS, TOURNAMENT_STATUS_FINAL},
+	TOURNAMENT_STATUS_FINAL:        {TOURNAMENT_STATUS_IN_PROGRESS, TOURNAMENT_STATUS_ARCHIVED},
+	TOURNAMENT_STATUS_ARCHIVED:     {TOURNAMENT_STATUS_FINAL},
+}
+
+// ErrTournamentStatusChanged is returned when a tournament moved on from
+// the status a transition was checked against.
+var ErrTournamentStatusChanged = errors.New("tournament status changed since it was read")
+
+func CanTransitionTournament(from string, to string) bool {
+	for _, status := range tournamentTransitions[from] {
+		if status == to {
+			return true
+		}
+	}
+
+	return false
+}
+
+// IsFrozen reports whether the tournament only accepts reads.
+func (t Tournament) IsFrozen() bool {
+	return t.Status == TOURNAMENT_STATUS_FINAL || t.Status == TOURNAMENT_STATUS_ARCHIVED
+}
+
+// AcceptsScores reports whether teams may tee off and post scores. Teeing
+// off before the tournament is in progress starts it.
+func (t Tournament) AcceptsScores() bool {
+	return t.Status == TOURNAMENT_STATUS_DRAFT || t.Status == TOURNAMENT_STATUS_REGISTRATION || t.Status == TOURNAMENT_STATUS_IN_PROGRESS
+}
+
+type TournamentEvent struct {
+	Id           string `db:"id" json:"id"`
+	TournamentId string `db:"tournament_id" json:"tournamentId"`
+	FromStatus   string `db:"from_status" json:"fromStatus"`
+	ToStatus     string `db:"to_status" json:"toStatus"`
+	Reason       string `db:"reason" json:"reason"`
+	Actor        string `db:"actor" json:"actor"`
+	Created      string `db:"created" json:"created"`
+}
+
+// TransitionTournament moves a tournament to a new status and records the
+// event. complete mirrors whether the tournament is final or archived. The
+// move only happens from the status the tournament was read with, so of two
+// racing transitions the second gets ErrTournamentStatusChanged.
+func TransitionTournament(db dbx.Builder, tournament *Tournament, to string, reason string, actor string) (*TournamentEvent, error) {
+	if !CanTransitionTournament(tournament.Status, to) {
+		return nil, fmt.Errorf("tournament can't move from %s to %s", tournament.Status, to)
+	}
+
+	result, err := db.
+		NewQuery(`
+			UPDATE tournaments
+			SET status = {:status}, complete = {:complete}, updated = {:updated}
+			WHERE id = {:id} AND status = {:from_status}
+		`).
+		Bind(dbx.Params{
+			"id":          tournament.Id,
+			"status":      to,
+			"from_status": tournament.Status,
+			"complete":    to == TOURNAMENT_STATUS_FINAL || to == TOURNAMENT_STATUS_ARCHIVED,
+			"updated":     time.Now().Format(time.RFC3339),
+		}).
+		Execute()
+
+	if err != nil {
+		return nil, err
+	}
+
+	updated, err := result.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+	if updated != 1 {
+		return nil, ErrTournamentStatusChanged
+	}
+
+	var event TournamentEvent
+
+	err = db.
+		NewQuery(`
+		INSERT INTO tournament_events (tournament_id, from_status, to_status, reason, actor, created, updated)
+		VALUES ({:tournament_id}, {:from_status}, {:to_status}, {:reason}, {:actor}, {:created}, {:updated})
+		RETURNING *
+	`).
+		Bind(dbx.Params{
+			"tournament_id": tournament.Id,
+			"from_status":   tournament.Status,
+			"to_status":     to,
+			"reason":        reason,
+			"actor":         actor,
+			"created":       time.Now().Format(time.RFC3339),
+			"updated":       time.Now().Format(time.RFC3339),
+		}).
+		One(&event)
+
+	if err != nil {
+		return nil, err
+	}
+
+	tournament.Status = to
+	tournament.IsComplete = to == TOURNAMENT_STATUS_FINAL || to == TOURNAMENT_STATUS_ARCHIVED
+
+	return &event, nil
+}
+
+func GetTournamentEvents(db dbx.Builder, tournamentId string) (*[]TournamentEvent, error) {
+	events := []TournamentEvent{}
+
+	err := db.
+		NewQuery(`
+			SELECT * FROM tournament_events
+			WHERE tournament_id = {:tournament_id}
+			ORDER BY created
+		`).
+		Bind(dbx.Params{
+			"tournament_id": tournamentId,
+		}).
+		All(&events)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &events, nil
+}
@@ -0,0 +1,210 @@
+package models
+
+import (
+	"time"
+
+	"github.com/pocketbase/dbx"
+)
+
+const (
+	WAGER_KIND_NASSAU = "nassau"
+
+	WAGER_SCORING_GROSS = "gross"
+	WAGER_SCORING_NET   = "net"
+
+	WAGER_SIDE_A = "a"
+	WAGER_SIDE_B = "b"
+)
+
+// Wager is a bet between two sides in a tournament. Each side is a list of
+// players or of teams, all of whose players play for it.
+type Wager struct {
+	Id           string       `db:"id" json:"id"`
+	TournamentId string       `db:"tournament_id" json:"tournamentId"`
+	Name         string       `db:"name" json:"name"`
+	Kind         string       `db:"kind" json:"kind"`
+	Scoring      string       `db:"scoring" json:"scoring"`
+	Stake        float64      `db:"stake" json:"stake"`
+	PressAfter   int          `db:"press_after" json:"pressAfter"`
+	SideA        []WagerEntry `db:"-" json:"sideA"`
+	SideB        []WagerEntry `db:"-" json:"sideB"`
+}
+
+// WagerEntry puts a player or a team on one side of a wager.
+type WagerEntry struct {
+	WagerId  string `db:"wager_id" json:"-"`
+	Side     string `db:"side" json:"-"`
+	PlayerId string `db:"player_id" json:"playerId,omitempty"`
+	TeamId   string `db:"team_id" json:"teamId,omitempty"`
+	Name     string `db:"name" json:"name"`
+}
+
+type WagerSideCreate struct {
+	PlayerIds []string `json:"playerIds,omitempty"`
+	TeamIds   []string `json:"teamIds,omitempty"`
+}
+
+type WagerCreate struct {
+	Name       string          `json:"name,omitempty"`
+	Kind       string          `json:"kind"`
+	Scoring    string          `json:"scoring"`
+	Stake      float64         `json:"stake"`
+	PressAfter int             `json:"pressAfter"`
+	SideA      WagerSideCreate `json:"sideA"`
+	SideB      WagerSideCreate `json:"sideB"`
+}
+
+func GetWagers(db dbx.Builder, tournamentId string) (*[]Wager, error) {
+	wagers := []Wager{}
+
+	err := db.
+		NewQuery(`
+			SELECT * FROM wagers
+			WHERE tournament_id = {:tournament_id}
+			ORDER BY created
+		`).
+		Bind(dbx.Params{
+			"tournament_id": tournamentId,
+		}).
+		All(&wagers)
+
+	if err != nil {
+		return nil, err
+	}
+
+	entries := []WagerEntry{}
+	err = db.
+		NewQuery(`
+			SELECT
+				wager_players.wager_id AS wager_id,
+				wager_players.side AS side,
+				wager_players.player_id AS player_id,
+				wager_players.team_id AS team_id,
+				COALESCE(players.name, teams.name, '') AS name
+			FROM wager_players
+			LEFT JOIN players ON players.id = wager_players.player_id
+			LEFT JOIN teams ON teams.id = wager_players.team_id
+			WHERE wager_players.tournament_id = {:tournament_id}
+			ORDER BY wager_players.rowid
+		`).
+		Bind(dbx.Params{
+			"tournament_id": tournamentId,
+		}).
+		All(&entries)
+
+	if err != nil {
+		return nil, err
+	}
+
+	byWager := make(map[string]*Wager)
+	for i := range wagers {
+		wagers[i].SideA = []WagerEntry{}
+		wagers[i].SideB = []WagerEntry{}
+		byWager[wagers[i].Id] = &wagers[i]
+	}
+	for _, entry := range entries {
+		wager, ok := byWager[entry.WagerId]
+		if !ok {
+			continue
+		}
+		if entry.Side == WAGER_SIDE_A {
+			wager.SideA = append(wager.SideA, entry)
+		} else {
+			wager.SideB = append(wager.SideB, entry)
+		}
+	}
+
+	return &wagers, nil
+}
+
+func CreateWager(db dbx.Builder, tournamentId string, data WagerCreate) (*Wager, error) {
+	var wager Wager
+
+	err := db.
+		NewQuery(`
+		INSERT INTO wagers (tournament_id, name, kind, scoring, stake, press_after, created, updated)
+		VALUES ({:tournament_id}, {:name}, {:kind}, {:scoring}, {:stake}, {:press_after}, {:created}, {:updated})
+		RETURNING *
+	`).
+		Bind(dbx.Params{
+			"tournament_id": tournamentId,
+			"name":          data.Name,
+			"kind":          data.Kind,
+			"scoring":       data.Scoring,
+			"stake":         data.Stake,
+			"press_after":   data.PressAfter,
+			"created":       time.Now().Format(time.RFC3339),
+			"updated":       time.Now().Format(time.RFC3339),
+		}).
+		One(&wager)
+
+	if err != nil {
+		return nil, err
+	}
+
+	for side, sideData := range map[string]WagerSideCreate{WAGER_SIDE_A: data.SideA, WAGER_SIDE_B: data.SideB} {
+		for _, playerId := range sideData.PlayerIds {
+			err = createWagerEntry(db, tournamentId, wager.Id, side, playerId, "")
+			if err != nil {
+				return nil, err
+			}
+		}
+		for _, teamId := range sideData.TeamIds {
+			err = createWagerEntry(db, tournamentId, wager.Id, side, "", teamId)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return &wager, nil
+}
+
+func createWagerEntry(db dbx.Builder, tournamentId string, wagerId string, side string, playerId string, teamId string) error {
+	_, err := db.
+		NewQuery(`
+		INSERT INTO wager_players (wager_id, tournament_id, side, player_id, team_id, created, updated)
+		VALUES ({:wager_id}, {:tournament_id}, {:side}, {:player_id}, {:team_id}, {:created}, {:updated})
+	`).
+		Bind(dbx.Params{
+			"wager_id":      wagerId,
+			"tournament_id": tournamentId,
+			"side":          side,
+			"player_id":     playerId,
+			"team_id":       teamId,
+			"created":       time.Now().Format(time.RFC3339),
+			"updated":       time.Now().Format(time.RFC3339),
+		}).
+		Execute()
+
+	return err
+}
+
+// DeleteWager removes a wager and its sides, reporting whether it existed.
+func DeleteWager(db dbx.Builder, tournamentId string, id string) (bool, error) {
+	result, err := db.
+		NewQuery("DELETE FROM wagers WHERE id = {:id} AND tournament_id = {:tournament_id}").
+		Bind(dbx.Params{
+			"id":            id,
+			"tournament_id": tournamentId,
+		}).
+		Execute()
+
+	if err != nil {
+		return false, err
+	}
+
+	deleted, err := result.RowsAffected()
+	if err != nil || deleted == 0 {
+		return false, err
+	}
+
+	_, err = db.
+		NewQuery("DELETE FROM wager_players WHERE wager_id = {:id}").
+		Bind(dbx.Params{
+			"id": id,
+		}).
+		Execute()
+
+	return err == nil, err
+}